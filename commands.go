@@ -0,0 +1,214 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Subcommands. `spotify-shortcut play "Morning" -device
+// Kitchen` is another way of writing `-playlist Morning -device Kitchen`:
+// each subcommand turns on one of the mode flags and takes the flags that
+// go with it, under shorter names where the original repeats the mode
+// (`devices -type` for `-devices-type`). The flags are shared with the
+// original ones, so everything after parsing works the same, and the
+// original flags still work on their own.
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// command is a subcommand.
+type command struct {
+	name    string
+	summary string
+	// mode is the boolean flag the command turns on; empty for commands
+	// whose argument says what to do.
+	mode string
+	// arg is the flag the command's argument fills, and argName how usage
+	// shows it. argRequired commands fail without one.
+	arg         string
+	argName     string
+	argRequired bool
+	// flags are the command's flags: an original flag's name, or
+	// "name=original" to take it under a shorter name.
+	flags []string
+}
+
+// globalFlags work with every command.
+var globalFlags = []string{"account", "json", "quiet", "verbose", "no-color", "non-interactive", "debug", "fake-device", "no-browser", "no-open", "auth-timeout"}
+
+// playFlags are the flags of a play.
+var playFlags = []string{"device", "liked", "album", "artist", "track", "audiobook", "preset", "shuffle", "start", "duration", "wait", "delay", "newest-first", "least-played"}
+
+// commands are the subcommands, in the order help lists them.
+var commands = []command{
+	{name: "play", summary: "Play a playlist (default SPOTIFY_PLAYLIST_ID), or an album, artist, track, audiobook, or preset", arg: "playlist", argName: "[playlist]", flags: playFlags},
+	{name: "pause", summary: "Pause playback (only on -device, if given)", mode: "pause", flags: []string{"device"}},
+	{name: "stop", summary: "Pause and rewind, optionally moving the session to -transfer", mode: "stop", flags: []string{"transfer=stop-transfer"}},
+	{name: "resume", summary: "Resume the most recent listening at its saved position", mode: "resume-last", flags: []string{"device"}},
+	{name: "status", summary: "Show what's playing", mode: "status"},
+	{name: "seek", summary: "Seek to a position (milliseconds) in the current track", arg: "seek", argName: "<ms>", argRequired: true},
+	{name: "queue", summary: "Add a track or episode to the queue", arg: "queue", argName: "<uri>", argRequired: true},
+	{name: "search", summary: "Search Spotify's catalog", arg: "search", argName: "<query>", argRequired: true, flags: []string{"type=search-type", "play-first"}},
+	{name: "devices", summary: "List Spotify Connect devices", mode: "devices", flags: []string{"type=devices-type", "active=devices-active", "name=devices-name", "sort=devices-sort", "watch", "watch-interval"}},
+	{name: "register-devices", summary: "Add every current device to the device registry", mode: "register-devices"},
+	{name: "playlists", summary: "List your playlists", mode: "playlists"},
+	{name: "tracks", summary: "List a playlist's tracks", arg: "tracks", argName: "<playlist>", argRequired: true, flags: []string{"limit", "offset"}},
+	{name: "create", summary: "Create a playlist and print its ID", arg: "create-playlist", argName: "<name>", argRequired: true, flags: []string{"description=create-description", "public=create-public", "collaborative=create-collaborative"}},
+	{name: "follow", summary: "Add a playlist to your library", arg: "follow", argName: "<playlist>", argRequired: true, flags: []string{"public=follow-public"}},
+	{name: "unfollow", summary: "Remove a playlist from your library", arg: "unfollow", argName: "<playlist>", argRequired: true},
+	{name: "export", summary: "Write a playlist's tracks as JSON or CSV", arg: "export-playlist", argName: "<playlist>", argRequired: true, flags: []string{"format", "output"}},
+	{name: "backup", summary: "Write every playlist to a directory", arg: "backup-playlists", argName: "<dir>", argRequired: true},
+	{name: "restore", summary: "Recreate the playlists backed up in a directory", arg: "restore-playlists", argName: "<dir>", argRequired: true},
+	{name: "schedules", summary: "List the schedules, or turn one on or off", mode: "schedules", flags: []string{"enable=enable-schedule", "disable=disable-schedule"}},
+	{name: "import-ha", summary: "Import rooms and presets from Home Assistant", mode: "import-ha"},
+	{name: "serve", summary: "Run the HTTP API server", mode: "server"},
+	{name: "auth", summary: "Sign in and save the login", mode: "auth", flags: []string{"manual=auth-manual"}},
+	{name: "logout", summary: "Delete the saved login", mode: "logout"},
+	{name: "doctor", summary: "Check the Spotify app configuration with Spotify", mode: "doctor"},
+	{name: "check-config", summary: "Check the environment, settings, and saved login", mode: "check-config"},
+}
+
+// withPrefix matches the "With -devices, " that starts the usage of a flag
+// that only goes with one mode, which a subcommand already implies.
+var withPrefix = regexp.MustCompile(`^With -[a-z-]+( -[a-z-]+)?, `)
+
+// usageError is a command-line mistake parseCommandLine caught itself, as
+// opposed to a bad flag, which the flag set has already reported. usage,
+// if set, prints the help that goes with it.
+type usageError struct {
+	msg   string
+	usage func()
+}
+
+// Error returns the message.
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// parseArgs parses the command line: a subcommand and its flags, or the
+// original flags on their own. Bad usage exits, as flag.Parse does.
+func parseArgs(args []string) {
+	flag.Usage = printUsage
+	if len(args) > 0 && args[0] == "help" {
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				cmd.flagSet(flag.CommandLine).Usage()
+				os.Exit(0)
+			}
+		}
+		printUsage()
+		os.Exit(0)
+	}
+
+	err := parseCommandLine(flag.CommandLine, args)
+	if err == nil {
+		return
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	var uerr *usageError
+	if errors.As(err, &uerr) {
+		fmt.Fprintln(os.Stderr, uerr.msg)
+		if uerr.usage != nil {
+			uerr.usage()
+		}
+	}
+	os.Exit(2)
+}
+
+// parseCommandLine does parseArgs' work on the flags in `global` without
+// exiting: a subcommand's argument and mode are set on `global`, and bad
+// usage comes back as an error.
+func parseCommandLine(global *flag.FlagSet, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return global.Parse(args)
+	}
+
+	name := args[0]
+	cmd := findCommand(name)
+	if cmd == nil {
+		return &usageError{msg: fmt.Sprintf("unknown command %q\n", name), usage: global.Usage}
+	}
+
+	// Flags can come before or after the argument; a name in several
+	// words needn't be quoted.
+	fs := cmd.flagSet(global)
+	var words []string
+	for remaining := args[1:]; ; {
+		if err := fs.Parse(remaining); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		words = append(words, fs.Arg(0))
+		remaining = fs.Args()[1:]
+	}
+	rest := strings.Join(words, " ")
+	switch {
+	case rest != "" && cmd.arg == "":
+		return &usageError{msg: fmt.Sprintf("%s takes no arguments, got %q", cmd.name, rest)}
+	case rest == "" && cmd.argRequired:
+		return &usageError{msg: fmt.Sprintf("%s needs %s", cmd.name, cmd.argName), usage: fs.Usage}
+	}
+	if rest != "" {
+		if err := global.Set(cmd.arg, rest); err != nil {
+			return &usageError{msg: fmt.Sprintf("%s: invalid %s %q: %v", cmd.name, cmd.argName, rest, err)}
+		}
+	}
+	if cmd.mode != "" {
+		global.Set(cmd.mode, "true")
+	}
+	return nil
+}
+
+// findCommand returns the subcommand called `name`, or nil.
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// flagSet returns the command's flags, sharing their values with the
+// original flags in `global` so parsing either fills the same variables.
+// Errors are returned, not exited on, and reported to global's output.
+func (c *command) flagSet(global *flag.FlagSet) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(global.Output())
+	for _, spec := range append(append([]string{}, c.flags...), globalFlags...) {
+		name, original, found := strings.Cut(spec, "=")
+		if !found {
+			original = name
+		}
+		f := global.Lookup(original)
+		usage := withPrefix.ReplaceAllString(f.Usage, "")
+		fs.Var(f.Value, name, strings.ToUpper(usage[:1])+usage[1:])
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n\n%s.\n\nFlags:\n", os.Args[0], strings.TrimSpace(c.name+" "+c.argName), c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// printUsage lists the subcommands, then the original flags.
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s <command> [arguments] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-18s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(out, "\nRun %s help <command> for a command's flags.\n\nThe original flags still work without a command:\n", os.Args[0])
+	flag.PrintDefaults()
+}
@@ -0,0 +1,133 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Tests for subcommand parsing. The real flags are defined
+// in main, so testFlags registers stand-ins of the same types on a fresh
+// flag set for parseCommandLine to fill.
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testFlags returns a flag set with every flag the commands refer to,
+// with output discarded so parse errors don't clutter the test log.
+func testFlags(t *testing.T) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("spotify-shortcut", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	bools := map[string]bool{
+		"devices": true, "watch": true, "playlists": true, "debug": true, "shuffle": true, "liked": true,
+		"server": true, "pause": true, "stop": true, "resume-last": true, "newest-first": true,
+		"least-played": true, "play-first": true, "follow-public": true, "create-public": true,
+		"create-collaborative": true, "register-devices": true, "import-ha": true, "logout": true,
+		"schedules": true, "auth": true, "auth-manual": true, "doctor": true, "status": true, "json": true,
+		"check-config": true, "no-browser": true, "no-open": true, "quiet": true, "verbose": true,
+		"non-interactive": true, "no-color": true,
+	}
+	for _, c := range commands {
+		names := append([]string{c.mode, c.arg}, globalFlags...)
+		for _, spec := range c.flags {
+			_, original, found := strings.Cut(spec, "=")
+			if !found {
+				original = spec
+			}
+			names = append(names, original)
+		}
+		for _, name := range names {
+			if name == "" || fs.Lookup(name) != nil {
+				continue
+			}
+			switch {
+			case bools[name]:
+				fs.Bool(name, false, "test flag")
+			case name == "seek":
+				fs.Int(name, -1, "test flag")
+			default:
+				fs.String(name, "", "test flag")
+			}
+		}
+	}
+	return fs
+}
+
+// TestParseCommandLine sets the mode and argument flags a subcommand
+// implies, takes its flags before or after the argument, and turns bad
+// usage into errors instead of exiting.
+func TestParseCommandLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "original flags", args: []string{"-playlist", "Focus", "-shuffle"}, want: map[string]string{"playlist": "Focus", "shuffle": "true"}},
+		{name: "no arguments", args: nil, want: map[string]string{"playlist": "", "pause": "false"}},
+		{name: "play with words", args: []string{"play", "Morning", "Mix", "-device", "Kitchen"}, want: map[string]string{"playlist": "Morning Mix", "device": "Kitchen"}},
+		{name: "flags before the argument", args: []string{"play", "-shuffle", "Jazz"}, want: map[string]string{"playlist": "Jazz", "shuffle": "true"}},
+		{name: "mode command", args: []string{"pause", "-device", "Office"}, want: map[string]string{"pause": "true", "device": "Office"}},
+		{name: "renamed flag", args: []string{"devices", "-type", "Speaker"}, want: map[string]string{"devices": "true", "devices-type": "Speaker"}},
+		{name: "global flag", args: []string{"status", "-json"}, want: map[string]string{"status": "true", "json": "true"}},
+		{name: "unknown command", args: []string{"dance"}, wantErr: `unknown command "dance"`},
+		{name: "unexpected argument", args: []string{"status", "now"}, wantErr: `status takes no arguments, got "now"`},
+		{name: "missing argument", args: []string{"seek"}, wantErr: "seek needs <ms>"},
+		{name: "invalid argument", args: []string{"seek", "soon"}, wantErr: `seek: invalid <ms> "soon"`},
+		{name: "flag from another command", args: []string{"pause", "-shuffle"}, wantErr: "flag provided but not defined: -shuffle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global := testFlags(t)
+			err := parseCommandLine(global, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := global.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseCommandLine_UsageErrors reports the command's own usage with a
+// missing argument, and the command list with an unknown command.
+func TestParseCommandLine_UsageErrors(t *testing.T) {
+	global := testFlags(t)
+	listed := false
+	global.Usage = func() { listed = true }
+
+	var uerr *usageError
+	if err := parseCommandLine(global, []string{"dance"}); !errors.As(err, &uerr) || uerr.usage == nil {
+		t.Fatalf("expected a usage error with usage, got %v", err)
+	}
+	uerr.usage()
+	if !listed {
+		t.Error("expected the command list for an unknown command")
+	}
+
+	if err := parseCommandLine(global, []string{"tracks"}); !errors.As(err, &uerr) || uerr.usage == nil {
+		t.Fatalf("expected a usage error with usage, got %v", err)
+	}
+	if err := parseCommandLine(global, []string{"status", "now"}); !errors.As(err, &uerr) || uerr.usage != nil {
+		t.Fatalf("expected a usage error without usage, got %v", err)
+	}
+	if err := parseCommandLine(global, []string{"play", "-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected flag.ErrHelp, got %v", err)
+	}
+}
@@ -14,9 +14,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata" // schedule timezones work in containers without zoneinfo
 
 	"github.com/cloudmanic/spotify-shortcut/spotify"
 	"github.com/joho/godotenv"
@@ -29,14 +33,152 @@ import (
 func main() {
 	// Parse command line flags
 	listDevices := flag.Bool("devices", false, "List available Spotify Connect devices and exit")
+	devicesType := flag.String("devices-type", "", "With -devices, only these device types, comma-separated, like Speaker,TV")
+	devicesActive := flag.String("devices-active", "", "With -devices, only the active device (true) or only inactive ones (false)")
+	devicesName := flag.String("devices-name", "", "With -devices, only devices whose name contains this")
+	devicesSort := flag.String("devices-sort", "", "With -devices, order by name, type, active, or volume")
+	watch := flag.Bool("watch", false, "With -devices, keep polling and log devices appearing, disappearing, and switching active state")
+	watchInterval := flag.Duration("watch-interval", spotify.DefaultDeviceWatchInterval, "With -devices -watch, how often to poll")
 	listPlaylists := flag.Bool("playlists", false, "List your Spotify playlists and exit")
 	debug := flag.Bool("debug", false, "Print raw API responses for debugging")
+	fakeDevice := flag.String("fake-device", "", "Add a simulated device with this name that logs commands instead of playing, for development")
 	shuffle := flag.Bool("shuffle", false, "Enable shuffle mode and start at random track")
 	deviceFlag := flag.String("device", "", "Device name or ID to play on")
-	playlistFlag := flag.String("playlist", "", "Playlist ID or URL to play")
+	playlistFlag := flag.String("playlist", "", "Playlist ID or URL to play; a link to an album, artist, track, show, episode, or audiobook plays that")
+	likedFlag := flag.Bool("liked", false, "Play your Liked Songs instead of a playlist")
+	albumFlag := flag.String("album", "", "Album URL, URI, ID, or saved album name to play instead of a playlist")
+	artistFlag := flag.String("artist", "", "Artist name, URL, URI, or ID to play instead of a playlist")
+	trackFlag := flag.String("track", "", "Track URL, URI, ID, or search query to play on its own instead of a playlist")
+	audiobookFlag := flag.String("audiobook", "", "Audiobook or chapter URL, URI, or ID, or saved audiobook name, to resume instead of a playlist")
 	serverMode := flag.Bool("server", false, "Start as HTTP API server")
-	pauseMode := flag.Bool("pause", false, "Pause playback on all devices")
-	flag.Parse()
+	pauseMode := flag.Bool("pause", false, "Pause playback (only on -device, if given)")
+	stopMode := flag.Bool("stop", false, "Stop playback: pause, rewind, and optionally move the session (-stop-transfer)")
+	stopTransfer := flag.String("stop-transfer", "", "With -stop, device name or ID to move the stopped session to")
+	resumeLast := flag.Bool("resume-last", false, "Resume the most recent listening context at its saved position (on -device if given)")
+	startFlag := flag.String("start", "", "Start-position strategy: first, random, least-recent, newest")
+	durationFlag := flag.String("duration", "", "Play about this long of the playlist, like 45m or 1h30m")
+	waitFlag := flag.String("wait", "", "Wait up to this long, like 30s, for -device to appear instead of falling back to another device")
+	delayFlag := flag.String("delay", "", "Play once this long has passed, like 20m, waiting in the foreground (Ctrl-C cancels)")
+	newestFirst := flag.Bool("newest-first", false, "Play the playlist sorted by date added, newest first")
+	leastPlayed := flag.Bool("least-played", false, "Play the playlist sorted by local play history, least played first")
+	presetFlag := flag.String("preset", "", "Play a named preset from the settings file")
+	queueFlag := flag.String("queue", "", "Add a track or episode (URI, URL, or track ID) to the queue and exit")
+	searchFlag := flag.String("search", "", "Search Spotify's catalog and list the results")
+	searchType := flag.String("search-type", "", "Comma-separated types for -search: playlist, album, artist, track, show, episode (default playlist,album,artist,track)")
+	playFirst := flag.Bool("play-first", false, "With -search, play the top result")
+	followFlag := flag.String("follow", "", "Add a playlist (URI, URL, or ID) to your library and exit")
+	unfollowFlag := flag.String("unfollow", "", "Remove a playlist (name, URI, URL, or ID) from your library and exit")
+	followPublic := flag.Bool("follow-public", false, "With -follow, show the playlist on your profile")
+	addTrackFlag := flag.String("add-track", "", "Add tracks (comma-separated URIs, links, or IDs, or \"current\" for the track playing now) to -add-to and exit")
+	addToFlag := flag.String("add-to", "", "With -add-track, the playlist (name, URI, link, or ID) to add to")
+	removeTrackFlag := flag.String("remove-track", "", "Remove tracks (comma-separated URIs, links, IDs, or 1-based positions, or \"current\") from -remove-from and exit")
+	removeFromFlag := flag.String("remove-from", "", "With -remove-track, the playlist (name, URI, link, or ID) to remove from")
+	dryRun := flag.Bool("dry-run", false, "With -remove-track, list what would be removed without removing it")
+	exportFlag := flag.String("export-playlist", "", "Write every track of a playlist (name, URI, link, or ID) as JSON or CSV and exit")
+	exportFormat := flag.String("format", spotify.ExportJSON, "With -export-playlist, the output format: json or csv")
+	exportOutput := flag.String("output", "", "With -export-playlist, the file to write instead of stdout")
+	sortPlaylist := flag.String("sort-playlist", "", "Reorder a playlist (name, URI, link, or ID) by -by and exit")
+	sortBy := flag.String("by", "", "With -sort-playlist, the field to sort by: artist, album, added, title, or duration")
+	mergeFlag := flag.String("merge-playlists", "", "Append the tracks of these playlists (comma-separated names, URIs, links, or IDs) to -into and exit")
+	mergeInto := flag.String("into", "", "With -merge-playlists, the playlist to add to; a new name creates it")
+	mergeDedupe := flag.Bool("dedupe", false, "With -merge-playlists, add each track once, skipping ones the target already has")
+	tracksFlag := flag.String("tracks", "", "List the tracks of a playlist (name, URI, link, or ID) and exit")
+	tracksLimit := flag.Int("limit", 0, "With -tracks, the most tracks to list (default all)")
+	tracksOffset := flag.Int("offset", 0, "With -tracks, how many tracks to skip from the start")
+	backupDir := flag.String("backup-playlists", "", "Write every playlist in the library to this directory, one JSON file each, and exit")
+	restoreDir := flag.String("restore-playlists", "", "Recreate (or re-follow) the playlists backed up in this directory and exit")
+	createFlag := flag.String("create-playlist", "", "Create a playlist with this name, print its ID, and exit")
+	createDescription := flag.String("create-description", "", "With -create-playlist, the playlist's description")
+	createPublic := flag.Bool("create-public", false, "With -create-playlist, show the playlist on your profile")
+	createCollaborative := flag.Bool("create-collaborative", false, "With -create-playlist, let others edit the playlist (can't be public)")
+	seekPosition := flag.Int("seek", -1, "Seek to this position (milliseconds) in the current track and exit")
+	registerDevices := flag.Bool("register-devices", false, "Add every current Spotify Connect device to the device registry and exit")
+	importHA := flag.Bool("import-ha", false, "Import rooms/presets from Home Assistant (HASS_URL, HASS_TOKEN) into the settings file")
+	accountFlag := flag.String("account", "", "Named Spotify account (from SPOTIFY_ACCOUNTS) to use instead of the default")
+	logout := flag.Bool("logout", false, "Delete the stored token (of -account, or the default account) and exit")
+	listSchedules := flag.Bool("schedules", false, "List the scheduled plays and pauses with their next run, and exit")
+	enableSchedule := flag.String("enable-schedule", "", "Turn on a schedule added over the API, by ID, and exit")
+	disableSchedule := flag.String("disable-schedule", "", "Turn off a schedule added over the API, by ID, and exit")
+	authLogin := flag.Bool("auth", false, "Sign in through the browser (or the running server with -no-browser), save the login, and exit")
+	authManual := flag.Bool("auth-manual", false, "Authenticate by pasting the redirect URL or code, without a local callback server, and exit")
+	doctor := flag.Bool("doctor", false, "Check the Spotify app configuration (client ID/secret and redirect URI) with Spotify and exit")
+	statusMode := flag.Bool("status", false, "Print what's playing and exit")
+	jsonFlag := flag.Bool("json", false, "Print -devices, -playlists, -status, and play, pause, stop, and resume results as JSON on stdout")
+	checkConfig := flag.Bool("check-config", false, "Check the environment, settings file, and saved login, and resolve the default playlist and device, then exit")
+	noBrowser := flag.Bool("no-browser", false, "Authenticate through the running API server's /auth page and wait for it to save the token, instead of starting a callback server")
+	noOpen := flag.Bool("no-open", false, "Print the authentication URL without opening it in the default browser")
+	authTimeout := flag.Duration("auth-timeout", spotify.DefaultAuthTimeout, "How long to wait for authentication to finish (0 waits until interrupted)")
+	quiet := flag.Bool("quiet", false, "Print only what was asked for and errors, and exit with a code saying what failed")
+	verbose := flag.Bool("verbose", false, "Also print Spotify API call timings and why a device was chosen, on stderr")
+	nonInteractive := flag.Bool("non-interactive", false, "Never ask to pick a playlist or device; exit with an error instead, as when not run in a terminal")
+	noColor := flag.Bool("no-color", false, "Print tables in plain ASCII without colors or emoji (automatic when stdout isn't a terminal or NO_COLOR is set)")
+	parseArgs(os.Args[1:])
+	jsonOutput = *jsonFlag
+	if *quiet && *verbose {
+		log.Fatal("-quiet and -verbose don't go together")
+	}
+	switch {
+	case *quiet:
+		spotify.SetOutputLevel(spotify.OutputQuiet)
+	case *verbose:
+		spotify.SetOutputLevel(spotify.OutputVerbose)
+	}
+	if jsonOutput {
+		spotify.SetProgressOutput(os.Stderr)
+	}
+	if *nonInteractive || jsonOutput {
+		spotify.SetNonInteractive()
+	}
+	if *noColor {
+		spotify.DisableColor()
+	}
+
+	deviceFilter, err := spotify.ParseDeviceFilter(*devicesType, *devicesActive, *devicesName, *devicesSort)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if (*addTrackFlag == "") != (*addToFlag == "") {
+		log.Fatal("-add-track and -add-to go together")
+	}
+	if (*removeTrackFlag == "") != (*removeFromFlag == "") {
+		log.Fatal("-remove-track and -remove-from go together")
+	}
+	if *dryRun && *removeTrackFlag == "" {
+		log.Fatal("-dry-run only works with -remove-track")
+	}
+	if *exportFormat != spotify.ExportJSON && *exportFormat != spotify.ExportCSV {
+		log.Fatalf("-format must be %s or %s", spotify.ExportJSON, spotify.ExportCSV)
+	}
+	if *exportOutput != "" && *exportFlag == "" {
+		log.Fatal("-output only works with -export-playlist")
+	}
+	if (*sortPlaylist == "") != (*sortBy == "") {
+		log.Fatal("-sort-playlist and -by go together")
+	}
+	if (*tracksLimit != 0 || *tracksOffset != 0) && *tracksFlag == "" {
+		log.Fatal("-limit and -offset only work with -tracks")
+	}
+	if (*mergeFlag == "") != (*mergeInto == "") {
+		log.Fatal("-merge-playlists and -into go together")
+	}
+	if *mergeDedupe && *mergeFlag == "" {
+		log.Fatal("-dedupe only works with -merge-playlists")
+	}
+	var watchEvery time.Duration
+	if *watch {
+		if !*listDevices {
+			log.Fatal("-watch only works with -devices")
+		}
+		if jsonOutput {
+			log.Fatal("-json doesn't work with -watch")
+		}
+		watchEvery = *watchInterval
+	}
+
+	// The simulated device has to exist before the client is set
+	if *fakeDevice != "" {
+		spotify.EnableFakeDevice(*fakeDevice)
+	}
 
 	// Load .env file if it exists (ignore error if not found)
 	_ = godotenv.Load()
@@ -55,8 +197,39 @@ func main() {
 	}
 	spotify.SetTokenFile(tokenFile)
 
+	// Named accounts, each with its own token file
+	if err := spotify.ParseAccounts(os.Getenv("SPOTIFY_ACCOUNTS")); err != nil {
+		log.Fatalf("Invalid SPOTIFY_ACCOUNTS: %v", err)
+	}
+
+	settingsFile := os.Getenv("SPOTIFY_SETTINGS_FILE")
+	if settingsFile == "" {
+		settingsFile = spotify.DefaultSettingsFile
+	}
+	spotify.SetSettingsFile(settingsFile)
+	// -check-config reports a bad settings file itself.
+	if err := spotify.LoadSettings(); err != nil && !*checkConfig {
+		log.Fatalf("Failed to load settings: %v", err)
+	}
+
+	given := 0
+	for _, v := range []string{*playlistFlag, *albumFlag, *artistFlag, *trackFlag, *audiobookFlag} {
+		if v != "" {
+			given++
+		}
+	}
+	if *likedFlag {
+		given++
+	}
+	if given > 1 {
+		log.Fatal("only one of -playlist, -liked, -album, -artist, -track, or -audiobook can be given")
+	}
+
 	// Playlist ID from flag takes priority over env var
 	playlistID := *playlistFlag
+	if *likedFlag {
+		playlistID = spotify.LikedPlaylist
+	}
 	if playlistID == "" {
 		playlistID = os.Getenv("SPOTIFY_PLAYLIST_ID")
 	}
@@ -67,13 +240,32 @@ func main() {
 		deviceName = os.Getenv("SPOTIFY_DEVICE_NAME")
 	}
 
-	if clientID == "" || clientSecret == "" {
+	if (clientID == "" || clientSecret == "") && !*checkConfig && !*doctor {
 		log.Fatal("SPOTIFY_CLIENT_ID and SPOTIFY_CLIENT_SECRET environment variables are required")
 	}
 
-	// Only require playlist ID if not listing devices, playlists, pausing, or running in server mode
-	if playlistID == "" && !*listDevices && !*listPlaylists && !*serverMode && !*pauseMode {
-		log.Fatal("SPOTIFY_PLAYLIST_ID is required. Use -playlist flag or set in .env")
+	// Only a play needs a playlist; every other command has its own mode
+	// flag. In a terminal the play asks for one instead.
+	otherMode := *listDevices || *listPlaylists || *serverMode || *pauseMode || *stopMode || *resumeLast || *doctor || *importHA || *registerDevices ||
+		*seekPosition >= 0 || *presetFlag != "" || *queueFlag != "" || *searchFlag != "" || *followFlag != "" || *unfollowFlag != "" ||
+		*createFlag != "" || *addTrackFlag != "" || *removeTrackFlag != "" || *exportFlag != "" || *tracksFlag != "" || *backupDir != "" || *restoreDir != "" || *sortPlaylist != "" || *mergeFlag != "" ||
+		*listSchedules || *enableSchedule != "" || *disableSchedule != "" || *checkConfig || *authLogin || *authManual || *logout || *statusMode
+	if !otherMode && playlistID == "" && *albumFlag == "" && *artistFlag == "" && *trackFlag == "" && *audiobookFlag == "" && !spotify.Interactive() {
+		log.Fatal("SPOTIFY_PLAYLIST_ID is required. Use -playlist (or -liked, -album, -artist, -track, or -audiobook) flag or set in .env")
+	}
+
+	// -delay only holds back a play
+	var delay time.Duration
+	if *delayFlag != "" {
+		if playlistID == "" && *albumFlag == "" && *artistFlag == "" && *trackFlag == "" && *audiobookFlag == "" && *presetFlag == "" {
+			log.Fatal("-delay only works when playing a playlist, album, artist, track, audiobook, or preset")
+		}
+		if *listDevices || *listPlaylists || *serverMode {
+			log.Fatal("-delay only works when playing")
+		}
+		if delay, err = spotify.ParseDelay(*delayFlag); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	// Get API access token for server mode
@@ -82,18 +274,393 @@ func main() {
 		log.Fatal("API_ACCESS_TOKEN environment variable is required for server mode")
 	}
 	spotify.SetAPIAccessToken(apiAccessToken)
+	spotify.SetLegacyRoutesEnabled(os.Getenv("DISABLE_LEGACY_ROUTES") != "true")
+	spotify.SetHandoffDevice(os.Getenv("HANDOFF_DEVICE"))
+	spotify.SetDeviceFallback(os.Getenv("SPOTIFY_DEVICE_FALLBACK"))
+	spotify.SetStrictMetadata(os.Getenv("STRICT_METADATA") != "false")
+
+	// Browser clients on other origins need CORS
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		maxAge, _ := strconv.Atoi(os.Getenv("CORS_MAX_AGE"))
+		spotify.SetCORSConfig(spotify.NewCORSConfig(origins, os.Getenv("CORS_ALLOWED_METHODS"), os.Getenv("CORS_ALLOWED_HEADERS"), maxAge))
+	}
+
+	// Cache lifetimes for read-only endpoints
+	for env, class := range map[string]string{
+		"CACHE_TTL_PLAYLISTS": spotify.CachePlaylists,
+		"CACHE_TTL_DEVICES":   spotify.CacheDevices,
+		"CACHE_TTL_SPEC":      spotify.CacheSpec,
+	} {
+		if v := os.Getenv(env); v != "" {
+			d, err := time.ParseDuration(v)
+			if err == nil {
+				err = spotify.SetCacheTTL(class, d)
+			}
+			if err != nil {
+				log.Fatalf("Invalid %s %q: must be a duration like 60s (0 to always revalidate)", env, v)
+			}
+		}
+	}
+	spotify.SetCacheShared(os.Getenv("CACHE_SHARED") == "true")
+
+	// HTTP server timeouts, limits, TLS, and HTTP/2
+	serverCfg := spotify.DefaultServerConfig()
+	for env, dst := range map[string]*time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT": &serverCfg.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":        &serverCfg.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":       &serverCfg.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        &serverCfg.IdleTimeout,
+	} {
+		if v := os.Getenv(env); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				log.Fatalf("Invalid %s %q: must be a duration like 30s (0 for no limit)", env, v)
+			}
+			*dst = d
+		}
+	}
+	if v := os.Getenv("HTTP_MAX_HEADER_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid HTTP_MAX_HEADER_BYTES %q: must be a byte count", v)
+		}
+		serverCfg.MaxHeaderBytes = n
+	}
+	serverCfg.KeepAlives = os.Getenv("HTTP_KEEPALIVE") != "false"
+	serverCfg.HTTP2 = os.Getenv("HTTP2") != "false"
+	serverCfg.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	serverCfg.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+	if err := spotify.SetServerConfig(serverCfg); err != nil {
+		log.Fatalf("Invalid HTTP server settings: %v", err)
+	}
+
+	// Logging, retries, and circuit breaking for Spotify API calls
+	if v := os.Getenv("SPOTIFY_CALL_LOG"); v != "" {
+		if err := spotify.SetCallLogging(v); err != nil {
+			log.Fatalf("Invalid SPOTIFY_CALL_LOG: %v", err)
+		}
+	}
+	if v := os.Getenv("SPOTIFY_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err == nil {
+			err = spotify.SetCallRetries(n)
+		}
+		if err != nil {
+			log.Fatalf("Invalid SPOTIFY_RETRIES %q: must be a non-negative number", v)
+		}
+	}
+	threshold, cooldown := spotify.DefaultBreakerThreshold, spotify.DefaultBreakerCooldown
+	if v := os.Getenv("SPOTIFY_BREAKER_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid SPOTIFY_BREAKER_THRESHOLD %q: must be a non-negative number (0 disables)", v)
+		}
+		threshold = n
+	}
+	if v := os.Getenv("SPOTIFY_BREAKER_COOLDOWN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid SPOTIFY_BREAKER_COOLDOWN %q: must be a duration like 30s", v)
+		}
+		cooldown = d
+	}
+	spotify.SetCircuitBreaker(threshold, cooldown)
+
+	// How often playback state is polled while something plays
+	if secs, err := strconv.Atoi(os.Getenv("PLAYBACK_POLL_INTERVAL")); err == nil && secs > 0 {
+		spotify.SetPlaybackPollInterval(time.Duration(secs) * time.Second)
+	}
+
+	// The rest of the polling schedule and the Spotify call budget
+	policy := spotify.DefaultPollPolicy()
+	if v := os.Getenv("PLAYBACK_POLL_STRATEGY"); v != "" {
+		policy.Strategy = v
+	}
+	for name, d := range map[string]*time.Duration{
+		"PLAYBACK_POLL_IDLE_INTERVAL": &policy.Idle,
+		"PLAYBACK_POLL_MIN":           &policy.Min,
+		"PLAYBACK_POLL_MAX":           &policy.Max,
+		"PLAYBACK_POLL_COMMAND_DELAY": &policy.CommandDelay,
+	} {
+		if v := os.Getenv(name); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil || parsed < 0 {
+				log.Fatalf("Invalid %s %q: must be a duration like 15s", name, v)
+			}
+			*d = parsed
+		}
+	}
+	if v := os.Getenv("SPOTIFY_CALL_BUDGET"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid SPOTIFY_CALL_BUDGET %q: must be a number of calls per minute", v)
+		}
+		policy.Budget = n
+	}
+	if err := spotify.SetPollPolicy(policy); err != nil {
+		log.Fatalf("Invalid polling settings: %v", err)
+	}
+
+	// Open the local play history used by least-played ordering
+	historyFile := os.Getenv("SPOTIFY_HISTORY_FILE")
+	if historyFile == "" {
+		historyFile = spotify.DefaultHistoryFile
+	}
+	halfLife := spotify.DefaultHistoryHalfLife
+	if v := os.Getenv("HISTORY_HALF_LIFE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid HISTORY_HALF_LIFE %q: must be a positive duration like 720h", v)
+		}
+		halfLife = d
+	}
+	if h, err := spotify.OpenHistory(historyFile, halfLife); err != nil {
+		spotify.Warnf("play history disabled: %v", err)
+	} else {
+		spotify.SetHistory(h)
+		spotify.OpenAccountHistories(historyFile, halfLife)
+	}
+
+	// Open the device registry that keeps presets working across device ID changes
+	registryFile := os.Getenv("SPOTIFY_DEVICE_REGISTRY_FILE")
+	if registryFile == "" {
+		registryFile = spotify.DefaultDeviceRegistryFile
+	}
+	if reg, err := spotify.OpenDeviceRegistry(registryFile); err != nil {
+		spotify.Warnf("device registry disabled: %v", err)
+	} else {
+		spotify.SetDeviceRegistry(reg)
+	}
+
+	// Open the log of plays that fell back to another device
+	fallbackFile := os.Getenv("SPOTIFY_FALLBACK_LOG_FILE")
+	if fallbackFile == "" {
+		fallbackFile = spotify.DefaultFallbackLogFile
+	}
+	if l, err := spotify.OpenFallbackLog(fallbackFile); err != nil {
+		spotify.Warnf("fallback log disabled: %v", err)
+	} else {
+		spotify.SetFallbackLog(l)
+	}
 
 	// Initialize the authenticator
 	spotify.InitAuth(clientID, clientSecret, redirectURI)
 
+	// Run the whole path a play takes, from the environment to the
+	// default playlist, exiting non-zero if any of it fails
+	if *doctor {
+		checks := spotify.Doctor(context.Background(), spotify.DoctorConfig{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURI:  redirectURI,
+			APIToken:     apiAccessToken,
+			Playlist:     os.Getenv("SPOTIFY_PLAYLIST_ID"),
+			Device:       os.Getenv("SPOTIFY_DEVICE_NAME"),
+			Port:         os.Getenv("PORT"),
+		})
+		spotify.PrintPreflightChecks(checks)
+		if spotify.PreflightFailed(checks) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check everything a deployment needs, exiting non-zero if anything
+	// is wrong
+	if *checkConfig {
+		checks := spotify.CheckConfig(context.Background(), spotify.ConfigToCheck{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURI:  redirectURI,
+			Playlist:     os.Getenv("SPOTIFY_PLAYLIST_ID"),
+			Device:       os.Getenv("SPOTIFY_DEVICE_NAME"),
+		})
+		spotify.PrintPreflightChecks(checks)
+		if spotify.PreflightFailed(checks) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// If --server flag is set, start HTTP API server
 	if *serverMode {
+		// Warn rather than refuse to start: a Spotify outage would
+		// otherwise keep the server down
+		for _, c := range spotify.ValidateAppConfig(context.Background(), clientID, clientSecret, redirectURI) {
+			if c.Status != spotify.CheckOK {
+				spotify.Warnf("%s check %s: %s", c.Name, c.Status, c.Detail)
+			}
+		}
 		runServerMode()
 		return
 	}
 
+	// Schedules are local files, so listing and toggling them needs no
+	// login. A running server picks up the change within a minute.
+	if *listSchedules || *enableSchedule != "" || *disableSchedule != "" {
+		if *enableSchedule != "" && *disableSchedule != "" {
+			log.Fatal("-enable-schedule and -disable-schedule can't be used together")
+		}
+		sched := openScheduler()
+		id, enable, verb := *enableSchedule, true, "Enabled"
+		if *disableSchedule != "" {
+			id, enable, verb = *disableSchedule, false, "Disabled"
+		}
+		if id != "" {
+			if _, err := sched.SetEnabled(id, enable); err != nil {
+				log.Fatal(err)
+			}
+			printResult(fmt.Sprintf("%s schedule %s", verb, id))
+		}
+		if *listSchedules {
+			spotify.PrintSchedulesTable(sched.List())
+		}
+		return
+	}
+
+	// Forget the login of -account (or the default account), e.g. before
+	// handing the machine on. Presets and device mappings don't pick the
+	// account here.
+	if *logout {
+		name := strings.ToLower(*accountFlag)
+		if name == "" {
+			name = spotify.DefaultAccount
+		}
+		msg, err := spotify.Logout(spotify.WithAccount(context.Background(), name))
+		if err != nil {
+			log.Fatalf("Logout failed: %v", err)
+		}
+		printResult(msg)
+		return
+	}
+
+	// In CLI mode the chosen account simply takes over the token file and
+	// play history: a preset's account applies when -account isn't given,
+	// then the account device_accounts assigns to the target device.
+	account := *accountFlag
+	device := deviceName
+	if *presetFlag != "" {
+		if p, ok := spotify.GetSettings().FindPreset(*presetFlag); ok {
+			if account == "" {
+				account = p.Account
+			}
+			if device == "" {
+				device = p.Device
+			}
+		}
+	}
+	if account == "" {
+		account = spotify.DeviceAccount(device)
+	}
+	account = strings.ToLower(account)
+	if account != "" && account != spotify.DefaultAccount {
+		file, err := spotify.TokenFileFor(account)
+		if err != nil {
+			log.Fatal(err)
+		}
+		spotify.SetTokenFile(file)
+		if spotify.GetHistory() != nil {
+			h, err := spotify.OpenHistory(spotify.AccountHistoryFile(historyFile, account), halfLife)
+			if err != nil {
+				spotify.Warnf("play history disabled: %v", err)
+			}
+			spotify.SetHistory(h)
+		}
+	}
+
+	spotify.SetAuthOptions(spotify.AuthOptions{
+		Timeout:   *authTimeout,
+		NoBrowser: *noBrowser,
+		Account:   account,
+		NoOpen:    *noOpen,
+	})
+
+	// Copy-and-paste authentication for machines without a browser, or
+	// where the callback port is taken
+	if *authManual {
+		ctx := context.Background()
+		client, err := spotify.AuthenticateManual(ctx, os.Stdin, os.Stdout)
+		if err != nil {
+			log.Fatalf("Authentication failed: %v", err)
+		}
+		user, err := client.CurrentUser(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get user info: %v", err)
+		}
+		spotify.Sayf("Authenticated as: %s", user.DisplayName)
+		return
+	}
+
+	// Sign in and exit, replacing any saved login
+	if *authLogin {
+		client := authenticate()
+		user, err := client.CurrentUser(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to get user info: %v", err)
+		}
+		spotify.Sayf("Authenticated as: %s", user.DisplayName)
+		return
+	}
+
 	// Run CLI mode
-	runCLIMode(listDevices, listPlaylists, debug, shuffle, pauseMode, deviceName, playlistID)
+	runCLIMode(cliOptions{
+		listDevices:         *listDevices,
+		listPlaylists:       *listPlaylists,
+		debug:               *debug,
+		shuffle:             *shuffle,
+		newestFirst:         *newestFirst,
+		leastPlayed:         *leastPlayed,
+		pauseMode:           *pauseMode,
+		stopMode:            *stopMode,
+		resumeLast:          *resumeLast,
+		importHA:            *importHA,
+		registerDevices:     *registerDevices,
+		followPublic:        *followPublic,
+		playFirst:           *playFirst,
+		seekPosition:        *seekPosition,
+		deviceName:          deviceName,
+		playlistID:          playlistID,
+		albumName:           *albumFlag,
+		artistName:          *artistFlag,
+		trackName:           *trackFlag,
+		audiobookName:       *audiobookFlag,
+		startName:           *startFlag,
+		durationName:        *durationFlag,
+		waitName:            *waitFlag,
+		presetName:          *presetFlag,
+		queueURI:            *queueFlag,
+		searchQuery:         *searchFlag,
+		searchTypes:         *searchType,
+		stopTransfer:        *stopTransfer,
+		followPlaylist:      *followFlag,
+		unfollowPlaylist:    *unfollowFlag,
+		deviceFilter:        deviceFilter,
+		watchEvery:          watchEvery,
+		createName:          *createFlag,
+		createDescription:   *createDescription,
+		createPublic:        *createPublic,
+		createCollaborative: *createCollaborative,
+		addTracks:           *addTrackFlag,
+		addTo:               *addToFlag,
+		removeTracks:        *removeTrackFlag,
+		removeFrom:          *removeFromFlag,
+		dryRun:              *dryRun,
+		exportPlaylist:      *exportFlag,
+		exportFormat:        *exportFormat,
+		exportOutput:        *exportOutput,
+		sortPlaylist:        *sortPlaylist,
+		sortBy:              *sortBy,
+		mergeSources:        *mergeFlag,
+		mergeInto:           *mergeInto,
+		mergeDedupe:         *mergeDedupe,
+		backupDir:           *backupDir,
+		restoreDir:          *restoreDir,
+		tracksPlaylist:      *tracksFlag,
+		tracksLimit:         *tracksLimit,
+		tracksOffset:        *tracksOffset,
+		delay:               delay,
+		statusMode:          *statusMode,
+	})
 }
 
 // runServerMode starts the HTTP API server.
@@ -105,87 +672,669 @@ func runServerMode() {
 		ctx := context.Background()
 		user, err := client.CurrentUser(ctx)
 		if err == nil {
-			fmt.Printf("Authenticated as: %s\n", user.DisplayName)
+			spotify.Sayf("Authenticated as: %s", user.DisplayName)
 			spotify.SetClient(client)
 		} else {
-			fmt.Println("Existing token expired. Visit /auth to re-authenticate.")
+			spotify.Sayf("Existing token expired. Visit /auth to re-authenticate.")
 		}
 	} else {
-		fmt.Println("No Spotify token found. Visit /auth to authenticate.")
+		spotify.Sayf("No Spotify token found. Visit /auth to authenticate.")
+	}
+	spotify.LoadAccountTokens()
+
+	// Optional now-playing lyrics
+	if name := os.Getenv("LYRICS_PROVIDER"); name != "" {
+		provider, err := spotify.LyricsProviderFor(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cacheDir := os.Getenv("LYRICS_CACHE_DIR")
+		if cacheDir == "" {
+			cacheDir = spotify.DefaultLyricsCacheDir
+		}
+		spotify.SetLyricsProvider(provider, cacheDir)
 	}
+
+	// Optional public now-playing page
+	if os.Getenv("STATUS_PAGE") == "true" {
+		cfg := spotify.StatusPageConfig{Account: os.Getenv("STATUS_PAGE_ACCOUNT")}
+		if v := os.Getenv("STATUS_PAGE_TTL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Invalid STATUS_PAGE_TTL %q: must be a positive duration like 15s", v)
+			}
+			cfg.TTL = d
+		}
+		if v := os.Getenv("STATUS_PAGE_RATE"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid STATUS_PAGE_RATE %q: must be a positive number of requests per minute", v)
+			}
+			cfg.Rate = n
+		}
+		spotify.EnableStatusPage(cfg)
+	}
+
+	// Keep the token warm in the background so an idle night doesn't leave
+	// the first morning request holding a dead token.
+	interval := 15 * time.Minute
+	if v := os.Getenv("TOKEN_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid TOKEN_CHECK_INTERVAL %q: must be a positive duration like 15m", v)
+		}
+		interval = d
+	}
+	spotify.StartTokenHealthChecker(context.Background(), interval)
+
+	// Credit tracks heard from playback we started, for least-played ordering
+	spotify.StartHistoryRecorder(context.Background())
+
+	// Output sinks for the event bus
+	var sinks []spotify.EventSink
+	if p := os.Getenv("EVENTS_FILE"); p != "" {
+		sinks = append(sinks, &spotify.FileSink{Path: p})
+	}
+	for _, u := range strings.Split(os.Getenv("EVENTS_WEBHOOK_URL"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			sinks = append(sinks, spotify.WebhookSink{URL: u})
+		}
+	}
+	if u := os.Getenv("EVENTS_MQTT_URL"); u != "" {
+		sink, err := spotify.NewMQTTSink(u)
+		if err != nil {
+			log.Fatalf("Invalid EVENTS_MQTT_URL: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	topics, err := spotify.ParseEventTopics(os.Getenv("EVENTS_TOPICS"))
+	if err != nil {
+		log.Fatalf("Invalid EVENTS_TOPICS: %v", err)
+	}
+	spotify.StartEventSinks(context.Background(), topics, sinks...)
+
+	// Playback rules and volume schedules from the settings file
+	if err := spotify.StartSettingsJobs(context.Background()); err != nil {
+		log.Fatalf("Invalid settings file: %v", err)
+	}
+
+	// Cron schedules from the settings file and the API
+	spotify.SetScheduler(openScheduler())
+	spotify.StartScheduler(context.Background())
+
+	// Notifier channels for background jobs
+	var notifiers []spotify.Notifier
+	if u := os.Getenv("NOTIFY_WEBHOOK_URL"); u != "" {
+		notifiers = append(notifiers, spotify.WebhookNotifier{URL: u})
+	}
+	if u := os.Getenv("NOTIFY_NTFY_URL"); u != "" {
+		notifiers = append(notifiers, spotify.NtfyNotifier{URL: u, Token: os.Getenv("NOTIFY_NTFY_TOKEN")})
+	}
+	spotify.SetNotifiers(notifiers...)
+	spotify.SetNotifyFallbacks(os.Getenv("NOTIFY_FALLBACKS") == "true")
+
+	// Recently-added digest for shared playlists
+	digestFile := os.Getenv("SPOTIFY_DIGEST_STATE_FILE")
+	if digestFile == "" {
+		digestFile = spotify.DefaultDigestStateFile
+	}
+	var digestPlaylists []string
+	for _, p := range strings.Split(os.Getenv("DIGEST_PLAYLISTS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			digestPlaylists = append(digestPlaylists, p)
+		}
+	}
+	if job, err := spotify.OpenDigestJob(digestFile, digestPlaylists); err != nil {
+		spotify.Warnf("digest disabled: %v", err)
+	} else {
+		spotify.SetDigestJob(job)
+	}
+	if v := os.Getenv("DIGEST_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid DIGEST_INTERVAL %q: must be a positive duration like 24h", v)
+		}
+		spotify.StartDigestScheduler(context.Background(), d)
+	}
+
+	// Telegram bot, answering commands from the allowed chats
+	if t := os.Getenv("TELEGRAM_BOT_TOKEN"); t != "" {
+		bot, err := spotify.NewTelegramBot(t, os.Getenv("TELEGRAM_CHAT_IDS"))
+		if err != nil {
+			log.Fatalf("Invalid Telegram configuration: %v", err)
+		}
+		bot.Start(context.Background())
+	}
+
+	// Slack slash command at /integrations/slack
+	if s := os.Getenv("SLACK_SIGNING_SECRET"); s != "" {
+		spotify.EnableSlack(s)
+	}
+
+	// Alexa skill at /integrations/alexa
+	if id := os.Getenv("ALEXA_SKILL_ID"); id != "" {
+		spotify.EnableAlexa(id)
+	}
+
+	// SIGHUP reloads the settings file and API token, as /api/v1/reload does
+	spotify.SetAPITokenSource(reloadAPIToken)
+	go reloadOnSIGHUP()
+
 	spotify.StartAPIServer()
 }
 
+// reloadAPIToken re-reads API_ACCESS_TOKEN for a reload: from .env if it
+// sets one, otherwise from the environment.
+func reloadAPIToken() (string, error) {
+	token := os.Getenv("API_ACCESS_TOKEN")
+	if env, err := godotenv.Read(); err == nil && env["API_ACCESS_TOKEN"] != "" {
+		token = env["API_ACCESS_TOKEN"]
+	}
+	if token == "" {
+		return "", fmt.Errorf("API_ACCESS_TOKEN is empty")
+	}
+	return token, nil
+}
+
+// reloadOnSIGHUP reloads the configuration each time the process gets a
+// SIGHUP. A failed reload is logged and the old configuration kept.
+func reloadOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if _, err := spotify.Reload(); err != nil {
+			log.Printf("Reload failed, keeping the current configuration: %v", err)
+		}
+	}
+}
+
+// authenticate runs the CLI OAuth flow, giving up on Ctrl-C, SIGTERM or
+// the -auth-timeout. Signals are only captured for the wait, so they end
+// the process as usual afterwards.
+func authenticate() *spotifyLib.Client {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client, err := spotify.Authenticate(ctx)
+	if err != nil {
+		log.Fatalf("Authentication failed: %v", err)
+	}
+	return client
+}
+
+// openScheduler opens the settings-file and API schedules with the zone
+// and location from the environment, exiting if any are invalid.
+func openScheduler() *spotify.Scheduler {
+	if err := spotify.SetScheduleTimezone(os.Getenv("SCHEDULE_TIMEZONE")); err != nil {
+		log.Fatalf("Invalid SCHEDULE_TIMEZONE: %v", err)
+	}
+	if err := spotify.SetScheduleCoordinates(os.Getenv("SCHEDULE_LATITUDE"), os.Getenv("SCHEDULE_LONGITUDE")); err != nil {
+		log.Fatalf("Invalid schedule location: %v", err)
+	}
+	schedulesFile := os.Getenv("SPOTIFY_SCHEDULES_FILE")
+	if schedulesFile == "" {
+		schedulesFile = spotify.DefaultSchedulesPath()
+	}
+	sched, err := spotify.OpenScheduler(schedulesFile)
+	if err != nil {
+		log.Fatalf("Invalid schedules: %v", err)
+	}
+	return sched
+}
+
+// reauthHint tells CLI users how to sign in again on purpose.
+const reauthHint = "run with -logout, then run the command again, or use -auth-manual"
+
+// fatalSpotify logs a failed Spotify call and exits with the code for its
+// kind of failure. Login and scope problems get their fix spelled out
+// rather than just Spotify's error.
+func fatalSpotify(msg string, err error) {
+	if jsonOutput {
+		printFailureJSON(msg, err)
+	}
+	if p := spotify.DiagnoseLogin(err); p != nil {
+		if p.Reauthenticate() {
+			log.Printf("%s: %v\n%s To sign in again, %s.", msg, err, p.Fix(), reauthHint)
+		} else {
+			log.Printf("%s: %v\n%s", msg, err, p.Fix())
+		}
+	} else {
+		log.Printf("%s: %v", msg, err)
+	}
+	os.Exit(exitCode(err))
+}
+
+// cliOptions are the flags runCLIMode acts on, after parsing and the
+// checks in main.
+type cliOptions struct {
+	// Listing
+	listDevices, listPlaylists, debug bool
+	deviceFilter                      spotify.DeviceFilter
+	watchEvery                        time.Duration
+	statusMode                        bool
+
+	// Playing
+	deviceName, playlistID                          string
+	albumName, artistName, trackName, audiobookName string
+	presetName, startName, durationName, waitName   string
+	shuffle, newestFirst, leastPlayed               bool
+	delay                                           time.Duration
+	pauseMode, stopMode, resumeLast                 bool
+	stopTransfer, queueURI                          string
+	seekPosition                                    int
+	searchQuery, searchTypes                        string
+	playFirst                                       bool
+
+	// Devices
+	importHA, registerDevices bool
+
+	// Playlists
+	followPlaylist, unfollowPlaylist           string
+	followPublic                               bool
+	createName, createDescription              string
+	createPublic, createCollaborative          bool
+	addTracks, addTo, removeTracks, removeFrom string
+	dryRun                                     bool
+	exportPlaylist, exportFormat, exportOutput string
+	sortPlaylist, sortBy                       string
+	mergeSources, mergeInto                    string
+	mergeDedupe                                bool
+	backupDir, restoreDir                      string
+	tracksPlaylist                             string
+	tracksLimit, tracksOffset                  int
+}
+
 // runCLIMode handles all command-line interface operations.
-func runCLIMode(listDevices, listPlaylists, debug, shuffle, pauseMode *bool, deviceName, playlistID string) {
-	// For CLI mode, require authentication
+func runCLIMode(opts cliOptions) {
+	// For CLI mode, require authentication. Say why a saved login can't
+	// be used before asking to sign in again.
 	client, err := spotify.LoadToken()
 	if err != nil {
-		// No valid token, need to authenticate
-		client = spotify.Authenticate()
+		if p := spotify.DiagnoseLogin(err); p != nil {
+			spotify.Sayf("%s", p.Fix())
+		} else {
+			spotify.Sayf("Couldn't load the saved login: %v", err)
+		}
+		client = authenticate()
+	} else if missing := spotify.MissingScopes(spotify.GetTokenFile()); len(missing) > 0 {
+		spotify.Warnf("the saved login wasn't granted %s; commands that need them fail until you sign in again (%s)", strings.Join(missing, ", "), reauthHint)
 	}
 
-	ctx := context.Background()
+	// The token file already points at the chosen account, so name the
+	// default one; otherwise a preset's account would be looked up again.
+	ctx := spotify.WithAccount(context.Background(), spotify.DefaultAccount)
 
-	// Get user info to verify authentication
+	// Get user info to verify authentication. Only login problems are
+	// worth signing in again for; a network failure isn't.
 	user, err := client.CurrentUser(ctx)
 	if err != nil {
-		log.Printf("Token may be expired, re-authenticating: %v", err)
-		client = spotify.Authenticate()
+		p := spotify.DiagnoseLogin(err)
+		if p == nil || !p.Reauthenticate() {
+			fatalSpotify("Failed to get user info", err)
+		}
+		spotify.Sayf("%s", p.Fix())
+		client = authenticate()
 		user, err = client.CurrentUser(ctx)
 		if err != nil {
-			log.Fatalf("Failed to get user info: %v", err)
+			fatalSpotify("Failed to get user info", err)
 		}
 	}
 
-	fmt.Printf("Authenticated as: %s\n", user.DisplayName)
+	spotify.Sayf("Authenticated as: %s", user.DisplayName)
 
 	// Store client globally
 	spotify.SetClient(client)
 
+	// Handle --delay after signing in, so a login prompt doesn't wait too
+	if opts.delay > 0 && !waitForDelay(ctx, opts.delay) {
+		return
+	}
+
 	// Handle --playlists flag
-	if *listPlaylists {
-		handleListPlaylists(ctx, client, debug)
+	if opts.listPlaylists {
+		handleListPlaylists(ctx, client, opts.debug)
+		return
+	}
+
+	// Handle --search flag
+	if opts.searchQuery != "" {
+		handleSearch(ctx, opts.searchQuery, opts.searchTypes, opts.deviceName, opts.playFirst)
+		return
+	}
+
+	// Handle --import-ha flag
+	if opts.importHA {
+		handleImportHomeAssistant(ctx, client)
 		return
 	}
 
-	// Handle --pause flag
-	if *pauseMode {
-		result, err := spotify.PausePlayback()
+	// Handle --register-devices flag
+	if opts.registerDevices {
+		registered, err := spotify.RegisterDevices(ctx)
 		if err != nil {
-			log.Fatalf("Failed to pause: %v", err)
+			fatalSpotify("Failed to register devices", err)
+		}
+		for _, d := range registered {
+			fmt.Printf("  %s  %s (%s)  %s\n", d.StableID, d.Name, d.Type, d.SpotifyID)
 		}
-		fmt.Println(result)
+		fmt.Printf("%d registered device(s)\n", len(registered))
 		return
 	}
 
-	// Get available devices
-	devices, err := client.PlayerDevices(ctx)
+	// Handle --status flag
+	if opts.statusMode {
+		status, err := spotify.BuildShortcutSummary(ctx)
+		if err != nil {
+			fatalSpotify("Failed to get playback state", err)
+		}
+		printStatus(status)
+		return
+	}
+
+	// Handle --pause flag; with -device, that device or group only
+	if opts.pauseMode {
+		pause := spotify.PausePlayback
+		if opts.deviceName != "" {
+			pause = func(ctx context.Context) (string, error) { return spotify.PauseDevice(ctx, opts.deviceName) }
+		}
+		result, err := pause(ctx)
+		if err != nil {
+			fatalSpotify("Failed to pause", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --stop flag
+	if opts.stopMode {
+		result, err := spotify.StopPlayback(ctx, opts.stopTransfer)
+		if err != nil {
+			fatalSpotify("Failed to stop", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --resume-last flag
+	if opts.resumeLast {
+		result, err := spotify.ResumeLast(ctx, opts.deviceName)
+		if err != nil {
+			fatalSpotify("Failed to resume", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --preset flag
+	if opts.presetName != "" {
+		result, err := spotify.PlayPreset(ctx, opts.presetName)
+		if err != nil {
+			fatalSpotify("Failed to play preset", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --queue flag
+	if opts.queueURI != "" {
+		result, err := spotify.QueueTrack(ctx, opts.queueURI)
+		if err != nil {
+			fatalSpotify("Failed to queue", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --follow flag
+	if opts.followPlaylist != "" {
+		result, err := spotify.FollowPlaylist(ctx, opts.followPlaylist, opts.followPublic)
+		if err != nil {
+			fatalSpotify("Failed to follow playlist", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --create-playlist flag
+	if opts.createName != "" {
+		playlist, err := spotify.CreatePlaylist(ctx, opts.createName, opts.createDescription, opts.createPublic, opts.createCollaborative)
+		if err != nil {
+			fatalSpotify("Failed to create playlist", err)
+		}
+		spotify.Sayf("Created playlist %q", playlist.Name)
+		fmt.Println(playlist.ID)
+		return
+	}
+
+	// Handle --add-track flag
+	if opts.addTracks != "" {
+		result, err := spotify.AddToPlaylist(ctx, opts.addTo, strings.Split(opts.addTracks, ",")...)
+		if err != nil {
+			fatalSpotify("Failed to add to playlist", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --remove-track flag
+	if opts.removeTracks != "" {
+		result, removed, err := spotify.RemoveFromPlaylist(ctx, opts.removeFrom, opts.dryRun, strings.Split(opts.removeTracks, ",")...)
+		if err != nil {
+			fatalSpotify("Failed to remove from playlist", err)
+		}
+		for _, t := range removed {
+			if t.Artists != "" {
+				fmt.Printf("%4d  %s - %s\n", t.Position, t.Name, t.Artists)
+			} else {
+				fmt.Printf("%4d  %s\n", t.Position, t.Name)
+			}
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --export-playlist flag
+	if opts.exportPlaylist != "" {
+		export, err := spotify.ExportPlaylist(ctx, opts.exportPlaylist)
+		if err != nil {
+			fatalSpotify("Failed to export playlist", err)
+		}
+		if opts.exportOutput == "" {
+			if err := spotify.WritePlaylistExport(os.Stdout, export, opts.exportFormat); err != nil {
+				log.Fatalf("Failed to write export: %v", err)
+			}
+			return
+		}
+		file, err := os.Create(opts.exportOutput)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", opts.exportOutput, err)
+		}
+		if err := spotify.WritePlaylistExport(file, export, opts.exportFormat); err != nil {
+			file.Close()
+			log.Fatalf("Failed to write export: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			log.Fatalf("Failed to write export: %v", err)
+		}
+		printResult(fmt.Sprintf("Exported %d track(s) from %q to %s", len(export.Tracks), export.Name, opts.exportOutput))
+		return
+	}
+
+	// Handle --sort-playlist flag
+	if opts.sortPlaylist != "" {
+		result, err := spotify.SortPlaylist(ctx, opts.sortPlaylist, opts.sortBy)
+		if err != nil {
+			fatalSpotify("Failed to sort playlist", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --merge-playlists flag
+	if opts.mergeSources != "" {
+		result, err := spotify.MergePlaylists(ctx, strings.Split(opts.mergeSources, ","), opts.mergeInto, opts.mergeDedupe)
+		if err != nil {
+			fatalSpotify("Failed to merge playlists", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --tracks flag
+	if opts.tracksPlaylist != "" {
+		name, total, tracks, err := spotify.ListPlaylistTracks(ctx, opts.tracksPlaylist, opts.tracksLimit, opts.tracksOffset)
+		if err != nil {
+			fatalSpotify("Failed to list tracks", err)
+		}
+		spotify.PrintPlaylistTracksTable(name, total, tracks)
+		return
+	}
+
+	// Handle --backup-playlists flag
+	if opts.backupDir != "" {
+		result, err := spotify.BackupPlaylists(ctx, opts.backupDir, spotify.ProgressWriter())
+		if err != nil {
+			fatalSpotify("Failed to back up playlists", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --restore-playlists flag
+	if opts.restoreDir != "" {
+		result, err := spotify.RestorePlaylists(ctx, opts.restoreDir, spotify.ProgressWriter())
+		if err != nil {
+			fatalSpotify("Failed to restore playlists", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --unfollow flag
+	if opts.unfollowPlaylist != "" {
+		result, err := spotify.UnfollowPlaylist(ctx, opts.unfollowPlaylist)
+		if err != nil {
+			fatalSpotify("Failed to unfollow playlist", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --seek flag
+	if opts.seekPosition >= 0 {
+		result, err := spotify.Seek(ctx, opts.seekPosition)
+		if err != nil {
+			fatalSpotify("Failed to seek", err)
+		}
+		printResult(result)
+		return
+	}
+
+	// Handle --devices --watch; it runs until Ctrl-C, so an empty list
+	// isn't fatal
+	if opts.listDevices && opts.watchEvery > 0 {
+		watchCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := spotify.WatchDevices(watchCtx, opts.watchEvery, opts.deviceFilter, os.Stdout); err != nil {
+			fatalSpotify("Failed to watch devices", err)
+		}
+		return
+	}
+
+	// Get available devices, with the simulated one if -fake-device is set
+	devices, err := spotify.GetClient().PlayerDevices(ctx)
 	if err != nil {
-		log.Fatalf("Failed to get devices: %v", err)
+		fatalSpotify("Failed to get devices", err)
 	}
 
-	if len(devices) == 0 {
+	// With -wait the device may still be waking up.
+	if len(devices) == 0 && opts.waitName == "" {
 		log.Fatal("No Spotify Connect devices found. Make sure a device is active.")
 	}
 
 	// Handle --debug flag for devices
-	if *debug {
+	if opts.debug {
 		printDebugJSON("Device", devices)
 	}
 
 	// Handle --devices flag
-	if *listDevices {
-		spotify.PrintDevicesTable(devices)
+	if opts.listDevices {
+		printDevices(devices, opts.deviceFilter)
 		return
 	}
 
-	// Play the playlist
-	handlePlayPlaylist(ctx, client, devices, deviceName, playlistID, shuffle)
+	// Without a playlist, only a terminal gets this far: ask for one.
+	if opts.playlistID == "" && opts.albumName == "" && opts.artistName == "" && opts.trackName == "" && opts.audiobookName == "" {
+		opts.playlistID = pickPlaylist(ctx, "")
+	}
+
+	// Play the album, artist, track, or audiobook, or else the playlist
+	// (or whatever its link points at)
+	req := spotify.PlayRequest{
+		Device:      opts.deviceName,
+		Shuffle:     opts.shuffle,
+		Start:       opts.startName,
+		NewestFirst: opts.newestFirst,
+		LeastPlayed: opts.leastPlayed,
+		Duration:    opts.durationName,
+		Wait:        opts.waitName,
+	}
+	switch {
+	case opts.albumName != "":
+		req.Album = opts.albumName
+		handlePlayRequest(ctx, req, "Failed to play album")
+		return
+	case opts.artistName != "":
+		req.Artist = opts.artistName
+		handlePlayRequest(ctx, req, "Failed to play artist")
+		return
+	case opts.trackName != "":
+		req.Track = opts.trackName
+		handlePlayRequest(ctx, req, "Failed to play track")
+		return
+	case opts.audiobookName != "":
+		req.Audiobook = opts.audiobookName
+		handlePlayRequest(ctx, req, "Failed to play audiobook")
+		return
+	}
+	req.Playlist = opts.playlistID
+	handlePlayRequest(ctx, req, "Failed to play")
+}
+
+// waitForDelay waits out -delay before playing, reporting false if Ctrl-C
+// cancelled the play.
+func waitForDelay(ctx context.Context, delay time.Duration) bool {
+	spotify.Sayf("Playing at %s; press Ctrl-C to cancel", time.Now().Add(delay).Format("15:04:05"))
+	waitCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-waitCtx.Done():
+		spotify.Sayf("Cancelled; nothing was played")
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// handlePlayRequest plays a playlist, album, artist, track, or audiobook
+// through the shared play path, which resolves names and claims the
+// device if needed.
+func handlePlayRequest(ctx context.Context, req spotify.PlayRequest, failMsg string) {
+	result, err := spotify.Play(ctx, req)
+	if err != nil {
+		if retry, ok := pickAfterFailure(ctx, req, err); ok {
+			result, err = spotify.Play(ctx, retry)
+		}
+	}
+	if err != nil {
+		fatalSpotify(failMsg, err)
+	}
+	printResult(result)
 }
 
 // handleListPlaylists fetches and displays all user playlists.
-func handleListPlaylists(ctx context.Context, client *spotifyLib.Client, debug *bool) {
+func handleListPlaylists(ctx context.Context, client *spotifyLib.Client, debug bool) {
 	var allPlaylists []spotifyLib.SimplePlaylist
 	limit := 50
 	offset := 0
@@ -193,7 +1342,7 @@ func handleListPlaylists(ctx context.Context, client *spotifyLib.Client, debug *
 	for {
 		playlists, err := client.CurrentUsersPlaylists(ctx, spotifyLib.Limit(limit), spotifyLib.Offset(offset))
 		if err != nil {
-			log.Fatalf("Failed to get playlists: %v", err)
+			fatalSpotify("Failed to get playlists", err)
 		}
 
 		allPlaylists = append(allPlaylists, playlists.Playlists...)
@@ -204,100 +1353,71 @@ func handleListPlaylists(ctx context.Context, client *spotifyLib.Client, debug *
 		offset += limit
 	}
 
-	if *debug {
+	if debug {
 		printDebugJSON("Playlist", allPlaylists)
 	}
 
-	spotify.PrintPlaylistsTable(allPlaylists)
+	printPlaylists(allPlaylists)
 }
 
-// handlePlayPlaylist starts playback on the specified device.
-func handlePlayPlaylist(ctx context.Context, client *spotifyLib.Client, devices []spotifyLib.PlayerDevice, deviceName, playlistID string, shuffle *bool) {
-	// Find the target device by name or ID
-	var targetDevice *spotifyLib.PlayerDevice
-	fmt.Println("\nAvailable devices:")
-	for i, device := range devices {
-		fmt.Printf("  %d. %s (%s) - Active: %v\n", i+1, device.Name, device.Type, device.Active)
-		if deviceName != "" && (device.Name == deviceName || string(device.ID) == deviceName) {
-			targetDevice = &devices[i]
-		}
+// handleSearch searches the catalog and lists the results, then with
+// playFirst plays the top one on deviceName.
+func handleSearch(ctx context.Context, query, types, deviceName string, playFirst bool) {
+	hits, err := spotify.SearchCatalog(ctx, query, types, 0)
+	if err != nil {
+		fatalSpotify("Failed to search", err)
 	}
+	spotify.PrintSearchTable(query, hits)
 
-	// If no device name/ID specified or not found, use the first active device or first device
-	if targetDevice == nil {
-		if deviceName != "" {
-			fmt.Printf("\nDevice '%s' not found. ", deviceName)
-		}
-		for i, device := range devices {
-			if device.Active {
-				targetDevice = &devices[i]
-				break
-			}
-		}
-		if targetDevice == nil {
-			targetDevice = &devices[0]
-		}
-		fmt.Printf("Using device: %s\n", targetDevice.Name)
-	} else {
-		fmt.Printf("\nUsing specified device: %s\n", targetDevice.Name)
+	if !playFirst {
+		return
+	}
+	if len(hits) == 0 {
+		log.Fatal("Nothing to play: the search found no results")
 	}
+	handlePlayRequest(ctx, spotify.PlayRequest{Device: deviceName, Playlist: hits[0].URI}, "Failed to play the top result")
+}
 
-	// Resolve playlist by URL, name, or ID
-	resolvedPlaylistID, err := spotify.ResolvePlaylistID(ctx, client, playlistID)
-	if err != nil {
-		log.Fatalf("Failed to resolve playlist: %v", err)
+// handleImportHomeAssistant pulls areas and media players from Home
+// Assistant, matches them against every Spotify Connect device we can see
+// (cloud list + LAN scan), and merges the resulting rooms/presets into the
+// settings file without overwriting existing entries.
+func handleImportHomeAssistant(ctx context.Context, client *spotifyLib.Client) {
+	haURL := os.Getenv("HASS_URL")
+	haToken := os.Getenv("HASS_TOKEN")
+	if haURL == "" || haToken == "" {
+		log.Fatal("HASS_URL and HASS_TOKEN environment variables are required for -import-ha")
 	}
 
-	// Get playlist info
-	playlist, err := client.GetPlaylist(ctx, spotifyLib.ID(resolvedPlaylistID))
+	var names []string
+	devices, err := client.PlayerDevices(ctx)
 	if err != nil {
-		log.Fatalf("Failed to get playlist (ID: %s): %v\nMake sure the playlist ID is correct and the playlist is accessible.", resolvedPlaylistID, err)
+		spotify.Warnf("Failed to get Spotify devices: %v", err)
 	}
-
-	trackCount := int(playlist.Tracks.Total)
-	playlistURI := spotifyLib.URI("spotify:playlist:" + resolvedPlaylistID)
-
-	// Build play options
-	opts := &spotifyLib.PlayOptions{
-		DeviceID:        &targetDevice.ID,
-		PlaybackContext: &playlistURI,
+	for _, d := range devices {
+		names = append(names, d.Name)
+	}
+	locals, err := spotify.DefaultDiscoveryCache().Devices(ctx)
+	if err != nil {
+		spotify.Warnf("LAN discovery failed: %v", err)
+	}
+	for _, d := range locals {
+		names = append(names, d.FriendlyName)
 	}
 
-	// If shuffle is enabled, pick a random starting track
-	if *shuffle {
-		randomOffset := rand.Intn(trackCount)
-		offset := &spotifyLib.PlaybackOffset{Position: &randomOffset}
-		opts.PlaybackOffset = offset
-
-		err = client.PlayOpt(ctx, opts)
-		if err != nil {
-			log.Fatalf("Failed to start playback: %v", err)
-		}
-
-		fmt.Printf("Now playing playlist \"%s\" on %s (starting at track %d of %d)\n",
-			playlist.Name, targetDevice.Name, randomOffset+1, trackCount)
-
-		// Wait for playback to initialize before setting shuffle
-		time.Sleep(500 * time.Millisecond)
-
-		// Enable shuffle mode
-		err = client.Shuffle(ctx, true)
-		if err != nil {
-			log.Printf("Warning: Failed to enable shuffle: %v", err)
-		} else {
-			fmt.Println("Shuffle mode enabled")
-		}
-	} else {
-		// Start from the beginning (track 1) without shuffle
-		startPosition := 0
-		opts.PlaybackOffset = &spotifyLib.PlaybackOffset{Position: &startPosition}
+	imported, skipped, err := spotify.ImportHomeAssistant(ctx, spotify.NewHomeAssistantClient(haURL, haToken), names)
+	if err != nil {
+		log.Fatalf("Failed to import from Home Assistant: %v", err)
+	}
 
-		err = client.PlayOpt(ctx, opts)
-		if err != nil {
-			log.Fatalf("Failed to start playback: %v", err)
-		}
+	rooms, presets, err := spotify.MergeIntoSettings(imported)
+	if err != nil {
+		log.Fatalf("Failed to save settings: %v", err)
+	}
 
-		fmt.Printf("Now playing playlist \"%s\" on %s (starting at track 1)\n", playlist.Name, targetDevice.Name)
+	printResult(fmt.Sprintf("Imported %d room(s) and %d preset(s) into %s", rooms, presets, spotify.GetSettingsFile()))
+	for _, area := range skipped {
+		spotify.Warnf("skipped area %q: no media player matched a Spotify Connect device", area)
 	}
 }
 
@@ -306,7 +1426,7 @@ func printDebugJSON(label string, data interface{}) {
 	fmt.Printf("\n=== Raw %s Data ===\n", label)
 	rawJSON, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		log.Printf("Warning: Failed to marshal %s data: %v", label, err)
+		spotify.Warnf("Failed to marshal %s data: %v", label, err)
 	} else {
 		fmt.Println(string(rawJSON))
 	}
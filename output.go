@@ -0,0 +1,117 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: -json output and exit codes. With -json, -devices,
+// -playlists, -status, and the results of play, pause, stop, and resume
+// go to stdout as JSON in the API's shapes instead of tables and
+// sentences, and a failed Spotify call prints {"success": false, ...}
+// before exiting, so scripts can pipe the output into jq. Progress and
+// warnings stay on stderr. A failed Spotify call exits with a code for
+// its kind of failure, so scripts can tell a missing speaker from a
+// missing login without parsing the message.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// jsonOutput is set by -json.
+var jsonOutput bool
+
+// printJSON writes `v` to stdout as indented JSON.
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// Exit codes of a failed Spotify call, by its error code.
+const (
+	exitFailure     = 1 // anything else
+	exitBadRequest  = 2 // bad_request, like the flag package's usage errors
+	exitAuth        = 3 // auth: sign in again
+	exitDevice      = 4 // device: the speaker isn't there
+	exitNotFound    = 5 // not_found: the playlist, track, etc. isn't there
+	exitUnavailable = 6 // network, rate_limited, unavailable: try again later
+)
+
+// exitCode returns the exit code for a failed Spotify call.
+func exitCode(err error) int {
+	switch spotify.ErrorCodeOf(err) {
+	case spotify.CodeBadRequest:
+		return exitBadRequest
+	case spotify.CodeAuth:
+		return exitAuth
+	case spotify.CodeDevice:
+		return exitDevice
+	case spotify.CodeNotFound:
+		return exitNotFound
+	case spotify.CodeNetwork, spotify.CodeRateLimited, spotify.CodeUnavailable:
+		return exitUnavailable
+	}
+	return exitFailure
+}
+
+// printResult prints a command's result message, as an APIResponse with
+// -json. -quiet drops the sentence but not the JSON.
+func printResult(msg string) {
+	if jsonOutput {
+		printJSON(spotify.APIResponse{Success: true, Message: msg})
+		return
+	}
+	if !spotify.Quiet() {
+		fmt.Println(msg)
+	}
+}
+
+// printFailureJSON prints a failure as an APIResponse and exits with its
+// exit code.
+func printFailureJSON(msg string, err error) {
+	printJSON(spotify.APIResponse{Success: false, Error: fmt.Sprintf("%s: %v", msg, err), Code: spotify.ErrorCodeOf(err)})
+	os.Exit(exitCode(err))
+}
+
+// printDevices prints the devices `filter` keeps, as /api/v1/devices does
+// with -json.
+func printDevices(devices []spotifyLib.PlayerDevice, filter spotify.DeviceFilter) {
+	if !jsonOutput {
+		spotify.PrintDevicesTable(devices, filter)
+		return
+	}
+	infos := spotify.DeviceInfos(filter.Apply(devices))
+	printJSON(spotify.DevicesResponse{Success: true, Message: fmt.Sprintf("Found %d device(s)", len(infos)), Devices: infos})
+}
+
+// printPlaylists prints the playlists, as /api/v1/playlists does with
+// -json.
+func printPlaylists(playlists []spotifyLib.SimplePlaylist) {
+	if !jsonOutput {
+		spotify.PrintPlaylistsTable(playlists)
+		return
+	}
+	infos := make([]spotify.PlaylistInfo, 0, len(playlists))
+	for _, p := range playlists {
+		infos = append(infos, spotify.NewPlaylistInfo(p))
+	}
+	printJSON(spotify.PlaylistsResponse{Success: true, Message: fmt.Sprintf("Found %d playlist(s)", len(infos)), Total: len(infos), Playlists: infos})
+}
+
+// printStatus prints what's playing: a sentence, or with -json the
+// dictionary /api/v1/shortcut/summary returns.
+func printStatus(status spotify.ShortcutSummary) {
+	if jsonOutput {
+		printJSON(status)
+		return
+	}
+	fmt.Println(status.Summary)
+}
@@ -0,0 +1,94 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Picking a playlist or device in a terminal. A play with no
+// playlist, or with a playlist or device that can't be found, asks the
+// user to pick one with spotify.Pick instead of exiting, when the CLI is
+// interactive (see spotify.Interactive). Cancelling the pick plays
+// nothing.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// pickOrExit runs the picker, exiting when the user cancels it.
+func pickOrExit(title, query string, items []spotify.PickItem) string {
+	choice, err := spotify.Pick(title, query, items)
+	if errors.Is(err, spotify.ErrPickCancelled) {
+		spotify.Sayf("Cancelled; nothing was played")
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", title, err)
+	}
+	return choice.Value
+}
+
+// pickPlaylist asks for one of the user's playlists, with `query` typed
+// in the filter, and returns its ID.
+func pickPlaylist(ctx context.Context, query string) string {
+	playlists, err := spotify.ListPlaylists(ctx)
+	if err != nil {
+		fatalSpotify("Failed to get playlists", err)
+	}
+	items := make([]spotify.PickItem, 0, len(playlists))
+	for _, p := range playlists {
+		items = append(items, spotify.PickItem{Label: fmt.Sprintf("%s (%d tracks)", p.Name, p.Tracks.Total), Value: string(p.ID)})
+	}
+	return pickOrExit("Pick a playlist", query, items)
+}
+
+// pickDevice asks for one of `devices`, with `query` typed in the filter.
+func pickDevice(devices []spotifyLib.PlayerDevice, query string) *spotifyLib.PlayerDevice {
+	items := make([]spotify.PickItem, 0, len(devices))
+	for i, d := range devices {
+		label := fmt.Sprintf("%s (%s)", d.Name, d.Type)
+		if d.Active {
+			label += " - active"
+		}
+		items = append(items, spotify.PickItem{Label: label, Value: strconv.Itoa(i)})
+	}
+	i, _ := strconv.Atoi(pickOrExit("Pick a device", query, items))
+	return &devices[i]
+}
+
+// pickAfterFailure asks for another device or playlist when a play failed
+// because its device or playlist couldn't be found, returning the request
+// to retry. It reports false when picking wouldn't help.
+func pickAfterFailure(ctx context.Context, req spotify.PlayRequest, err error) (spotify.PlayRequest, bool) {
+	if !spotify.Interactive() {
+		return req, false
+	}
+	switch spotify.ErrorCodeOf(err) {
+	case spotify.CodeDevice:
+		devices, derr := spotify.ListDevices(ctx)
+		if derr != nil || len(devices) == 0 {
+			return req, false
+		}
+		spotify.Sayf("%v", err)
+		req.Device = string(pickDevice(devices, req.Device).ID)
+		return req, true
+	case spotify.CodeNotFound:
+		if req.Playlist == "" || spotify.IsLikedSongs(req.Playlist) {
+			return req, false
+		}
+		spotify.Sayf("%v", err)
+		req.Playlist = pickPlaylist(ctx, req.Playlist)
+		return req, true
+	}
+	return req, false
+}
@@ -76,6 +76,12 @@ else
   echo "==> No local .spotify_token.json — first start will need /auth flow."
 fi
 
+# Settings file (rooms, presets) is optional too.
+if [ -f .spotify_settings.json ]; then
+  echo "==> Copying .spotify_settings.json..."
+  scp -q .spotify_settings.json "${REMOTE}:${REMOTE_DIR}/.spotify_settings.json"
+fi
+
 # --- Install launchd plist ---------------------------------------------------
 
 # Generate plist locally then scp. Heredoc is single-quoted to avoid local
@@ -0,0 +1,351 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Named Spotify accounts. One server can control several
+// accounts (say, yours and your partner's), each with its own token file
+// and client. Requests pick an account with the `account` parameter, which
+// travels in the request context; code that talks to Spotify gets its
+// client from clientFor(ctx) rather than a single global.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAccount names the account behind SPOTIFY_TOKEN_FILE. Requests
+// that don't name an account use it.
+const DefaultAccount = "default"
+
+// Account is a named Spotify account with its own token file, and its own
+// play history so family members' least-played orderings stay separate.
+type Account struct {
+	Name      string
+	TokenFile string
+	client    Client
+	history   *HistoryDB
+	recorder  *historyRecorder
+}
+
+var (
+	accountsMu sync.RWMutex
+	// accounts holds the named accounts other than the default one, whose
+	// client lives in spotifyClient.
+	accounts = map[string]*Account{}
+)
+
+// accountNamePattern keeps account names safe for file names and URLs.
+var accountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// AccountTokenFile is the token file a named account uses unless one is
+// given: .spotify_token.<name>.json.
+func AccountTokenFile(name string) string {
+	return ".spotify_token." + name + ".json"
+}
+
+// AddAccount registers a named account whose token lives in `tokenFile`
+// (AccountTokenFile(name) when empty). Names are lowercase letters,
+// digits, dashes, and underscores.
+func AddAccount(name, tokenFile string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid account name %q: use lowercase letters, digits, - and _", name)
+	}
+	if name == DefaultAccount {
+		return fmt.Errorf("%q is the account behind SPOTIFY_TOKEN_FILE and can't be redefined", name)
+	}
+	if tokenFile == "" {
+		tokenFile = AccountTokenFile(name)
+	}
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+	accounts[name] = &Account{Name: name, TokenFile: tokenFile, recorder: &historyRecorder{}}
+	return nil
+}
+
+// ParseAccounts registers every account in a SPOTIFY_ACCOUNTS list:
+// comma-separated names, each optionally followed by =<token file>.
+func ParseAccounts(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, file, _ := strings.Cut(entry, "=")
+		if err := AddAccount(name, strings.TrimSpace(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AccountNames returns the default account plus every named account,
+// sorted after it.
+func AccountNames() []string {
+	accountsMu.RLock()
+	defer accountsMu.RUnlock()
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultAccount}, names...)
+}
+
+// lookupAccount returns the named account. The default account isn't in
+// the map.
+func lookupAccount(name string) (*Account, bool) {
+	accountsMu.RLock()
+	defer accountsMu.RUnlock()
+	a, ok := accounts[name]
+	return a, ok
+}
+
+// accountExists reports whether `name` is the default or a named account.
+func accountExists(name string) bool {
+	if name == "" || name == DefaultAccount {
+		return true
+	}
+	_, ok := lookupAccount(name)
+	return ok
+}
+
+// TokenFileFor returns the token file of account `name`, or an error if
+// it isn't configured.
+func TokenFileFor(name string) (string, error) {
+	name = strings.ToLower(name)
+	if !accountExists(name) {
+		return "", fmt.Errorf("unknown account %q: add it to SPOTIFY_ACCOUNTS", name)
+	}
+	return accountTokenFile(name), nil
+}
+
+// accountTokenFile returns the token file of `name`.
+func accountTokenFile(name string) string {
+	if a, ok := lookupAccount(name); ok {
+		return a.TokenFile
+	}
+	return tokenFile
+}
+
+// SetAccountClient sets the client of `name`, wrapped like SetClient's;
+// the default account's is the one SetClient sets.
+func SetAccountClient(name string, client Client) error {
+	if name == "" || name == DefaultAccount {
+		SetClient(client)
+		return nil
+	}
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+	a, ok := accounts[name]
+	if !ok {
+		return fmt.Errorf("unknown account %q", name)
+	}
+	a.client = instrument(client, name)
+	return nil
+}
+
+// LoadAccountTokens loads each named account's saved token, logging the
+// ones that still need /auth?account=<name>.
+func LoadAccountTokens() {
+	for _, name := range AccountNames()[1:] {
+		a, _ := lookupAccount(name)
+		tok, err := readTokenFile(a.TokenFile)
+		if err != nil {
+			log.Printf("Account %s has no token yet. Visit /auth?account=%s to authenticate.", name, name)
+			continue
+		}
+		SetAccountClient(name, newClientFromTokenFile(tok, a.TokenFile))
+		Sayf("Loaded account: %s", name)
+	}
+}
+
+// AccountHistoryFile is the play-history file of account `name`, next to
+// the default account's `path`: .spotify_history.json becomes
+// .spotify_history.<name>.json.
+func AccountHistoryFile(path, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// OpenAccountHistories opens a play history for every named account,
+// beside the default account's at `path`. An account whose history can't
+// be opened goes without, as the default account would.
+func OpenAccountHistories(path string, halfLife time.Duration) {
+	for _, name := range AccountNames()[1:] {
+		db, err := OpenHistory(AccountHistoryFile(path, name), halfLife)
+		if err != nil {
+			log.Printf("Warning: play history for account %s disabled: %v", name, err)
+			continue
+		}
+		accountsMu.Lock()
+		accounts[name].history = db
+		accountsMu.Unlock()
+	}
+}
+
+// historyFor returns the play history and recorder of account `name`. The
+// history is nil when disabled.
+func historyFor(name string) (*HistoryDB, *historyRecorder) {
+	if name == "" || name == DefaultAccount {
+		return history, defaultHistoryRecorder
+	}
+	a, ok := lookupAccount(name)
+	if !ok {
+		return nil, &historyRecorder{}
+	}
+	return a.history, a.recorder
+}
+
+// DeviceAccount returns the account the settings file's device_accounts
+// assigns to device `ref`, or "". Entries may name the device by name,
+// Spotify ID, or stable ID; the device registry connects them.
+func DeviceAccount(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	if name := GetSettings().AccountForDevice(ref); name != "" {
+		return name
+	}
+
+	var recs []RegisteredDevice
+	if rec, ok := deviceRegistry.Lookup(ref); ok {
+		recs = append(recs, rec)
+	}
+	for _, rec := range deviceRegistry.List() {
+		if strings.EqualFold(rec.Name, ref) {
+			recs = append(recs, rec)
+		}
+	}
+	for _, rec := range recs {
+		for _, alias := range append([]string{rec.StableID, rec.Name, rec.SpotifyID}, rec.PreviousIDs...) {
+			if name := GetSettings().AccountForDevice(alias); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// routeByDevice returns ctx set to the account device_accounts assigns to
+// `device`, or to a group's primary, unless ctx already names an account.
+func routeByDevice(ctx context.Context, device string) context.Context {
+	if accountNamed(ctx) {
+		return ctx
+	}
+	if g, ok := GetSettings().FindGroup(device); ok {
+		device = g.primaryRef()
+	}
+	if name := DeviceAccount(device); name != "" {
+		return WithAccount(ctx, name)
+	}
+	return ctx
+}
+
+// accountNamed reports whether ctx names an account, even the default one.
+func accountNamed(ctx context.Context) bool {
+	_, ok := ctx.Value(accountKey{}).(string)
+	return ok
+}
+
+// accountKey is the context key for the selected account.
+type accountKey struct{}
+
+// WithAccount returns a context that selects account `name`. An empty
+// name leaves the default.
+func WithAccount(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, accountKey{}, strings.ToLower(name))
+}
+
+// AccountFrom returns the account `ctx` selects.
+func AccountFrom(ctx context.Context) string {
+	if name, ok := ctx.Value(accountKey{}).(string); ok && name != "" {
+		return name
+	}
+	return DefaultAccount
+}
+
+// clientFor returns the client of the account `ctx` selects, or an error
+// saying how to authenticate it.
+func clientFor(ctx context.Context) (Client, error) {
+	name := AccountFrom(ctx)
+	if name == DefaultAccount {
+		if spotifyClient == nil {
+			return nil, fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+		}
+		return spotifyClient, nil
+	}
+
+	accountsMu.RLock()
+	defer accountsMu.RUnlock()
+	a, ok := accounts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %q", name)
+	}
+	if a.client == nil {
+		return nil, fmt.Errorf("Spotify account %q not authenticated. Visit /auth?account=%s to authenticate", name, name)
+	}
+	return a.client, nil
+}
+
+// requestAccount finds the account a request names: the `account` query
+// parameter, the X-Spotify-Account header, or an "account" field in a JSON
+// POST body. The body is put back for the handler to read.
+func requestAccount(r *http.Request) string {
+	if name := r.URL.Query().Get("account"); name != "" {
+		return name
+	}
+	if name := r.Header.Get("X-Spotify-Account"); name != "" {
+		return name
+	}
+	if r.Method != http.MethodPost || r.Body == nil {
+		return ""
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes+1))
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	var body struct {
+		Account string `json:"account"`
+	}
+	// Malformed bodies are readParams' to report.
+	_ = json.Unmarshal(data, &body)
+	return body.Account
+}
+
+// withAccount puts the account a request names into its context, and
+// rejects names that aren't configured.
+func withAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.ToLower(requestAccount(r))
+		if !accountExists(name) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{Success: false, Error: fmt.Sprintf("unknown account %q", name), Code: CodeBadRequest})
+			return
+		}
+		next(w, r.WithContext(WithAccount(r.Context(), name)))
+	}
+}
@@ -0,0 +1,149 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Album playback. Albums are named by URI, link, ID, or by
+// the name of an album saved in your library, and play as a context the
+// same way playlists do.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// albumIDFromInput returns the album ID named by a URI, link, shortlink,
+// or bare ID. ok is false when the input isn't any of those and should be
+// looked up as a saved album's name.
+func albumIDFromInput(ctx context.Context, input string) (id string, ok bool, err error) {
+	r, err := spotifyuri.Resolve(ctx, shortlinkHTTPClient, input)
+	if errors.Is(err, spotifyuri.ErrUnrecognized) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if r.Type != spotifyuri.Album && r.Type != "" {
+		return "", false, fmt.Errorf("%q is a Spotify %s, not an album", input, r.Type)
+	}
+	return r.ID, true, nil
+}
+
+// savedAlbumsNamed pages through the user's saved albums and returns those
+// whose name matches `name`, case-insensitively, in library order.
+func savedAlbumsNamed(ctx context.Context, client Client, name string) ([]spotifyLib.SavedAlbum, error) {
+	var matches []spotifyLib.SavedAlbum
+	limit := 50
+	offset := 0
+
+	for {
+		page, err := client.CurrentUsersAlbums(ctx, spotifyLib.Limit(limit), spotifyLib.Offset(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get saved albums: %w", err)
+		}
+
+		for _, album := range page.Albums {
+			if strings.EqualFold(album.Name, name) {
+				matches = append(matches, album)
+			}
+		}
+
+		if len(page.Albums) < limit {
+			break
+		}
+		offset += limit
+	}
+	return matches, nil
+}
+
+// ResolveAlbumID resolves an album input (URI, link, ID, or the name of a
+// saved album) to an album ID. When several saved albums share the name
+// the first is used and a warning lists the others.
+func ResolveAlbumID(ctx context.Context, client Client, input string) (string, error) {
+	if id, ok, err := albumIDFromInput(ctx, input); err != nil || ok {
+		return id, err
+	}
+
+	matches, err := savedAlbumsNamed(ctx, client, input)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no album in your library is named %q", input)
+	}
+	if len(matches) > 1 {
+		warnf(ctx, "%d saved albums are named %q (%s); playing the first — pass a link or ID to pick another", len(matches), input, albumList(matches))
+	}
+	return string(matches[0].ID), nil
+}
+
+// albumList formats saved albums as "ID by Artist" for warnings.
+func albumList(albums []spotifyLib.SavedAlbum) string {
+	parts := make([]string, len(albums))
+	for i, a := range albums {
+		parts[i] = fmt.Sprintf("%s by %s", a.ID, albumArtists(a.SimpleAlbum))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// albumArtists joins an album's artist names.
+func albumArtists(album spotifyLib.SimpleAlbum) string {
+	names := make([]string, len(album.Artists))
+	for i, a := range album.Artists {
+		names[i] = a.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// playAlbum starts req's album on `targetDevice`, at the track the start
+// strategy picks.
+func playAlbum(ctx context.Context, client Client, req PlayRequest, strategy StartStrategy, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	albumID, err := ResolveAlbumID(ctx, client, req.Album)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve album: %w", err)
+	}
+
+	album, err := client.GetAlbum(ctx, spotifyLib.ID(albumID))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get album: %w", err)
+	}
+	trackCount := int(album.TotalTracks)
+	albumURI := spotifyLib.URI(spotifyuri.Resource{Type: spotifyuri.Album, ID: albumID}.URI())
+
+	position, err := strategy.Pick(ctx, client, albumID, trackCount)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to pick start track: %w", err)
+	}
+
+	err = client.PlayOpt(ctx, &spotifyLib.PlayOptions{
+		DeviceID:        &targetDevice.ID,
+		PlaybackContext: &albumURI,
+		PlaybackOffset:  &spotifyLib.PlaybackOffset{Position: &position},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	defaultStartHistory.record(albumID, position)
+	recordStart(ctx, string(albumURI), nil)
+	target := &playbackTarget{device: targetDevice, contextURI: albumURI}
+
+	title := fmt.Sprintf("\"%s\"", album.Name)
+	if artists := albumArtists(album.SimpleAlbum); artists != "" {
+		title += " by " + artists
+	}
+
+	if req.Shuffle {
+		enableShuffle(ctx, client)
+		return fmt.Sprintf("Now playing album %s on %s (shuffle enabled, starting at track %d of %d)",
+			title, targetDevice.Name, position+1, trackCount), target, nil
+	}
+	return fmt.Sprintf("Now playing album %s on %s (starting at track %d of %d)", title, targetDevice.Name, position+1, trackCount), target, nil
+}
@@ -0,0 +1,305 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Alexa custom skill endpoint. With ALEXA_SKILL_ID set,
+// /integrations/alexa answers the skill's requests, so "Alexa, tell house
+// music to play the dinner playlist" plays here. Each request is checked
+// the way Amazon requires of skills hosted outside Lambda: the signing
+// certificate chain is fetched from Amazon, verified, and used to check
+// the body's signature, the timestamp must be recent, and the skill ID
+// must match. The skill itself only needs a launch request and the
+// PlayIntent (playlist and device slots), PauseIntent, and the built-in
+// help and stop intents.
+//
+
+package spotify
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alexaSkillID is the skill's application ID; empty means the endpoint is
+// off.
+var alexaSkillID string
+
+// alexaMaxSkew is how far a request's timestamp may be from now.
+const alexaMaxSkew = 150 * time.Second
+
+// alexaCertSAN is the name Amazon's signing certificate must carry.
+const alexaCertSAN = "echo-api.amazon.com"
+
+// alexaFetchCert downloads a signing certificate chain; a variable so
+// tests can serve their own.
+var alexaFetchCert = func(ctx context.Context, certURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+}
+
+// alexaRoots are the roots a signing chain must lead to; nil means the
+// system's. A variable so tests can use their own CA.
+var alexaRoots *x509.CertPool
+
+// alexaCerts caches verified signing certificates by URL until they
+// expire.
+var alexaCerts = struct {
+	sync.Mutex
+	byURL map[string]*x509.Certificate
+}{byURL: map[string]*x509.Certificate{}}
+
+// EnableAlexa turns on /integrations/alexa for the skill with
+// application ID `skillID`.
+func EnableAlexa(skillID string) {
+	alexaSkillID = skillID
+}
+
+// alexaRequest is the part of a skill request the endpoint reads.
+type alexaRequest struct {
+	Context struct {
+		System struct {
+			Application struct {
+				ApplicationID string `json:"applicationId"`
+			} `json:"application"`
+		} `json:"System"`
+	} `json:"context"`
+	Request struct {
+		Type      string    `json:"type"`
+		Timestamp time.Time `json:"timestamp"`
+		Intent    struct {
+			Name  string `json:"name"`
+			Slots map[string]struct {
+				Value string `json:"value"`
+			} `json:"slots"`
+		} `json:"intent"`
+	} `json:"request"`
+}
+
+// AlexaResponse is a skill response: something to say, and whether the
+// session ends.
+type AlexaResponse struct {
+	Version  string `json:"version"`
+	Response struct {
+		OutputSpeech struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"outputSpeech"`
+		ShouldEndSession bool `json:"shouldEndSession"`
+	} `json:"response"`
+}
+
+// newAlexaResponse returns a response that says `text`.
+func newAlexaResponse(text string, endSession bool) AlexaResponse {
+	var resp AlexaResponse
+	resp.Version = "1.0"
+	resp.Response.OutputSpeech.Type = "PlainText"
+	resp.Response.OutputSpeech.Text = text
+	resp.Response.ShouldEndSession = endSession
+	return resp
+}
+
+// alexaHelp is said on launch and for help.
+const alexaHelp = "You can say play, then a playlist and a speaker, like play the dinner playlist on the kitchen. Or say pause."
+
+// alexaPlayPrompt asks for the playlist when a play didn't name one.
+const alexaPlayPrompt = "Which playlist should I play? Say play, then its name."
+
+// validAlexaCertURL reports whether `certURL` is where Amazon keeps its
+// signing certificates: https on s3.amazonaws.com, port 443, under
+// /echo.api/.
+func validAlexaCertURL(certURL string) bool {
+	u, err := url.Parse(certURL)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") || !strings.EqualFold(u.Hostname(), "s3.amazonaws.com") {
+		return false
+	}
+	if p := u.Port(); p != "" && p != "443" {
+		return false
+	}
+	return strings.HasPrefix(path.Clean(u.Path), "/echo.api/")
+}
+
+// alexaSigningCert returns the verified signing certificate at `certURL`.
+func alexaSigningCert(ctx context.Context, certURL string, now time.Time) (*x509.Certificate, error) {
+	if !validAlexaCertURL(certURL) {
+		return nil, fmt.Errorf("certificate URL %q isn't Amazon's", certURL)
+	}
+	alexaCerts.Lock()
+	cert := alexaCerts.byURL[certURL]
+	alexaCerts.Unlock()
+	if cert != nil && now.Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	data, err := alexaFetchCert(ctx, certURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch certificate: %w", err)
+	}
+	var chain []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		chain = append(chain, c)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate at %s", certURL)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		DNSName:       alexaCertSAN,
+		Roots:         alexaRoots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verify certificate: %w", err)
+	}
+
+	alexaCerts.Lock()
+	alexaCerts.byURL[certURL] = chain[0]
+	alexaCerts.Unlock()
+	return chain[0], nil
+}
+
+// verifyAlexaSignature checks the request's Signature-256 over `body`
+// with the certificate its SignatureCertChainUrl names.
+func verifyAlexaSignature(ctx context.Context, header http.Header, body []byte, now time.Time) error {
+	cert, err := alexaSigningCert(ctx, header.Get("SignatureCertChainUrl"), now)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certificate key isn't RSA")
+	}
+	sig, err := base64.StdEncoding.DecodeString(header.Get("Signature-256"))
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("missing or malformed Signature-256")
+	}
+	digest := sha256.Sum256(body)
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+// HandleAlexaRequest handles POST /integrations/alexa: a request from the
+// Alexa skill. It's only served with ALEXA_SKILL_ID set.
+func HandleAlexaRequest(w http.ResponseWriter, r *http.Request) {
+	skillID := alexaSkillID
+	if skillID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if err := verifyAlexaSignature(r.Context(), r.Header, body, now); err != nil {
+		log.Printf("Alexa: refusing a request: %v", err)
+		http.Error(w, "Bad request: invalid Alexa signature", http.StatusBadRequest)
+		return
+	}
+	var req alexaRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if skew := now.Sub(req.Request.Timestamp); skew > alexaMaxSkew || skew < -alexaMaxSkew {
+		http.Error(w, "Bad request: stale Alexa request", http.StatusBadRequest)
+		return
+	}
+	if req.Context.System.Application.ApplicationID != skillID {
+		http.Error(w, "Bad request: wrong skill", http.StatusBadRequest)
+		return
+	}
+
+	resp := alexaRespond(withPlaySource(r.Context(), "alexa"), &req)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// alexaRespond runs a verified skill request and returns what to say.
+func alexaRespond(ctx context.Context, req *alexaRequest) AlexaResponse {
+	switch req.Request.Type {
+	case "LaunchRequest":
+		return newAlexaResponse(alexaHelp, false)
+	case "IntentRequest":
+	default:
+		// SessionEndedRequest and anything newer get an empty answer.
+		return newAlexaResponse("", true)
+	}
+
+	intent := req.Request.Intent
+	slot := func(name string) string {
+		return strings.TrimSpace(intent.Slots[name].Value)
+	}
+	var msg string
+	var err error
+	switch intent.Name {
+	case "PlayIntent":
+		playlist, device := slot("playlist"), slot("device")
+		if playlist == "" {
+			// Keep the session open so the answer comes back as a PlayIntent.
+			return newAlexaResponse(alexaPlayPrompt, false)
+		}
+		if _, ok := GetSettings().FindPreset(playlist); ok && device == "" {
+			msg, err = PlayPreset(ctx, playlist)
+		} else {
+			msg, err = Play(ctx, PlayRequest{Playlist: playlist, Device: device})
+		}
+	case "PauseIntent", "AMAZON.PauseIntent":
+		if device := slot("device"); device != "" {
+			msg, err = PauseDevice(ctx, device)
+		} else {
+			msg, err = PausePlayback(ctx)
+		}
+	case "AMAZON.HelpIntent":
+		return newAlexaResponse(alexaHelp, false)
+	case "AMAZON.StopIntent", "AMAZON.CancelIntent", "AMAZON.NavigateHomeIntent":
+		return newAlexaResponse("Okay.", true)
+	default:
+		return newAlexaResponse("Sorry, I can't do that yet. "+alexaHelp, false)
+	}
+	if err != nil {
+		return newAlexaResponse("Sorry, that didn't work. "+err.Error(), true)
+	}
+	return newAlexaResponse(shortMessage(msg)+".", true)
+}
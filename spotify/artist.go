@@ -0,0 +1,113 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Artist playback. Artists are named by URI, link, ID, or
+// by name, looked up in Spotify's catalog, and play as an artist context:
+// Spotify starts with their popular tracks and carries on through their
+// catalog.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// artistSearchLimit is how many search results are checked for an exact
+// name match.
+const artistSearchLimit = 10
+
+// artistIDFromInput returns the artist ID named by a URI, link, shortlink,
+// or bare ID. ok is false when the input isn't any of those and should be
+// searched for by name.
+func artistIDFromInput(ctx context.Context, input string) (id string, ok bool, err error) {
+	r, err := spotifyuri.Resolve(ctx, shortlinkHTTPClient, input)
+	if errors.Is(err, spotifyuri.ErrUnrecognized) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if r.Type != spotifyuri.Artist && r.Type != "" {
+		return "", false, fmt.Errorf("%q is a Spotify %s, not an artist", input, r.Type)
+	}
+	return r.ID, true, nil
+}
+
+// ResolveArtist finds the artist `input` names. Names are searched for in
+// the catalog: the first result with exactly that name wins (search
+// results come most relevant first), else the top result, which the
+// caller should flag since it may be someone else.
+func ResolveArtist(ctx context.Context, client Client, input string) (*ResolvedArtist, error) {
+	artist := &ResolvedArtist{Input: input}
+
+	id, ok, err := artistIDFromInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		artist.ID, artist.MatchedBy = id, "uri"
+		if spotifyuri.IsID(strings.TrimSpace(input)) {
+			artist.MatchedBy = "id"
+		}
+		full, err := client.GetArtist(ctx, spotifyLib.ID(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get artist: %w", err)
+		}
+		artist.Name = full.Name
+	} else {
+		result, err := client.Search(ctx, input, spotifyLib.SearchTypeArtist, spotifyLib.Limit(artistSearchLimit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for artist: %w", err)
+		}
+		if result.Artists == nil || len(result.Artists.Artists) == 0 {
+			return nil, fmt.Errorf("no artist found for %q", input)
+		}
+		found := result.Artists.Artists[0]
+		artist.MatchedBy = "closest"
+		for _, a := range result.Artists.Artists {
+			if strings.EqualFold(a.Name, strings.TrimSpace(input)) {
+				found, artist.MatchedBy = a, "name"
+				break
+			}
+		}
+		artist.ID, artist.Name = string(found.ID), found.Name
+	}
+
+	artist.URI = spotifyuri.Resource{Type: spotifyuri.Artist, ID: artist.ID}.URI()
+	return artist, nil
+}
+
+// playArtist starts req's artist on `targetDevice`. Spotify doesn't take
+// a start offset for artist contexts, so it picks the first track.
+func playArtist(ctx context.Context, client Client, req PlayRequest, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	artist, err := ResolveArtist(ctx, client, req.Artist)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve artist: %w", err)
+	}
+	if artist.MatchedBy == "closest" {
+		warnf(ctx, "no artist is named exactly %q; playing the closest match, %s — pass a link to pick another", req.Artist, artist.Name)
+	}
+
+	artistURI := spotifyLib.URI(artist.URI)
+	err = client.PlayOpt(ctx, &spotifyLib.PlayOptions{DeviceID: &targetDevice.ID, PlaybackContext: &artistURI})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	recordStart(ctx, artist.URI, nil)
+	target := &playbackTarget{device: targetDevice, contextURI: artistURI}
+
+	if req.Shuffle {
+		enableShuffle(ctx, client)
+		return fmt.Sprintf("Now playing %s on %s (shuffle enabled)", artist.Name, targetDevice.Name), target, nil
+	}
+	return fmt.Sprintf("Now playing %s on %s", artist.Name, targetDevice.Name), target, nil
+}
@@ -0,0 +1,275 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Audiobook playback. Audiobooks are named by URI, link, ID,
+// or the name of one saved in your library, and resume where Spotify says
+// you left off: the first unfinished chapter, at its resume position. A
+// chapter link resumes that chapter instead.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// audiobookPageLimit is the page size for chapter and saved-audiobook
+// listings, Spotify's maximum.
+const audiobookPageLimit = 50
+
+// apiAudiobook is the part of the Web API's audiobook object we use. The
+// upstream library has no audiobook support, so these are fetched raw.
+type apiAudiobook struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	URI     string `json:"uri"`
+	Authors []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+}
+
+// authors joins the audiobook's author names.
+func (a apiAudiobook) authors() string {
+	names := make([]string, len(a.Authors))
+	for i, author := range a.Authors {
+		names[i] = author.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// apiChapter is the part of the Web API's chapter object we use. Audiobook
+// is only filled when the chapter is fetched on its own.
+type apiChapter struct {
+	ID            string                       `json:"id"`
+	Name          string                       `json:"name"`
+	URI           string                       `json:"uri"`
+	ChapterNumber int                          `json:"chapter_number"`
+	ResumePoint   spotifyLib.ResumePointObject `json:"resume_point"`
+	Audiobook     *apiAudiobook                `json:"audiobook,omitempty"`
+}
+
+// getSpotifyAPI GETs `path` under spotifyAPIBaseURL with the client's
+// access token and decodes the JSON answer into `result`. Error answers
+// come back as spotifyLib.Error, so DiagnoseLogin recognizes them.
+func getSpotifyAPI(ctx context.Context, client Client, path string, query url.Values, result any) error {
+	tok, err := client.Token()
+	if err != nil {
+		return fmt.Errorf("get access token: %w", err)
+	}
+
+	endpoint := spotifyAPIBaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var e struct {
+			Error spotifyLib.Error `json:"error"`
+		}
+		if json.Unmarshal(body, &e) != nil || e.Error.Message == "" {
+			e.Error = spotifyLib.Error{Message: strings.TrimSpace(string(body)), Status: resp.StatusCode}
+		}
+		e.Error.Status = resp.StatusCode
+		return e.Error
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// audiobookInput identifies an audiobook or chapter URI, link, shortlink,
+// or bare ID (taken as an audiobook). ok is false when the input isn't
+// any of those and should be looked up as a saved audiobook's name.
+func audiobookInput(ctx context.Context, input string) (r spotifyuri.Resource, ok bool, err error) {
+	r, err = spotifyuri.Resolve(ctx, shortlinkHTTPClient, input)
+	if errors.Is(err, spotifyuri.ErrUnrecognized) {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, err
+	}
+	switch r.Type {
+	case "":
+		r.Type = spotifyuri.Audiobook
+	case spotifyuri.Audiobook, spotifyuri.Chapter:
+	default:
+		return r, false, fmt.Errorf("%q is a Spotify %s, not an audiobook or chapter", input, r.Type)
+	}
+	return r, true, nil
+}
+
+// savedAudiobooksNamed pages through the user's saved audiobooks and
+// returns those whose name matches `name`, case-insensitively.
+func savedAudiobooksNamed(ctx context.Context, client Client, name string) ([]apiAudiobook, error) {
+	var matches []apiAudiobook
+	for offset := 0; ; offset += audiobookPageLimit {
+		var page struct {
+			Items []apiAudiobook `json:"items"`
+			Next  string         `json:"next"`
+		}
+		query := url.Values{"limit": {fmt.Sprint(audiobookPageLimit)}, "offset": {fmt.Sprint(offset)}}
+		if err := getSpotifyAPI(ctx, client, "me/audiobooks", query, &page); err != nil {
+			return nil, fmt.Errorf("failed to get saved audiobooks: %w", err)
+		}
+		for _, book := range page.Items {
+			if strings.EqualFold(book.Name, name) {
+				matches = append(matches, book)
+			}
+		}
+		if page.Next == "" {
+			return matches, nil
+		}
+	}
+}
+
+// resumeChapter pages through an audiobook's chapters and returns the
+// first one not fully played. ok is false when every chapter is finished.
+func resumeChapter(ctx context.Context, client Client, audiobookID string) (chapter apiChapter, ok bool, err error) {
+	for offset := 0; ; offset += audiobookPageLimit {
+		var page struct {
+			Items []apiChapter `json:"items"`
+			Next  string       `json:"next"`
+		}
+		query := url.Values{"limit": {fmt.Sprint(audiobookPageLimit)}, "offset": {fmt.Sprint(offset)}}
+		if err := getSpotifyAPI(ctx, client, "audiobooks/"+audiobookID+"/chapters", query, &page); err != nil {
+			return chapter, false, fmt.Errorf("failed to get chapters: %w", err)
+		}
+		for _, c := range page.Items {
+			if !c.ResumePoint.FullyPlayed {
+				return c, true, nil
+			}
+		}
+		if page.Next == "" {
+			return chapter, false, nil
+		}
+	}
+}
+
+// ResolveAudiobook finds the audiobook `input` names and where to resume
+// it. An audiobook resumes at its first unfinished chapter; a chapter
+// resumes itself. Either picks up at Spotify's resume position. A finished
+// audiobook starts over with a warning.
+func ResolveAudiobook(ctx context.Context, client Client, input string) (*ResolvedAudiobook, error) {
+	resolved := &ResolvedAudiobook{Input: input}
+
+	r, ok, err := audiobookInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var book apiAudiobook
+	switch {
+	case ok && r.Type == spotifyuri.Chapter:
+		var chapter apiChapter
+		if err := getSpotifyAPI(ctx, client, "chapters/"+r.ID, nil, &chapter); err != nil {
+			return nil, fmt.Errorf("failed to get chapter: %w", err)
+		}
+		if chapter.Audiobook == nil {
+			return nil, fmt.Errorf("chapter %s has no audiobook", r.ID)
+		}
+		book = *chapter.Audiobook
+		resolved.MatchedBy = "chapter"
+		resolved.setChapter(chapter)
+	case ok:
+		if err := getSpotifyAPI(ctx, client, "audiobooks/"+r.ID, nil, &book); err != nil {
+			return nil, fmt.Errorf("failed to get audiobook: %w", err)
+		}
+		resolved.MatchedBy = "uri"
+		if spotifyuri.IsID(strings.TrimSpace(input)) {
+			resolved.MatchedBy = "id"
+		}
+	default:
+		matches, err := savedAudiobooksNamed(ctx, client, input)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no audiobook in your library is named %q", input)
+		}
+		if len(matches) > 1 {
+			warnf(ctx, "%d saved audiobooks are named %q; playing the first, by %s — pass a link or ID to pick another", len(matches), input, matches[0].authors())
+		}
+		book = matches[0]
+		resolved.MatchedBy = "saved"
+	}
+	resolved.ID, resolved.URI, resolved.Name, resolved.Authors = book.ID, book.URI, book.Name, book.authors()
+
+	if resolved.ChapterURI == "" {
+		chapter, ok, err := resumeChapter(ctx, client, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resolved.setChapter(chapter)
+		} else {
+			warnf(ctx, "every chapter of %q is finished; starting from the beginning", book.Name)
+		}
+	}
+	return resolved, nil
+}
+
+// setChapter records the chapter to resume and its position. A finished
+// chapter starts from its beginning.
+func (a *ResolvedAudiobook) setChapter(c apiChapter) {
+	a.Chapter, a.ChapterURI, a.ChapterNumber = c.Name, c.URI, c.ChapterNumber
+	a.PositionMs = 0
+	if !c.ResumePoint.FullyPlayed {
+		a.PositionMs = int(c.ResumePoint.ResumePositionMs)
+	}
+}
+
+// playAudiobook starts req's audiobook on `targetDevice` where it left
+// off.
+func playAudiobook(ctx context.Context, client Client, req PlayRequest, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	book, err := ResolveAudiobook(ctx, client, req.Audiobook)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve audiobook: %w", err)
+	}
+
+	bookURI := spotifyLib.URI(book.URI)
+	opts := &spotifyLib.PlayOptions{DeviceID: &targetDevice.ID, PlaybackContext: &bookURI}
+	if book.ChapterURI != "" {
+		opts.PlaybackOffset = &spotifyLib.PlaybackOffset{URI: spotifyLib.URI(book.ChapterURI)}
+		opts.PositionMs = spotifyLib.Numeric(book.PositionMs)
+	}
+	if err := client.PlayOpt(ctx, opts); err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	recordStart(ctx, book.URI, nil)
+	target := &playbackTarget{device: targetDevice, contextURI: bookURI}
+
+	title := fmt.Sprintf("\"%s\"", book.Name)
+	if book.Authors != "" {
+		title += " by " + book.Authors
+	}
+	switch {
+	case book.ChapterURI == "":
+		return fmt.Sprintf("Now playing %s on %s (from the beginning)", title, targetDevice.Name), target, nil
+	case book.PositionMs > 0:
+		return fmt.Sprintf("Now playing %s on %s (chapter %d, resuming at %s)", title, targetDevice.Name, book.ChapterNumber, formatPosition(book.PositionMs)), target, nil
+	default:
+		return fmt.Sprintf("Now playing %s on %s (chapter %d)", title, targetDevice.Name, book.ChapterNumber), target, nil
+	}
+}
@@ -0,0 +1,134 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Track audio features (tempo, energy, danceability). They're
+// fetched the first time a track shows up in playback and then kept: in
+// memory for the life of the process, and on the track's play-history
+// record, so a track is asked about once rather than on every poll.
+//
+
+package spotify
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// featureRetryAfter is how long lookups stay off after Spotify refuses
+// one. Apps registered since late 2024 get a 403 for every track, and
+// there's no point asking on every track change.
+const featureRetryAfter = 10 * time.Minute
+
+// maxCachedFeatures bounds the in-memory cache; it starts over when full.
+const maxCachedFeatures = 5000
+
+// AudioFeatures are Spotify's audio analysis figures for a track. Energy
+// and Danceability run from 0 to 1; Tempo is in beats per minute.
+type AudioFeatures struct {
+	Tempo        float64 `json:"tempo"`
+	Energy       float64 `json:"energy"`
+	Danceability float64 `json:"danceability"`
+}
+
+// AudioFeatureCache remembers each track's features, including that
+// Spotify has none, and backs off after a failed lookup.
+type AudioFeatureCache struct {
+	mu sync.Mutex
+	// features maps a track URI to its features; nil means Spotify has
+	// none for it.
+	features map[string]*AudioFeatures
+	// failedAt is when the last lookup failed; zero once one succeeds.
+	failedAt time.Time
+}
+
+// audioFeatures is the process-wide feature cache.
+var audioFeatures = &AudioFeatureCache{}
+
+// lookup returns the cached features for `uri`, and whether there's an
+// entry at all.
+func (c *AudioFeatureCache) lookup(uri string) (*AudioFeatures, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.features[uri]
+	return f, ok
+}
+
+// store caches `f` for `uri`.
+func (c *AudioFeatureCache) store(uri string, f *AudioFeatures) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.features == nil || len(c.features) >= maxCachedFeatures {
+		c.features = map[string]*AudioFeatures{}
+	}
+	c.features[uri] = f
+}
+
+// backingOff reports whether a recent failure means not asking yet.
+func (c *AudioFeatureCache) backingOff(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.failedAt.IsZero() && now.Sub(c.failedAt) < featureRetryAfter
+}
+
+// noteResult records a lookup's outcome, logging the first failure of a
+// run of them.
+func (c *AudioFeatureCache) noteResult(err error, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.failedAt = time.Time{}
+		return
+	}
+	if c.failedAt.IsZero() {
+		log.Printf("audio features: %v (retrying in %s)", err, featureRetryAfter)
+	}
+	c.failedAt = now
+}
+
+// featuresFor returns the audio features of the track `uri`, from the
+// cache, then the play history of ctx's account, then Spotify. It returns
+// nil for episodes and anything else that isn't a track, for tracks
+// Spotify has no features for, and while lookups are failing.
+func featuresFor(ctx context.Context, client Client, uri string) *AudioFeatures {
+	r, err := spotifyuri.Parse(uri)
+	if err != nil || r.Type != spotifyuri.Track {
+		return nil
+	}
+	if f, ok := audioFeatures.lookup(uri); ok {
+		return f
+	}
+	if db, _ := historyFor(AccountFrom(ctx)); db != nil {
+		if rec, ok := db.Get(uri); ok && rec.Features != nil {
+			audioFeatures.store(uri, rec.Features)
+			return rec.Features
+		}
+	}
+
+	now := time.Now()
+	if audioFeatures.backingOff(now) {
+		return nil
+	}
+	list, err := client.GetAudioFeatures(ctx, spotifyLib.ID(r.ID))
+	audioFeatures.noteResult(err, now)
+	if err != nil {
+		return nil
+	}
+
+	var f *AudioFeatures
+	if len(list) > 0 && list[0] != nil {
+		f = &AudioFeatures{
+			Tempo:        float64(list[0].Tempo),
+			Energy:       float64(list[0].Energy),
+			Danceability: float64(list[0].Danceability),
+		}
+	}
+	audioFeatures.store(uri, f)
+	return f
+}
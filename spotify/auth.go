@@ -9,12 +9,20 @@
 package spotify
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	spotifyLib "github.com/zmb3/spotify/v2"
 	"golang.org/x/oauth2"
@@ -22,108 +30,444 @@ import (
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 )
 
+// authScopes are the OAuth scopes every sign-in requests.
+var authScopes = []string{
+	spotifyauth.ScopeUserReadPlaybackState,
+	spotifyauth.ScopeUserModifyPlaybackState,
+	spotifyauth.ScopeUserReadCurrentlyPlaying,
+	spotifyauth.ScopePlaylistReadPrivate,
+	spotifyauth.ScopePlaylistReadCollaborative,
+	// Needed to follow and unfollow playlists.
+	spotifyauth.ScopePlaylistModifyPublic,
+	spotifyauth.ScopePlaylistModifyPrivate,
+	// Needed to find saved albums by name.
+	spotifyauth.ScopeUserLibraryRead,
+	// Needed to resume audiobooks where they left off. The upstream
+	// library has no constant for it.
+	"user-read-playback-position",
+	// Streaming + email + private profile are required by the
+	// Spotify Connect eSDK on third-party speakers (e.g. WiiM)
+	// when we push our access token via the zeroconf addUser
+	// flow to claim a device for our account.
+	spotifyauth.ScopeStreaming,
+	spotifyauth.ScopeUserReadEmail,
+	spotifyauth.ScopeUserReadPrivate,
+}
+
 // InitAuth initializes the Spotify authenticator with the provided credentials.
 func InitAuth(clientID, clientSecret, redirectURI string) {
+	authRedirectURI = redirectURI
 	auth = spotifyauth.New(
 		spotifyauth.WithClientID(clientID),
 		spotifyauth.WithClientSecret(clientSecret),
 		spotifyauth.WithRedirectURL(redirectURI),
-		spotifyauth.WithScopes(
-			spotifyauth.ScopeUserReadPlaybackState,
-			spotifyauth.ScopeUserModifyPlaybackState,
-			spotifyauth.ScopeUserReadCurrentlyPlaying,
-			spotifyauth.ScopePlaylistReadPrivate,
-			spotifyauth.ScopePlaylistReadCollaborative,
-			// Streaming + email + private profile are required by the
-			// Spotify Connect eSDK on third-party speakers (e.g. WiiM)
-			// when we push our access token via the zeroconf addUser
-			// flow to claim a device for our account.
-			spotifyauth.ScopeStreaming,
-			spotifyauth.ScopeUserReadEmail,
-			spotifyauth.ScopeUserReadPrivate,
-		),
+		spotifyauth.WithScopes(authScopes...),
 	)
 }
 
+// DefaultAuthTimeout is how long the CLI waits for the user to finish
+// authenticating before giving up.
+const DefaultAuthTimeout = 5 * time.Minute
+
+// AuthOptions tunes how the CLI waits for authentication.
+type AuthOptions struct {
+	// Timeout bounds the wait; 0 waits until cancelled.
+	Timeout time.Duration
+	// NoBrowser skips the local callback listener: the running server's
+	// /auth URL is printed instead and the token file is polled until the
+	// server saves a new token to it.
+	NoBrowser bool
+	// Account is the account the server authenticates in NoBrowser mode.
+	Account string
+	// NoOpen only prints the consent URL instead of also opening it in
+	// the default browser.
+	NoOpen bool
+}
+
+// authOptions holds the CLI authentication options.
+var authOptions = AuthOptions{Timeout: DefaultAuthTimeout}
+
+// authRedirectURI is the redirect URI given to InitAuth; NoBrowser mode
+// derives the server's /auth URL from it.
+var authRedirectURI = DefaultRedirectURI
+
+// callbackAddr is where the CLI's temporary callback listener binds.
+var callbackAddr = ":8080"
+
+// tokenPollInterval is how often NoBrowser mode checks the token file.
+var tokenPollInterval = 2 * time.Second
+
+// SetAuthOptions sets how the CLI waits for authentication.
+func SetAuthOptions(opts AuthOptions) {
+	authOptions = opts
+}
+
 // Authenticate starts the OAuth flow and returns an authenticated Spotify client.
-// It starts a local HTTP server to handle the callback from Spotify.
-func Authenticate() *spotifyLib.Client {
-	http.HandleFunc("/callback", completeAuth)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+// It starts a temporary local HTTP server to handle the callback from Spotify
+// and shuts it down once a valid callback arrives, the wait times out or ctx
+// is cancelled (e.g. on Ctrl-C). Stray hits on the callback (stale browser
+// tabs, unknown state values, failed exchanges) are answered with an error
+// page and ignored rather than ending the wait. With AuthOptions.NoBrowser
+// no listener is started; see waitForTokenFile.
+func Authenticate(ctx context.Context) (*spotifyLib.Client, error) {
+	if authOptions.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, authOptions.Timeout)
+		defer cancel()
+	}
+
+	var client *spotifyLib.Client
+	var err error
+	if authOptions.NoBrowser {
+		client, err = waitForTokenFile(ctx)
+	} else {
+		client, err = authenticateWithCallback(ctx)
+	}
+
+	switch {
+	case err == nil:
+		return client, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, fmt.Errorf("timed out after %s waiting for authentication", authOptions.Timeout)
+	case errors.Is(err, context.Canceled):
+		return nil, errors.New("authentication cancelled")
+	}
+	return nil, err
+}
+
+// authenticateWithCallback runs the browser flow against a temporary
+// callback listener, which is always shut down before returning.
+func authenticateWithCallback(ctx context.Context) (*spotifyLib.Client, error) {
+	flowState, err := pendingAuthFlows.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(chan *spotifyLib.Client, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		completeAuth(w, r, clients)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("Got request for:", r.URL.String())
 	})
 
-	go func() {
-		err := http.ListenAndServe(":8080", nil)
+	// Listen up front so a taken port fails now instead of after the
+	// user has approved in the browser.
+	ln, err := net.Listen("tcp", callbackAddr)
+	if err != nil {
+		pendingAuthFlows.Complete(flowState)
+		return nil, fmt.Errorf("start callback server: %w (try -auth-manual or -no-browser)", err)
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+		// Shutdown misses the listener if Serve hasn't picked it up yet.
+		ln.Close()
+	}()
+
+	// The URL goes to the progress output even when quiet: without it
+	// there's no way to finish signing in.
+	url := auth.AuthURL(flowState)
+	fmt.Fprintf(progressOutput, "Please visit this URL to authenticate:\n%s\n", url)
+	if !authOptions.NoOpen {
+		if err := openBrowser(url); err != nil {
+			Warnf("couldn't open a browser (%v); open the URL above yourself", err)
+		}
+	}
+
+	// Wait for auth to complete; the deferred shutdown releases the port.
+	stop := startSpinner(ProgressWriter(), "Waiting for authentication")
+	defer stop()
+	select {
+	case client := <-clients:
+		return client, nil
+	case <-ctx.Done():
+		// Drop the state so a late callback can't complete the flow.
+		pendingAuthFlows.Complete(flowState)
+		return nil, ctx.Err()
+	}
+}
+
+// waitForTokenFile prints the running API server's /auth URL and polls the
+// token file until the server saves a token that differs from the one there
+// now. It's for machines where the CLI can't receive the callback itself,
+// e.g. over SSH, while the server is reachable from a browser.
+func waitForTokenFile(ctx context.Context) (*spotifyLib.Client, error) {
+	before, _ := readTokenFile(tokenFile)
+
+	fmt.Fprintf(progressOutput, "Open this URL of the running API server in a browser to authenticate:\n%s\n", serverAuthURL(authOptions.Account))
+	Sayf("Waiting for a new token in %s...", tokenFile)
+
+	stop := startSpinner(ProgressWriter(), "Waiting for the server")
+	defer stop()
+	ticker := time.NewTicker(tokenPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		// A missing or half-written file just means not yet.
+		tok, err := readTokenFile(tokenFile)
+		if err != nil || tok.AccessToken == "" {
+			continue
+		}
+		if before != nil && tok.AccessToken == before.AccessToken {
+			continue
+		}
+		return NewClientFromToken(tok), nil
+	}
+}
+
+// serverAuthURL returns the API server's /auth URL for account, on the
+// host and port of the redirect URI. The API token is filled in when this
+// process knows it, otherwise a placeholder is shown.
+func serverAuthURL(account string) string {
+	u, err := url.Parse(authRedirectURI)
+	if err != nil || u.Host == "" {
+		u, _ = url.Parse(DefaultRedirectURI)
+	}
+	u.Path = "/auth"
+	token := "<API_ACCESS_TOKEN>"
+	if t := GetAPIAccessToken(); t != "" {
+		token = url.QueryEscape(t)
+	}
+	u.RawQuery = "token=" + token
+	if account != "" && account != DefaultAccount {
+		u.RawQuery += "&account=" + url.QueryEscape(account)
+	}
+	return u.String()
+}
+
+// AuthenticateManual runs the OAuth flow without a local callback server,
+// for headless machines or when the callback port is taken. It prints the
+// auth URL to `out` and reads the URL the browser was redirected to (or
+// just its code) from `in`, prompting again on bad input, until the code
+// exchange succeeds or `in` runs dry.
+func AuthenticateManual(ctx context.Context, in io.Reader, out io.Writer) (*spotifyLib.Client, error) {
+	flowState, err := pendingAuthFlows.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(out, "Please visit this URL to authenticate:")
+	fmt.Fprintln(out, auth.AuthURL(flowState))
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "After you approve, the browser is sent to the redirect URL, which may not load.")
+	fmt.Fprintln(out, "Paste that full URL from the address bar (or just its code) here:")
+
+	lines := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !lines.Scan() {
+			if err := lines.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("no authorization code entered")
+		}
+
+		code, err := parseAuthRedirect(lines.Text(), flowState)
 		if err != nil {
-			log.Fatal(err)
+			fmt.Fprintf(out, "%v, try again\n", err)
+			continue
 		}
-	}()
 
-	url := auth.AuthURL(state)
-	fmt.Println("Please visit this URL to authenticate:")
-	fmt.Println(url)
+		tok, err := auth.Exchange(ctx, code)
+		if err != nil {
+			fmt.Fprintf(out, "Couldn't get token (%v). Codes work once: open the URL above again and paste the new one.\n", err)
+			continue
+		}
 
-	// Wait for auth to complete
-	client := <-ch
-	return client
+		pendingAuthFlows.Complete(flowState)
+		SaveToken(tok)
+		return NewClientFromToken(tok), nil
+	}
+}
+
+// parseAuthRedirect pulls the authorization code out of `input`: a pasted
+// redirect URL, its query string, or a bare code. A URL from another flow
+// than `state`, or one carrying Spotify's error, is refused.
+func parseAuthRedirect(input, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("nothing was pasted")
+	}
+	if !strings.ContainsAny(input, "?=&") {
+		return input, nil
+	}
+
+	query := input
+	if i := strings.Index(query, "?"); i >= 0 {
+		query = query[i+1:]
+	}
+	if i := strings.Index(query, "#"); i >= 0 {
+		query = query[:i]
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the pasted URL: %w", err)
+	}
+	if e := values.Get("error"); e != "" {
+		return "", fmt.Errorf("Spotify refused authorization: %s", e)
+	}
+	if st := values.Get("state"); st != "" && st != state {
+		return "", fmt.Errorf("the pasted URL belongs to a different authentication attempt")
+	}
+	code := values.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("the pasted URL has no code")
+	}
+	return code, nil
 }
 
 // completeAuth handles the OAuth callback from Spotify, exchanges the code
 // for a token, saves it for future use, and sends the client to the channel.
-func completeAuth(w http.ResponseWriter, r *http.Request) {
-	tok, err := auth.Token(r.Context(), state, r)
+// Callbacks that don't belong to a pending flow, or repeat one already
+// claimed or used, are rejected and logged.
+func completeAuth(w http.ResponseWriter, r *http.Request, clients chan<- *spotifyLib.Client) {
+	st := r.FormValue("state")
+	if err := pendingAuthFlows.Claim(st); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Printf("Ignoring OAuth callback with state %q: %v", st, err)
+		return
+	}
+
+	tok, err := auth.Token(r.Context(), st, r)
 	if err != nil {
+		pendingAuthFlows.Release(st)
 		http.Error(w, "Couldn't get token", http.StatusForbidden)
-		log.Fatal(err)
+		log.Printf("Warning: OAuth token exchange failed, still waiting: %v", err)
+		return
 	}
 
-	if st := r.FormValue("state"); st != state {
-		http.NotFound(w, r)
-		log.Fatalf("State mismatch: %s != %s\n", st, state)
+	if !pendingAuthFlows.Complete(st) {
+		http.Error(w, "Authentication request expired", http.StatusBadRequest)
+		return
 	}
 
 	// Save token for future use
 	SaveToken(tok)
 
-	client := spotifyLib.New(auth.Client(r.Context(), tok))
+	client := NewClientFromToken(tok)
 	fmt.Fprintf(w, "Authentication successful! You can close this window.")
-	ch <- client
+	clients <- client
 }
 
 // SaveToken saves the OAuth token to a file for reuse in future sessions.
 func SaveToken(token *oauth2.Token) {
-	file, err := os.Create(tokenFile)
+	saveTokenFile(tokenFile, token)
+}
+
+// saveTokenFile saves `token` to `path`, logging failures.
+func saveTokenFile(path string, token *oauth2.Token) {
+	// Keep the scopes we last saw granted when Spotify didn't repeat them.
+	scope, _ := token.Extra("scope").(string)
+	if scope == "" {
+		scope, _ = readTokenScope(path)
+	}
+
+	file, err := os.Create(path)
 	if err != nil {
 		log.Printf("Warning: Failed to save token: %v", err)
 		return
 	}
 	defer file.Close()
 
-	err = json.NewEncoder(file).Encode(token)
+	err = json.NewEncoder(file).Encode(savedToken{Token: token, Scope: scope})
 	if err != nil {
 		log.Printf("Warning: Failed to encode token: %v", err)
 	}
 }
 
+// Logout forgets the Spotify login of the account ctx selects, so the
+// machine can be handed to someone else or another account signed in: its
+// token file is overwritten with zeros and removed, and its client is
+// dropped. Spotify has no API to revoke a token. The access token keeps
+// working until it expires (within the hour), and removing the app at
+// spotify.com/account/apps revokes the refresh token.
+func Logout(ctx context.Context) (string, error) {
+	name := AccountFrom(ctx)
+	if !accountExists(name) {
+		return "", fmt.Errorf("unknown account %q", name)
+	}
+
+	SetAccountClient(name, nil)
+	if name == DefaultAccount {
+		setTokenStatus(TokenStatus{Error: "logged out", LastCheck: time.Now()})
+	}
+
+	removed, err := shredFile(accountTokenFile(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to delete token: %w", err)
+	}
+	if !removed {
+		return fmt.Sprintf("Logged out of account %s (no saved token)", name), nil
+	}
+	return fmt.Sprintf("Logged out of account %s", name), nil
+}
+
+// shredFile overwrites the file at `path` with zeros, syncs it, and
+// removes it, so the token isn't left readable in the freed blocks (best
+// effort: copy-on-write filesystems and SSDs may keep old blocks anyway).
+// It reports false when there was no file.
+func shredFile(path string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	info, err := f.Stat()
+	if err == nil {
+		_, err = f.Write(make([]byte, info.Size()))
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, os.Remove(path)
+}
+
 // LoadToken attempts to load a previously saved OAuth token from disk
 // and returns a Spotify client if the token is still valid.
 func LoadToken() (*spotifyLib.Client, error) {
-	file, err := os.Open(tokenFile)
+	token, err := readTokenFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read token file %s: %w", tokenFile, err)
+	}
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return nil, fmt.Errorf("read token file %s: %w", tokenFile, errEmptyToken)
+	}
+
+	// Build a client that refreshes early and persists refreshed tokens
+	return NewClientFromToken(token), nil
+}
+
+// readTokenFile decodes the token saved at `path`.
+func readTokenFile(path string) (*oauth2.Token, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
 	var token oauth2.Token
-	err = json.NewDecoder(file).Decode(&token)
-	if err != nil {
+	if err := json.NewDecoder(file).Decode(&token); err != nil {
 		return nil, err
 	}
-
-	// Create a new authenticator and client with the saved token
-	ctx := context.Background()
-	client := spotifyLib.New(auth.Client(ctx, &token))
-
-	return client, nil
+	return &token, nil
 }
@@ -0,0 +1,174 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Registry of pending OAuth flows keyed by their state value.
+// Each /auth hit (or CLI Authenticate call) gets its own state with an
+// expiry, so two people authenticating at once don't trample each other
+// and stray or stale callbacks can be told apart from real ones. A state
+// is claimed before its code is exchanged and remembered once used, so a
+// replayed or duplicated callback can't complete a flow twice.
+//
+
+package spotify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Errors returned by authFlows.Claim.
+var (
+	errAuthStateUnknown = errors.New("unknown or expired authentication request")
+	errAuthStateUsed    = errors.New("authentication request already used")
+)
+
+// authFlows tracks OAuth state values we've handed out and when they
+// expire. A state is valid from Begin until it is completed or expires.
+type authFlows struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	pending map[string]time.Time
+	// accounts maps a flow's state to the named account it authenticates;
+	// flows for the default account aren't listed.
+	accounts map[string]string
+	// claimed lists pending states whose code is being exchanged.
+	claimed map[string]bool
+	// used remembers completed states until they would have expired, so
+	// a replay is reported as such rather than as unknown.
+	used map[string]time.Time
+	now  func() time.Time
+}
+
+// newAuthFlows builds an empty registry whose states live for `ttl`.
+func newAuthFlows(ttl time.Duration) *authFlows {
+	return &authFlows{
+		ttl:      ttl,
+		pending:  make(map[string]time.Time),
+		accounts: make(map[string]string),
+		claimed:  make(map[string]bool),
+		used:     make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// pendingAuthFlows is the package-level registry shared by the CLI and
+// server OAuth paths. 10 minutes is plenty to click through Spotify's
+// consent page.
+var pendingAuthFlows = newAuthFlows(10 * time.Minute)
+
+// Begin starts a new flow for the default account and returns its state
+// value.
+func (f *authFlows) Begin() (string, error) {
+	return f.BeginFor("")
+}
+
+// BeginFor starts a new flow for `account` ("" for the default) and
+// returns its state value. Expired flows are pruned on the way in so the
+// map can't grow without bound.
+func (f *authFlows) BeginFor(account string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate oauth state: %w", err)
+	}
+	st := hex.EncodeToString(buf)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	for k, exp := range f.pending {
+		if now.After(exp) {
+			delete(f.pending, k)
+			delete(f.accounts, k)
+			delete(f.claimed, k)
+		}
+	}
+	for k, exp := range f.used {
+		if now.After(exp) {
+			delete(f.used, k)
+		}
+	}
+	f.pending[st] = now.Add(f.ttl)
+	if account != "" && account != DefaultAccount {
+		f.accounts[st] = account
+	}
+	return st, nil
+}
+
+// Valid reports whether `st` belongs to a pending, unexpired flow without
+// consuming it. Callbacks check this before the token exchange so a failed
+// exchange leaves the flow open for another try.
+func (f *authFlows) Valid(st string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exp, ok := f.pending[st]
+	return ok && !f.claimed[st] && !f.now().After(exp)
+}
+
+// Claim reserves `st` for one callback's token exchange. Only the first
+// caller gets nil; a duplicate delivery of the same callback, or a replay
+// after the flow completed, gets errAuthStateUsed. Follow with Complete,
+// or Release if the exchange failed so the flow can be retried.
+func (f *authFlows) Claim(st string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.used[st]; ok {
+		return errAuthStateUsed
+	}
+	exp, ok := f.pending[st]
+	if !ok || f.now().After(exp) {
+		return errAuthStateUnknown
+	}
+	if f.claimed[st] {
+		return errAuthStateUsed
+	}
+	f.claimed[st] = true
+	return nil
+}
+
+// Release gives up a Claim on `st` without completing it.
+func (f *authFlows) Release(st string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.claimed, st)
+}
+
+// Complete consumes `st`, returning true only for the first caller while
+// the flow is still unexpired.
+func (f *authFlows) Complete(st string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exp, ok := f.pending[st]
+	if !ok {
+		return false
+	}
+	delete(f.pending, st)
+	delete(f.accounts, st)
+	delete(f.claimed, st)
+	f.used[st] = exp
+	return !f.now().After(exp)
+}
+
+// Account returns the named account flow `st` authenticates, or "" for
+// the default account. Read it before Complete.
+func (f *authFlows) Account(st string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.accounts[st]
+}
+
+// Pending returns how many flows are currently outstanding.
+func (f *authFlows) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending)
+}
@@ -0,0 +1,92 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Opening the default browser and the waiting spinner used by
+// the CLI authentication flow.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// spinnerFrames are drawn in turn while the CLI waits for authentication.
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// spinnerInterval is how often the spinner redraws.
+var spinnerInterval = 100 * time.Millisecond
+
+// openBrowser opens url in the default browser. It's a variable so tests
+// can stub it out.
+var openBrowser = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener without waiting on the browser it launched.
+	go cmd.Wait()
+	return nil
+}
+
+// isTerminal reports whether f is an interactive terminal, where redrawing
+// a line with \r makes sense.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// startSpinner draws a spinner with the time elapsed since it started on
+// one line of w until the returned stop function is called, which clears
+// the line again. Nothing is drawn when w isn't a terminal, so piped output
+// and logs stay clean.
+func startSpinner(w io.Writer, message string) (stop func()) {
+	if f, ok := w.(*os.File); !ok || !isTerminal(f) {
+		return func() {}
+	}
+	return spin(w, message)
+}
+
+// spin runs the spinner for startSpinner on any writer.
+func spin(w io.Writer, message string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	start := time.Now()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(spinnerInterval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			elapsed := time.Since(start).Truncate(time.Second)
+			fmt.Fprintf(w, "\r%s %s (%s)", spinnerFrames[frame%len(spinnerFrames)], message, elapsed)
+			select {
+			case <-ctx.Done():
+				fmt.Fprint(w, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
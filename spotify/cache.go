@@ -0,0 +1,132 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: HTTP caching for read-only endpoints. Routes that declare a
+// cache class get a Cache-Control max-age from that class's TTL and a
+// strong ETag over the response body, and conditional requests that still
+// match get a 304. Browser dashboards and reverse proxies can then reuse
+// responses instead of re-asking us, and us Spotify.
+//
+
+package spotify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache classes. Each has its own TTL (see SetCacheTTL).
+const (
+	// CachePlaylists covers the playlist listing.
+	CachePlaylists = "playlists"
+	// CacheDevices covers the device lists and registry, which change
+	// whenever a speaker wakes or sleeps.
+	CacheDevices = "devices"
+	// CacheSpec covers the OpenAPI document, which only changes on deploy.
+	CacheSpec = "spec"
+)
+
+// cacheTTLs are the max-age values per class. Zero means clients must
+// revalidate every time, which the ETag still makes cheap.
+var cacheTTLs = map[string]time.Duration{
+	CachePlaylists: 60 * time.Second,
+	CacheDevices:   5 * time.Second,
+	CacheSpec:      5 * time.Minute,
+}
+
+// cacheShared marks cacheable responses public so shared caches (reverse
+// proxies) may store them. Off by default, since every response is behind
+// the API token.
+var cacheShared bool
+
+// SetCacheTTL sets the max-age for a cache class.
+func SetCacheTTL(class string, ttl time.Duration) error {
+	if _, ok := cacheTTLs[class]; !ok {
+		return fmt.Errorf("unknown cache class %q", class)
+	}
+	if ttl < 0 {
+		return fmt.Errorf("cache TTL for %s must not be negative", class)
+	}
+	cacheTTLs[class] = ttl
+	return nil
+}
+
+// SetCacheShared sets whether cacheable responses are marked public.
+func SetCacheShared(shared bool) {
+	cacheShared = shared
+}
+
+// cacheControl returns the Cache-Control value for `class`.
+func cacheControl(class string) string {
+	scope := "private"
+	if cacheShared {
+		scope = "public"
+	}
+	ttl := cacheTTLs[class]
+	if ttl <= 0 {
+		return scope + ", no-cache"
+	}
+	return scope + ", max-age=" + strconv.Itoa(int(ttl/time.Second))
+}
+
+// withCaching wraps a read-only handler with Cache-Control and ETag
+// handling for `class`. Only successful GETs are touched; errors and
+// other methods pass through unchanged.
+func withCaching(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		buf := &bufferedResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+		next(buf, r)
+
+		for k, v := range buf.header {
+			w.Header()[k] = v
+		}
+		if buf.statusCode != http.StatusOK {
+			w.WriteHeader(buf.statusCode)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl(class))
+		// The token and account decide what a response may contain.
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Token")
+		w.Header().Add("Vary", "X-Spotify-Account")
+
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.body.Bytes())
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches `etag`,
+// using the weak comparison RFC 9110 requires for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Chat commands shared by the Telegram bot and the Slack
+// slash command: `play jazz kitchen`, `pause`, `status`, `devices`, and the
+// rest, each calling the same functions as the HTTP API. The chat
+// integrations only differ in how a command arrives and how its reply is
+// formatted, which a chatStyle describes.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chatStyle is how one chat integration spells commands and formats
+// replies.
+type chatStyle struct {
+	// Prefix is written before a command in help, e.g. "/" or "/music ".
+	Prefix string
+	// Bold marks text up as bold; nil leaves it plain.
+	Bold func(string) string
+}
+
+// bold marks `s` up as bold in the style.
+func (cs chatStyle) bold(s string) string {
+	if cs.Bold == nil {
+		return s
+	}
+	return cs.Bold(s)
+}
+
+// chatCommands are the commands and their help, in the order help lists
+// them.
+var chatCommands = []struct{ usage, help string }{
+	{"play <playlist> [device]", "play a playlist, or a preset by name"},
+	{"pause [device]", "pause playback"},
+	{"next", "skip to the next track"},
+	{"volume <0-100> [device]", "set the volume"},
+	{"status", "what's playing"},
+	{"devices", "list Spotify Connect devices"},
+	{"presets", "list presets"},
+}
+
+// help lists the commands.
+func (cs chatStyle) help() string {
+	lines := []string{"Commands:"}
+	for _, c := range chatCommands {
+		lines = append(lines, fmt.Sprintf("%s%s - %s", cs.Prefix, c.usage, c.help))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runChatCommand runs `cmd` with `args` and returns the reply, errors
+// included.
+func runChatCommand(ctx context.Context, cs chatStyle, cmd, args string) string {
+	args = strings.TrimSpace(args)
+	var msg string
+	var err error
+	switch strings.ToLower(cmd) {
+	case "", "help", "start":
+		return cs.help()
+	case "play":
+		if args == "" {
+			return fmt.Sprintf("Usage: %s%s", cs.Prefix, chatCommands[0].usage)
+		}
+		msg, err = chatPlay(ctx, args)
+	case "pause":
+		if args == "" {
+			msg, err = PausePlayback(ctx)
+		} else {
+			msg, err = PauseDevice(ctx, args)
+		}
+	case "next":
+		msg, err = SkipToNext(ctx)
+	case "volume":
+		level, device, _ := strings.Cut(args, " ")
+		percent, perr := strconv.Atoi(strings.TrimSuffix(level, "%"))
+		if perr != nil {
+			return fmt.Sprintf("Usage: %svolume <0-100> [device]", cs.Prefix)
+		}
+		msg, err = SetVolume(ctx, percent, strings.TrimSpace(device))
+	case "status":
+		msg, err = chatStatus(ctx, cs)
+	case "devices":
+		msg, err = chatDevices(ctx, cs)
+	case "presets":
+		msg = chatPresets()
+	default:
+		return fmt.Sprintf("Unknown command %q.\n\n%s", cmd, cs.help())
+	}
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return msg
+}
+
+// chatPlay plays `args`: a preset's name, "<playlist> on <device>", or a
+// playlist followed by the name of a room, group, or device, as in
+// "jazz kitchen". The device has to be one of those, so "Songs on Repeat"
+// is a playlist. Anything else is a playlist on the default device.
+func chatPlay(ctx context.Context, args string) (string, error) {
+	if _, ok := GetSettings().FindPreset(args); ok {
+		return PlayPreset(ctx, args)
+	}
+	known := chatDeviceNames(ctx)
+	if i := strings.LastIndex(strings.ToLower(args), " on "); i > 0 {
+		if device := strings.TrimSpace(args[i+4:]); known[strings.ToLower(device)] {
+			return Play(ctx, PlayRequest{Playlist: strings.TrimSpace(args[:i]), Device: device})
+		}
+	}
+
+	words := strings.Fields(args)
+	// Longest trailing name first, so "living room" wins over "room"; the
+	// playlist keeps at least one word.
+	for n := len(words) - 1; n >= 1; n-- {
+		device := strings.Join(words[len(words)-n:], " ")
+		if known[strings.ToLower(device)] {
+			return Play(ctx, PlayRequest{Playlist: strings.Join(words[:len(words)-n], " "), Device: device})
+		}
+	}
+	return Play(ctx, PlayRequest{Playlist: args})
+}
+
+// chatDeviceNames returns the lower-cased names a play can target: rooms,
+// groups, and the devices visible now.
+func chatDeviceNames(ctx context.Context) map[string]bool {
+	names := map[string]bool{}
+	s := GetSettings()
+	for _, r := range s.Rooms {
+		names[strings.ToLower(r.Name)] = true
+	}
+	for _, g := range s.Groups {
+		names[strings.ToLower(g.Name)] = true
+	}
+	if devices, err := ListDevices(ctx); err == nil {
+		for _, d := range devices {
+			names[strings.ToLower(d.Name)] = true
+		}
+	}
+	return names
+}
+
+// chatStatus describes what's playing.
+func chatStatus(ctx context.Context, cs chatStyle) (string, error) {
+	resp, err := BuildContext(ctx, time.Now())
+	if err != nil {
+		return "", err
+	}
+	np := resp.NowPlaying
+	if np == nil || np.TrackName == "" {
+		return "Nothing is playing.", nil
+	}
+	state := "Playing"
+	if !np.IsPlaying {
+		state = "Paused"
+	}
+	msg := fmt.Sprintf("%s: %s", state, cs.bold(np.TrackName))
+	if np.Artists != "" {
+		msg += " by " + np.Artists
+	}
+	if np.DeviceName != "" {
+		msg += fmt.Sprintf("\non %s at %d%%", np.DeviceName, np.Volume)
+	}
+	return msg, nil
+}
+
+// chatDevices lists the Spotify Connect devices, marking the active one.
+func chatDevices(ctx context.Context, cs chatStyle) (string, error) {
+	devices, err := ListDevices(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(devices) == 0 {
+		return "No devices are visible right now.", nil
+	}
+	var sb strings.Builder
+	for _, d := range devices {
+		mark, name := "  ", d.Name
+		if d.Active {
+			mark, name = "▶ ", cs.bold(d.Name)
+		}
+		fmt.Fprintf(&sb, "%s%s (%s, %d%%)\n", mark, name, d.Type, d.Volume)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// chatPresets lists the presets by name.
+func chatPresets() string {
+	presets := presetSummaries()
+	if len(presets) == 0 {
+		return "No presets are configured."
+	}
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		names[i] = p.Name
+	}
+	return "Presets: " + strings.Join(names, ", ")
+}
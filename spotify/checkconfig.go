@@ -0,0 +1,211 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The -check-config deployment check. It goes further than
+// -doctor: besides asking Spotify about the app, it checks the
+// credentials look right before they're sent, that the settings and
+// token files parse, and that the default playlist and device resolve
+// the way a play would. Each check prints as a pass/fail line, so a typo
+// in .env shows up before the first play fails.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ConfigToCheck is the configuration -check-config checks, as read from
+// the environment.
+type ConfigToCheck struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	// Playlist and Device are SPOTIFY_PLAYLIST_ID and
+	// SPOTIFY_DEVICE_NAME; either may be empty.
+	Playlist string
+	Device   string
+}
+
+// configCheckClient loads the saved login's client; a variable so tests
+// can supply a mock.
+var configCheckClient = func() (Client, error) {
+	client, err := LoadToken()
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// CheckConfig checks `cfg` and the settings and token files, and resolves
+// the default playlist and device with the saved login.
+func CheckConfig(ctx context.Context, cfg ConfigToCheck) []PreflightCheck {
+	checks := []PreflightCheck{
+		checkAppCredential("client ID", "SPOTIFY_CLIENT_ID", cfg.ClientID),
+		checkAppCredential("client secret", "SPOTIFY_CLIENT_SECRET", cfg.ClientSecret),
+	}
+	if PreflightFailed(checks) {
+		redirect := PreflightCheck{Name: "redirect URI", Status: CheckOK, Detail: fmt.Sprintf("%s is in a form Spotify accepts; it can't be checked with Spotify until the credentials are set", cfg.RedirectURI)}
+		if problem := redirectURIProblem(cfg.RedirectURI); problem != "" {
+			redirect.Status, redirect.Detail = CheckFailed, problem
+		}
+		checks = append(checks, redirect)
+	} else {
+		checks = append(checks, ValidateAppConfig(ctx, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURI)...)
+	}
+	checks = append(checks, checkSettingsFile(GetSettingsFile()))
+
+	token := checkTokenFile(GetTokenFile())
+	checks = append(checks, token)
+	if token.Status != CheckOK {
+		skipped := "needs a working saved login"
+		return append(checks,
+			PreflightCheck{Name: "default playlist", Status: CheckUnknown, Detail: skipped},
+			PreflightCheck{Name: "default device", Status: CheckUnknown, Detail: skipped},
+		)
+	}
+	client, err := configCheckClient()
+	if err != nil {
+		token.Status, token.Detail = CheckFailed, err.Error()
+		checks[len(checks)-1] = token
+		return checks
+	}
+	// Playlist names are looked up through the process-wide client.
+	SetClient(client)
+	return append(checks, checkDefaultPlaylist(ctx, client, cfg.Playlist), checkDefaultDevice(ctx, client, cfg.Device))
+}
+
+// checkAppCredential checks a client ID or secret is set and looks like
+// one: 32 hex characters. Quotes or spaces copied into .env are the usual
+// reason it doesn't.
+func checkAppCredential(name, env, value string) PreflightCheck {
+	check := PreflightCheck{Name: name}
+	switch {
+	case value == "":
+		check.Status, check.Detail = CheckFailed, env+" isn't set"
+	case len(value) != 32 || strings.Trim(strings.ToLower(value), "0123456789abcdef") != "":
+		check.Status = CheckFailed
+		check.Detail = fmt.Sprintf("%s doesn't look like one from the Spotify developer dashboard (32 hex characters); check for quotes or spaces around it", env)
+	default:
+		check.Status, check.Detail = CheckOK, env+" is set"
+	}
+	return check
+}
+
+// checkSettingsFile checks the settings file parses. A missing one is
+// fine: nothing is configured yet.
+func checkSettingsFile(path string) PreflightCheck {
+	check := PreflightCheck{Name: "settings file"}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		check.Status, check.Detail = CheckOK, fmt.Sprintf("%s doesn't exist; no rooms, groups, or presets are configured", path)
+		return check
+	}
+	s, err := ReadSettingsFile(path)
+	if err != nil {
+		check.Status, check.Detail = CheckFailed, err.Error()
+		return check
+	}
+	check.Status = CheckOK
+	check.Detail = fmt.Sprintf("%s: %d rooms, %d groups, %d presets, %d schedules", path, len(s.Rooms), len(s.Groups), len(s.Presets), len(s.Schedules))
+	return check
+}
+
+// checkTokenFile checks the saved login parses, holds a token, and was
+// granted every scope.
+func checkTokenFile(path string) PreflightCheck {
+	check := PreflightCheck{Name: "token file"}
+	token, err := readTokenFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("no saved login at %s; run the CLI once (or with -auth-manual) to sign in", path)
+	case err != nil:
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("%s isn't a saved login: %v", path, err)
+	case token.AccessToken == "" && token.RefreshToken == "":
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("%s holds no token; sign in again", path)
+	default:
+		if missing := MissingScopes(path); len(missing) > 0 {
+			check.Status = CheckFailed
+			check.Detail = fmt.Sprintf("%s wasn't granted %s; run with -logout, then sign in again", path, strings.Join(missing, ", "))
+			return check
+		}
+		check.Status, check.Detail = CheckOK, fmt.Sprintf("%s parses and holds a login", path)
+	}
+	return check
+}
+
+// checkDefaultPlaylist resolves SPOTIFY_PLAYLIST_ID the way a play
+// would.
+func checkDefaultPlaylist(ctx context.Context, client Client, ref string) PreflightCheck {
+	check := PreflightCheck{Name: "default playlist"}
+	if ref == "" {
+		check.Status, check.Detail = CheckOK, "SPOTIFY_PLAYLIST_ID isn't set; every play has to name one"
+		return check
+	}
+
+	var pl *ResolvedPlaylist
+	var warnings []string
+	var err error
+	if IsLikedSongs(ref) {
+		pl, err = resolveLiked(ctx, client, ref)
+	} else {
+		pl, warnings, err = resolvePlaylist(ctx, client, PlayRequest{Playlist: ref})
+	}
+	if err != nil {
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("SPOTIFY_PLAYLIST_ID %q: %v", ref, err)
+		return check
+	}
+	check.Status = CheckOK
+	check.Detail = fmt.Sprintf("%q is %s (matched by %s)", ref, describeResolvedPlaylist(pl), pl.MatchedBy)
+	for _, w := range warnings {
+		if strings.HasPrefix(w, "playback would fail") {
+			check.Status = CheckFailed
+		}
+	}
+	if len(warnings) > 0 {
+		check.Detail += "; " + strings.Join(warnings, "; ")
+	}
+	return check
+}
+
+// describeResolvedPlaylist names a resolved playlist for a check line.
+func describeResolvedPlaylist(pl *ResolvedPlaylist) string {
+	name := pl.Name
+	if name == "" {
+		name = pl.URI
+	}
+	if pl.Tracks > 0 {
+		return fmt.Sprintf("%s, %d tracks", name, pl.Tracks)
+	}
+	return name
+}
+
+// checkDefaultDevice resolves SPOTIFY_DEVICE_NAME the way a play would.
+// A device that isn't linked right now can't be told apart from a typo,
+// so it's reported as unknown.
+func checkDefaultDevice(ctx context.Context, client Client, ref string) PreflightCheck {
+	check := PreflightCheck{Name: "default device"}
+	if ref == "" {
+		check.Status, check.Detail = CheckOK, "SPOTIFY_DEVICE_NAME isn't set; plays go to the active device"
+		return check
+	}
+	dev, warnings, err := resolveDevice(ctx, client, ref)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("SPOTIFY_DEVICE_NAME %q: %v", ref, err)
+	case dev.MatchedBy == "claim":
+		check.Status = CheckUnknown
+		check.Detail = fmt.Sprintf("%q isn't linked to your account right now; a play would try to claim it over zeroconf. Check the name if the speaker is on", ref)
+	default:
+		check.Status, check.Detail = CheckOK, fmt.Sprintf("%q is %s (%s, matched by %s)", ref, dev.Name, dev.Type, dev.MatchedBy)
+		if len(warnings) > 0 {
+			check.Detail += "; " + strings.Join(warnings, "; ")
+		}
+	}
+	return check
+}
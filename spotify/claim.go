@@ -40,8 +40,9 @@ type ClaimResult struct {
 // handshake fails, or the device never appears in the Spotify cloud
 // devices list within the wait window.
 func ClaimDevice(ctx context.Context, deviceName string) (*ClaimResult, error) {
-	if spotifyClient == nil {
-		return nil, fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Skip the full handshake if Spotify cloud already lists the device —
@@ -63,12 +64,12 @@ func ClaimDevice(ctx context.Context, deviceName string) (*ClaimResult, error) {
 		return nil, fmt.Errorf("device %q resolved with no IP address", deviceName)
 	}
 
-	user, err := spotifyClient.CurrentUser(ctx)
+	user, err := client.CurrentUser(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get current user: %w", err)
 	}
 
-	tok, err := spotifyClient.Token()
+	tok, err := client.Token()
 	if err != nil {
 		return nil, fmt.Errorf("get access token: %w", err)
 	}
@@ -99,7 +100,11 @@ func ClaimDevice(ctx context.Context, deviceName string) (*ClaimResult, error) {
 // either its friendly name or its hex device ID. Returns a copy of the
 // matched device and true on hit.
 func findCloudDevice(ctx context.Context, target string) (PlayerDeviceLite, bool) {
-	devices, err := spotifyClient.PlayerDevices(ctx)
+	client, err := clientFor(ctx)
+	if err != nil {
+		return PlayerDeviceLite{}, false
+	}
+	devices, err := client.PlayerDevices(ctx)
 	if err != nil {
 		return PlayerDeviceLite{}, false
 	}
@@ -124,9 +129,13 @@ type PlayerDeviceLite struct {
 // (preferred — guaranteed unique) or the friendly name (fallback for cases
 // where the deviceID Spotify reports differs from the local zeroconf ID).
 func waitForCloudRegistration(ctx context.Context, expectedID, friendlyName string, timeout time.Duration) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
 	deadline := time.Now().Add(timeout)
 	for {
-		devices, err := spotifyClient.PlayerDevices(ctx)
+		devices, err := client.PlayerDevices(ctx)
 		if err == nil {
 			for _, d := range devices {
 				if strings.EqualFold(string(d.ID), expectedID) ||
@@ -9,23 +9,42 @@
 package spotify
 
 import (
-	spotifyLib "github.com/zmb3/spotify/v2"
+	"sync"
 
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 )
 
 const (
-	DefaultRedirectURI = "http://127.0.0.1:8080/callback"
-	DefaultTokenFile   = ".spotify_token.json"
+	DefaultRedirectURI  = "http://127.0.0.1:8080/callback"
+	DefaultTokenFile    = ".spotify_token.json"
+	DefaultSettingsFile = ".spotify_settings.json"
 )
 
 var (
-	auth           *spotifyauth.Authenticator
-	ch             = make(chan *spotifyLib.Client)
-	state          = "spotify-shortcut-state"
+	auth *spotifyauth.Authenticator
+	// spotifyClient is the default account's client. Request code should
+	// use clientFor(ctx) so the account parameter is honored.
 	spotifyClient  Client
 	apiAccessToken string
 	tokenFile      string
+	settingsFile   = DefaultSettingsFile
+	settings       = &Settings{}
+
+	// legacyRoutesEnabled controls whether the frozen GET forms of
+	// /api/v1/play and /api/v1/pause are still served.
+	legacyRoutesEnabled = true
+
+	// apiAccessTokenMu guards apiAccessToken, which a reload can change
+	// while requests are being served.
+	apiAccessTokenMu sync.RWMutex
+
+	// settingsMu guards settings, which a reload or a device remap can
+	// swap while requests are being served.
+	settingsMu sync.RWMutex
+
+	// strictMetadataDefault controls whether Play fails when Spotify won't
+	// return a playlist's metadata, for requests that don't say.
+	strictMetadataDefault = true
 )
 
 // SetTokenFile sets the token file path.
@@ -38,19 +57,60 @@ func GetTokenFile() string {
 	return tokenFile
 }
 
+// SetSettingsFile sets the settings file path.
+func SetSettingsFile(path string) {
+	settingsFile = path
+}
+
+// GetSettingsFile returns the settings file path.
+func GetSettingsFile() string {
+	return settingsFile
+}
+
+// GetSettings returns the currently loaded settings. Treat them as
+// read-only; changes are made to a copy and swapped in with setSettings.
+func GetSettings() *Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}
+
+// setSettings swaps in `s` as the loaded settings.
+func setSettings(s *Settings) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settings = s
+}
+
+// SetLegacyRoutesEnabled turns the legacy GET /api/v1/play and
+// /api/v1/pause routes on or off.
+func SetLegacyRoutesEnabled(enabled bool) {
+	legacyRoutesEnabled = enabled
+}
+
+// SetStrictMetadata sets the default for PlayRequest.StrictMetadata.
+func SetStrictMetadata(strict bool) {
+	strictMetadataDefault = strict
+}
+
 // SetAPIAccessToken sets the API access token.
 func SetAPIAccessToken(token string) {
+	apiAccessTokenMu.Lock()
+	defer apiAccessTokenMu.Unlock()
 	apiAccessToken = token
 }
 
 // GetAPIAccessToken returns the API access token.
 func GetAPIAccessToken() string {
+	apiAccessTokenMu.RLock()
+	defer apiAccessTokenMu.RUnlock()
 	return apiAccessToken
 }
 
-// SetClient sets the Spotify client.
+// SetClient sets the default account's Spotify client, wrapped with
+// logging, metrics, and retries (see instrument.go).
 func SetClient(client Client) {
-	spotifyClient = client
+	spotifyClient = withFakeDevice(instrument(client, DefaultAccount))
 }
 
 // GetClient returns the Spotify client.
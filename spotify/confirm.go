@@ -0,0 +1,111 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Confirming that playback really started. Spotify answers a
+// play command as soon as it accepts it, and occasionally a speaker never
+// starts; polling the player state until the expected context is moving on
+// the expected device lets confirm=true requests report what happened.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// ErrPlaybackNotStarted is wrapped by waitUntilPlaying when the player
+// never reached the expected state.
+var ErrPlaybackNotStarted = errors.New("playback did not start")
+
+// confirmTimeout bounds how long a confirm=true play request waits.
+var confirmTimeout = 10 * time.Second
+
+// playingPollInterval is how often the player is polled while waiting for
+// playback to start.
+var playingPollInterval = 500 * time.Millisecond
+
+// playbackTarget is what startPlayback asked Spotify to play: the device,
+// and either the context (playlist) or, for track-list playback, the first
+// track.
+type playbackTarget struct {
+	device     *spotifyLib.PlayerDevice
+	contextURI spotifyLib.URI
+	trackURI   spotifyLib.URI
+	// lastTrackURI is the last of a track list, for on_end=fade-out.
+	lastTrackURI spotifyLib.URI
+	// fallback is set when the requested device couldn't be found and
+	// device was picked instead.
+	fallback bool
+	// via is the fallback chain entry that picked device, if the chain
+	// was used.
+	via string
+	// volumeSet is set when the request's volume was applied before
+	// playback started.
+	volumeSet bool
+}
+
+// matches reports whether `state` shows the target playing. A nil target
+// matches anything playing. The position has to be past zero: Spotify
+// reports is_playing as soon as it accepts a command, before any audio.
+func (t *playbackTarget) matches(state *spotifyLib.PlayerState) bool {
+	if state == nil || !state.Playing || state.Item == nil || state.Progress == 0 {
+		return false
+	}
+	if t == nil {
+		return true
+	}
+	if t.device != nil && state.Device.ID != t.device.ID {
+		return false
+	}
+	if t.contextURI != "" && state.PlaybackContext.URI != t.contextURI {
+		return false
+	}
+	if t.trackURI != "" && state.Item.URI != t.trackURI {
+		return false
+	}
+	return true
+}
+
+// describe names the target for error messages.
+func (t *playbackTarget) describe() string {
+	if t == nil || t.device == nil {
+		return ""
+	}
+	return " on " + t.device.Name
+}
+
+// waitUntilPlaying polls the player until `target` is playing and returns
+// the time elapsed since `started`. It gives up after `timeout` with an
+// error wrapping ErrPlaybackNotStarted.
+func waitUntilPlaying(ctx context.Context, started time.Time, timeout time.Duration, target *playbackTarget) (time.Duration, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(playingPollInterval)
+	defer ticker.Stop()
+
+	for {
+		state, err := client.PlayerState(ctx)
+		if err == nil && target.matches(state) {
+			return time.Since(started), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("%w%s within %s", ErrPlaybackNotStarted, target.describe(), timeout)
+		case <-ticker.C:
+		}
+	}
+}
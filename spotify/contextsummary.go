@@ -0,0 +1,104 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The combined context endpoint. GET /api/v1/context returns
+// what a voice assistant or dashboard needs to draw itself in one call:
+// what's playing, the devices, the presets, the volume schedules and
+// whether any is holding volumes down right now. A part that can't be
+// fetched is left out with a warning rather than failing the rest.
+//
+
+package spotify
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// PresetSummary is a preset as the context endpoint lists it: enough to
+// show it and play it by name.
+type PresetSummary struct {
+	Name     string `json:"name"`
+	Playlist string `json:"playlist,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Shuffle  bool   `json:"shuffle,omitempty"`
+	Account  string `json:"account,omitempty"`
+}
+
+// ScheduleState is a volume schedule and where it stands now. Cap is the
+// volume it allows at the moment, while Active.
+type ScheduleState struct {
+	VolumeSchedule
+	Active bool `json:"active"`
+	Cap    *int `json:"cap,omitempty"`
+}
+
+// QuietHours says whether any volume schedule is capping volumes now.
+// DeviceCap is the cap on the device playing now, if one applies.
+type QuietHours struct {
+	Active    bool `json:"active"`
+	DeviceCap *int `json:"device_cap,omitempty"`
+}
+
+// BuildContext gathers the context of ctx's account at `now`. It fails
+// only when there's no client at all; a failed Spotify call leaves its
+// part out and adds a warning.
+func BuildContext(ctx context.Context, now time.Time) (*ContextResponse, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ContextResponse{Success: true, Time: now, Devices: []DeviceInfo{}, Presets: presetSummaries(), Schedules: []ScheduleState{}}
+
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		resp.Warnings = append(resp.Warnings, "failed to get now playing: "+err.Error())
+	} else if state != nil && state.Item != nil {
+		ev := snapshotFromState(state).event(EventState, now)
+		resp.NowPlaying = &ev
+	}
+
+	devices, err := client.PlayerDevices(ctx)
+	if err != nil {
+		resp.Warnings = append(resp.Warnings, "failed to get devices: "+err.Error())
+	} else {
+		observeDevices(devices)
+		for _, d := range devices {
+			resp.Devices = append(resp.Devices, newDeviceInfo(d))
+		}
+	}
+
+	schedules, err := compileVolumeSchedules(GetSettings().VolumeSchedules)
+	if err != nil {
+		resp.Warnings = append(resp.Warnings, err.Error())
+		return resp, nil
+	}
+	for _, s := range schedules {
+		st := ScheduleState{VolumeSchedule: s.VolumeSchedule}
+		if v, ok := s.capAt(now); ok {
+			st.Active, st.Cap = true, &v
+			resp.QuietHours.Active = true
+		}
+		resp.Schedules = append(resp.Schedules, st)
+	}
+	if resp.NowPlaying != nil {
+		if v, ok := volumeCap(schedules, *resp.NowPlaying, now); ok {
+			resp.QuietHours.DeviceCap = &v
+		}
+	}
+	return resp, nil
+}
+
+// presetSummaries lists the settings file's presets by name.
+func presetSummaries() []PresetSummary {
+	presets := GetSettings().Presets
+	out := make([]PresetSummary, 0, len(presets))
+	for name, p := range presets {
+		out = append(out, PresetSummary{Name: name, Playlist: p.Playlist, Device: p.Device, Shuffle: p.Shuffle, Account: p.Account})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
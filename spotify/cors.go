@@ -0,0 +1,151 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: CORS for the API routes, so a browser-based remote on
+// another origin can call /api/v1/*. Off unless CORS_ALLOWED_ORIGINS is
+// set; preflight OPTIONS requests are answered here and never reach the
+// handlers.
+//
+
+package spotify
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Defaults for the optional CORS settings.
+const (
+	DefaultCORSMethods = "GET, POST, OPTIONS"
+	DefaultCORSHeaders = "Authorization, Content-Type, X-API-Token"
+	DefaultCORSMaxAge  = 600
+)
+
+// CORSConfig lists what cross-origin callers may do. An origin of "*"
+// allows any origin.
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// MaxAge is how long, in seconds, browsers may cache a preflight.
+	MaxAge int
+}
+
+// corsConfig is the active configuration; nil disables CORS.
+var corsConfig *CORSConfig
+
+// SetCORSConfig enables CORS with `cfg`, or disables it when nil.
+func SetCORSConfig(cfg *CORSConfig) {
+	corsConfig = cfg
+}
+
+// NewCORSConfig builds a config from comma-separated lists, as found in
+// CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS, and CORS_ALLOWED_HEADERS.
+// Empty methods or headers fall back to the defaults; a non-positive
+// maxAge uses DefaultCORSMaxAge.
+func NewCORSConfig(origins, methods, headers string, maxAge int) *CORSConfig {
+	if methods == "" {
+		methods = DefaultCORSMethods
+	}
+	if headers == "" {
+		headers = DefaultCORSHeaders
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultCORSMaxAge
+	}
+
+	cfg := &CORSConfig{
+		AllowedMethods: splitList(methods),
+		AllowedHeaders: splitList(headers),
+		MaxAge:         maxAge,
+	}
+	for _, o := range splitList(origins) {
+		cfg.AllowedOrigins = append(cfg.AllowedOrigins, strings.TrimSuffix(o, "/"))
+	}
+	for i, m := range cfg.AllowedMethods {
+		cfg.AllowedMethods[i] = strings.ToUpper(m)
+	}
+	return cfg
+}
+
+// splitList splits a comma-separated list, trimming blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for `origin`,
+// or "" if it isn't allowed.
+func (c *CORSConfig) allowOrigin(origin string) string {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return "*"
+		}
+		if strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// allowsMethod reports whether `method` is in the allowed list.
+func (c *CORSConfig) allowsMethod(method string) bool {
+	for _, m := range c.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// isCORSPath reports whether CORS applies to `path`: the API and the
+// health probe, not the OAuth pages.
+func isCORSPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/healthz"
+}
+
+// corsMiddleware adds CORS headers to API responses for allowed origins
+// and answers preflight requests: 204 when the origin and method are
+// allowed, 403 otherwise. Requests without an Origin header, or for
+// non-API paths, pass through untouched.
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := corsConfig
+		origin := r.Header.Get("Origin")
+		if cfg == nil || origin == "" || !isCORSPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := cfg.allowOrigin(origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if allowed == "" || !cfg.allowsMethod(r.Header.Get("Access-Control-Request-Method")) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
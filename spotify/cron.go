@@ -0,0 +1,380 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Cron expressions for the scheduler. The usual five fields
+// (minute, hour, day of month, month, day of week) with `*`, lists,
+// ranges, and steps, plus month and weekday names, evaluated in local
+// time: "30 6 * * mon-fri" is 6:30 every weekday morning. Schedules can
+// also say it the friendly way, "weekdays at 6:30", which is turned into
+// the same thing, or tie the time to the sun: "daily at sunset" (see
+// solar.go).
+//
+
+package spotify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is one field's allowed range and names.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names[i] stands for min+i
+}
+
+// cronFields are the five fields in order. Sunday is 0 or 7.
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// cronSpec is a parsed expression: the values each field allows. When
+// both day fields are restricted a day matching either runs, as in cron.
+type cronSpec struct {
+	minute, hour, dom, month, dow [60]bool
+	domAny, dowAny                bool
+	// solar, when set, replaces the minute and hour fields with a time
+	// tied to sunrise or sunset.
+	solar *solarTime
+}
+
+// parseCron parses a five-field cron expression.
+func parseCron(expr string) (cronSpec, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return cronSpec{}, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", expr, len(parts))
+	}
+
+	var spec cronSpec
+	sets := []*[60]bool{&spec.minute, &spec.hour, &spec.dom, &spec.month, &spec.dow}
+	for i, part := range parts {
+		if err := cronFields[i].parse(strings.ToLower(part), sets[i]); err != nil {
+			return cronSpec{}, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	if spec.dow[7] {
+		spec.dow[0] = true
+	}
+	spec.domAny, spec.dowAny = parts[2] == "*", parts[4] == "*"
+	return spec, nil
+}
+
+// parse sets the values `s` allows: comma-separated items, each `*`, a
+// value, or a range, optionally with a /step.
+func (f cronField) parse(s string, set *[60]bool) error {
+	for _, item := range strings.Split(s, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return fmt.Errorf("%s: bad step %q", f.name, stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(to); err != nil {
+					return err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return fmt.Errorf("%s: range %q runs backwards", f.name, rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// value parses one number or name in the field.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s: %q isn't between %d and %d", f.name, s, f.min, f.max)
+	}
+	return n, nil
+}
+
+// dayMatches reports whether the day of `t` is allowed.
+func (c cronSpec) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// matches reports whether the minute `t` falls in runs.
+func (c cronSpec) matches(t time.Time) bool {
+	return c.minute[t.Minute()] && c.hour[t.Hour()] && c.month[int(t.Month())] && c.dayMatches(t)
+}
+
+// next returns when a schedule on this expression next runs after
+// `after`, in after's zone, treating clock changes as dueAt does. An
+// expression that never matches (February 30th) reports false.
+func (c cronSpec) next(after time.Time) (time.Time, bool) {
+	if c.solar != nil {
+		return c.solarNext(after)
+	}
+	loc := after.Location()
+	wall := wallClock(after)
+	for {
+		var ok bool
+		if wall, ok = c.nextWall(wall); !ok {
+			return time.Time{}, false
+		}
+		t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+		if got := wallClock(t); !got.Equal(wall) {
+			// Skipped when the clocks went forward: it runs as they jump,
+			// whichever side of the jump time.Date put it.
+			start, end := t.ZoneBounds()
+			if t = start; got.Before(wall) {
+				t = end
+			}
+		}
+		// A time repeated when the clocks went back has already run.
+		if t.After(after) {
+			return t, true
+		}
+	}
+}
+
+// nextWall returns the first wall-clock minute after `after` that
+// matches, skipping whole months, days, and hours that can't.
+func (c cronSpec) nextWall(after time.Time) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !c.month[int(m)]:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dayGroups are the day names a human-friendly schedule understands
+// besides the days themselves.
+var dayGroups = map[string]string{
+	"daily":     "sun-sat",
+	"everyday":  "sun-sat",
+	"every day": "sun-sat",
+	"weekdays":  "mon-fri",
+	"weekday":   "mon-fri",
+	"weekends":  "sat,sun",
+	"weekend":   "sat,sun",
+}
+
+// parseDays parses a day list like "weekdays", "sat,sun", "mon-wed,fri",
+// or "friday". Ranges may wrap past Saturday ("fri-mon").
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	s = strings.ToLower(strings.TrimSpace(s))
+	if group, ok := dayGroups[s]; ok {
+		s = group
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if group, ok := dayGroups[item]; ok {
+			d, _ := parseDays(group)
+			for i := range days {
+				days[i] = days[i] || d[i]
+			}
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		lo, err := dayNumber(from)
+		if err != nil {
+			return days, err
+		}
+		hi := lo
+		if isRange {
+			if hi, err = dayNumber(to); err != nil {
+				return days, err
+			}
+		}
+		for d := lo; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == hi {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// dayNumber parses a day name, short ("mon") or long ("monday"), to its
+// weekday number.
+func dayNumber(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 3 {
+		for i := time.Sunday; i <= time.Saturday; i++ {
+			if name := strings.ToLower(i.String()); strings.HasPrefix(name, s) {
+				return int(i), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", s)
+}
+
+// parseWhen turns a human-friendly schedule like "weekdays at 6:30",
+// "sat,sun 9am", or "7:15pm" (every day) into a cron expression: the
+// days, if any, then an optional "at", then the time.
+func parseWhen(when string) (string, error) {
+	days, clock := splitWhen(when)
+	hour, minute, err := parseTimeOfDay(clock)
+	if err != nil {
+		return "", fmt.Errorf("schedule %q: %w", when, err)
+	}
+	dow, err := whenDays(when, days)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d %d %s", minute, hour, dow), nil
+}
+
+// compileWhen parses a `when` schedule. Clock times go through
+// parseWhen; sunrise and sunset, like "weekdays at sunset-15m", are
+// worked out for `at`, which must be set.
+func compileWhen(when string, at *geoPoint) (cronSpec, error) {
+	days, clock := splitWhen(when)
+	event, offset, ok, err := parseSolarTime(clock)
+	if err != nil {
+		return cronSpec{}, fmt.Errorf("schedule %q: %w", when, err)
+	}
+	if !ok {
+		expr, err := parseWhen(when)
+		if err != nil {
+			return cronSpec{}, err
+		}
+		return parseCron(expr)
+	}
+	if at == nil {
+		return cronSpec{}, fmt.Errorf("schedule %q: %s needs SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE", when, event)
+	}
+	dow, err := whenDays(when, days)
+	if err != nil {
+		return cronSpec{}, err
+	}
+	spec, err := parseCron("0 0 " + dow)
+	if err != nil {
+		return cronSpec{}, err
+	}
+	spec.solar = &solarTime{event: event, offset: offset, at: *at}
+	return spec, nil
+}
+
+// splitWhen splits a `when` schedule into its days ("daily" if it names
+// none) and its time.
+func splitWhen(when string) (days, clock string) {
+	s := strings.ToLower(strings.Join(strings.Fields(when), " "))
+	cut := strings.LastIndex(s, " ")
+	days, clock = "daily", s
+	if cut >= 0 {
+		days, clock = strings.TrimSuffix(strings.TrimSpace(s[:cut]), " at"), s[cut+1:]
+		if days == "at" || days == "" {
+			days = "daily"
+		}
+	}
+	return days, clock
+}
+
+// whenDays turns a `when` schedule's days into the last three cron
+// fields.
+func whenDays(when, days string) (string, error) {
+	set, err := parseDays(days)
+	if err != nil {
+		return "", fmt.Errorf("schedule %q: %w", when, err)
+	}
+	var names []string
+	for d, on := range set {
+		if on {
+			names = append(names, strconv.Itoa(d))
+		}
+	}
+	return "* * " + strings.Join(names, ","), nil
+}
+
+// parseTimeOfDay parses a time of day: 24-hour ("6:30", "18:00") or
+// 12-hour ("6:30am", "7pm").
+func parseTimeOfDay(s string) (hour, minute int, err error) {
+	for _, layout := range []string{"15:04", "3:04pm", "3pm"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Hour(), t.Minute(), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("time %q isn't like 6:30, 18:00, 7pm, or sunset", s)
+}
+
+// wallClock is `t`'s local date and time as written on a clock, with no
+// zone, so minutes can be counted across a DST change.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// dueAt reports whether a schedule on this expression in `loc` runs in
+// the minute starting at `at`, given it last ran at `last` (zero if
+// never). Clock changes are handled the way people expect an alarm to
+// behave: a time skipped when the clocks go forward runs at the first
+// minute after the jump, and a time repeated when they go back runs
+// only the first time.
+func (c cronSpec) dueAt(at time.Time, loc *time.Location, last time.Time) bool {
+	// The sun's times are instants, never skipped or repeated.
+	if c.solar != nil {
+		return c.solarDue(at, loc)
+	}
+	local := at.In(loc)
+	wall := wallClock(local)
+	if c.matches(local) {
+		return last.IsZero() || !wallClock(last.In(loc)).Equal(wall)
+	}
+	// Any wall-clock minutes between the previous minute and this one
+	// were skipped.
+	for skipped := wallClock(at.Add(-time.Minute).In(loc)).Add(time.Minute); skipped.Before(wall); skipped = skipped.Add(time.Minute) {
+		if c.matches(skipped) {
+			return true
+		}
+	}
+	return false
+}
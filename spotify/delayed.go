@@ -0,0 +1,141 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: One-shot delayed plays. `/api/v1/play?delay=20m` checks the
+// request now and plays it once the delay is up, returning a job ID that
+// `DELETE /api/v1/jobs/{id}` cancels until then — "start the party
+// playlist right when guests arrive". Jobs live in memory and don't
+// survive a restart; recurring plays belong in the scheduler.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delays must fall between minDelay and maxDelay.
+const (
+	minDelay = time.Second
+	maxDelay = 24 * time.Hour
+)
+
+// DelayedPlay is a play waiting for its time.
+type DelayedPlay struct {
+	ID      string           `json:"id"`
+	At      time.Time        `json:"at"`
+	Account string           `json:"account"`
+	Request EffectiveRequest `json:"request"`
+}
+
+// delayedPlay is a pending job with its timer.
+type delayedPlay struct {
+	DelayedPlay
+	timer *time.Timer
+}
+
+// DelayedPlays holds the pending delayed plays.
+type DelayedPlays struct {
+	mu   sync.Mutex
+	jobs map[string]*delayedPlay
+	// play starts a job's request; a field so tests can watch.
+	play func(ctx context.Context, req PlayRequest) (string, error)
+}
+
+// delayedPlays is the process-wide set of pending delayed plays.
+var delayedPlays = &DelayedPlays{play: Play}
+
+// ParseDelay parses a delay like "20m" or "1h30m".
+func ParseDelay(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay %q (use a value like 20m)", s)
+	}
+	if d < minDelay || d > maxDelay {
+		return 0, fmt.Errorf("delay must be between %s and %s, got %s", formatDuration(minDelay), formatDuration(maxDelay), formatDuration(d))
+	}
+	return d, nil
+}
+
+// PlayLater plays `req` on ctx's account once `delay` has passed and
+// returns the pending job. The request should already be validated;
+// anything that only shows up at play time (a missing device) is logged
+// when the job runs.
+func PlayLater(ctx context.Context, req PlayRequest, delay time.Duration) (DelayedPlay, error) {
+	return delayedPlays.add(AccountFrom(ctx), req, delay)
+}
+
+// add creates and starts a job playing `req` on `account` after `delay`.
+func (d *DelayedPlays) add(account string, req PlayRequest, delay time.Duration) (DelayedPlay, error) {
+	id, err := randomID("job-")
+	if err != nil {
+		return DelayedPlay{}, err
+	}
+	job := &delayedPlay{DelayedPlay: DelayedPlay{
+		ID:      id,
+		At:      time.Now().Add(delay).Truncate(time.Second),
+		Account: account,
+		Request: effectiveRequest(req),
+	}}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.jobs == nil {
+		d.jobs = map[string]*delayedPlay{}
+	}
+	d.jobs[id] = job
+	job.timer = time.AfterFunc(delay, func() { d.run(id, req) })
+	return job.DelayedPlay, nil
+}
+
+// run plays job `id`'s request unless it was cancelled.
+func (d *DelayedPlays) run(id string, req PlayRequest) {
+	d.mu.Lock()
+	job := d.jobs[id]
+	delete(d.jobs, id)
+	d.mu.Unlock()
+	if job == nil {
+		return
+	}
+
+	msg, err := d.play(withPlaySource(WithAccount(context.Background(), job.Account), "job "+id), req)
+	if err != nil {
+		log.Printf("delayed play %s (%s): %v", id, job.Account, err)
+		return
+	}
+	log.Printf("delayed play %s (%s): %s", id, job.Account, msg)
+}
+
+// List returns the pending jobs, soonest first.
+func (d *DelayedPlays) List() []DelayedPlay {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DelayedPlay, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		out = append(out, job.DelayedPlay)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out
+}
+
+// Cancel stops job `id` before it plays. A job that's unknown or has
+// already played is a CodeNotFound error.
+func (d *DelayedPlays) Cancel(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job := d.jobs[id]
+	if job == nil {
+		return withCode(CodeNotFound, fmt.Errorf("no pending job %s", id))
+	}
+	job.timer.Stop()
+	delete(d.jobs, id)
+	return nil
+}
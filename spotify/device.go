@@ -10,7 +10,7 @@ package spotify
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -18,24 +18,21 @@ import (
 	spotifyLib "github.com/zmb3/spotify/v2"
 )
 
-// PrintDevicesTable displays available Spotify devices in a formatted table
-// with colors to indicate active status.
-func PrintDevicesTable(devices []spotifyLib.PlayerDevice) {
+// PrintDevicesTable displays the available Spotify devices that pass
+// `filter` in a formatted table with colors to indicate active status.
+func PrintDevicesTable(all []spotifyLib.PlayerDevice, filter DeviceFilter) {
+	devices := filter.Apply(all)
 	green := color.New(color.FgGreen, color.Bold)
-	cyan := color.New(color.FgCyan)
 
-	fmt.Println()
-	cyan.Println("🎵 Available Spotify Connect Devices")
-	fmt.Println()
+	printHeading("🎵", "Available Spotify Connect Devices")
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"#", "Name", "Type", "Status", "Device ID"})
+	t := newTable()
+	t.AppendHeader(table.Row{"#", "Name", "Type", "Status", "Groups", "Device ID"})
 
 	for i, device := range devices {
 		status := "Inactive"
 		if device.Active {
-			status = color.GreenString("● Active")
+			status = color.GreenString(symbol("●", "*") + " Active")
 		}
 
 		t.AppendRow(table.Row{
@@ -43,13 +40,48 @@ func PrintDevicesTable(devices []spotifyLib.PlayerDevice) {
 			color.New(color.Bold).Sprint(device.Name),
 			device.Type,
 			status,
+			strings.Join(groupsOf(device), ", "),
 			color.HiBlackString(string(device.ID)),
 		})
 	}
 
-	t.SetStyle(table.StyleRounded)
 	t.Render()
 
 	fmt.Println()
-	green.Printf("Total devices: %d\n", len(devices))
+	if len(devices) < len(all) {
+		green.Printf("Total devices: %d (of %d)\n", len(devices), len(all))
+	} else {
+		green.Printf("Total devices: %d\n", len(devices))
+	}
+
+	// Groups are checked against every device, so members filtered out
+	// of the table don't show as offline.
+	if len(GetSettings().Groups) > 0 {
+		printGroupsTable(all)
+	}
+}
+
+// printGroupsTable lists the device groups with their members, marking the
+// ones in `devices` and the primary a play would use.
+func printGroupsTable(devices []spotifyLib.PlayerDevice) {
+	printHeading("🔊", "Device Groups")
+
+	t := newTable()
+	t.AppendHeader(table.Row{"Group", "Members", "Plays On"})
+	for _, g := range GetSettings().Groups {
+		var members []string
+		for _, ref := range g.members() {
+			if findDevice(devices, ref) != nil {
+				members = append(members, color.GreenString(ref))
+			} else {
+				members = append(members, color.HiBlackString(ref+" (offline)"))
+			}
+		}
+		playsOn := color.HiBlackString("none online")
+		if d := g.pick(devices); d != nil {
+			playsOn = d.Name
+		}
+		t.AppendRow(table.Row{color.New(color.Bold).Sprint(g.Name), strings.Join(members, ", "), playsOn})
+	}
+	t.Render()
 }
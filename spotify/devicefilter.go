@@ -0,0 +1,119 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Filtering and sorting the device list. An account that's
+// been around a while sees a dozen devices, most of them old phones and
+// web players; `-devices -devices-type Speaker` or
+// /api/v1/devices?type=Speaker&sort=name narrows it to the ones that
+// matter.
+//
+
+package spotify
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// deviceSorts are the accepted sort orders. Without one, devices keep
+// Spotify's order.
+var deviceSorts = []string{"name", "type", "active", "volume"}
+
+// DeviceFilter narrows and orders a device list. Zero values match
+// everything.
+type DeviceFilter struct {
+	// Types keeps devices of these types, like Speaker or Computer,
+	// ignoring case.
+	Types []string
+	// Active, if set, keeps only active (true) or inactive (false) devices.
+	Active *bool
+	// Name keeps devices whose name contains it, ignoring case.
+	Name string
+	// Sort orders the result: name, type (then name), active (active
+	// first, then name), or volume (loudest first).
+	Sort string
+}
+
+// ParseDeviceFilter builds a filter from its text form, as the API
+// parameters and CLI flags give it: a comma-separated type list, active as
+// true or false, a name substring, and a sort order.
+func ParseDeviceFilter(types, active, name, sortBy string) (DeviceFilter, error) {
+	f := DeviceFilter{Name: strings.TrimSpace(name), Sort: strings.ToLower(strings.TrimSpace(sortBy))}
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Types = append(f.Types, t)
+		}
+	}
+	if active != "" {
+		v, err := strconv.ParseBool(active)
+		if err != nil {
+			return DeviceFilter{}, fmt.Errorf("active must be true or false, got %q", active)
+		}
+		f.Active = &v
+	}
+	if f.Sort != "" && !containsFold(deviceSorts, f.Sort) {
+		return DeviceFilter{}, fmt.Errorf("sort must be one of %s, got %q", strings.Join(deviceSorts, ", "), sortBy)
+	}
+	return f, nil
+}
+
+// matches reports whether `d` passes the filter.
+func (f DeviceFilter) matches(d spotifyLib.PlayerDevice) bool {
+	if len(f.Types) > 0 && !containsFold(f.Types, d.Type) {
+		return false
+	}
+	if f.Active != nil && d.Active != *f.Active {
+		return false
+	}
+	return f.Name == "" || strings.Contains(strings.ToLower(d.Name), strings.ToLower(f.Name))
+}
+
+// Apply returns the devices that pass the filter, in the filter's order.
+// `devices` is left as it was.
+func (f DeviceFilter) Apply(devices []spotifyLib.PlayerDevice) []spotifyLib.PlayerDevice {
+	out := make([]spotifyLib.PlayerDevice, 0, len(devices))
+	for _, d := range devices {
+		if f.matches(d) {
+			out = append(out, d)
+		}
+	}
+
+	byName := func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) }
+	switch f.Sort {
+	case "name":
+		sort.SliceStable(out, byName)
+	case "type":
+		sort.SliceStable(out, func(i, j int) bool {
+			if ti, tj := strings.ToLower(out[i].Type), strings.ToLower(out[j].Type); ti != tj {
+				return ti < tj
+			}
+			return byName(i, j)
+		})
+	case "active":
+		sort.SliceStable(out, func(i, j int) bool {
+			if out[i].Active != out[j].Active {
+				return out[i].Active
+			}
+			return byName(i, j)
+		})
+	case "volume":
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Volume > out[j].Volume })
+	}
+	return out
+}
+
+// containsFold reports whether `list` holds `s`, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Device registry. Spotify Connect device IDs change when a
+// speaker is reset or re-linked, which silently breaks presets that name a
+// device by ID. The registry fingerprints devices by name and type, gives
+// each a stable ID ("dev-…") that never changes, remembers every Spotify
+// ID it has seen under that fingerprint, and rewrites preset and room
+// references when a device's Spotify ID moves.
+//
+
+package spotify
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// DefaultDeviceRegistryFile is where the registry lives unless
+// SPOTIFY_DEVICE_REGISTRY_FILE says otherwise.
+const DefaultDeviceRegistryFile = ".spotify_devices.json"
+
+// maxPreviousDeviceIDs bounds how many retired Spotify IDs are remembered
+// per device.
+const maxPreviousDeviceIDs = 10
+
+// RegisteredDevice is one physical device as the registry knows it.
+type RegisteredDevice struct {
+	StableID    string    `json:"stable_id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	SpotifyID   string    `json:"spotify_id"`
+	PreviousIDs []string  `json:"previous_ids,omitempty"`
+	Remaps      int       `json:"remaps"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// DeviceRemap records a device's Spotify ID changing.
+type DeviceRemap struct {
+	StableID string
+	Name     string
+	OldID    string
+	NewID    string
+}
+
+// DeviceRegistry is the persisted set of RegisteredDevice records, keyed
+// by stable ID.
+type DeviceRegistry struct {
+	mu      sync.Mutex
+	path    string
+	devices map[string]*RegisteredDevice
+	now     func() time.Time
+}
+
+// deviceRegistry is the process-wide registry; nil disables it.
+var deviceRegistry *DeviceRegistry
+
+// SetDeviceRegistry sets the registry used for device lookups.
+func SetDeviceRegistry(r *DeviceRegistry) {
+	deviceRegistry = r
+}
+
+// GetDeviceRegistry returns the registry, or nil if disabled.
+func GetDeviceRegistry() *DeviceRegistry {
+	return deviceRegistry
+}
+
+// OpenDeviceRegistry loads the registry at `path`. A missing file is an
+// empty registry.
+func OpenDeviceRegistry(path string) (*DeviceRegistry, error) {
+	r := &DeviceRegistry{path: path, devices: map[string]*RegisteredDevice{}, now: time.Now}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read device registry: %w", err)
+	}
+
+	var list []*RegisteredDevice
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse device registry %s: %w", path, err)
+	}
+	for _, d := range list {
+		r.devices[d.StableID] = d
+	}
+	return r, nil
+}
+
+// StableDeviceID derives a device's stable ID from its name and type, so
+// the same speaker gets the same ID even if the registry file is lost.
+func StableDeviceID(name, deviceType string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(name) + "|" + strings.ToLower(deviceType)))
+	return "dev-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// Observe records the devices Spotify currently reports: new devices are
+// registered, and a known device showing up under a new Spotify ID is
+// remapped. The remaps are returned and the file is saved if anything
+// changed. Safe on a nil registry.
+func (r *DeviceRegistry) Observe(devices []spotifyLib.PlayerDevice) []DeviceRemap {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var remaps []DeviceRemap
+	changed := false
+
+	for _, d := range devices {
+		if d.ID == "" {
+			continue
+		}
+		stable := StableDeviceID(d.Name, d.Type)
+		rec, ok := r.devices[stable]
+		if !ok {
+			r.devices[stable] = &RegisteredDevice{
+				StableID:  stable,
+				Name:      d.Name,
+				Type:      d.Type,
+				SpotifyID: string(d.ID),
+				FirstSeen: now,
+				LastSeen:  now,
+			}
+			changed = true
+			continue
+		}
+
+		if rec.SpotifyID != string(d.ID) {
+			remaps = append(remaps, DeviceRemap{StableID: stable, Name: d.Name, OldID: rec.SpotifyID, NewID: string(d.ID)})
+			rec.PreviousIDs = append(rec.PreviousIDs, rec.SpotifyID)
+			if len(rec.PreviousIDs) > maxPreviousDeviceIDs {
+				rec.PreviousIDs = rec.PreviousIDs[len(rec.PreviousIDs)-maxPreviousDeviceIDs:]
+			}
+			rec.SpotifyID = string(d.ID)
+			rec.Remaps++
+			changed = true
+		}
+		// Only persist last-seen when something else changed or it's gone
+		// stale, so routine polling doesn't rewrite the file every time.
+		if changed || now.Sub(rec.LastSeen) > time.Hour {
+			rec.LastSeen = now
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := r.save(); err != nil {
+			log.Printf("Warning: failed to save device registry: %v", err)
+		}
+	}
+	return remaps
+}
+
+// Lookup finds the registered device `ref` refers to: a stable ID, or any
+// Spotify ID the device has ever had. Safe on a nil registry.
+func (r *DeviceRegistry) Lookup(ref string) (RegisteredDevice, bool) {
+	if r == nil || ref == "" {
+		return RegisteredDevice{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rec, ok := r.devices[ref]; ok {
+		return *rec, true
+	}
+	for _, rec := range r.devices {
+		if rec.SpotifyID == ref {
+			return *rec, true
+		}
+		for _, prev := range rec.PreviousIDs {
+			if prev == ref {
+				return *rec, true
+			}
+		}
+	}
+	return RegisteredDevice{}, false
+}
+
+// List returns every registered device, sorted by name. Safe on a nil
+// registry.
+func (r *DeviceRegistry) List() []RegisteredDevice {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]RegisteredDevice, 0, len(r.devices))
+	for _, rec := range r.devices {
+		out = append(out, *rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].StableID < out[j].StableID
+	})
+	return out
+}
+
+// save writes the registry atomically. Callers hold r.mu.
+func (r *DeviceRegistry) save() error {
+	list := make([]*RegisteredDevice, 0, len(r.devices))
+	for _, rec := range r.devices {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StableID < list[j].StableID })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".devices-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// observeDevices feeds a fresh device list to the registry and carries any
+// remaps into the settings file, so presets and rooms that named a device
+// by its old Spotify ID keep working.
+func observeDevices(devices []spotifyLib.PlayerDevice) {
+	for _, m := range deviceRegistry.Observe(devices) {
+		log.Printf("device registry: %s (%s) changed Spotify ID %s -> %s", m.Name, m.StableID, m.OldID, m.NewID)
+		remapSettingsDevice(m)
+	}
+}
+
+// remapSettingsDevice carries one remap into a copy of the settings,
+// swaps it in, and saves it. It holds reloadMu so a reload can't swap in
+// the file from under it.
+func remapSettingsDevice(m DeviceRemap) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	s, err := GetSettings().clone()
+	if err != nil {
+		log.Printf("Warning: failed to copy settings for remap: %v", err)
+		return
+	}
+	if n := s.RemapDeviceID(m.OldID, m.NewID); n > 0 {
+		log.Printf("device registry: updated %d preset/room reference(s) to %s", n, m.Name)
+		setSettings(s)
+		if err := WriteSettingsFile(settingsFile, s); err != nil {
+			log.Printf("Warning: failed to save remapped settings: %v", err)
+		}
+	}
+}
+
+// findDevice returns the device in `devices` that `ref` names: by name or
+// Spotify ID first, then through the registry by stable ID or a retired
+// Spotify ID. Returns nil if nothing matches.
+func findDevice(devices []spotifyLib.PlayerDevice, ref string) *spotifyLib.PlayerDevice {
+	if ref == "" {
+		return nil
+	}
+	for i, d := range devices {
+		if d.Name == ref || string(d.ID) == ref {
+			return &devices[i]
+		}
+	}
+
+	rec, ok := deviceRegistry.Lookup(ref)
+	if !ok {
+		return nil
+	}
+	for i, d := range devices {
+		if StableDeviceID(d.Name, d.Type) == rec.StableID {
+			if ref != rec.StableID {
+				log.Printf("device registry: %q is a retired ID for %s, using %s", ref, rec.Name, d.ID)
+			}
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+// RegisterDevices registers every device Spotify currently reports in one
+// go, so presets can be pointed at stable IDs before anything breaks.
+func RegisterDevices(ctx context.Context) ([]RegisteredDevice, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deviceRegistry == nil {
+		return nil, fmt.Errorf("device registry is disabled")
+	}
+
+	devices, err := client.PlayerDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	observeDevices(devices)
+	return deviceRegistry.List(), nil
+}
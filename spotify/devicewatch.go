@@ -0,0 +1,135 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Watching the device list. `-devices -watch` prints the
+// devices once, then polls Spotify and logs each device that appears,
+// disappears, or becomes active or inactive, for debugging a speaker that
+// keeps dropping off the account.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fatih/color"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// DefaultDeviceWatchInterval is how often -devices -watch polls unless
+// -watch-interval says otherwise.
+const DefaultDeviceWatchInterval = 5 * time.Second
+
+// minDeviceWatchInterval keeps the watch from hammering Spotify.
+const minDeviceWatchInterval = time.Second
+
+// Device change kinds.
+const (
+	DeviceAppeared    = "appeared"
+	DeviceDisappeared = "disappeared"
+	DeviceActivated   = "activated"
+	DeviceDeactivated = "deactivated"
+)
+
+// DeviceChange is one difference between two polls of the device list.
+type DeviceChange struct {
+	Kind   string
+	Device spotifyLib.PlayerDevice
+}
+
+// diffDevices lists what changed from `prev` to `cur`, matching devices by
+// Spotify ID: disappearances first, in `prev` order, then appearances and
+// active switches in `cur` order.
+func diffDevices(prev, cur []spotifyLib.PlayerDevice) []DeviceChange {
+	before := make(map[spotifyLib.ID]spotifyLib.PlayerDevice, len(prev))
+	for _, d := range prev {
+		before[d.ID] = d
+	}
+	now := make(map[spotifyLib.ID]bool, len(cur))
+	for _, d := range cur {
+		now[d.ID] = true
+	}
+
+	var changes []DeviceChange
+	for _, d := range prev {
+		if !now[d.ID] {
+			changes = append(changes, DeviceChange{Kind: DeviceDisappeared, Device: d})
+		}
+	}
+	for _, d := range cur {
+		old, seen := before[d.ID]
+		switch {
+		case !seen:
+			changes = append(changes, DeviceChange{Kind: DeviceAppeared, Device: d})
+		case d.Active && !old.Active:
+			changes = append(changes, DeviceChange{Kind: DeviceActivated, Device: d})
+		case !d.Active && old.Active:
+			changes = append(changes, DeviceChange{Kind: DeviceDeactivated, Device: d})
+		}
+	}
+	return changes
+}
+
+// String describes the change for the watch log.
+func (c DeviceChange) String() string {
+	d := c.Device
+	switch c.Kind {
+	case DeviceAppeared:
+		return color.GreenString("+ %s (%s) appeared", d.Name, d.Type) + color.HiBlackString("  %s", d.ID)
+	case DeviceDisappeared:
+		return color.RedString("- %s (%s) disappeared", d.Name, d.Type) + color.HiBlackString("  %s", d.ID)
+	case DeviceActivated:
+		return color.CyanString("%s %s is now active", symbol("●", "*"), d.Name)
+	default:
+		return fmt.Sprintf("%s %s is no longer active", symbol("○", "o"), d.Name)
+	}
+}
+
+// WatchDevices prints the devices that pass `filter`, then polls every
+// `interval` and writes a timestamped line to `out` for each change until
+// ctx is done. A failed poll is logged and the next one compares against
+// the last list that came back.
+func WatchDevices(ctx context.Context, interval time.Duration, filter DeviceFilter, out io.Writer) error {
+	if interval < minDeviceWatchInterval {
+		return fmt.Errorf("watch interval must be at least %s, got %s", minDeviceWatchInterval, interval)
+	}
+
+	all, err := ListDevices(ctx)
+	if err != nil {
+		return err
+	}
+	PrintDevicesTable(all, filter)
+	prev := filter.Apply(all)
+	fmt.Fprintf(out, "Watching for device changes every %s (Ctrl-C to stop)\n", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		devices, err := ListDevices(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		stamp := time.Now().Format("15:04:05")
+		if err != nil {
+			fmt.Fprintf(out, "%s %s\n", stamp, color.YellowString("! %v", err))
+			continue
+		}
+		cur := filter.Apply(devices)
+		for _, c := range diffDevices(prev, cur) {
+			fmt.Fprintf(out, "%s %s\n", stamp, c)
+		}
+		prev = cur
+	}
+}
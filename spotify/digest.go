@@ -0,0 +1,361 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Recently-added digest. A scheduled job looks through shared
+// playlists — collaborative ones and ones followed from other people — for
+// tracks someone else added since the last run, and sends a summary to the
+// notifier channels. The same digest is available on demand from
+// /api/v1/digest.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// DefaultDigestStateFile is where the digest remembers its last run unless
+// SPOTIFY_DIGEST_STATE_FILE says otherwise.
+const DefaultDigestStateFile = ".spotify_digest.json"
+
+// defaultDigestLookback is how far back a digest looks when it has never
+// run before.
+const defaultDigestLookback = 24 * time.Hour
+
+// maxDigestLines caps the tracks listed in a notification message; the
+// webhook payload still carries all of them.
+const maxDigestLines = 20
+
+// DigestTrack is one track added to a watched playlist.
+type DigestTrack struct {
+	Name    string    `json:"name"`
+	Artists string    `json:"artists"`
+	URI     string    `json:"uri"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// DigestPlaylist is a watched playlist with its new tracks.
+type DigestPlaylist struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	URL    string        `json:"url"`
+	Tracks []DigestTrack `json:"tracks"`
+}
+
+// Digest lists tracks added to watched playlists between Since and Until.
+type Digest struct {
+	Since     time.Time        `json:"since"`
+	Until     time.Time        `json:"until"`
+	Total     int              `json:"total"`
+	Playlists []DigestPlaylist `json:"playlists"`
+}
+
+// digestState is what the job persists between runs. Snapshots maps
+// playlist ID to the snapshot ID seen last run, so unchanged playlists
+// aren't re-read.
+type digestState struct {
+	LastRun   time.Time         `json:"last_run"`
+	Snapshots map[string]string `json:"snapshots"`
+}
+
+// DigestJob builds digests and remembers where the last one ended.
+type DigestJob struct {
+	mu        sync.Mutex
+	path      string
+	playlists []string
+	state     digestState
+}
+
+// digestJob is the process-wide job; nil means the digest is unavailable.
+var digestJob *DigestJob
+
+// SetDigestJob sets the digest job.
+func SetDigestJob(j *DigestJob) {
+	digestJob = j
+}
+
+// OpenDigestJob loads the job state at `path`. `playlists` are names, IDs,
+// or links to watch; empty watches every collaborative playlist and every
+// followed playlist owned by someone else.
+func OpenDigestJob(path string, playlists []string) (*DigestJob, error) {
+	j := &DigestJob{path: path, playlists: playlists, state: digestState{Snapshots: map[string]string{}}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read digest state: %w", err)
+	}
+	if err := json.Unmarshal(data, &j.state); err != nil {
+		return nil, fmt.Errorf("parse digest state %s: %w", path, err)
+	}
+	if j.state.Snapshots == nil {
+		j.state.Snapshots = map[string]string{}
+	}
+	return j, nil
+}
+
+// LastRun returns when the last scheduled digest ended, or the zero time.
+func (j *DigestJob) LastRun() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state.LastRun
+}
+
+// Preview builds the digest of tracks added since `since` (the last run
+// when zero) without sending it or moving the last-run mark.
+func (j *DigestJob) Preview(ctx context.Context, since time.Time) (*Digest, error) {
+	if since.IsZero() {
+		since = j.LastRun()
+	}
+	if since.IsZero() {
+		since = time.Now().Add(-defaultDigestLookback)
+	}
+	digest, _, err := j.collect(ctx, since, time.Now(), nil)
+	return digest, err
+}
+
+// Run builds the digest since the last run, sends it to the notifier
+// channels and publishes it on the event bus if anything was added, and
+// moves the last-run mark. The notifiers are called directly rather than
+// from the bus so a failed send keeps the mark for the next run to retry.
+func (j *DigestJob) Run(ctx context.Context) (*Digest, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	since := j.state.LastRun
+	if since.IsZero() {
+		since = now.Add(-defaultDigestLookback)
+	}
+
+	digest, snapshots, err := j.collect(ctx, since, now, j.state.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	if digest.Total > 0 {
+		if err := notify(ctx, digest.Notification()); err != nil {
+			// Keep the mark where it was so the next run retries.
+			return digest, fmt.Errorf("failed to send digest: %w", err)
+		}
+		publishEvent(TopicDigest, EventDigestSent, AccountFrom(ctx), digest)
+	}
+
+	j.state.LastRun = now
+	j.state.Snapshots = snapshots
+	if err := j.save(); err != nil {
+		log.Printf("Warning: failed to save digest state: %v", err)
+	}
+	return digest, nil
+}
+
+// collect reads the watched playlists for tracks added in (since, until]
+// by anyone but the user. A playlist whose snapshot ID matches `known`
+// hasn't changed and is skipped. The snapshot IDs seen are returned.
+func (j *DigestJob) collect(ctx context.Context, since, until time.Time, known map[string]string) (*Digest, map[string]string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	me, err := client.CurrentUser(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	watched, err := j.watchedPlaylists(ctx, client, me.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digest := &Digest{Since: since, Until: until, Playlists: []DigestPlaylist{}}
+	snapshots := map[string]string{}
+
+	for _, pl := range watched {
+		id := string(pl.ID)
+		snapshots[id] = pl.SnapshotID
+		if pl.SnapshotID != "" && known[id] == pl.SnapshotID {
+			continue
+		}
+
+		items, err := fetchPlaylistItems(ctx, client, id)
+		if err != nil {
+			log.Printf("Warning: digest skipped %q: %v", pl.Name, err)
+			// Forget the snapshot so the next run tries again.
+			delete(snapshots, id)
+			continue
+		}
+
+		entry := DigestPlaylist{ID: id, Name: pl.Name, URL: "https://open.spotify.com/playlist/" + id}
+		for _, item := range items {
+			if item.AddedBy.ID == me.ID || item.Track.Track == nil {
+				continue
+			}
+			addedAt, err := time.Parse(spotifyLib.TimestampLayout, item.AddedAt)
+			if err != nil || !addedAt.After(since) || addedAt.After(until) {
+				continue
+			}
+			entry.Tracks = append(entry.Tracks, digestTrack(item, addedAt))
+		}
+		if len(entry.Tracks) > 0 {
+			sort.SliceStable(entry.Tracks, func(a, b int) bool { return entry.Tracks[a].AddedAt.Before(entry.Tracks[b].AddedAt) })
+			digest.Playlists = append(digest.Playlists, entry)
+			digest.Total += len(entry.Tracks)
+		}
+	}
+	return digest, snapshots, nil
+}
+
+// watchedPlaylists returns the playlists to check: the configured ones,
+// or every collaborative playlist and every playlist someone else owns.
+func (j *DigestJob) watchedPlaylists(ctx context.Context, client Client, myID string) ([]spotifyLib.SimplePlaylist, error) {
+	all, err := ListPlaylists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(j.playlists) == 0 {
+		var out []spotifyLib.SimplePlaylist
+		for _, p := range all {
+			if p.Collaborative || p.Owner.ID != myID {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+	}
+
+	byID := make(map[string]spotifyLib.SimplePlaylist, len(all))
+	for _, p := range all {
+		byID[string(p.ID)] = p
+	}
+	var out []spotifyLib.SimplePlaylist
+	for _, ref := range j.playlists {
+		id, err := ResolvePlaylistIDQuiet(ctx, client, ref)
+		if err != nil {
+			log.Printf("Warning: digest playlist %q: %v", ref, err)
+			continue
+		}
+		if p, ok := byID[id]; ok {
+			out = append(out, p)
+		} else {
+			// Not in the library; read it without a snapshot to compare.
+			out = append(out, spotifyLib.SimplePlaylist{ID: spotifyLib.ID(id), Name: ref})
+		}
+	}
+	return out, nil
+}
+
+// digestTrack describes a playlist item for the digest.
+func digestTrack(item spotifyLib.PlaylistItem, addedAt time.Time) DigestTrack {
+	t := item.Track.Track
+	names := make([]string, len(t.Artists))
+	for i, a := range t.Artists {
+		names[i] = a.Name
+	}
+	addedBy := item.AddedBy.DisplayName
+	if addedBy == "" {
+		addedBy = item.AddedBy.ID
+	}
+	return DigestTrack{
+		Name:    t.Name,
+		Artists: strings.Join(names, ", "),
+		URI:     string(t.URI),
+		AddedBy: addedBy,
+		AddedAt: addedAt,
+	}
+}
+
+// Notification renders the digest as a notifier message: one line per
+// track, capped at maxDigestLines.
+func (d *Digest) Notification() Notification {
+	title := fmt.Sprintf("%d new track(s) in %d playlist(s)", d.Total, len(d.Playlists))
+
+	var lines []string
+	for _, pl := range d.Playlists {
+		for _, t := range pl.Tracks {
+			line := fmt.Sprintf("%s: %s — %s", pl.Name, t.Name, t.Artists)
+			if t.AddedBy != "" {
+				line += " (added by " + t.AddedBy + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxDigestLines {
+		lines = append(lines[:maxDigestLines], fmt.Sprintf("…and %d more", len(lines)-maxDigestLines))
+	}
+
+	n := Notification{Kind: "digest", Title: title, Message: strings.Join(lines, "\n"), Data: d}
+	if len(d.Playlists) == 1 {
+		n.URL = d.Playlists[0].URL
+	}
+	return n
+}
+
+// save writes the job state atomically. Callers hold j.mu.
+func (j *DigestJob) save() error {
+	data, err := json.MarshalIndent(j.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), ".digest-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
+
+// StartDigestScheduler runs the digest job every `interval` until ctx is
+// done. It does nothing if the job is disabled.
+func StartDigestScheduler(ctx context.Context, interval time.Duration) {
+	if digestJob == nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if spotifyClient == nil {
+				continue
+			}
+			digest, err := digestJob.Run(ctx)
+			if err != nil {
+				log.Printf("digest: %v", err)
+				continue
+			}
+			log.Printf("digest: %d new track(s) in %d playlist(s)", digest.Total, len(digest.Playlists))
+		}
+	}()
+}
@@ -0,0 +1,203 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The -doctor checklist. It runs the whole path a play takes,
+// end to end: the environment, the app with Spotify, the saved login and a
+// real token refresh, the user and devices the login can see, the default
+// playlist, and the API port. Each failure comes with a hint for fixing
+// it, so "it doesn't play" turns into the one line that's red.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/oauth2"
+)
+
+// DoctorConfig is what -doctor checks, as read from the environment.
+type DoctorConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	// APIToken, Playlist, Device, and Port are API_ACCESS_TOKEN,
+	// SPOTIFY_PLAYLIST_ID, SPOTIFY_DEVICE_NAME, and PORT; any may be
+	// empty.
+	APIToken string
+	Playlist string
+	Device   string
+	Port     string
+}
+
+// doctorRefresh refreshes a saved token with Spotify; a variable so tests
+// can fake it.
+var doctorRefresh = refreshViaAuth
+
+// Doctor runs the -doctor checklist. Checks that need an earlier one to
+// pass are reported as unknown when it didn't.
+func Doctor(ctx context.Context, cfg DoctorConfig) []PreflightCheck {
+	id := checkAppCredential("client ID", "SPOTIFY_CLIENT_ID", cfg.ClientID)
+	secret := checkAppCredential("client secret", "SPOTIFY_CLIENT_SECRET", cfg.ClientSecret)
+	for _, c := range []*PreflightCheck{&id, &secret} {
+		c.Hint = "copy it from your app at developer.spotify.com/dashboard into .env, without quotes"
+	}
+	checks := []PreflightCheck{id, secret, checkAPIToken(cfg.APIToken)}
+	if PreflightFailed(checks) {
+		checks = append(checks, PreflightCheck{Name: "app", Status: CheckUnknown, Detail: "needs the client ID and secret"})
+	} else {
+		checks = append(checks, ValidateAppConfig(ctx, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURI)...)
+	}
+	checks = append(checks, checkPortFree(cfg.Port))
+
+	token := checkTokenFile(GetTokenFile())
+	token.Hint = "run `spotify-shortcut auth` (or `auth -manual` on a machine without a browser)"
+	checks = append(checks, token)
+	skipped := func(names ...string) []PreflightCheck {
+		var out []PreflightCheck
+		for _, n := range names {
+			out = append(out, PreflightCheck{Name: n, Status: CheckUnknown, Detail: "needs a working saved login"})
+		}
+		return out
+	}
+	if token.Status != CheckOK {
+		return append(checks, skipped("token refresh", "user", "devices", "default playlist")...)
+	}
+
+	refresh := checkTokenRefresh(ctx, GetTokenFile())
+	checks = append(checks, refresh)
+	if refresh.Status != CheckOK {
+		return append(checks, skipped("user", "devices", "default playlist")...)
+	}
+	client, err := configCheckClient()
+	if err != nil {
+		token.Status, token.Detail = CheckFailed, err.Error()
+		checks[len(checks)-2] = token
+		return append(checks, skipped("user", "devices", "default playlist")...)
+	}
+	// Playlist names are looked up through the process-wide client.
+	SetClient(client)
+
+	user := checkCurrentUser(ctx, client)
+	checks = append(checks, user)
+	if user.Status != CheckOK {
+		return append(checks, skipped("devices", "default playlist")...)
+	}
+	playlist := checkDefaultPlaylist(ctx, client, cfg.Playlist)
+	playlist.Hint = "run `spotify-shortcut playlists` and set SPOTIFY_PLAYLIST_ID to one listed"
+	return append(checks, checkDevicesVisible(ctx, client, cfg.Device), playlist)
+}
+
+// checkAPIToken checks API_ACCESS_TOKEN, which only server mode needs.
+func checkAPIToken(token string) PreflightCheck {
+	check := PreflightCheck{Name: "API token"}
+	if token == "" {
+		check.Status, check.Detail = CheckUnknown, "API_ACCESS_TOKEN isn't set; the CLI doesn't need it, but the server won't start without it"
+		check.Hint = "set API_ACCESS_TOKEN in .env to a long random string before running `spotify-shortcut serve`"
+		return check
+	}
+	check.Status, check.Detail = CheckOK, "API_ACCESS_TOKEN is set"
+	return check
+}
+
+// checkPortFree checks the API server could listen on `port`.
+func checkPortFree(port string) PreflightCheck {
+	if port == "" {
+		port = "8080"
+	}
+	check := PreflightCheck{Name: "API port"}
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("can't listen on port %s: %v", port, err)
+		check.Hint = "stop whatever holds the port (a server already running is fine), or set PORT to a free one"
+		return check
+	}
+	ln.Close()
+	check.Status, check.Detail = CheckOK, fmt.Sprintf("port %s is free", port)
+	return check
+}
+
+// checkTokenRefresh refreshes the saved login with Spotify and saves the
+// result, proving the refresh token still works. An access token that
+// can't be refreshed stops working within the hour.
+func checkTokenRefresh(ctx context.Context, path string) PreflightCheck {
+	check := PreflightCheck{Name: "token refresh"}
+	tok, err := readTokenFile(path)
+	if err != nil {
+		check.Status, check.Detail = CheckFailed, err.Error()
+		return check
+	}
+	if tok.RefreshToken == "" {
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("%s has no refresh token, so the login lapses when the access token expires", path)
+		check.Hint = "run `spotify-shortcut auth` to sign in again"
+		return check
+	}
+	fresh, err := doctorRefresh(ctx, tok)
+	if err != nil {
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("Spotify refused to refresh the login: %v", err)
+		check.Hint = "if the app was removed at spotify.com/account/apps or the password changed, run `spotify-shortcut auth`; otherwise check the client secret"
+		var retrieve *oauth2.RetrieveError
+		if !errors.As(err, &retrieve) {
+			check.Status = CheckUnknown
+			check.Hint = "check the network connection to accounts.spotify.com"
+		}
+		return check
+	}
+	saveTokenFile(path, fresh)
+	check.Status, check.Detail = CheckOK, fmt.Sprintf("refreshed; the new token expires at %s", fresh.Expiry.Local().Format("15:04"))
+	return check
+}
+
+// checkCurrentUser checks the login can fetch its own profile.
+func checkCurrentUser(ctx context.Context, client Client) PreflightCheck {
+	check := PreflightCheck{Name: "user"}
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("couldn't fetch the signed-in user: %v", err)
+		check.Hint = "run `spotify-shortcut logout`, then `spotify-shortcut auth`"
+		return check
+	}
+	name := user.DisplayName
+	if name == "" {
+		name = user.ID
+	}
+	check.Status, check.Detail = CheckOK, "signed in as "+name
+	if user.Product != "" && user.Product != "premium" {
+		check.Status = CheckFailed
+		check.Detail = fmt.Sprintf("signed in as %s, a %s account; Spotify only lets Premium accounts control playback", name, user.Product)
+		check.Hint = "sign in with a Premium account"
+	}
+	return check
+}
+
+// checkDevicesVisible checks the login sees at least one Spotify Connect
+// device, and resolves the default device if there is one. No devices
+// isn't necessarily wrong: speakers drop off when idle.
+func checkDevicesVisible(ctx context.Context, client Client, defaultDevice string) PreflightCheck {
+	check := PreflightCheck{Name: "devices"}
+	devices, err := client.PlayerDevices(ctx)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = CheckFailed, fmt.Sprintf("couldn't list devices: %v", err)
+		check.Hint = "check the network connection to api.spotify.com"
+	case len(devices) == 0:
+		check.Status, check.Detail = CheckUnknown, "no Spotify Connect devices are visible right now"
+		check.Hint = "open Spotify on a phone or speaker, or claim one with `spotify-shortcut -device <name>` on the same network"
+	default:
+		check.Status, check.Detail = CheckOK, fmt.Sprintf("%d visible, like %s", len(devices), devices[0].Name)
+	}
+	if defaultDevice != "" && check.Status != CheckFailed {
+		device := checkDefaultDevice(ctx, client, defaultDevice)
+		check.Detail += "; " + device.Detail
+		if device.Status == CheckFailed {
+			check.Status = CheckFailed
+			check.Hint = "set SPOTIFY_DEVICE_NAME to a name `spotify-shortcut devices` lists"
+		}
+	}
+	return check
+}
@@ -0,0 +1,154 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Duration targeting ("play about 45 minutes of this"). The
+// playlist's tracks are picked, in the order the request asks for, until
+// their lengths add up to about the target, and the pick plays as an
+// explicit URI list so playback stops when time's up. on_end=fade-out
+// fades the last one out.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// Target durations must fall between minTargetDuration and
+// maxTargetDuration.
+const (
+	minTargetDuration = time.Minute
+	maxTargetDuration = 12 * time.Hour
+)
+
+// durationSlack is how close to the target a pick has to get before we
+// stop looking for tracks that fit.
+const durationSlack = 30 * time.Second
+
+// parseTargetDuration parses a duration value like "45m" or "1h30m".
+func parseTargetDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (use a value like 45m or 1h30m)", s)
+	}
+	if d < minTargetDuration || d > maxTargetDuration {
+		return 0, fmt.Errorf("duration must be between %s and %s, got %s", formatDuration(minTargetDuration), formatDuration(maxTargetDuration), formatDuration(d))
+	}
+	return d, nil
+}
+
+// validateDuration checks req.Duration. Only playlists are picked from.
+func (req PlayRequest) validateDuration() error {
+	if req.Duration == "" {
+		return nil
+	}
+	if req.Album != "" || req.Artist != "" || req.Track != "" || req.Audiobook != "" || req.Show != "" {
+		return fmt.Errorf("duration only applies to playlists")
+	}
+	_, err := parseTargetDuration(req.Duration)
+	return err
+}
+
+// formatDuration renders d as "1h 5m" or "44m 31s".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second)
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	case s > 0:
+		return fmt.Sprintf("%dm %ds", m, s)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// itemDuration returns a playlist item's length, or 0 when unknown.
+func itemDuration(item spotifyLib.PlaylistItem) time.Duration {
+	switch {
+	case item.Track.Track != nil:
+		return item.Track.Track.TimeDuration()
+	case item.Track.Episode != nil:
+		return time.Duration(item.Track.Episode.Duration_ms) * time.Millisecond
+	}
+	return 0
+}
+
+// fitDuration picks tracks, in order, until their lengths add up to about
+// `target`; tracks of unknown length are passed over. A track that would
+// run past the target is taken if that lands closer than stopping short,
+// and ends the pick; otherwise it's skipped in the hope a shorter one
+// fits. It returns the URIs and their total length.
+func fitDuration(tracks []SourceTrack, target time.Duration) ([]spotifyLib.URI, time.Duration) {
+	var uris []spotifyLib.URI
+	var total time.Duration
+	for _, t := range tracks {
+		uri, d := t.URI, t.Duration
+		if d == 0 {
+			continue
+		}
+		switch {
+		case total+d <= target:
+			uris, total = append(uris, uri), total+d
+		case total+d-target < target-total:
+			uris, total = append(uris, uri), total+d
+		}
+		if target-total <= durationSlack || len(uris) == maxOrderedURIs {
+			break
+		}
+	}
+	return uris, total
+}
+
+// durationURIs picks about req.Duration of the playlist: newest or least
+// played first in those modes, in a random order with shuffle, and
+// otherwise in playlist order from the start strategy's track, wrapping
+// around. It returns the URIs and a label for the play message.
+func durationURIs(ctx context.Context, client Client, req PlayRequest, strategy StartStrategy, playlistID string, trackCount int) ([]spotifyLib.URI, string, error) {
+	target, err := parseTargetDuration(req.Duration)
+	if err != nil {
+		return nil, "", err
+	}
+	tracks, err := gatherTracks(ctx, client, PlaylistSource{ID: playlistID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	order := ""
+	switch {
+	case req.NewestFirst:
+		sortNewestFirst(tracks)
+		order = "newest first, "
+	case req.LeastPlayed:
+		db, _ := historyFor(AccountFrom(ctx))
+		sortLeastPlayed(tracks, db)
+		order = "least played first, "
+	case req.Shuffle:
+		rand.Shuffle(len(tracks), func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] })
+		order = "shuffled, "
+	case len(tracks) > 0:
+		position, err := strategy.Pick(ctx, client, playlistID, trackCount)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to pick start track: %w", err)
+		}
+		position %= len(tracks)
+		tracks = slices.Concat(tracks[position:], tracks[:position])
+	}
+
+	uris, total := fitDuration(tracks, target)
+	if len(uris) == 0 {
+		return nil, "", fmt.Errorf("playlist has no playable tracks")
+	}
+	label := fmt.Sprintf("%s%s of %s", order, formatDuration(total), formatDuration(target))
+	return uris, label, nil
+}
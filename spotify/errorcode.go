@@ -0,0 +1,178 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The error taxonomy. Every error is classified into one of
+// a small, fixed set of codes (auth, rate_limited, device, not_found,
+// network, unavailable, bad_request, internal), and that one vocabulary is
+// used everywhere: the `code` field of API error responses, the Spotify
+// call log, and the spotify_errors_total{type} metric on /metrics. The
+// codes are part of the API; add to them, never rename one.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// ErrorCode is the class of an error, stable across releases so
+// dashboards and automations can match on it.
+type ErrorCode string
+
+// The error codes.
+const (
+	// CodeAuth: a credential is missing or refused — the API token, the
+	// saved Spotify login, or a Spotify 401/403 (which includes Premium
+	// being required).
+	CodeAuth ErrorCode = "auth"
+	// CodeRateLimited: Spotify answered 429.
+	CodeRateLimited ErrorCode = "rate_limited"
+	// CodeDevice: the device isn't there, couldn't be claimed, or didn't
+	// start playing.
+	CodeDevice ErrorCode = "device"
+	// CodeNotFound: the playlist, preset, track, or other thing asked for
+	// doesn't exist.
+	CodeNotFound ErrorCode = "not_found"
+	// CodeNetwork: Spotify couldn't be reached, or didn't answer in time.
+	CodeNetwork ErrorCode = "network"
+	// CodeUnavailable: Spotify is failing (5xx, or the circuit breaker is
+	// open), or the feature asked for is turned off.
+	CodeUnavailable ErrorCode = "unavailable"
+	// CodeBadRequest: the request itself was wrong: a missing or invalid
+	// parameter.
+	CodeBadRequest ErrorCode = "bad_request"
+	// CodeInternal: anything else.
+	CodeInternal ErrorCode = "internal"
+)
+
+// errorCodes lists every code, so /metrics reports each one, at zero if
+// need be, and a dashboard never sees a series appear from nowhere.
+var errorCodes = []ErrorCode{
+	CodeAuth, CodeRateLimited, CodeDevice, CodeNotFound,
+	CodeNetwork, CodeUnavailable, CodeBadRequest, CodeInternal,
+}
+
+// codedError gives an error a code without changing its message, for
+// errors of our own that couldn't otherwise be told apart.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+// Error returns the wrapped error's message.
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withCode marks `err` as being of class `code`.
+func withCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// ErrorCodeOf classifies `err`. It returns "" for nil.
+func ErrorCodeOf(err error) ErrorCode {
+	var coded *codedError
+	var spErr spotifyLib.Error
+	var retrieveErr *oauth2.RetrieveError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, ErrSpotifyUnavailable):
+		return CodeUnavailable
+	case errors.Is(err, ErrPlaybackNotStarted):
+		return CodeDevice
+	case errors.Is(err, ErrLyricsNotFound):
+		return CodeNotFound
+	case errors.As(err, &retrieveErr), errors.Is(err, errNoRefreshToken), errors.Is(err, errEmptyToken):
+		return CodeAuth
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeNetwork
+	case errors.As(err, &spErr):
+		return spotifyErrorCode(spErr)
+	case errors.As(err, &netErr):
+		return CodeNetwork
+	}
+	return CodeInternal
+}
+
+// spotifyErrorCode classifies a Spotify Web API error by status. A 404
+// about a device ("Device not found", "No active device found") is a
+// device error rather than a missing resource.
+func spotifyErrorCode(err spotifyLib.Error) ErrorCode {
+	switch {
+	case err.Status == http.StatusUnauthorized, err.Status == http.StatusForbidden:
+		return CodeAuth
+	case err.Status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case err.Status == http.StatusNotFound && strings.Contains(strings.ToLower(err.Message), "device"):
+		return CodeDevice
+	case err.Status == http.StatusNotFound:
+		return CodeNotFound
+	case err.Status == http.StatusBadRequest:
+		return CodeBadRequest
+	case err.Status >= 500:
+		return CodeUnavailable
+	}
+	return CodeInternal
+}
+
+// ErrorCounts counts errors by code.
+type ErrorCounts struct {
+	mu     sync.Mutex
+	counts map[ErrorCode]int
+}
+
+// spotifyErrors counts failed Spotify calls across all accounts; it backs
+// spotify_errors_total.
+var spotifyErrors = &ErrorCounts{}
+
+// Add counts one error of class `code`.
+func (c *ErrorCounts) Add(code ErrorCode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[ErrorCode]int{}
+	}
+	c.counts[code]++
+}
+
+// Snapshot returns the count for every code, known ones included at zero,
+// in a stable order.
+func (c *ErrorCounts) Snapshot() ([]ErrorCode, map[ErrorCode]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[ErrorCode]int, len(errorCodes))
+	for _, code := range errorCodes {
+		out[code] = 0
+	}
+	for code, n := range c.counts {
+		out[code] = n
+	}
+	codes := make([]ErrorCode, 0, len(out))
+	for code := range out {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes, out
+}
@@ -0,0 +1,363 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Event bus. Everything that happens in the background —
+// playback changes seen by the watcher, plays started or failed, token
+// health transitions, device fallbacks, digests, Spotify API errors — is
+// published once to a single in-process bus.
+// WebSocket and SSE clients, the play history, the notifier channels and
+// the configured output sinks (a JSON-lines file, a webhook, MQTT) all
+// subscribe to it rather than each polling Spotify on their own.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event topics. A subscriber picks the topics it wants; none means all.
+const (
+	TopicPlayback = "playback"
+	TopicPlay     = "play"
+	TopicAuth     = "auth"
+	TopicFallback = "fallback"
+	TopicDigest   = "digest"
+	TopicError    = "error"
+)
+
+// Event types on the play, auth, fallback, digest, and error topics.
+// Playback events use the PlaybackEvent types (EventTrackChanged and so
+// on).
+const (
+	EventPlayStarted    = "started"
+	EventPlayFailed     = "failed"
+	EventAuthReady      = "ready"
+	EventAuthNotReady   = "not_ready"
+	EventDeviceFallback = "device_fallback"
+	EventDigestSent     = "sent"
+	EventAPIError       = "api_error"
+)
+
+// eventBufferSize is how many events a subscriber can fall behind before
+// it starts losing them; sinks get more room since they do network I/O.
+const (
+	eventBufferSize = 64
+	sinkBufferSize  = 256
+)
+
+// Event is one message on the bus. Data is the topic's payload: a
+// PlaybackEvent, PlayEvent, TokenStatus, FallbackEvent, Digest, or
+// APIErrorEvent.
+type Event struct {
+	Topic   string    `json:"topic"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Account string    `json:"account,omitempty"`
+	Data    any       `json:"data,omitempty"`
+}
+
+// eventSub is one subscriber's queue and topic filter.
+type eventSub struct {
+	ch     chan Event
+	topics []string
+}
+
+// wants reports whether the subscriber asked for `topic`.
+func (s *eventSub) wants(topic string) bool {
+	return len(s.topics) == 0 || slices.Contains(s.topics, topic)
+}
+
+// EventBus fans published events out to its subscribers.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+}
+
+// eventBus is the process-wide bus.
+var eventBus = &EventBus{}
+
+// subscribe registers a queue of `size` for `topics` (all when empty),
+// primed with `initial`. Call the returned func to unsubscribe; it closes
+// the channel.
+func (b *EventBus) subscribe(topics []string, initial []Event, size int) (<-chan Event, func()) {
+	sub := &eventSub{ch: make(chan Event, max(size, len(initial))), topics: topics}
+	for _, ev := range initial {
+		sub.ch <- ev
+	}
+
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = map[*eventSub]struct{}{}
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; !ok {
+			return
+		}
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers `ev` to every subscriber of its topic. A subscriber
+// that can't keep up loses the event rather than stalling the publisher.
+func (b *EventBus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !sub.wants(ev.Topic) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// publishEvent publishes an event to the process-wide bus.
+func publishEvent(topic, typ, account string, data any) {
+	eventBus.Publish(Event{Topic: topic, Type: typ, Account: account, Data: data})
+}
+
+// SubscribeEvents subscribes to `topics` (all when none are given). While
+// the subscription includes playback, the playback watcher keeps polling
+// at PLAYBACK_POLL_INTERVAL, and the channel starts with the last known
+// state of each account. Call the returned func to unsubscribe.
+func SubscribeEvents(topics ...string) (<-chan Event, func()) {
+	return subscribeEvents(0, eventBufferSize, topics...)
+}
+
+// subscribeEvents is SubscribeEvents with a queue of `size`. `every` is
+// how often playback needs polling for this subscriber; zero means the
+// watcher's configured interval.
+func subscribeEvents(every time.Duration, size int, topics ...string) (<-chan Event, func()) {
+	if len(topics) > 0 && !slices.Contains(topics, TopicPlayback) {
+		return eventBus.subscribe(topics, nil, size)
+	}
+	return playbackWatcher.subscribe(every, size, topics)
+}
+
+// ParseEventTopics splits a comma-separated topic list, as taken by
+// EVENTS_TOPICS and ?topics=, rejecting unknown topics. An empty list
+// means all topics.
+func ParseEventTopics(s string) ([]string, error) {
+	var topics []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch t {
+		case "":
+			continue
+		case TopicPlayback, TopicPlay, TopicAuth, TopicFallback, TopicDigest, TopicError:
+			topics = append(topics, t)
+		default:
+			return nil, fmt.Errorf("unknown event topic %q (use %s, %s, %s, %s, %s, or %s)", t, TopicPlayback, TopicPlay, TopicAuth, TopicFallback, TopicDigest, TopicError)
+		}
+	}
+	return topics, nil
+}
+
+// EventSink receives the bus's events for one output.
+type EventSink interface {
+	// Name identifies the sink in logs.
+	Name() string
+	Send(ctx context.Context, ev Event) error
+}
+
+// StartEventSinks subscribes each sink to `topics` (all when empty) and
+// delivers events to it from its own goroutine, so a slow sink only
+// delays itself. Sinks that are io.Closers are closed when ctx is done.
+func StartEventSinks(ctx context.Context, topics []string, sinks ...EventSink) {
+	for _, sink := range sinks {
+		events, unsubscribe := subscribeEvents(0, sinkBufferSize, topics...)
+		go runEventSink(ctx, sink, events, unsubscribe)
+	}
+}
+
+// runEventSink feeds `events` to `sink` until ctx is done.
+func runEventSink(ctx context.Context, sink EventSink, events <-chan Event, unsubscribe func()) {
+	defer unsubscribe()
+	if c, ok := sink.(interface{ Close() error }); ok {
+		defer c.Close()
+	}
+
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			// Log each distinct failure once rather than every event.
+			if err := sink.Send(ctx, ev); err != nil {
+				if err.Error() != lastErr {
+					log.Printf("Warning: %s event sink: %v", sink.Name(), err)
+					lastErr = err.Error()
+				}
+			} else {
+				lastErr = ""
+			}
+		}
+	}
+}
+
+// FileSink appends each event as a line of JSON to a file.
+type FileSink struct {
+	Path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Name identifies the sink in logs.
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+// Send appends `ev`, opening the file on first use.
+func (s *FileSink) Send(ctx context.Context, ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		s.f = f
+	}
+	_, err = s.f.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// WebhookSink POSTs each event as JSON to a URL.
+type WebhookSink struct {
+	URL string
+}
+
+// Name identifies the sink in logs.
+func (s WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send posts `ev` as JSON.
+func (s WebhookSink) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sendNotification(req)
+}
+
+// sseHeartbeat is how often an idle SSE stream sends a comment, so
+// proxies don't time it out.
+var sseHeartbeat = 30 * time.Second
+
+// HandleEventStreamRequest handles GET /api/v1/events, a Server-Sent
+// Events stream of the bus. `topics` narrows it (comma-separated) and
+// `account` keeps only that account's events. Each message's event name
+// is "<topic>.<type>" and its data is the Event as JSON.
+func HandleEventStreamRequest(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	topics, err := ParseEventTopics(r.URL.Query().Get("topics"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+	account := strings.ToLower(r.URL.Query().Get("account"))
+
+	// The stream outlives HTTP_WRITE_TIMEOUT; a writer that can't lift
+	// the deadline just ends the stream early.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	events, unsubscribe := SubscribeEvents(topics...)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if account != "" && ev.Account != account {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s.%s\ndata: %s\n\n", ev.Topic, ev.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
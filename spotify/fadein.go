@@ -0,0 +1,237 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Alarm-style fade-in. A play with fadein=10m and
+// target_volume=70 starts quietly (at `volume`, or fadeInStartVolume) and
+// raises the volume in steps until it reaches the target at the end of
+// the fade. Pausing by hand, playback moving to another device, or a new
+// play on the account stops the fade where it is.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// fadeInStartVolume is where a fade-in starts when the request doesn't
+// set a volume.
+const fadeInStartVolume = 5
+
+// Fade-ins must last between minFadeIn and maxFadeIn.
+const (
+	minFadeIn = 10 * time.Second
+	maxFadeIn = 2 * time.Hour
+)
+
+// fadeInMinStep is the shortest time between two volume changes, which
+// keeps a long fade from spending the Spotify API budget. A variable so
+// tests can shorten it.
+var fadeInMinStep = 5 * time.Second
+
+// parseFadeIn parses a fadein value like "10m".
+func parseFadeIn(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid fadein %q (use a value like 10m)", s)
+	}
+	if d < minFadeIn || d > maxFadeIn {
+		return 0, fmt.Errorf("fadein must be between %s and %s, got %s", formatDuration(minFadeIn), formatDuration(maxFadeIn), formatDuration(d))
+	}
+	return d, nil
+}
+
+// fadeInStart is the volume a fade-in starts from.
+func (req PlayRequest) fadeInStart() int {
+	if req.Volume != nil {
+		return *req.Volume
+	}
+	return fadeInStartVolume
+}
+
+// validateFadeIn checks req.FadeIn and req.TargetVolume, which only make
+// sense together, with the target above the starting volume.
+func (req PlayRequest) validateFadeIn() error {
+	if req.FadeIn == "" {
+		if req.TargetVolume != nil {
+			return fmt.Errorf("target_volume needs fadein")
+		}
+		return nil
+	}
+	if req.TargetVolume == nil {
+		return fmt.Errorf("fadein needs target_volume")
+	}
+	if *req.TargetVolume < 0 || *req.TargetVolume > 100 {
+		return fmt.Errorf("target_volume must be between 0 and 100, got %d", *req.TargetVolume)
+	}
+	if _, err := parseFadeIn(req.FadeIn); err != nil {
+		return err
+	}
+	if start := req.fadeInStart(); start >= *req.TargetVolume {
+		return fmt.Errorf("target_volume (%d) must be above the starting volume (%d)", *req.TargetVolume, start)
+	}
+	return nil
+}
+
+// fadeIn is one account's running fade-in.
+type fadeIn struct {
+	deviceID spotifyLib.ID
+	started  time.Time
+	cancel   context.CancelFunc
+}
+
+// FadeInWatcher holds the running fade-ins, one per account, and is
+// subscribed to playback events while it holds any so a manual pause can
+// stop them.
+type FadeInWatcher struct {
+	mu          sync.Mutex
+	fades       map[string]*fadeIn
+	unsubscribe func()
+}
+
+// fadeIns is the process-wide fade-in watcher.
+var fadeIns = &FadeInWatcher{}
+
+// startFadeIn starts raising the volume on `device` for the play Play
+// just started on ctx's account, replacing any earlier fade there, and
+// returns a note for Play's message. A play without a fade-in just stops
+// the earlier one.
+func startFadeIn(ctx context.Context, req PlayRequest, device *spotifyLib.PlayerDevice) string {
+	account := AccountFrom(ctx)
+	if req.FadeIn == "" {
+		fadeIns.clear(account)
+		return ""
+	}
+	client, err := clientFor(ctx)
+	if err != nil {
+		return ""
+	}
+	d, _ := parseFadeIn(req.FadeIn)
+	from, to := req.fadeInStart(), *req.TargetVolume
+	steps, every := fadeInSteps(from, to, d)
+
+	// The fade outlives the request; keep only its account.
+	fadeCtx, cancel := context.WithCancel(WithAccount(context.Background(), account))
+	f := &fadeIn{deviceID: device.ID, started: time.Now(), cancel: cancel}
+	fadeIns.add(account, f)
+	go func() {
+		defer fadeIns.done(account, f)
+		runFadeIn(fadeCtx, client, device.ID, from, to, steps, every)
+	}()
+	return fmt.Sprintf("; fading in to %d%% over %s", to, formatDuration(d))
+}
+
+// fadeInSteps splits a fade from `from` to `to` over `d` into even steps,
+// at most one per volume point and no closer together than fadeInMinStep.
+func fadeInSteps(from, to int, d time.Duration) (steps int, every time.Duration) {
+	steps = max(min(to-from, int(d/fadeInMinStep)), 1)
+	return steps, d / time.Duration(steps)
+}
+
+// runFadeIn raises the volume on `deviceID` from `from` to `to` in
+// `steps` steps, one `every`, until done or ctx is cancelled. A cancelled
+// fade leaves the volume where it got to.
+func runFadeIn(ctx context.Context, client Client, deviceID spotifyLib.ID, from, to, steps int, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	opts := &spotifyLib.PlayOptions{DeviceID: &deviceID}
+	for i := 1; i <= steps; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := client.VolumeOpt(ctx, from+(to-from)*i/steps, opts); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("fadein (%s): %v", AccountFrom(ctx), err)
+			}
+			return
+		}
+	}
+}
+
+// add installs `f` for `account`, stopping any earlier fade there and
+// subscribing to playback events if this is the first fade.
+func (fw *FadeInWatcher) add(account string, f *fadeIn) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.removeLocked(account)
+	if fw.fades == nil {
+		fw.fades = map[string]*fadeIn{}
+	}
+	fw.fades[account] = f
+
+	if fw.unsubscribe == nil {
+		events, unsubscribe := SubscribeEvents(TopicPlayback)
+		fw.unsubscribe = unsubscribe
+		go func() {
+			for ev := range events {
+				if pe, ok := ev.Data.(PlaybackEvent); ok {
+					fw.handle(ev.Account, pe)
+				}
+			}
+		}()
+	}
+}
+
+// clear stops `account`'s fade.
+func (fw *FadeInWatcher) clear(account string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.removeLocked(account)
+}
+
+// done drops `f` once it has finished, unless another fade replaced it.
+func (fw *FadeInWatcher) done(account string, f *fadeIn) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.fades[account] == f {
+		fw.removeLocked(account)
+	}
+}
+
+// removeLocked stops and drops `account`'s fade, unsubscribing once none
+// are left. Callers hold fw.mu.
+func (fw *FadeInWatcher) removeLocked(account string) {
+	f := fw.fades[account]
+	if f == nil {
+		return
+	}
+	f.cancel()
+	delete(fw.fades, account)
+	if len(fw.fades) == 0 && fw.unsubscribe != nil {
+		fw.unsubscribe()
+		fw.unsubscribe = nil
+	}
+}
+
+// handle stops `account`'s fade when playback is paused or moves to
+// another device after the fade started. The fade never pauses, so a
+// pause is the user's.
+func (fw *FadeInWatcher) handle(account string, ev PlaybackEvent) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	f := fw.fades[account]
+	if f == nil || ev.Time.Before(f.started) {
+		return
+	}
+	switch {
+	case ev.Type == EventPaused:
+		log.Printf("fadein (%s): stopped, playback was paused", account)
+	case ev.Type == EventDeviceChanged && ev.DeviceID != string(f.deviceID):
+		log.Printf("fadein (%s): stopped, playback moved to %s", account, ev.DeviceName)
+	default:
+		return
+	}
+	fw.removeLocked(account)
+}
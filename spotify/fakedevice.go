@@ -0,0 +1,416 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: A simulated Spotify Connect device for development.
+// -fake-device "Dev Speaker" adds a speaker that exists only in this
+// process: it shows up in the default account's device list (and so in
+// the device registry), and play, pause, volume, and the other player
+// commands aimed at it are logged and kept in a simulated player instead
+// of reaching Spotify. Everything else — resolution, presets, rules,
+// events — runs for real, so the whole request pipeline can be exercised
+// without any speaker on the network.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// fakeDeviceType is the device type the simulated device reports.
+const fakeDeviceType = "Speaker"
+
+// fakeDevice is the simulated device and its player. While active it holds
+// the account's session: commands without a device go to it, and the
+// player state is its own.
+type fakeDevice struct {
+	mu     sync.Mutex
+	device spotifyLib.PlayerDevice
+	now    func() time.Time
+
+	active     bool
+	playing    bool
+	contextURI spotifyLib.URI
+	uris       []spotifyLib.URI
+	index      int
+	// progress is the position at `since`; while playing it advances
+	// from there.
+	progress time.Duration
+	since    time.Time
+	shuffle  bool
+	repeat   string
+	// item caches the track at index, fetched from Spotify.
+	item      *spotifyLib.FullTrack
+	itemIndex int
+}
+
+// simulated is the fake device, or nil when -fake-device isn't set.
+var simulated *fakeDevice
+
+// EnableFakeDevice adds a simulated device called `name` to the default
+// account. Call it before SetClient.
+func EnableFakeDevice(name string) {
+	simulated = &fakeDevice{
+		device: spotifyLib.PlayerDevice{
+			ID:     spotifyLib.ID("fake-" + strings.TrimPrefix(StableDeviceID(name, fakeDeviceType), "dev-")),
+			Name:   name,
+			Type:   fakeDeviceType,
+			Volume: 50,
+		},
+		now:    time.Now,
+		repeat: "off",
+	}
+	log.Printf("fake device %q enabled (id %s); commands to it are logged, not sent to Spotify", name, simulated.device.ID)
+}
+
+// IsFakeDevice reports whether `ref` names the simulated device.
+func IsFakeDevice(ref string) bool {
+	return simulated != nil && ref != "" && findDevice([]spotifyLib.PlayerDevice{simulated.device}, ref) != nil
+}
+
+// withFakeDevice wraps `c` with the simulated device, if it's enabled.
+func withFakeDevice(c Client) Client {
+	if simulated == nil || c == nil {
+		return c
+	}
+	return &fakeDeviceClient{Client: c, fake: simulated}
+}
+
+// fakeDeviceClient is a Client with the simulated device added. Calls it
+// doesn't intercept go to the wrapped client.
+type fakeDeviceClient struct {
+	Client
+	fake *fakeDevice
+}
+
+// targets reports whether a command aimed at `id` (nil for the active
+// device) is for the simulated device.
+func (f *fakeDevice) targets(id *spotifyLib.ID) bool {
+	if id != nil {
+		return *id == f.device.ID
+	}
+	return f.active
+}
+
+// position returns the current position, counting time played since the
+// last change.
+func (f *fakeDevice) position() time.Duration {
+	if !f.playing {
+		return f.progress
+	}
+	return f.progress + f.now().Sub(f.since)
+}
+
+// setPosition moves to `p`, from now.
+func (f *fakeDevice) setPosition(p time.Duration) {
+	f.progress, f.since = p, f.now()
+}
+
+// logf logs a command the simulated device received.
+func (f *fakeDevice) logf(format string, args ...any) {
+	log.Printf("fake device %q: %s", f.device.Name, fmt.Sprintf(format, args...))
+}
+
+// PlayerDevices lists the account's devices plus the simulated one. While
+// it holds the session no other device is active.
+func (c *fakeDeviceClient) PlayerDevices(ctx context.Context) ([]spotifyLib.PlayerDevice, error) {
+	devices, err := c.Client.PlayerDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+
+	out := make([]spotifyLib.PlayerDevice, 0, len(devices)+1)
+	for _, d := range devices {
+		if c.fake.active {
+			d.Active = false
+		}
+		out = append(out, d)
+	}
+	fake := c.fake.device
+	fake.Active = c.fake.active
+	return append(out, fake), nil
+}
+
+// PlayOpt starts playback on the simulated device when it's the target,
+// and otherwise hands the session back to Spotify.
+func (c *fakeDeviceClient) PlayOpt(ctx context.Context, opts *spotifyLib.PlayOptions) error {
+	var id *spotifyLib.ID
+	if opts != nil {
+		id = opts.DeviceID
+	}
+	c.fake.mu.Lock()
+	if !c.fake.targets(id) {
+		c.fake.active = false
+		c.fake.mu.Unlock()
+		return c.Client.PlayOpt(ctx, opts)
+	}
+	defer c.fake.mu.Unlock()
+
+	f := c.fake
+	f.active = true
+	if opts == nil || (opts.PlaybackContext == nil && len(opts.URIs) == 0) {
+		f.logf("resume")
+		f.since, f.playing = f.now(), true
+		return nil
+	}
+
+	f.contextURI, f.uris, f.index, f.item = "", opts.URIs, 0, nil
+	if opts.PlaybackContext != nil {
+		f.contextURI = *opts.PlaybackContext
+	}
+	if off := opts.PlaybackOffset; off != nil {
+		switch {
+		case off.Position != nil:
+			f.index = *off.Position
+		case off.URI != "":
+			for i, u := range f.uris {
+				if u == off.URI {
+					f.index = i
+				}
+			}
+		}
+	}
+	f.setPosition(time.Duration(opts.PositionMs) * time.Millisecond)
+	f.playing = true
+	f.logf("play context=%q uris=%d offset=%d position=%s", f.contextURI, len(f.uris), f.index, f.progress)
+	return nil
+}
+
+// Pause pauses the simulated device while it holds the session.
+func (c *fakeDeviceClient) Pause(ctx context.Context) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.Pause(ctx)
+	}
+	c.fake.progress, c.fake.playing = c.fake.position(), false
+	c.fake.logf("pause at %s", c.fake.progress)
+	return nil
+}
+
+// PauseOpt pauses the simulated device when it's the target.
+func (c *fakeDeviceClient) PauseOpt(ctx context.Context, opt *spotifyLib.PlayOptions) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.targets(opt.DeviceID) {
+		return c.Client.PauseOpt(ctx, opt)
+	}
+	c.fake.progress, c.fake.playing = c.fake.position(), false
+	c.fake.logf("pause at %s", c.fake.progress)
+	return nil
+}
+
+// Volume sets the simulated device's volume while it holds the session.
+func (c *fakeDeviceClient) Volume(ctx context.Context, percent int) error {
+	return c.VolumeOpt(ctx, percent, &spotifyLib.PlayOptions{})
+}
+
+// VolumeOpt sets the simulated device's volume when it's the target.
+func (c *fakeDeviceClient) VolumeOpt(ctx context.Context, percent int, opt *spotifyLib.PlayOptions) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.targets(opt.DeviceID) {
+		if opt.DeviceID == nil {
+			return c.Client.Volume(ctx, percent)
+		}
+		return c.Client.VolumeOpt(ctx, percent, opt)
+	}
+	c.fake.device.Volume = spotifyLib.Numeric(percent)
+	c.fake.logf("volume %d%%", percent)
+	return nil
+}
+
+// Shuffle sets shuffle on the simulated device while it holds the
+// session. It's only reported; the simulated order doesn't change.
+func (c *fakeDeviceClient) Shuffle(ctx context.Context, shuffle bool) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.Shuffle(ctx, shuffle)
+	}
+	c.fake.shuffle = shuffle
+	c.fake.logf("shuffle %v", shuffle)
+	return nil
+}
+
+// Repeat sets the repeat mode on the simulated device while it holds the
+// session.
+func (c *fakeDeviceClient) Repeat(ctx context.Context, state string) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.Repeat(ctx, state)
+	}
+	c.fake.repeat = state
+	c.fake.logf("repeat %s", state)
+	return nil
+}
+
+// Next skips to the next track on the simulated device while it holds the
+// session.
+func (c *fakeDeviceClient) Next(ctx context.Context) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.Next(ctx)
+	}
+	c.fake.index++
+	c.fake.setPosition(0)
+	c.fake.logf("next, now at track %d", c.fake.index)
+	return nil
+}
+
+// Seek moves the simulated device's position while it holds the session.
+func (c *fakeDeviceClient) Seek(ctx context.Context, position int) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.Seek(ctx, position)
+	}
+	c.fake.setPosition(time.Duration(position) * time.Millisecond)
+	c.fake.logf("seek to %s", c.fake.progress)
+	return nil
+}
+
+// QueueSong queues a track on the simulated device while it holds the
+// session: it's added to the end of a track list, and only logged for a
+// context.
+func (c *fakeDeviceClient) QueueSong(ctx context.Context, trackID spotifyLib.ID) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.QueueSong(ctx, trackID)
+	}
+	if c.fake.contextURI == "" {
+		c.fake.uris = append(c.fake.uris, spotifyLib.URI("spotify:track:"+string(trackID)))
+	}
+	c.fake.logf("queue %s", trackID)
+	return nil
+}
+
+// TransferPlayback moves the session to or from the simulated device.
+func (c *fakeDeviceClient) TransferPlayback(ctx context.Context, deviceID spotifyLib.ID, play bool) error {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if deviceID != c.fake.device.ID {
+		c.fake.active = false
+		return c.Client.TransferPlayback(ctx, deviceID, play)
+	}
+	c.fake.active = true
+	c.fake.progress = c.fake.position()
+	c.fake.since, c.fake.playing = c.fake.now(), play
+	c.fake.logf("session transferred here (play=%v)", play)
+	return nil
+}
+
+// PlayerState reports the simulated player while the simulated device
+// holds the session.
+func (c *fakeDeviceClient) PlayerState(ctx context.Context, opts ...spotifyLib.RequestOption) (*spotifyLib.PlayerState, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.PlayerState(ctx, opts...)
+	}
+	return c.fake.state(ctx, c.Client), nil
+}
+
+// PlayerCurrentlyPlaying reports the simulated player while the simulated
+// device holds the session.
+func (c *fakeDeviceClient) PlayerCurrentlyPlaying(ctx context.Context, opts ...spotifyLib.RequestOption) (*spotifyLib.CurrentlyPlaying, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if !c.fake.active {
+		return c.Client.PlayerCurrentlyPlaying(ctx, opts...)
+	}
+	current := c.fake.state(ctx, c.Client).CurrentlyPlaying
+	return &current, nil
+}
+
+// state builds the simulated player state. Tracks are looked up on
+// Spotify as they come up, and playing past the end of one moves on to
+// the next, so track changes and the end of the context happen as they
+// would on a real speaker.
+func (f *fakeDevice) state(ctx context.Context, client Client) *spotifyLib.PlayerState {
+	item := f.track(ctx, client)
+	// Bounded, in case Spotify reports a run of zero-length tracks.
+	for i := 0; i < 100 && f.playing && item != nil && item.Duration > 0 && f.position() >= item.TimeDuration(); i++ {
+		f.progress, f.since = f.position()-item.TimeDuration(), f.now()
+		f.index++
+		if item = f.track(ctx, client); item == nil {
+			f.playing, f.progress = false, 0
+			f.logf("reached the end of the context")
+		}
+	}
+
+	device := f.device
+	device.Active = true
+	return &spotifyLib.PlayerState{
+		CurrentlyPlaying: spotifyLib.CurrentlyPlaying{
+			Timestamp:       f.now().UnixMilli(),
+			PlaybackContext: spotifyLib.PlaybackContext{URI: f.contextURI},
+			Progress:        spotifyLib.Numeric(f.position().Milliseconds()),
+			Playing:         f.playing,
+			Item:            item,
+		},
+		Device:       device,
+		ShuffleState: f.shuffle,
+		RepeatState:  f.repeat,
+	}
+}
+
+// track returns the track at the current index: from the track list, or
+// the playlist or album context. It's nil past the end, for contexts it
+// can't page through (artists, Liked Songs), and if Spotify can't say.
+func (f *fakeDevice) track(ctx context.Context, client Client) *spotifyLib.FullTrack {
+	if f.item != nil && f.itemIndex == f.index {
+		return f.item
+	}
+
+	var item *spotifyLib.FullTrack
+	switch {
+	case len(f.uris) > 0:
+		if f.index >= len(f.uris) {
+			return nil
+		}
+		r, err := spotifyuri.Parse(string(f.uris[f.index]))
+		if err != nil || r.Type != spotifyuri.Track {
+			return nil
+		}
+		if item, err = client.GetTrack(ctx, spotifyLib.ID(r.ID)); err != nil {
+			return nil
+		}
+	case f.contextURI != "":
+		r, err := spotifyuri.Parse(string(f.contextURI))
+		if err != nil {
+			return nil
+		}
+		switch r.Type {
+		case spotifyuri.Playlist:
+			page, err := client.GetPlaylistItems(ctx, spotifyLib.ID(r.ID), spotifyLib.Offset(f.index), spotifyLib.Limit(1))
+			if err != nil || len(page.Items) == 0 || page.Items[0].Track.Track == nil {
+				return nil
+			}
+			item = page.Items[0].Track.Track
+		case spotifyuri.Album:
+			page, err := client.GetAlbumTracks(ctx, spotifyLib.ID(r.ID), spotifyLib.Offset(f.index), spotifyLib.Limit(1))
+			if err != nil || len(page.Tracks) == 0 {
+				return nil
+			}
+			item = &spotifyLib.FullTrack{SimpleTrack: page.Tracks[0]}
+		}
+	}
+	f.item, f.itemIndex = item, f.index
+	return item
+}
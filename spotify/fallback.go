@@ -0,0 +1,259 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Device fallbacks. A play request that names no device, or
+// one that can't be found, walks the fallback chain
+// (SPOTIFY_DEVICE_FALLBACK="Office Speaker,Living Room,any"). When it
+// starts somewhere other than the device it named, the response says where
+// playback actually went and which chain entry picked it, the fallback is kept in a persisted log
+// (/api/v1/fallbacks), and the notifier channels are told if
+// NOTIFY_FALLBACKS is on — so music in the wrong room leaves a trace.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// DefaultFallbackLogFile is where fallbacks are kept unless overridden.
+const DefaultFallbackLogFile = ".spotify_fallbacks.json"
+
+// fallbackAny is the fallback chain entry that takes the active device,
+// else the first one listed.
+const fallbackAny = "any"
+
+// deviceFallbackChain is the devices tried, in order, when a play names no
+// device or the one it names can't be found. Entries are device names, IDs,
+// or groups, or fallbackAny.
+var deviceFallbackChain = []string{fallbackAny}
+
+// SetDeviceFallback sets the fallback chain from a comma-separated list,
+// like "Office Speaker,Living Room,any". Without "any" at the end, a play
+// fails rather than starting on a device not in the list. Empty restores
+// the default of just "any".
+func SetDeviceFallback(list string) {
+	var chain []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			chain = append(chain, entry)
+		}
+	}
+	if len(chain) == 0 {
+		chain = []string{fallbackAny}
+	}
+	deviceFallbackChain = chain
+}
+
+// FallbackDevice walks the fallback chain and returns the first device in
+// `devices` it matches, with the entry that matched. The device is nil if
+// no entry does.
+func FallbackDevice(devices []spotifyLib.PlayerDevice) (*spotifyLib.PlayerDevice, string) {
+	for _, entry := range deviceFallbackChain {
+		if !strings.EqualFold(entry, fallbackAny) {
+			if d := lookupDevice(devices, entry); d != nil {
+				return d, entry
+			}
+			continue
+		}
+		if len(devices) == 0 {
+			continue
+		}
+		for i, d := range devices {
+			if d.Active {
+				return &devices[i], fallbackAny
+			}
+		}
+		return &devices[0], fallbackAny
+	}
+	return nil, ""
+}
+
+// maxFallbackEvents caps the log; the oldest events are dropped first.
+const maxFallbackEvents = 200
+
+// PlayedDevice is the device a play request started on.
+type PlayedDevice struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Requested is the device the request named, if any.
+	Requested string `json:"requested,omitempty"`
+	// Fallback is set when Requested couldn't be found and this device
+	// was used instead.
+	Fallback bool `json:"fallback"`
+	// Via is the fallback chain entry that picked the device, when the
+	// chain was walked.
+	Via string `json:"via,omitempty"`
+}
+
+// playedDeviceKey is the context key for a request's PlayedDevice.
+type playedDeviceKey struct{}
+
+// WithPlayedDevice returns ctx with a slot Play fills with the device it
+// started on, and the slot.
+func WithPlayedDevice(ctx context.Context) (context.Context, *PlayedDevice) {
+	p := &PlayedDevice{}
+	return context.WithValue(ctx, playedDeviceKey{}, p), p
+}
+
+// orNil returns p, or nil when nothing was played, for omitting it from
+// responses.
+func (p *PlayedDevice) orNil() *PlayedDevice {
+	if p == nil || p.ID == "" {
+		return nil
+	}
+	return p
+}
+
+// notePlayedDevice fills ctx's PlayedDevice slot, if it has one.
+func notePlayedDevice(ctx context.Context, d PlayedDevice) {
+	if p, ok := ctx.Value(playedDeviceKey{}).(*PlayedDevice); ok {
+		*p = d
+	}
+}
+
+// FallbackEvent is one play that landed on another device than requested.
+type FallbackEvent struct {
+	Time      time.Time `json:"time"`
+	Account   string    `json:"account"`
+	Requested string    `json:"requested"`
+	Via       string    `json:"via,omitempty"`
+	DeviceID  string    `json:"device_id"`
+	Device    string    `json:"device"`
+	Playlist  string    `json:"playlist"`
+	Album     string    `json:"album,omitempty"`
+	Artist    string    `json:"artist,omitempty"`
+	Track     string    `json:"track,omitempty"`
+	Audiobook string    `json:"audiobook,omitempty"`
+	Show      string    `json:"show,omitempty"`
+}
+
+// FallbackLog is the persisted list of recent fallbacks.
+type FallbackLog struct {
+	mu     sync.Mutex
+	path   string
+	events []FallbackEvent
+}
+
+// fallbackLog is the process-wide log; nil disables it.
+var fallbackLog *FallbackLog
+
+// SetFallbackLog sets the process-wide fallback log.
+func SetFallbackLog(l *FallbackLog) {
+	fallbackLog = l
+}
+
+// notifyFallbacks sends each fallback to the notifier channels.
+var notifyFallbacks bool
+
+// SetNotifyFallbacks turns fallback notifications on or off.
+func SetNotifyFallbacks(on bool) {
+	notifyFallbacks = on
+}
+
+// OpenFallbackLog loads the fallback log at `path`, starting empty if the
+// file doesn't exist yet.
+func OpenFallbackLog(path string) (*FallbackLog, error) {
+	l := &FallbackLog{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read fallback log: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.events); err != nil {
+		return nil, fmt.Errorf("parse fallback log %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Record appends `ev`, drops the oldest events past the cap, and saves.
+func (l *FallbackLog) Record(ev FallbackEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, ev)
+	if len(l.events) > maxFallbackEvents {
+		l.events = l.events[len(l.events)-maxFallbackEvents:]
+	}
+	return l.save()
+}
+
+// List returns the logged fallbacks, newest first.
+func (l *FallbackLog) List() []FallbackEvent {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]FallbackEvent, len(l.events))
+	for i, ev := range l.events {
+		out[len(out)-1-i] = ev
+	}
+	return out
+}
+
+// save writes the log via a temp file and rename. Callers hold l.mu.
+func (l *FallbackLog) save() error {
+	data, err := json.MarshalIndent(l.events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode fallback log: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write fallback log: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write fallback log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write fallback log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write fallback log: %w", err)
+	}
+	return nil
+}
+
+// recordFallback logs `ev` and publishes it on the event bus, where the
+// notifier channels pick it up with NOTIFY_FALLBACKS.
+func recordFallback(ctx context.Context, ev FallbackEvent) {
+	if fallbackLog != nil {
+		if err := fallbackLog.Record(ev); err != nil {
+			log.Printf("Warning: failed to save fallback log: %v", err)
+		}
+	}
+	publishEvent(TopicFallback, EventDeviceFallback, ev.Account, ev)
+}
+
+// fallbackNotification describes `ev` for the notifier channels.
+func fallbackNotification(ev FallbackEvent) Notification {
+	return Notification{
+		Kind:    "fallback",
+		Title:   "Playing on another device",
+		Message: fmt.Sprintf("%q wasn't available, so %s started on %s", ev.Requested, ev.Playlist, ev.Device),
+		Data:    ev,
+	}
+}
@@ -0,0 +1,231 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Device groups. A group names several speakers, like
+// "downstairs" for the living room and the kitchen, and works wherever a
+// device name does. Spotify plays on one device per account, so there's
+// no synced playback: a play goes to the group's primary, the first member
+// that's online. Volume and pause go to every member.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// DeviceGroup is a named set of devices. Devices are names, Spotify IDs,
+// or stable IDs. Primary, if set, is played on first; otherwise members
+// are tried in order.
+type DeviceGroup struct {
+	Name    string   `json:"name"`
+	Devices []string `json:"devices"`
+	Primary string   `json:"primary,omitempty"`
+}
+
+// GroupMember is a group's member as /api/v1/groups lists it. Name and ID
+// are empty when the device isn't online.
+type GroupMember struct {
+	Ref    string `json:"ref"`
+	Name   string `json:"name,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Online bool   `json:"online"`
+}
+
+// GroupInfo is a group with its members and the device a play would use
+// now, if any is online.
+type GroupInfo struct {
+	Name    string        `json:"name"`
+	Primary string        `json:"primary,omitempty"`
+	Devices []GroupMember `json:"devices"`
+}
+
+// FindGroup looks up a device group by name (case insensitive).
+func (s *Settings) FindGroup(name string) (DeviceGroup, bool) {
+	if name == "" {
+		return DeviceGroup{}, false
+	}
+	for _, g := range s.Groups {
+		if strings.EqualFold(g.Name, name) {
+			return g, true
+		}
+	}
+	return DeviceGroup{}, false
+}
+
+// IsDeviceGroup reports whether `name` names a device group.
+func IsDeviceGroup(name string) bool {
+	_, ok := GetSettings().FindGroup(name)
+	return ok
+}
+
+// members returns the group's devices in the order they're tried:
+// Primary, then the rest.
+func (g DeviceGroup) members() []string {
+	if g.Primary == "" {
+		return g.Devices
+	}
+	out := []string{g.Primary}
+	for _, d := range g.Devices {
+		if !strings.EqualFold(d, g.Primary) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// primaryRef is the member tried first, or "" for an empty group.
+func (g DeviceGroup) primaryRef() string {
+	if m := g.members(); len(m) > 0 {
+		return m[0]
+	}
+	return ""
+}
+
+// pick returns the first member in `devices`, or nil when none is.
+func (g DeviceGroup) pick(devices []spotifyLib.PlayerDevice) *spotifyLib.PlayerDevice {
+	for _, ref := range g.members() {
+		if d := findDevice(devices, ref); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// has reports whether `d` is a member of the group.
+func (g DeviceGroup) has(d spotifyLib.PlayerDevice) bool {
+	for _, ref := range g.Devices {
+		if findDevice([]spotifyLib.PlayerDevice{d}, ref) != nil {
+			return true
+		}
+	}
+	return g.Primary != "" && findDevice([]spotifyLib.PlayerDevice{d}, g.Primary) != nil
+}
+
+// lookupDevice is findDevice that also takes a group name, for which it
+// returns the group's primary.
+func lookupDevice(devices []spotifyLib.PlayerDevice, ref string) *spotifyLib.PlayerDevice {
+	if g, ok := GetSettings().FindGroup(ref); ok {
+		return g.pick(devices)
+	}
+	return findDevice(devices, ref)
+}
+
+// groupsOf returns the names of the groups `d` belongs to.
+func groupsOf(d spotifyLib.PlayerDevice) []string {
+	var names []string
+	for _, g := range GetSettings().Groups {
+		if g.has(d) {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// SetGroupVolume sets the volume on every member of group `g` that's
+// online, each through its own account. Members that fail are reported as
+// warnings; it fails only when none could be set.
+func SetGroupVolume(ctx context.Context, percent int, g DeviceGroup) (string, error) {
+	var set []string
+	var lastErr error
+	for _, ref := range g.members() {
+		if _, err := setDeviceVolume(ctx, percent, ref); err != nil {
+			warnf(ctx, "failed to set volume on %s: %v", ref, err)
+			lastErr = err
+			continue
+		}
+		set = append(set, ref)
+	}
+	if len(set) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("group %q has no devices", g.Name)
+		}
+		return "", fmt.Errorf("failed to set volume on group %s: %w", g.Name, lastErr)
+	}
+	return fmt.Sprintf("Volume set to %d%% on %s (group %s)", percent, strings.Join(set, ", "), g.Name), nil
+}
+
+// PauseGroup pauses playback on whichever members of group `name` are
+// playing. Each account plays on one device at a time, so every account
+// the members belong to is checked once and paused if its active device
+// is in the group; playback elsewhere is left alone.
+func PauseGroup(ctx context.Context, name string) (string, error) {
+	g, ok := GetSettings().FindGroup(name)
+	if !ok {
+		return "", withCode(CodeNotFound, fmt.Errorf("unknown device group %q", name))
+	}
+
+	var paused []string
+	checked := map[string]bool{}
+	for _, ref := range g.members() {
+		mctx := routeByDevice(ctx, ref)
+		account := AccountFrom(mctx)
+		if checked[account] {
+			continue
+		}
+		checked[account] = true
+
+		client, err := clientFor(mctx)
+		if err != nil {
+			warnf(ctx, "can't check %s: %v", ref, err)
+			continue
+		}
+		state, err := client.PlayerState(mctx)
+		if err != nil {
+			warnf(ctx, "failed to get playback state for %s: %v", ref, err)
+			continue
+		}
+		if state == nil || !state.Playing || !g.has(state.Device) {
+			continue
+		}
+		if err := client.PauseOpt(mctx, &spotifyLib.PlayOptions{DeviceID: &state.Device.ID}); err != nil {
+			return "", fmt.Errorf("failed to pause %s: %w", state.Device.Name, err)
+		}
+		paused = append(paused, state.Device.Name)
+	}
+
+	if len(paused) == 0 {
+		return fmt.Sprintf("Nothing playing in group %s", g.Name), nil
+	}
+	return fmt.Sprintf("Paused %s (group %s)", strings.Join(paused, ", "), g.Name), nil
+}
+
+// ListGroups lists the configured groups with which members are online,
+// looking each member up in the devices of the account it plays on.
+func ListGroups(ctx context.Context) ([]GroupInfo, error) {
+	devicesOf := map[string][]spotifyLib.PlayerDevice{}
+	groups := GetSettings().Groups
+	out := make([]GroupInfo, 0, len(groups))
+	for _, g := range groups {
+		info := GroupInfo{Name: g.Name, Devices: []GroupMember{}}
+		for _, ref := range g.members() {
+			mctx := routeByDevice(ctx, ref)
+			account := AccountFrom(mctx)
+			devices, ok := devicesOf[account]
+			if !ok {
+				var err error
+				if devices, err = ListDevices(mctx); err != nil {
+					return nil, err
+				}
+				devicesOf[account] = devices
+			}
+
+			member := GroupMember{Ref: ref}
+			if d := findDevice(devices, ref); d != nil {
+				member.Name, member.ID, member.Online = d.Name, string(d.ID), true
+				if info.Primary == "" {
+					info.Primary = d.Name
+				}
+			}
+			info.Devices = append(info.Devices, member)
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
@@ -0,0 +1,249 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Cross-instance playback handoff. One instance snapshots
+// what's playing (context, track, position, shuffle), sends it to a peer
+// instance listed in the settings file, and the peer resumes it on its own
+// local speaker — e.g. the office instance hands an audiobook playlist to
+// the home instance at the end of the day.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// Peer is another spotify-shortcut instance we can hand playback to.
+// Token is that instance's API_ACCESS_TOKEN.
+type Peer struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// HandoffSnapshot is the playback state carried from one instance to
+// another. Field names double as the receive endpoint's parameters.
+type HandoffSnapshot struct {
+	ContextURI string `json:"context_uri,omitempty"`
+	TrackURI   string `json:"track_uri"`
+	PositionMs int    `json:"position_ms"`
+	Shuffle    bool   `json:"shuffle"`
+	// Device optionally names the receiving instance's device. Empty
+	// means that instance's HANDOFF_DEVICE, or its active/first device.
+	Device string `json:"device,omitempty"`
+
+	// sourceDevice is where the snapshot was taken; it stays local.
+	sourceDevice spotifyLib.ID
+}
+
+// handoffDevice is the device this instance resumes handoffs on when the
+// sender doesn't name one. Empty means active or first device.
+var handoffDevice string
+
+// SetHandoffDevice sets the default device for received handoffs.
+func SetHandoffDevice(name string) {
+	handoffDevice = name
+}
+
+// handoffHTTPClient is used for peer calls. Peers resume playback before
+// answering, which can include a zeroconf claim, so allow some time.
+var handoffHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// FindPeer looks up a configured peer by name (case insensitive) or by
+// its exact URL, ignoring a trailing slash.
+func (s *Settings) FindPeer(nameOrURL string) (Peer, bool) {
+	want := strings.TrimSuffix(nameOrURL, "/")
+	for _, p := range s.Peers {
+		if strings.EqualFold(p.Name, nameOrURL) || strings.TrimSuffix(p.URL, "/") == want {
+			return p, true
+		}
+	}
+	return Peer{}, false
+}
+
+// SnapshotPlayback captures what's playing on this instance's account.
+func SnapshotPlayback(ctx context.Context) (*HandoffSnapshot, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playback state: %w", err)
+	}
+	if state == nil || state.Item == nil {
+		return nil, fmt.Errorf("nothing is playing to hand off")
+	}
+
+	return &HandoffSnapshot{
+		ContextURI: string(state.PlaybackContext.URI),
+		TrackURI:   string(state.Item.URI),
+		PositionMs: int(state.Progress),
+		Shuffle:    state.ShuffleState,
+
+		sourceDevice: state.Device.ID,
+	}, nil
+}
+
+// ResumeSnapshot starts `snap` on this instance: the same context at the
+// same track and position, on snap.Device, HANDOFF_DEVICE, or the
+// active/first device.
+func ResumeSnapshot(ctx context.Context, snap HandoffSnapshot) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if snap.TrackURI == "" {
+		return "", fmt.Errorf("track_uri is required")
+	}
+
+	devices, err := client.PlayerDevices(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get devices: %w", err)
+	}
+	if len(devices) == 0 {
+		return "", withCode(CodeDevice, fmt.Errorf("no Spotify Connect devices found"))
+	}
+
+	want := snap.Device
+	if want == "" {
+		want = handoffDevice
+	}
+	target := findDevice(devices, want)
+	if target == nil && want != "" {
+		return "", withCode(CodeDevice, fmt.Errorf("device %q not in Spotify cloud devices list", want))
+	}
+	if target == nil {
+		target = &devices[0]
+		for i, d := range devices {
+			if d.Active {
+				target = &devices[i]
+				break
+			}
+		}
+	}
+
+	if err := resumeOn(ctx, client, snap, target); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Resumed on %s at %s", target.Name, formatPosition(snap.PositionMs)), nil
+}
+
+// resumeOn plays `snap` on `target`: its context from its track and
+// position where the context allows, else the track on its own.
+func resumeOn(ctx context.Context, client Client, snap HandoffSnapshot, target *spotifyLib.PlayerDevice) error {
+	opts := &spotifyLib.PlayOptions{
+		DeviceID:   &target.ID,
+		PositionMs: spotifyLib.Numeric(snap.PositionMs),
+	}
+	contextURI := spotifyLib.URI(snap.ContextURI)
+	ctxRes, _ := spotifyuri.Parse(snap.ContextURI)
+	switch ctxRes.Type {
+	case spotifyuri.Album, spotifyuri.Playlist:
+		opts.PlaybackContext = &contextURI
+		opts.PlaybackOffset = &spotifyLib.PlaybackOffset{URI: spotifyLib.URI(snap.TrackURI)}
+	default:
+		// Artist radio and the like can't start at a given track, and a
+		// bare track list has no context to carry over: play the track.
+		opts.URIs = []spotifyLib.URI{spotifyLib.URI(snap.TrackURI)}
+	}
+
+	if err := client.PlayOpt(ctx, opts); err != nil {
+		return fmt.Errorf("failed to resume playback: %w", err)
+	}
+
+	if snap.Shuffle {
+		enableShuffle(ctx, client)
+	}
+	return nil
+}
+
+// Handoff snapshots local playback, pauses it, and asks the peer `to` (a
+// configured peer name or URL) to resume it. Pausing first keeps both
+// speakers from playing at once when the peers use different Spotify
+// accounts, and is harmless when they share one (the peer's play moves
+// the session anyway). If the peer fails, local playback is resumed.
+// `device` optionally picks the peer's device.
+func Handoff(ctx context.Context, to, device string) (string, error) {
+	peer, ok := GetSettings().FindPeer(to)
+	if !ok {
+		return "", fmt.Errorf("unknown peer %q: add it under \"peers\" in the settings file", to)
+	}
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	snap, err := SnapshotPlayback(ctx)
+	if err != nil {
+		return "", err
+	}
+	snap.Device = device
+
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return "", err
+	}
+
+	if err := client.Pause(ctx); err != nil {
+		return "", fmt.Errorf("failed to pause before handoff: %w", err)
+	}
+
+	msg, err := sendHandoff(ctx, peer, body)
+	if err != nil {
+		resume := &spotifyLib.PlayOptions{}
+		if snap.sourceDevice != "" {
+			resume.DeviceID = &snap.sourceDevice
+		}
+		if resumeErr := client.PlayOpt(ctx, resume); resumeErr != nil {
+			warnf(ctx, "handoff failed and local playback could not be resumed: %v", resumeErr)
+		}
+		return "", err
+	}
+
+	return fmt.Sprintf("Handed off to %s: %s", peer.Name, msg), nil
+}
+
+// sendHandoff posts a snapshot to the peer's receive endpoint and returns
+// the peer's success message.
+func sendHandoff(ctx context.Context, peer Peer, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(peer.URL, "/")+"/api/v1/handoff/receive", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := handoffHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("peer %s unreachable: %w", peer.Name, err)
+	}
+	defer resp.Body.Close()
+
+	var peerResp APIResponse
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(data, &peerResp); err != nil {
+		return "", fmt.Errorf("peer %s returned HTTP %d: %s", peer.Name, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	for _, w := range peerResp.Warnings {
+		warnf(ctx, "peer %s: %s", peer.Name, w)
+	}
+	if !peerResp.Success {
+		return "", fmt.Errorf("peer %s failed to resume: %s", peer.Name, peerResp.Error)
+	}
+	return peerResp.Message, nil
+}
@@ -0,0 +1,330 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Local play-history database. A small JSON file records how
+// often each track has been heard through this tool, as an exponentially
+// decaying score, so queue-building modes can prefer songs that haven't
+// come up lately. In server mode a background recorder follows the
+// playback events on the event bus and credits each track when the
+// playback is one we started.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// DefaultHistoryFile is where play history is kept unless
+// SPOTIFY_HISTORY_FILE says otherwise.
+const DefaultHistoryFile = ".spotify_history.json"
+
+// DefaultHistoryHalfLife is how long it takes a play to count for half as
+// much. A month keeps last week's songs down without burying them forever.
+const DefaultHistoryHalfLife = 30 * 24 * time.Hour
+
+// TrackHistory is the per-track record stored in the history file. Score
+// is the decayed play count as of Updated.
+type TrackHistory struct {
+	Plays      int       `json:"plays"`
+	Score      float64   `json:"score"`
+	Updated    time.Time `json:"updated"`
+	LastPlayed time.Time `json:"last_played"`
+	// Features are the track's audio features, saved the first time
+	// they're known.
+	Features *AudioFeatures `json:"features,omitempty"`
+}
+
+// HistoryEntry is one track's record, with its URI, as /api/v1/history
+// lists it.
+type HistoryEntry struct {
+	URI string `json:"uri"`
+	TrackHistory
+}
+
+// HistoryDB is the on-disk play history. It is safe for concurrent use.
+type HistoryDB struct {
+	mu       sync.Mutex
+	path     string
+	halfLife time.Duration
+	tracks   map[string]*TrackHistory
+	now      func() time.Time
+}
+
+// history is the process-wide history DB. Nil means history is disabled
+// and least-played ordering treats every track as unplayed.
+var history *HistoryDB
+
+// SetHistory installs the process-wide history DB.
+func SetHistory(h *HistoryDB) {
+	history = h
+}
+
+// GetHistory returns the process-wide history DB, which may be nil.
+func GetHistory() *HistoryDB {
+	return history
+}
+
+// OpenHistory loads the history file at `path`, creating an empty history
+// if the file doesn't exist yet. `halfLife` controls how quickly old plays
+// stop counting.
+func OpenHistory(path string, halfLife time.Duration) (*HistoryDB, error) {
+	if halfLife <= 0 {
+		return nil, fmt.Errorf("history half-life must be positive, got %s", halfLife)
+	}
+
+	h := &HistoryDB{
+		path:     path,
+		halfLife: halfLife,
+		tracks:   make(map[string]*TrackHistory),
+		now:      time.Now,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history file: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.tracks); err != nil {
+		return nil, fmt.Errorf("parse history file %s: %w", path, err)
+	}
+	if h.tracks == nil {
+		h.tracks = make(map[string]*TrackHistory)
+	}
+	return h, nil
+}
+
+// decayed returns `score` as it stands `age` after it was last updated.
+func (h *HistoryDB) decayed(score float64, age time.Duration) float64 {
+	if age <= 0 {
+		return score
+	}
+	return score * math.Pow(0.5, float64(age)/float64(h.halfLife))
+}
+
+// Score returns the decayed play score for `uri` right now. Tracks never
+// heard through this tool score 0.
+func (h *HistoryDB) Score(uri string) float64 {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.tracks[uri]
+	if !ok {
+		return 0
+	}
+	return h.decayed(t.Score, h.now().Sub(t.Updated))
+}
+
+// Get returns the stored record for `uri`.
+func (h *HistoryDB) Get(uri string) (TrackHistory, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.tracks[uri]
+	if !ok {
+		return TrackHistory{}, false
+	}
+	return *t, true
+}
+
+// Recent returns up to `limit` records, most recently played first.
+func (h *HistoryDB) Recent(limit int) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := make([]HistoryEntry, 0, len(h.tracks))
+	for uri, t := range h.tracks {
+		entries = append(entries, HistoryEntry{URI: uri, TrackHistory: *t})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastPlayed.After(entries[j].LastPlayed)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// RecordPlay credits one play to `uri` and writes the history file.
+func (h *HistoryDB) RecordPlay(uri string) error {
+	return h.recordPlay(uri, nil)
+}
+
+// recordPlay is RecordPlay, also saving `features` on the record if it
+// has none yet.
+func (h *HistoryDB) recordPlay(uri string, features *AudioFeatures) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	t, ok := h.tracks[uri]
+	if !ok {
+		t = &TrackHistory{}
+		h.tracks[uri] = t
+	}
+	t.Score = h.decayed(t.Score, now.Sub(t.Updated)) + 1
+	t.Plays++
+	t.Updated = now
+	t.LastPlayed = now
+	if t.Features == nil {
+		t.Features = features
+	}
+
+	return h.save()
+}
+
+// save writes the history file via a temp file and rename so a crash
+// mid-write can't leave a truncated file behind. Callers hold h.mu.
+func (h *HistoryDB) save() error {
+	data, err := json.MarshalIndent(h.tracks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode history: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write history file: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history file: %w", err)
+	}
+	return nil
+}
+
+// historyRecorder remembers what this tool last started so sampled tracks
+// can be attributed to it, and which track it credited last so a long song
+// isn't counted on every sample.
+type historyRecorder struct {
+	mu           sync.Mutex
+	contextURI   string
+	uris         map[string]bool
+	lastRecorded string
+}
+
+// defaultHistoryRecorder is fed by Play and drained by the background
+// recorder started in server mode.
+var defaultHistoryRecorder = &historyRecorder{}
+
+// noteStart records that we just started `contextURI` (for playlist
+// playback) or the explicit `uris` list (for ordered playback).
+func (r *historyRecorder) noteStart(contextURI string, uris []spotifyLib.URI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.contextURI = contextURI
+	r.uris = make(map[string]bool, len(uris))
+	for _, u := range uris {
+		r.uris[string(u)] = true
+	}
+	r.lastRecorded = ""
+}
+
+// recordStart notes a start on the recorder of the account ctx selects.
+func recordStart(ctx context.Context, contextURI string, uris []spotifyLib.URI) {
+	_, r := historyFor(AccountFrom(ctx))
+	r.noteStart(contextURI, uris)
+}
+
+// observe decides whether the playback an event describes should be
+// credited. It returns the track URI to record, or "" to skip.
+func (r *historyRecorder) observe(ev PlaybackEvent) string {
+	if !ev.IsPlaying || ev.TrackURI == "" {
+		return ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uri := ev.TrackURI
+	if uri == r.lastRecorded {
+		return ""
+	}
+
+	ours := false
+	if ev.ContextURI != "" {
+		ours = ev.ContextURI == r.contextURI
+	} else {
+		ours = r.uris[uri]
+	}
+	if !ours {
+		return ""
+	}
+
+	r.lastRecorded = uri
+	return uri
+}
+
+// historyPollInterval is how often the history recorder needs playback
+// polled when nothing else is watching it more closely.
+const historyPollInterval = 30 * time.Second
+
+// StartHistoryRecorder credits tracks from playback this tool started to
+// the history of the account playing them, from the playback events on
+// the bus, until ctx is cancelled. It keeps the playback watcher polling
+// at least every 30 seconds. It does nothing when history is disabled.
+func StartHistoryRecorder(ctx context.Context) {
+	if history == nil {
+		return
+	}
+
+	events, unsubscribe := subscribeEvents(historyPollInterval, eventBufferSize, TopicPlayback)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if pe, ok := ev.Data.(PlaybackEvent); ok {
+					creditHistory(ev.Account, pe)
+				}
+			}
+		}
+	}()
+}
+
+// creditHistory records the track `ev` describes in `account`'s history,
+// if this tool started it.
+func creditHistory(account string, ev PlaybackEvent) {
+	db, r := historyFor(account)
+	if db == nil {
+		return
+	}
+	if uri := r.observe(ev); uri != "" {
+		if err := db.recordPlay(uri, ev.Features); err != nil {
+			log.Printf("history (%s): %v", account, err)
+		}
+	}
+}
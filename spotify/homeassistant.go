@@ -0,0 +1,221 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Home Assistant importer. Reads the HA area registry and
+// media_player entities over HA's REST API, matches each entity to a
+// Spotify Connect device, and produces an initial rooms/presets settings
+// block so large homes don't have to map every speaker by hand.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// haAreasTemplate renders every HA area with the media_player entities
+// assigned to it. The area registry isn't exposed as a plain REST resource
+// (only over the websocket API), but the template endpoint can reach it.
+const haAreasTemplate = `[{% for area in areas() %}{"id": {{ area | tojson }}, "name": {{ area_name(area) | tojson }}, "entities": {{ area_entities(area) | select('match', 'media_player\.') | list | tojson }}}{% if not loop.last %},{% endif %}{% endfor %}]`
+
+// HomeAssistantClient is a minimal client for the parts of the Home
+// Assistant REST API the importer needs. Token is a long-lived access
+// token created from the HA user profile page.
+type HomeAssistantClient struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// HAArea is one entry from the HA area registry along with the
+// media_player entity IDs assigned to it.
+type HAArea struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Entities []string `json:"entities"`
+}
+
+// haState is the subset of an HA entity state we read — the entity ID and
+// its friendly_name attribute, which for speaker integrations is usually
+// the same name the Spotify Connect device advertises.
+type haState struct {
+	EntityID   string `json:"entity_id"`
+	Attributes struct {
+		FriendlyName string `json:"friendly_name"`
+	} `json:"attributes"`
+}
+
+// NewHomeAssistantClient builds a client for the HA instance at baseURL
+// (e.g. "http://homeassistant.local:8123").
+func NewHomeAssistantClient(baseURL, token string) *HomeAssistantClient {
+	return &HomeAssistantClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Areas returns every HA area with its media_player entities.
+func (h *HomeAssistantClient) Areas(ctx context.Context) ([]HAArea, error) {
+	body, err := json.Marshal(map[string]string{"template": haAreasTemplate})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := h.do(ctx, http.MethodPost, "/api/template", body)
+	if err != nil {
+		return nil, fmt.Errorf("render areas template: %w", err)
+	}
+
+	var areas []HAArea
+	if err := json.Unmarshal(data, &areas); err != nil {
+		return nil, fmt.Errorf("decode areas: %w", err)
+	}
+	return areas, nil
+}
+
+// MediaPlayerNames returns a map of media_player entity ID to its
+// friendly name.
+func (h *HomeAssistantClient) MediaPlayerNames(ctx context.Context) (map[string]string, error) {
+	data, err := h.do(ctx, http.MethodGet, "/api/states", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list states: %w", err)
+	}
+
+	var states []haState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("decode states: %w", err)
+	}
+
+	names := make(map[string]string)
+	for _, s := range states {
+		if strings.HasPrefix(s.EntityID, "media_player.") {
+			names[s.EntityID] = s.Attributes.FriendlyName
+		}
+	}
+	return names, nil
+}
+
+// do performs an authenticated request against the HA REST API and
+// returns the response body, treating any non-2xx status as an error.
+func (h *HomeAssistantClient) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("home assistant returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// ImportHomeAssistant builds a Settings block from the HA area registry.
+// Each area with at least one media_player that matches a known Spotify
+// Connect device becomes a room, and gets a starter preset (device only —
+// the playlist is left for the user to fill in).
+//
+// `spotifyNames` is the set of device names we know about, typically the
+// union of the Spotify cloud devices list and the mDNS LAN scan. Areas
+// whose speakers don't match anything are skipped and reported back so
+// the caller can tell the user.
+func ImportHomeAssistant(ctx context.Context, ha *HomeAssistantClient, spotifyNames []string) (*Settings, []string, error) {
+	areas, err := ha.Areas(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	friendly, err := ha.MediaPlayerNames(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byKey := make(map[string]string, len(spotifyNames))
+	for _, n := range spotifyNames {
+		byKey[normalizeDeviceKey(n)] = n
+	}
+
+	out := &Settings{Presets: make(map[string]Preset)}
+	var skipped []string
+
+	for _, area := range areas {
+		var devices []string
+		seen := make(map[string]bool)
+		for _, entityID := range area.Entities {
+			name, ok := matchSpotifyDevice(entityID, friendly[entityID], byKey)
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			devices = append(devices, name)
+		}
+
+		if len(devices) == 0 {
+			skipped = append(skipped, area.Name)
+			continue
+		}
+
+		out.Rooms = append(out.Rooms, Room{Name: area.Name, Devices: devices})
+		out.Presets[presetKey(area.Name)] = Preset{Device: devices[0]}
+	}
+
+	return out, skipped, nil
+}
+
+// matchSpotifyDevice maps an HA media_player to a Spotify device name,
+// trying the entity's friendly name first and then the object ID part of
+// the entity ID ("media_player.living_room_speakers").
+func matchSpotifyDevice(entityID, friendlyName string, byKey map[string]string) (string, bool) {
+	if name, ok := byKey[normalizeDeviceKey(friendlyName)]; ok && friendlyName != "" {
+		return name, true
+	}
+	objectID := strings.TrimPrefix(entityID, "media_player.")
+	if name, ok := byKey[normalizeDeviceKey(objectID)]; ok {
+		return name, true
+	}
+	return "", false
+}
+
+// normalizeDeviceKey lowercases a name and drops everything that isn't a
+// letter or digit, so "Living Room Speakers", "living_room_speakers" and
+// "Living-Room-Speakers" all compare equal.
+func normalizeDeviceKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// presetKey turns an area name into a preset name that's easy to type in
+// a URL or on the command line: "Living Room" -> "living-room".
+func presetKey(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, "-")
+}
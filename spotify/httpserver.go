@@ -0,0 +1,109 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: HTTP server tuning. The API server is built explicitly
+// rather than with http.ListenAndServe, whose zero timeouts let a slow
+// client (slow-loris style) hold a connection open forever. Timeouts,
+// header size, keep-alives, TLS, and HTTP/2 are all configurable.
+//
+
+package spotify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ServerConfig holds the http.Server knobs.
+type ServerConfig struct {
+	// ReadHeaderTimeout bounds how long a client may take to send the
+	// request headers. This is what stops slow-loris connections.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading the whole request, body included.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds a request from the end of its headers to the end
+	// of the response. It must outlast the slowest handler: a device claim
+	// plus confirm=true can take half a minute.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection waits for its next
+	// request.
+	IdleTimeout time.Duration
+	// MaxHeaderBytes caps the request header size.
+	MaxHeaderBytes int
+	// KeepAlives enables HTTP keep-alive connections.
+	KeepAlives bool
+	// TLSCertFile and TLSKeyFile serve HTTPS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// HTTP2 enables HTTP/2 when serving TLS. Plain HTTP is always HTTP/1.1.
+	HTTP2 bool
+}
+
+// DefaultServerConfig returns the settings used unless overridden.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+		KeepAlives:        true,
+		HTTP2:             true,
+	}
+}
+
+// serverConfig is the configuration StartAPIServer uses.
+var serverConfig = DefaultServerConfig()
+
+// SetServerConfig sets the HTTP server configuration.
+func SetServerConfig(cfg ServerConfig) error {
+	if cfg.ReadHeaderTimeout < 0 || cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.IdleTimeout < 0 {
+		return fmt.Errorf("server timeouts must not be negative")
+	}
+	if cfg.MaxHeaderBytes < 0 {
+		return fmt.Errorf("max header bytes must not be negative")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	serverConfig = cfg
+	return nil
+}
+
+// TLS reports whether the server is configured to serve HTTPS.
+func (c ServerConfig) TLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// newHTTPServer builds the http.Server for `addr` and `handler` from cfg.
+func newHTTPServer(addr string, handler http.Handler, cfg ServerConfig) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	srv.SetKeepAlivesEnabled(cfg.KeepAlives)
+	if !cfg.HTTP2 {
+		// A non-nil, empty TLSNextProto is how net/http is told not to
+		// negotiate h2.
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	return srv
+}
+
+// listen serves srv over TLS or plain HTTP as cfg says.
+func listen(srv *http.Server, cfg ServerConfig) error {
+	if cfg.TLS() {
+		return srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return srv.ListenAndServe()
+}
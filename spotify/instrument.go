@@ -0,0 +1,695 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The instrumented Spotify client. Every client handed to
+// SetClient or SetAccountClient is wrapped in an instrumentedClient, a
+// decorator around the Client interface that times and logs each call,
+// records per-operation metrics (/api/v1/stats/spotify) and the call
+// budget's count, tells the playback watcher about commands, retries calls
+// that are safe to retry, and stops calling Spotify for a while after a
+// run of server-side failures. Cross-cutting concerns for Spotify calls
+// belong here rather than in each feature.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// Call log levels for SetCallLogging.
+const (
+	CallLogOff    = "off"
+	CallLogErrors = "errors"
+	CallLogAll    = "all"
+)
+
+// Circuit breaker defaults (see SetCircuitBreaker).
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrSpotifyUnavailable is wrapped by calls refused while the circuit
+// breaker is open.
+var ErrSpotifyUnavailable = errors.New("Spotify API unavailable")
+
+var (
+	// callLogging is the call log level. Successful calls aren't logged by
+	// default because the playback poller would flood the log.
+	callLogging = CallLogErrors
+	// callRetries is how many times a failed call is retried.
+	callRetries = 2
+	// callRetryBackoff is the wait before the first retry; it doubles for
+	// each one after.
+	callRetryBackoff = 500 * time.Millisecond
+	// breakerThreshold is how many consecutive server-side failures open
+	// the circuit breaker.
+	breakerThreshold = DefaultBreakerThreshold
+	// breakerCooldown is how long an open breaker refuses calls before
+	// letting one through to test the water.
+	breakerCooldown = DefaultBreakerCooldown
+)
+
+// SetCallLogging sets which Spotify calls are logged: off, errors, or all.
+func SetCallLogging(level string) error {
+	switch level {
+	case CallLogOff, CallLogErrors, CallLogAll:
+		callLogging = level
+		return nil
+	}
+	return fmt.Errorf("unknown call log level %q: use off, errors, or all", level)
+}
+
+// SetCallRetries sets how many times a failed Spotify call is retried.
+func SetCallRetries(n int) error {
+	if n < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	callRetries = n
+	return nil
+}
+
+// SetCircuitBreaker sets how many consecutive failures open the breaker
+// and how long it stays open. A threshold of 0 disables it.
+func SetCircuitBreaker(threshold int, cooldown time.Duration) error {
+	if threshold < 0 || cooldown < 0 {
+		return fmt.Errorf("circuit breaker settings must not be negative")
+	}
+	breakerThreshold, breakerCooldown = threshold, cooldown
+	return nil
+}
+
+// SpotifyCallStat is the record for one Client operation.
+type SpotifyCallStat struct {
+	Calls  int `json:"calls"`
+	Errors int `json:"errors"`
+	// Retries counts extra attempts; a call that succeeded on its second
+	// try is one call and one retry.
+	Retries int `json:"retries"`
+	// Rejected counts calls the open circuit breaker refused.
+	Rejected int `json:"rejected"`
+	// Statuses counts final outcomes: "ok", an HTTP status, "network",
+	// "canceled", "unavailable", or "error".
+	Statuses map[string]int `json:"statuses"`
+	AvgMs    int64          `json:"avg_ms"`
+	MaxMs    int64          `json:"max_ms"`
+
+	total time.Duration
+}
+
+// SpotifyCallStats collects SpotifyCallStat records by operation.
+type SpotifyCallStats struct {
+	mu    sync.Mutex
+	since time.Time
+	ops   map[string]*SpotifyCallStat
+}
+
+// NewSpotifyCallStats returns an empty collector.
+func NewSpotifyCallStats() *SpotifyCallStats {
+	return &SpotifyCallStats{since: time.Now(), ops: make(map[string]*SpotifyCallStat)}
+}
+
+// spotifyCallStats is the process-wide collector every account feeds.
+var spotifyCallStats = NewSpotifyCallStats()
+
+// Record adds one finished call of `op`.
+func (s *SpotifyCallStats) Record(op, status string, took time.Duration, retries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.ops[op]
+	if !ok {
+		st = &SpotifyCallStat{Statuses: make(map[string]int)}
+		s.ops[op] = st
+	}
+	st.Calls++
+	st.Retries += retries
+	st.Statuses[status]++
+	switch status {
+	case "ok":
+	case "unavailable":
+		st.Errors++
+		st.Rejected++
+	default:
+		st.Errors++
+	}
+	st.total += took
+	st.AvgMs = (st.total / time.Duration(st.Calls)).Milliseconds()
+	if ms := took.Milliseconds(); ms > st.MaxMs {
+		st.MaxMs = ms
+	}
+}
+
+// Snapshot returns a copy of every record and when collection began.
+func (s *SpotifyCallStats) Snapshot() (map[string]SpotifyCallStat, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]SpotifyCallStat, len(s.ops))
+	for op, st := range s.ops {
+		cp := *st
+		cp.Statuses = make(map[string]int, len(st.Statuses))
+		for k, v := range st.Statuses {
+			cp.Statuses[k] = v
+		}
+		out[op] = cp
+	}
+	return out, s.since
+}
+
+// circuitBreaker refuses calls for breakerCooldown after breakerThreshold
+// consecutive server-side failures. Once the cooldown passes, calls go
+// through again; the first failure reopens it, the first success closes it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+// allow reports whether a call may go ahead, and if not, until when.
+func (b *circuitBreaker) allow() (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.now().Before(b.openUntil) {
+		return false, b.openUntil
+	}
+	return true, time.Time{}
+}
+
+// record notes the outcome of a call that went ahead.
+func (b *circuitBreaker) record(serverFailure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !serverFailure {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if breakerThreshold > 0 && b.failures >= breakerThreshold {
+		b.openUntil = b.now().Add(breakerCooldown)
+		// One more failure after the cooldown reopens it straight away.
+		b.failures = breakerThreshold - 1
+	}
+}
+
+// state describes the breaker for logs: "closed" or "open".
+func (b *circuitBreaker) state() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.now().Before(b.openUntil) {
+		return "open"
+	}
+	return "closed"
+}
+
+// instrumentedClient decorates a Client with logging, metrics, retries,
+// and circuit breaking. Token is passed straight through: it isn't a Web
+// API call.
+type instrumentedClient struct {
+	next    Client
+	account string
+	stats   *SpotifyCallStats
+	breaker *circuitBreaker
+}
+
+// instrument wraps `c` for `account`. Nil and already wrapped clients are
+// returned as they are.
+func instrument(c Client, account string) Client {
+	if c == nil {
+		return nil
+	}
+	if _, ok := c.(*instrumentedClient); ok {
+		return c
+	}
+	return &instrumentedClient{
+		next:    c,
+		account: account,
+		stats:   spotifyCallStats,
+		breaker: &circuitBreaker{now: time.Now},
+	}
+}
+
+// classifyCallError returns the status label for a call error, and whether
+// it is a server-side failure: one that counts toward the breaker and may
+// be retried when the call is idempotent. A 429 is always retryable.
+func classifyCallError(err error) (status string, serverFailure, retryable bool) {
+	var spErr spotifyLib.Error
+	var retrieveErr *oauth2.RetrieveError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return "ok", false, false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled", false, false
+	case errors.As(err, &retrieveErr):
+		// A refused token refresh is for the user to fix, not retry.
+		return "error", false, false
+	case errors.As(err, &spErr):
+		status := strconv.Itoa(spErr.Status)
+		switch {
+		case spErr.Status == http.StatusTooManyRequests:
+			return status, false, true
+		case spErr.Status >= 500:
+			return status, true, true
+		}
+		return status, false, false
+	case errors.As(err, &netErr):
+		return "network", true, true
+	}
+	return "error", false, false
+}
+
+// call runs `fn` as operation `op` with retries, metrics, logging, and the
+// circuit breaker. `idempotent` says a server-side failure can be retried
+// without risking the action happening twice.
+func (c *instrumentedClient) call(ctx context.Context, op string, idempotent bool, fn func(ctx context.Context) error) error {
+	start := time.Now()
+
+	if ok, until := c.breaker.allow(); !ok {
+		err := fmt.Errorf("%w: too many failures, retrying after %s", ErrSpotifyUnavailable, until.Format(time.TimeOnly))
+		c.finish(op, "unavailable", start, 0, err)
+		return err
+	}
+
+	var err error
+	var status string
+	retries := 0
+	for attempt := 0; ; attempt++ {
+		spotifyCallWindow.add(time.Now())
+		err = fn(ctx)
+		var serverFailure, retryable bool
+		status, serverFailure, retryable = classifyCallError(err)
+		c.breaker.record(serverFailure)
+
+		if err == nil || attempt >= callRetries || !retryable || (serverFailure && !idempotent) {
+			break
+		}
+		if ok, _ := c.breaker.allow(); !ok {
+			break
+		}
+
+		wait := callRetryBackoff << attempt
+		if callLogging != CallLogOff && !Quiet() {
+			log.Printf("spotify: %s account=%s status=%s, retrying in %s", op, c.account, status, wait)
+		}
+		select {
+		case <-ctx.Done():
+			c.finish(op, status, start, retries, err)
+			return err
+		case <-time.After(wait):
+		}
+		retries++
+	}
+
+	// Let playback subscribers see what the command did promptly.
+	if err == nil && playerCommands[op] {
+		playbackWatcher.pokeAfter(currentPollPolicy().CommandDelay)
+	}
+	c.finish(op, status, start, retries, err)
+	return err
+}
+
+// APIErrorEvent is the payload of an error-topic event: a Spotify call
+// that failed after any retries.
+type APIErrorEvent struct {
+	Op      string    `json:"op"`
+	Status  string    `json:"status"`
+	Code    ErrorCode `json:"code"`
+	Error   string    `json:"error"`
+	Retries int       `json:"retries,omitempty"`
+}
+
+// finish records and logs a finished call, and publishes a failure other
+// than a cancellation on the error topic.
+func (c *instrumentedClient) finish(op, status string, start time.Time, retries int, err error) {
+	took := time.Since(start)
+	c.stats.Record(op, status, took, retries)
+	if err != nil {
+		spotifyErrors.Add(ErrorCodeOf(err))
+		if status != "canceled" {
+			publishEvent(TopicError, EventAPIError, c.account, APIErrorEvent{Op: op, Status: status, Code: ErrorCodeOf(err), Error: err.Error(), Retries: retries})
+		}
+	}
+
+	logged := !Quiet() && (callLogging == CallLogAll || (callLogging == CallLogErrors && err != nil))
+	if !logged && !Verbose() {
+		return
+	}
+	line := fmt.Sprintf("spotify: %s account=%s status=%s took=%s", op, c.account, status, took.Round(time.Millisecond))
+	if retries > 0 {
+		line += fmt.Sprintf(" retries=%d", retries)
+	}
+	if err != nil {
+		line += fmt.Sprintf(" code=%s breaker=%s err=%v", ErrorCodeOf(err), c.breaker.state(), err)
+	}
+	if logged {
+		log.Print(line)
+		return
+	}
+	Verbosef("%s", line)
+}
+
+// CurrentUser calls the wrapped client's CurrentUser.
+func (c *instrumentedClient) CurrentUser(ctx context.Context) (user *spotifyLib.PrivateUser, err error) {
+	err = c.call(ctx, "CurrentUser", true, func(ctx context.Context) (err error) {
+		user, err = c.next.CurrentUser(ctx)
+		return err
+	})
+	return user, err
+}
+
+// CurrentUsersPlaylists calls the wrapped client's CurrentUsersPlaylists.
+func (c *instrumentedClient) CurrentUsersPlaylists(ctx context.Context, opts ...spotifyLib.RequestOption) (page *spotifyLib.SimplePlaylistPage, err error) {
+	err = c.call(ctx, "CurrentUsersPlaylists", true, func(ctx context.Context) (err error) {
+		page, err = c.next.CurrentUsersPlaylists(ctx, opts...)
+		return err
+	})
+	return page, err
+}
+
+// PlayerDevices calls the wrapped client's PlayerDevices.
+func (c *instrumentedClient) PlayerDevices(ctx context.Context) (devices []spotifyLib.PlayerDevice, err error) {
+	err = c.call(ctx, "PlayerDevices", true, func(ctx context.Context) (err error) {
+		devices, err = c.next.PlayerDevices(ctx)
+		return err
+	})
+	return devices, err
+}
+
+// GetPlaylist calls the wrapped client's GetPlaylist.
+func (c *instrumentedClient) GetPlaylist(ctx context.Context, playlistID spotifyLib.ID, opts ...spotifyLib.RequestOption) (playlist *spotifyLib.FullPlaylist, err error) {
+	err = c.call(ctx, "GetPlaylist", true, func(ctx context.Context) (err error) {
+		playlist, err = c.next.GetPlaylist(ctx, playlistID, opts...)
+		return err
+	})
+	return playlist, err
+}
+
+// PlayOpt calls the wrapped client's PlayOpt. Starting the same playback
+// twice lands in the same place, so it is retried like a read.
+func (c *instrumentedClient) PlayOpt(ctx context.Context, opts *spotifyLib.PlayOptions) error {
+	return c.call(ctx, "PlayOpt", true, func(ctx context.Context) error {
+		return c.next.PlayOpt(ctx, opts)
+	})
+}
+
+// Pause calls the wrapped client's Pause.
+func (c *instrumentedClient) Pause(ctx context.Context) error {
+	return c.call(ctx, "Pause", true, func(ctx context.Context) error {
+		return c.next.Pause(ctx)
+	})
+}
+
+// PauseOpt calls the wrapped client's PauseOpt.
+func (c *instrumentedClient) PauseOpt(ctx context.Context, opt *spotifyLib.PlayOptions) error {
+	return c.call(ctx, "PauseOpt", true, func(ctx context.Context) error {
+		return c.next.PauseOpt(ctx, opt)
+	})
+}
+
+// Shuffle calls the wrapped client's Shuffle.
+func (c *instrumentedClient) Shuffle(ctx context.Context, shuffle bool) error {
+	return c.call(ctx, "Shuffle", true, func(ctx context.Context) error {
+		return c.next.Shuffle(ctx, shuffle)
+	})
+}
+
+// Repeat calls the wrapped client's Repeat.
+func (c *instrumentedClient) Repeat(ctx context.Context, state string) error {
+	return c.call(ctx, "Repeat", true, func(ctx context.Context) error {
+		return c.next.Repeat(ctx, state)
+	})
+}
+
+// Volume calls the wrapped client's Volume.
+func (c *instrumentedClient) Volume(ctx context.Context, percent int) error {
+	return c.call(ctx, "Volume", true, func(ctx context.Context) error {
+		return c.next.Volume(ctx, percent)
+	})
+}
+
+// VolumeOpt calls the wrapped client's VolumeOpt.
+func (c *instrumentedClient) VolumeOpt(ctx context.Context, percent int, opt *spotifyLib.PlayOptions) error {
+	return c.call(ctx, "VolumeOpt", true, func(ctx context.Context) error {
+		return c.next.VolumeOpt(ctx, percent, opt)
+	})
+}
+
+// Next calls the wrapped client's Next. A skip that may have happened
+// isn't retried, or the user could lose a track.
+func (c *instrumentedClient) Next(ctx context.Context) error {
+	return c.call(ctx, "Next", false, func(ctx context.Context) error {
+		return c.next.Next(ctx)
+	})
+}
+
+// GetPlaylistItems calls the wrapped client's GetPlaylistItems.
+func (c *instrumentedClient) GetPlaylistItems(ctx context.Context, playlistID spotifyLib.ID, opts ...spotifyLib.RequestOption) (page *spotifyLib.PlaylistItemPage, err error) {
+	err = c.call(ctx, "GetPlaylistItems", true, func(ctx context.Context) (err error) {
+		page, err = c.next.GetPlaylistItems(ctx, playlistID, opts...)
+		return err
+	})
+	return page, err
+}
+
+// QueueSong calls the wrapped client's QueueSong. An add that may have
+// happened isn't retried, or the track could be queued twice.
+func (c *instrumentedClient) QueueSong(ctx context.Context, trackID spotifyLib.ID) error {
+	return c.call(ctx, "QueueSong", false, func(ctx context.Context) error {
+		return c.next.QueueSong(ctx, trackID)
+	})
+}
+
+// Seek calls the wrapped client's Seek.
+func (c *instrumentedClient) Seek(ctx context.Context, position int) error {
+	return c.call(ctx, "Seek", true, func(ctx context.Context) error {
+		return c.next.Seek(ctx, position)
+	})
+}
+
+// PlayerState calls the wrapped client's PlayerState.
+func (c *instrumentedClient) PlayerState(ctx context.Context, opts ...spotifyLib.RequestOption) (state *spotifyLib.PlayerState, err error) {
+	err = c.call(ctx, "PlayerState", true, func(ctx context.Context) (err error) {
+		state, err = c.next.PlayerState(ctx, opts...)
+		return err
+	})
+	return state, err
+}
+
+// PlayerRecentlyPlayedOpt calls the wrapped client's PlayerRecentlyPlayedOpt.
+func (c *instrumentedClient) PlayerRecentlyPlayedOpt(ctx context.Context, opt *spotifyLib.RecentlyPlayedOptions) (items []spotifyLib.RecentlyPlayedItem, err error) {
+	err = c.call(ctx, "PlayerRecentlyPlayedOpt", true, func(ctx context.Context) (err error) {
+		items, err = c.next.PlayerRecentlyPlayedOpt(ctx, opt)
+		return err
+	})
+	return items, err
+}
+
+// PlayerCurrentlyPlaying calls the wrapped client's PlayerCurrentlyPlaying.
+func (c *instrumentedClient) PlayerCurrentlyPlaying(ctx context.Context, opts ...spotifyLib.RequestOption) (cp *spotifyLib.CurrentlyPlaying, err error) {
+	err = c.call(ctx, "PlayerCurrentlyPlaying", true, func(ctx context.Context) (err error) {
+		cp, err = c.next.PlayerCurrentlyPlaying(ctx, opts...)
+		return err
+	})
+	return cp, err
+}
+
+// TransferPlayback calls the wrapped client's TransferPlayback.
+func (c *instrumentedClient) TransferPlayback(ctx context.Context, deviceID spotifyLib.ID, play bool) error {
+	return c.call(ctx, "TransferPlayback", true, func(ctx context.Context) error {
+		return c.next.TransferPlayback(ctx, deviceID, play)
+	})
+}
+
+// FollowPlaylist calls the wrapped client's FollowPlaylist.
+func (c *instrumentedClient) FollowPlaylist(ctx context.Context, playlist spotifyLib.ID, public bool) error {
+	return c.call(ctx, "FollowPlaylist", true, func(ctx context.Context) error {
+		return c.next.FollowPlaylist(ctx, playlist, public)
+	})
+}
+
+// CreatePlaylistForUser calls the wrapped client's CreatePlaylistForUser.
+// A retry after a server-side failure could create a second playlist, so
+// it isn't treated as idempotent.
+func (c *instrumentedClient) CreatePlaylistForUser(ctx context.Context, userID, name, description string, public, collaborative bool) (*spotifyLib.FullPlaylist, error) {
+	var out *spotifyLib.FullPlaylist
+	err := c.call(ctx, "CreatePlaylistForUser", false, func(ctx context.Context) error {
+		var err error
+		out, err = c.next.CreatePlaylistForUser(ctx, userID, name, description, public, collaborative)
+		return err
+	})
+	return out, err
+}
+
+// AddTracksToPlaylist calls the wrapped client's AddTracksToPlaylist. A
+// retry could add the tracks twice, so it isn't treated as idempotent.
+func (c *instrumentedClient) AddTracksToPlaylist(ctx context.Context, playlistID spotifyLib.ID, trackIDs ...spotifyLib.ID) (string, error) {
+	var snapshot string
+	err := c.call(ctx, "AddTracksToPlaylist", false, func(ctx context.Context) error {
+		var err error
+		snapshot, err = c.next.AddTracksToPlaylist(ctx, playlistID, trackIDs...)
+		return err
+	})
+	return snapshot, err
+}
+
+// RemoveTracksFromPlaylistOpt calls the wrapped client's
+// RemoveTracksFromPlaylistOpt. The snapshot makes a repeat fail rather than
+// remove the wrong entries, but it isn't retried either.
+func (c *instrumentedClient) RemoveTracksFromPlaylistOpt(ctx context.Context, playlistID spotifyLib.ID, tracks []spotifyLib.TrackToRemove, snapshotID string) (string, error) {
+	var snapshot string
+	err := c.call(ctx, "RemoveTracksFromPlaylistOpt", false, func(ctx context.Context) error {
+		var err error
+		snapshot, err = c.next.RemoveTracksFromPlaylistOpt(ctx, playlistID, tracks, snapshotID)
+		return err
+	})
+	return snapshot, err
+}
+
+// ReorderPlaylistTracks calls the wrapped client's ReorderPlaylistTracks.
+// Repeating a move moves different items, so it isn't retried.
+func (c *instrumentedClient) ReorderPlaylistTracks(ctx context.Context, playlistID spotifyLib.ID, opt spotifyLib.PlaylistReorderOptions) (string, error) {
+	var snapshot string
+	err := c.call(ctx, "ReorderPlaylistTracks", false, func(ctx context.Context) error {
+		var err error
+		snapshot, err = c.next.ReorderPlaylistTracks(ctx, playlistID, opt)
+		return err
+	})
+	return snapshot, err
+}
+
+// UnfollowPlaylist calls the wrapped client's UnfollowPlaylist.
+func (c *instrumentedClient) UnfollowPlaylist(ctx context.Context, playlist spotifyLib.ID) error {
+	return c.call(ctx, "UnfollowPlaylist", true, func(ctx context.Context) error {
+		return c.next.UnfollowPlaylist(ctx, playlist)
+	})
+}
+
+// CurrentUsersAlbums calls the wrapped client's CurrentUsersAlbums.
+func (c *instrumentedClient) CurrentUsersAlbums(ctx context.Context, opts ...spotifyLib.RequestOption) (page *spotifyLib.SavedAlbumPage, err error) {
+	err = c.call(ctx, "CurrentUsersAlbums", true, func(ctx context.Context) (err error) {
+		page, err = c.next.CurrentUsersAlbums(ctx, opts...)
+		return err
+	})
+	return page, err
+}
+
+// GetAlbum calls the wrapped client's GetAlbum.
+func (c *instrumentedClient) GetAlbum(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (album *spotifyLib.FullAlbum, err error) {
+	err = c.call(ctx, "GetAlbum", true, func(ctx context.Context) (err error) {
+		album, err = c.next.GetAlbum(ctx, id, opts...)
+		return err
+	})
+	return album, err
+}
+
+// Search calls the wrapped client's Search.
+func (c *instrumentedClient) Search(ctx context.Context, query string, t spotifyLib.SearchType, opts ...spotifyLib.RequestOption) (result *spotifyLib.SearchResult, err error) {
+	err = c.call(ctx, "Search", true, func(ctx context.Context) (err error) {
+		result, err = c.next.Search(ctx, query, t, opts...)
+		return err
+	})
+	return result, err
+}
+
+// GetArtist calls the wrapped client's GetArtist.
+func (c *instrumentedClient) GetArtist(ctx context.Context, id spotifyLib.ID) (artist *spotifyLib.FullArtist, err error) {
+	err = c.call(ctx, "GetArtist", true, func(ctx context.Context) (err error) {
+		artist, err = c.next.GetArtist(ctx, id)
+		return err
+	})
+	return artist, err
+}
+
+// GetTrack calls the wrapped client's GetTrack.
+func (c *instrumentedClient) GetTrack(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (track *spotifyLib.FullTrack, err error) {
+	err = c.call(ctx, "GetTrack", true, func(ctx context.Context) (err error) {
+		track, err = c.next.GetTrack(ctx, id, opts...)
+		return err
+	})
+	return track, err
+}
+
+// GetShow calls the wrapped client's GetShow.
+func (c *instrumentedClient) GetShow(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (show *spotifyLib.FullShow, err error) {
+	err = c.call(ctx, "GetShow", true, func(ctx context.Context) (err error) {
+		show, err = c.next.GetShow(ctx, id, opts...)
+		return err
+	})
+	return show, err
+}
+
+// GetEpisode calls the wrapped client's GetEpisode.
+func (c *instrumentedClient) GetEpisode(ctx context.Context, id string, opts ...spotifyLib.RequestOption) (episode *spotifyLib.EpisodePage, err error) {
+	err = c.call(ctx, "GetEpisode", true, func(ctx context.Context) (err error) {
+		episode, err = c.next.GetEpisode(ctx, id, opts...)
+		return err
+	})
+	return episode, err
+}
+
+// GetAudioFeatures calls the wrapped client's GetAudioFeatures.
+func (c *instrumentedClient) GetAudioFeatures(ctx context.Context, ids ...spotifyLib.ID) (features []*spotifyLib.AudioFeatures, err error) {
+	err = c.call(ctx, "GetAudioFeatures", true, func(ctx context.Context) (err error) {
+		features, err = c.next.GetAudioFeatures(ctx, ids...)
+		return err
+	})
+	return features, err
+}
+
+// CurrentUsersTracks calls the wrapped client's CurrentUsersTracks.
+func (c *instrumentedClient) CurrentUsersTracks(ctx context.Context, opts ...spotifyLib.RequestOption) (page *spotifyLib.SavedTrackPage, err error) {
+	err = c.call(ctx, "CurrentUsersTracks", true, func(ctx context.Context) (err error) {
+		page, err = c.next.CurrentUsersTracks(ctx, opts...)
+		return err
+	})
+	return page, err
+}
+
+// GetAlbumTracks calls the wrapped client's GetAlbumTracks.
+func (c *instrumentedClient) GetAlbumTracks(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (page *spotifyLib.SimpleTrackPage, err error) {
+	err = c.call(ctx, "GetAlbumTracks", true, func(ctx context.Context) (err error) {
+		page, err = c.next.GetAlbumTracks(ctx, id, opts...)
+		return err
+	})
+	return page, err
+}
+
+// GetArtistsTopTracks calls the wrapped client's GetArtistsTopTracks.
+func (c *instrumentedClient) GetArtistsTopTracks(ctx context.Context, artistID spotifyLib.ID, country string) (tracks []spotifyLib.FullTrack, err error) {
+	err = c.call(ctx, "GetArtistsTopTracks", true, func(ctx context.Context) (err error) {
+		tracks, err = c.next.GetArtistsTopTracks(ctx, artistID, country)
+		return err
+	})
+	return tracks, err
+}
+
+// GetRecommendations calls the wrapped client's GetRecommendations.
+func (c *instrumentedClient) GetRecommendations(ctx context.Context, seeds spotifyLib.Seeds, trackAttributes *spotifyLib.TrackAttributes, opts ...spotifyLib.RequestOption) (recs *spotifyLib.Recommendations, err error) {
+	err = c.call(ctx, "GetRecommendations", true, func(ctx context.Context) (err error) {
+		recs, err = c.next.GetRecommendations(ctx, seeds, trackAttributes, opts...)
+		return err
+	})
+	return recs, err
+}
+
+// Token returns the wrapped client's token.
+func (c *instrumentedClient) Token() (*oauth2.Token, error) {
+	return c.next.Token()
+}
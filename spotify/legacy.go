@@ -0,0 +1,121 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Compatibility layer for the original GET /api/v1/play and
+// /api/v1/pause routes. Existing Siri Shortcuts and cron jobs depend on the
+// exact query-param request and {success,message,error} response shape, so
+// those requests are pinned to that contract here — with deprecation
+// headers — while the rest of the API is free to grow.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// legacyRoutes are the routes whose GET form is frozen.
+var legacyRoutes = map[string]bool{
+	"/api/v1/play":  true,
+	"/api/v1/pause": true,
+}
+
+// legacyDocsURL is advertised in the Link header of legacy responses so
+// anyone inspecting them can find the migration notes.
+const legacyDocsURL = "https://github.com/cloudmanic/spotify-shortcut#legacy-routes"
+
+// legacyEnvelope is the frozen response shape of the legacy routes. Any
+// field added to APIResponse later is stripped from legacy responses,
+// except the ones listed here on purpose.
+type legacyEnvelope struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Code, Warnings, Device, and JobID are additions old clients ignore:
+	// why a request failed, what went only partly right, which device a
+	// play landed on (the fallback, when the named one was missing), and
+	// the job a `delay` scheduled, so it can be cancelled.
+	Code     ErrorCode     `json:"code,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Device   *PlayedDevice `json:"device,omitempty"`
+	JobID    string        `json:"job_id,omitempty"`
+}
+
+// bufferedResponseWriter captures a handler's status, headers, and body so
+// the compatibility layer can rewrite the body before it hits the wire.
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+// Header returns the captured header map.
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code.
+func (b *bufferedResponseWriter) WriteHeader(code int) {
+	b.statusCode = code
+}
+
+// Write appends to the captured body.
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// isLegacyRequest reports whether r uses the frozen legacy form: a GET to
+// one of the legacy routes.
+func isLegacyRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && legacyRoutes[r.URL.Path]
+}
+
+// legacyCompatMiddleware pins legacy requests to the original contract.
+// When legacy routes are disabled (DISABLE_LEGACY_ROUTES=true) they get a
+// 410 Gone instead. Non-legacy requests pass straight through.
+func legacyCompatMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLegacyRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+legacyDocsURL+">; rel=\"deprecation\"")
+
+		if !legacyRoutesEnabled {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(legacyEnvelope{
+				Success: false,
+				Error:   "Legacy GET routes are disabled on this server",
+			})
+			return
+		}
+
+		buf := &bufferedResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		for k, v := range buf.header {
+			w.Header()[k] = v
+		}
+
+		// Re-encode through the frozen envelope. If the handler wrote
+		// something that isn't our JSON envelope, pass it through untouched.
+		body := buf.body.Bytes()
+		var env legacyEnvelope
+		if err := json.Unmarshal(body, &env); err == nil {
+			var out bytes.Buffer
+			json.NewEncoder(&out).Encode(env)
+			body = out.Bytes()
+		}
+
+		w.Header().Del("Content-Length")
+		w.WriteHeader(buf.statusCode)
+		w.Write(body)
+	})
+}
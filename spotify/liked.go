@@ -0,0 +1,83 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Liked Songs. The saved-tracks collection isn't a playlist
+// and has no playlist ID, so `playlist=liked` (or -liked) names it
+// instead. It plays as the account's collection context,
+// spotify:user:<id>:collection, the way the Spotify apps play it.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// LikedPlaylist is the playlist input that plays Liked Songs.
+const LikedPlaylist = "liked"
+
+// IsLikedSongs reports whether a playlist input names Liked Songs:
+// "liked", or "Liked Songs" as the apps call it.
+func IsLikedSongs(input string) bool {
+	input = strings.TrimSpace(input)
+	return strings.EqualFold(input, LikedPlaylist) || strings.EqualFold(input, "liked songs")
+}
+
+// validateLiked checks the options that don't fit Liked Songs. Spotify
+// plays the collection as a whole, so there's no start position to pick
+// and no track list to order.
+func (req PlayRequest) validateLiked() error {
+	if !IsLikedSongs(req.Playlist) {
+		return nil
+	}
+	switch {
+	case req.Start != "", req.NewestFirst, req.LeastPlayed:
+		return fmt.Errorf("start strategies and ordered modes don't apply to Liked Songs")
+	case req.Duration != "":
+		return fmt.Errorf("duration doesn't apply to Liked Songs")
+	}
+	return nil
+}
+
+// resolveLiked returns Liked Songs as a resolved playlist, with the
+// account's collection URI.
+func resolveLiked(ctx context.Context, client Client, input string) (*ResolvedPlaylist, error) {
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return &ResolvedPlaylist{
+		Input:     input,
+		URI:       fmt.Sprintf("spotify:user:%s:collection", user.ID),
+		MatchedBy: "liked",
+		Name:      "Liked Songs",
+		Owner:     user.DisplayName,
+	}, nil
+}
+
+// playLiked plays the account's Liked Songs on `targetDevice`.
+func playLiked(ctx context.Context, client Client, req PlayRequest, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	liked, err := resolveLiked(ctx, client, req.Playlist)
+	if err != nil {
+		return "", nil, err
+	}
+
+	uri := spotifyLib.URI(liked.URI)
+	if err := client.PlayOpt(ctx, &spotifyLib.PlayOptions{DeviceID: &targetDevice.ID, PlaybackContext: &uri}); err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	recordStart(ctx, liked.URI, nil)
+	target := &playbackTarget{device: targetDevice, contextURI: uri}
+
+	if req.Shuffle {
+		enableShuffle(ctx, client)
+		return fmt.Sprintf("Now playing Liked Songs on %s (shuffle enabled)", targetDevice.Name), target, nil
+	}
+	return fmt.Sprintf("Now playing Liked Songs on %s", targetDevice.Name), target, nil
+}
@@ -0,0 +1,169 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Diagnosing why a stored Spotify login can't be used. A
+// missing or damaged token file, a revoked refresh token, app credentials
+// Spotify rejects, and a login granted too few scopes each have a
+// different fix, so the CLI explains the specific one instead of sending
+// the user round a generic re-authentication loop.
+//
+
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// Kinds of login problem reported by DiagnoseLogin.
+const (
+	LoginMissing       = "missing"
+	LoginCorrupt       = "corrupt"
+	LoginRevoked       = "revoked"
+	LoginBadClient     = "bad-client"
+	LoginRejected      = "rejected"
+	LoginMissingScopes = "missing-scopes"
+)
+
+var (
+	// errEmptyToken is a token file that decodes but holds no token.
+	errEmptyToken = errors.New("token file has no access or refresh token")
+	// errNoRefreshToken is an expired token that can't be refreshed.
+	errNoRefreshToken = errors.New("token expired and no refresh token available")
+)
+
+// savedToken is the token file layout: the OAuth token plus the scopes
+// Spotify granted, when known, so missing scopes can be spotted without
+// calling Spotify.
+type savedToken struct {
+	*oauth2.Token
+	Scope string `json:"scope,omitempty"`
+}
+
+// LoginProblem explains why the stored login can't be used.
+type LoginProblem struct {
+	// Kind is one of the Login* constants.
+	Kind string
+	// Err is the error that was diagnosed.
+	Err error
+	// Scopes lists the scopes the login lacks, for LoginMissingScopes.
+	Scopes []string
+}
+
+// Reauthenticate reports whether signing in again fixes the problem.
+// Rejected app credentials need the configuration fixed first.
+func (p *LoginProblem) Reauthenticate() bool {
+	return p.Kind != LoginBadClient
+}
+
+// Fix describes the problem and what to do about it.
+func (p *LoginProblem) Fix() string {
+	switch p.Kind {
+	case LoginMissing:
+		return fmt.Sprintf("No saved Spotify login in %s yet. Sign in to create one.", tokenFile)
+	case LoginCorrupt:
+		return fmt.Sprintf("The token file %s is damaged and can't be read (%v). Signing in again replaces it.", tokenFile, p.Err)
+	case LoginRevoked:
+		return "Spotify no longer accepts the saved login: its refresh token was revoked, e.g. the app was removed at spotify.com/account/apps or the account password changed. Sign in again."
+	case LoginBadClient:
+		return "Spotify rejected the app credentials. Check SPOTIFY_CLIENT_ID and SPOTIFY_CLIENT_SECRET against the Spotify developer dashboard; signing in again won't help until they match."
+	case LoginMissingScopes:
+		return fmt.Sprintf("The saved login wasn't granted %s, which this needs. Sign in again and approve the permissions Spotify asks for (%s).", strings.Join(p.Scopes, ", "), strings.Join(authScopes, ", "))
+	default:
+		return "Spotify rejected the saved access token. Sign in again."
+	}
+}
+
+// DiagnoseLogin classifies err, from loading the token or from a Spotify
+// call, as a login problem. It returns nil for errors signing in again
+// wouldn't fix, such as network failures or Spotify being down.
+func DiagnoseLogin(err error) *LoginProblem {
+	if err == nil {
+		return nil
+	}
+	problem := func(kind string) *LoginProblem {
+		return &LoginProblem{Kind: kind, Err: err}
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var retrieveErr *oauth2.RetrieveError
+	var spErr spotifyLib.Error
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return problem(LoginMissing)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, errEmptyToken):
+		return problem(LoginCorrupt)
+	case errors.Is(err, errNoRefreshToken):
+		return problem(LoginRejected)
+	case errors.As(err, &retrieveErr):
+		switch retrieveErr.ErrorCode {
+		case "invalid_grant":
+			return problem(LoginRevoked)
+		case "invalid_client", "unauthorized_client":
+			return problem(LoginBadClient)
+		}
+		return nil
+	case errors.As(err, &spErr):
+		switch {
+		case spErr.Status == http.StatusUnauthorized:
+			return problem(LoginRejected)
+		case spErr.Status == http.StatusForbidden && strings.Contains(strings.ToLower(spErr.Message), "scope"):
+			p := problem(LoginMissingScopes)
+			if p.Scopes = MissingScopes(tokenFile); len(p.Scopes) == 0 {
+				// Unknown or complete on record: Spotify knows better.
+				p.Scopes = []string{"every permission this app requests"}
+			}
+			return p
+		}
+	}
+	return nil
+}
+
+// MissingScopes returns the requested scopes the token file at path
+// wasn't granted. It returns nil when they're all there, or when the file
+// doesn't record its scopes (tokens saved before scopes were recorded).
+func MissingScopes(path string) []string {
+	scope, err := readTokenScope(path)
+	if err != nil || scope == "" {
+		return nil
+	}
+	granted := strings.Fields(scope)
+
+	var missing []string
+	for _, s := range authScopes {
+		if !slices.Contains(granted, s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// readTokenScope returns the granted scopes recorded in the token file at
+// path, space-separated as Spotify sends them, or "" if none are recorded.
+func readTokenScope(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var saved struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return "", err
+	}
+	return saved.Scope, nil
+}
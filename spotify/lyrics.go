@@ -0,0 +1,266 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Optional now-playing lyrics. A LyricsProvider (currently
+// LRCLIB) is asked for synced or plain lyrics for the current track, and
+// results are cached on disk per Spotify track ID so a wall display
+// polling /api/v1/lyrics/current doesn't hit the provider every time.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLyricsNotFound is returned by providers that have no lyrics for a
+// track.
+var ErrLyricsNotFound = errors.New("lyrics not found")
+
+// Lyrics is a provider's answer for one track. Synced is LRC-formatted
+// ("[mm:ss.xx] line") when the provider has timing; Plain is the same
+// text without timestamps.
+type Lyrics struct {
+	TrackID      string    `json:"track_id"`
+	Track        string    `json:"track"`
+	Artist       string    `json:"artist"`
+	Album        string    `json:"album,omitempty"`
+	Found        bool      `json:"found"`
+	Instrumental bool      `json:"instrumental,omitempty"`
+	Synced       string    `json:"synced,omitempty"`
+	Plain        string    `json:"plain,omitempty"`
+	Source       string    `json:"source"`
+	CachedAt     time.Time `json:"cached_at"`
+}
+
+// LyricsQuery identifies a track to a provider. Duration helps providers
+// pick the right version of songs with many releases.
+type LyricsQuery struct {
+	TrackID  string
+	Track    string
+	Artist   string
+	Album    string
+	Duration time.Duration
+}
+
+// LyricsProvider looks up lyrics for a track. Implementations return
+// ErrLyricsNotFound when they have nothing for it.
+type LyricsProvider interface {
+	Name() string
+	Lookup(ctx context.Context, q LyricsQuery) (*Lyrics, error)
+}
+
+// lyricsProvider is the configured provider; nil disables lyrics.
+var lyricsProvider LyricsProvider
+
+// lyricsCacheDir holds one JSON file per Spotify track ID.
+var lyricsCacheDir = DefaultLyricsCacheDir
+
+// DefaultLyricsCacheDir is where lyrics are cached unless LYRICS_CACHE_DIR
+// says otherwise.
+const DefaultLyricsCacheDir = ".lyrics_cache"
+
+// lyricsMissTTL is how long a "no lyrics" answer is trusted before we ask
+// the provider again — lyrics databases fill in over time.
+const lyricsMissTTL = 24 * time.Hour
+
+// SetLyricsProvider enables lyrics using `p`, caching results in
+// `cacheDir`. A nil provider disables lyrics.
+func SetLyricsProvider(p LyricsProvider, cacheDir string) {
+	lyricsProvider = p
+	lyricsCacheDir = cacheDir
+}
+
+// LyricsProviderFor returns the provider named `name`. Only "lrclib" is
+// supported.
+func LyricsProviderFor(name string) (LyricsProvider, error) {
+	switch strings.ToLower(name) {
+	case "lrclib":
+		return NewLRCLIBProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown lyrics provider %q (expected: lrclib)", name)
+	}
+}
+
+// LRCLIBProvider fetches lyrics from LRCLIB (https://lrclib.net), a free
+// community lyrics database with synced lyrics and no API key.
+type LRCLIBProvider struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewLRCLIBProvider builds a provider against the public LRCLIB API.
+func NewLRCLIBProvider() *LRCLIBProvider {
+	return &LRCLIBProvider{
+		BaseURL:    "https://lrclib.net",
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name identifies the provider in responses.
+func (p *LRCLIBProvider) Name() string {
+	return "lrclib"
+}
+
+// lrclibRecord is the subset of LRCLIB's /api/get response we use.
+type lrclibRecord struct {
+	TrackName    string `json:"trackName"`
+	ArtistName   string `json:"artistName"`
+	AlbumName    string `json:"albumName"`
+	Instrumental bool   `json:"instrumental"`
+	PlainLyrics  string `json:"plainLyrics"`
+	SyncedLyrics string `json:"syncedLyrics"`
+}
+
+// Lookup calls LRCLIB's exact-match /api/get endpoint.
+func (p *LRCLIBProvider) Lookup(ctx context.Context, q LyricsQuery) (*Lyrics, error) {
+	v := url.Values{
+		"track_name":  {q.Track},
+		"artist_name": {q.Artist},
+		"album_name":  {q.Album},
+		"duration":    {strconv.Itoa(int(q.Duration.Round(time.Second).Seconds()))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/api/get?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// LRCLIB asks clients to identify themselves.
+	req.Header.Set("User-Agent", "spotify-shortcut (https://github.com/cloudmanic/spotify-shortcut)")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lrclib request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrLyricsNotFound
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("lrclib response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lrclib returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var rec lrclibRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("decode lrclib response: %w", err)
+	}
+
+	return &Lyrics{
+		TrackID:      q.TrackID,
+		Track:        rec.TrackName,
+		Artist:       rec.ArtistName,
+		Album:        rec.AlbumName,
+		Found:        true,
+		Instrumental: rec.Instrumental,
+		Synced:       rec.SyncedLyrics,
+		Plain:        rec.PlainLyrics,
+		Source:       p.Name(),
+	}, nil
+}
+
+// cachedLyrics returns the cached entry for trackID if there is one and it
+// is still fresh.
+func cachedLyrics(trackID string) (*Lyrics, bool) {
+	data, err := os.ReadFile(filepath.Join(lyricsCacheDir, trackID+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var l Lyrics
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, false
+	}
+	if !l.Found && time.Since(l.CachedAt) > lyricsMissTTL {
+		return nil, false
+	}
+	return &l, true
+}
+
+// cacheLyrics writes `l` to the cache. Failures only cost a re-fetch, so
+// they're returned for logging rather than failing the request.
+func cacheLyrics(l *Lyrics) error {
+	if err := os.MkdirAll(lyricsCacheDir, 0o755); err != nil {
+		return fmt.Errorf("create lyrics cache: %w", err)
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(lyricsCacheDir, l.TrackID+".json"), data, 0o644)
+}
+
+// CurrentLyrics returns lyrics for the track playing right now, along with
+// the playback position so a display can line up synced lyrics. A track
+// the provider has no lyrics for comes back with Found=false.
+func CurrentLyrics(ctx context.Context) (*LyricsResponse, error) {
+	if lyricsProvider == nil {
+		return nil, fmt.Errorf("lyrics are disabled. Set LYRICS_PROVIDER=lrclib to enable them")
+	}
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, err := client.PlayerCurrentlyPlaying(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get currently playing: %w", err)
+	}
+	if cp == nil || cp.Item == nil {
+		return &LyricsResponse{Success: true, Message: "Nothing is playing"}, nil
+	}
+
+	track := cp.Item
+	resp := &LyricsResponse{
+		Success:    true,
+		IsPlaying:  cp.Playing,
+		ProgressMs: int(cp.Progress),
+	}
+
+	trackID := string(track.ID)
+	if l, ok := cachedLyrics(trackID); ok {
+		resp.Lyrics = l
+		return resp, nil
+	}
+
+	q := LyricsQuery{
+		TrackID:  trackID,
+		Track:    track.Name,
+		Album:    track.Album.Name,
+		Duration: track.TimeDuration(),
+	}
+	if len(track.Artists) > 0 {
+		q.Artist = track.Artists[0].Name
+	}
+
+	l, err := lyricsProvider.Lookup(ctx, q)
+	if errors.Is(err, ErrLyricsNotFound) {
+		l = &Lyrics{TrackID: trackID, Track: q.Track, Artist: q.Artist, Album: q.Album, Source: lyricsProvider.Name()}
+	} else if err != nil {
+		return nil, err
+	}
+	l.CachedAt = time.Now()
+
+	if err := cacheLyrics(l); err != nil {
+		log.Printf("Warning: failed to cache lyrics: %v", err)
+	}
+
+	resp.Lyrics = l
+	return resp, nil
+}
@@ -0,0 +1,161 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Notifier channels. Background jobs that have something to
+// tell the user (like the recently-added digest) send a Notification to
+// every configured channel: a generic JSON webhook and/or an ntfy topic.
+// Device fallbacks reach the channels through the event bus.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification is one message for the notifier channels.
+type Notification struct {
+	// Kind says what produced the message, e.g. "digest".
+	Kind    string `json:"kind"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	// URL is an optional link to open from the notification.
+	URL string `json:"url,omitempty"`
+	// Data is the structured payload behind the message, for webhooks.
+	Data any `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications to one channel.
+type Notifier interface {
+	// Name identifies the channel in logs.
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// notifyHTTPClient is used by the HTTP-based notifiers.
+var notifyHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// notifiers are the configured channels; empty means notifications are
+// only logged.
+var notifiers []Notifier
+
+// notifyEventsOnce starts the notifier channels' bus subscription.
+var notifyEventsOnce sync.Once
+
+// SetNotifiers sets the notifier channels, and starts following the event
+// bus for the events they announce.
+func SetNotifiers(n ...Notifier) {
+	notifiers = n
+	notifyEventsOnce.Do(func() {
+		events, _ := SubscribeEvents(TopicFallback)
+		go notifyEvents(events)
+	})
+}
+
+// notifyEvents turns bus events into notifications: device fallbacks,
+// with NOTIFY_FALLBACKS.
+func notifyEvents(events <-chan Event) {
+	for ev := range events {
+		if fe, ok := ev.Data.(FallbackEvent); ok && notifyFallbacks {
+			notify(context.Background(), fallbackNotification(fe))
+		}
+	}
+}
+
+// WebhookNotifier POSTs each notification as JSON to a URL.
+type WebhookNotifier struct {
+	URL string
+}
+
+// Name identifies the channel in logs.
+func (w WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify posts `n` as JSON.
+func (w WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sendNotification(req)
+}
+
+// NtfyNotifier publishes to an ntfy topic URL such as
+// https://ntfy.sh/my-topic. Token is an optional access token.
+type NtfyNotifier struct {
+	URL   string
+	Token string
+}
+
+// Name identifies the channel in logs.
+func (nt NtfyNotifier) Name() string {
+	return "ntfy"
+}
+
+// Notify publishes `n` with its title and click-through link as ntfy
+// headers.
+func (nt NtfyNotifier) Notify(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nt.URL, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	if n.URL != "" {
+		req.Header.Set("Click", n.URL)
+	}
+	if nt.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+nt.Token)
+	}
+	return sendNotification(req)
+}
+
+// sendNotification performs `req` and turns a non-2xx answer into an
+// error carrying the start of the body.
+func sendNotification(req *http.Request) error {
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// notify sends `n` to every channel, logging failures. The returned error
+// joins every channel's failure.
+func notify(ctx context.Context, n Notification) error {
+	if len(notifiers) == 0 {
+		log.Printf("notify (no channels configured): %s: %s", n.Title, n.Message)
+		return nil
+	}
+
+	var errs []error
+	for _, nt := range notifiers {
+		if err := nt.Notify(ctx, n); err != nil {
+			log.Printf("Warning: %s notification failed: %v", nt.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", nt.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
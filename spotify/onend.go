@@ -0,0 +1,331 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: End-of-playback behavior. A play request or preset can say
+// what happens when what it started runs out (on_end): stop, play it
+// again, switch to a preset, or fade the last track out. Plays with an
+// on_end are watched through the event bus until their context ends or
+// something else takes over the account.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// on_end values. A preset is named as "preset:<name>".
+const (
+	OnEndStop         = "stop"
+	OnEndRepeat       = "repeat"
+	OnEndFadeOut      = "fade-out"
+	onEndPresetPrefix = "preset:"
+)
+
+// fadeOutDuration is how long before the end of the last track a fade-out
+// starts, and fadeOutStep how often it lowers the volume. Variables so
+// tests can shorten them.
+var (
+	fadeOutDuration = 20 * time.Second
+	fadeOutStep     = time.Second
+)
+
+// onEndPreset returns the preset an on_end value switches to, if any.
+func onEndPreset(onEnd string) (string, bool) {
+	if len(onEnd) < len(onEndPresetPrefix) || !strings.EqualFold(onEnd[:len(onEndPresetPrefix)], onEndPresetPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(onEnd[len(onEndPresetPrefix):]), true
+}
+
+// validateOnEnd checks req.OnEnd. A fade-out has to know the last track,
+// which shuffle, artists, and audiobooks hide.
+func (req PlayRequest) validateOnEnd() error {
+	switch strings.ToLower(req.OnEnd) {
+	case "", OnEndStop, OnEndRepeat:
+		return nil
+	case OnEndFadeOut:
+		switch {
+		case req.Shuffle:
+			return fmt.Errorf("on_end=%s can't be combined with shuffle: the last track isn't known", OnEndFadeOut)
+		case req.Artist != "", req.Audiobook != "", IsLikedSongs(req.Playlist):
+			return fmt.Errorf("on_end=%s only applies to playlists, albums, and tracks", OnEndFadeOut)
+		}
+		return nil
+	}
+	name, ok := onEndPreset(req.OnEnd)
+	if !ok {
+		return fmt.Errorf("unknown on_end %q (use %s, %s, %s, or %s<name>)", req.OnEnd, OnEndStop, OnEndRepeat, OnEndFadeOut, onEndPresetPrefix)
+	}
+	if _, ok := GetSettings().FindPreset(name); !ok {
+		return fmt.Errorf("on_end names unknown preset %q", name)
+	}
+	return nil
+}
+
+// endWatch is one account's watched play.
+type endWatch struct {
+	ctx          context.Context
+	req          PlayRequest
+	contextURI   spotifyLib.URI
+	lastTrackURI spotifyLib.URI
+	deviceID     spotifyLib.ID
+	// seen is set once an event shows the play under way, so an event
+	// from before it started isn't taken for something else taking over.
+	seen bool
+	// cancelFade stops a running fade-out.
+	cancelFade context.CancelFunc
+}
+
+// stop cancels the watch's fade-out, if one is running.
+func (w *endWatch) stop() {
+	if w.cancelFade != nil {
+		w.cancelFade()
+	}
+}
+
+// EndWatcher holds the plays with an on_end, one per account, and is
+// subscribed to playback events while it holds any.
+type EndWatcher struct {
+	mu          sync.Mutex
+	watches     map[string]*endWatch
+	unsubscribe func()
+}
+
+// endWatcher is the process-wide end watcher.
+var endWatcher = &EndWatcher{}
+
+// watchEnd starts watching the play Play just started on ctx's account,
+// replacing any earlier watch there. A play without an on_end just ends
+// the earlier watch, since its context is gone.
+func watchEnd(ctx context.Context, client Client, req PlayRequest, target *playbackTarget) {
+	account := AccountFrom(ctx)
+	if req.OnEnd == "" {
+		endWatcher.clear(account)
+		return
+	}
+
+	// Spotify's own repeat would keep the context from ever ending.
+	if err := client.Repeat(ctx, "off"); err != nil {
+		warnf(ctx, "failed to turn off repeat for on_end: %v", err)
+	}
+	if strings.EqualFold(req.OnEnd, OnEndStop) {
+		endWatcher.clear(account)
+		return
+	}
+
+	w := &endWatch{
+		// The watch outlives the request; keep only its account.
+		ctx:          WithAccount(context.Background(), account),
+		req:          req,
+		contextURI:   target.contextURI,
+		lastTrackURI: target.lastTrackURI,
+		deviceID:     target.device.ID,
+	}
+	if strings.EqualFold(req.OnEnd, OnEndFadeOut) && w.lastTrackURI == "" {
+		uri, err := lastContextTrack(ctx, client, target.contextURI)
+		if err != nil {
+			warnf(ctx, "on_end=%s won't fade: %v", OnEndFadeOut, err)
+		}
+		w.lastTrackURI = uri
+	}
+	endWatcher.add(account, w)
+}
+
+// lastContextTrack finds the last track of a playlist or album context.
+func lastContextTrack(ctx context.Context, client Client, contextURI spotifyLib.URI) (spotifyLib.URI, error) {
+	r, err := spotifyuri.Parse(string(contextURI))
+	if err != nil {
+		return "", err
+	}
+	switch r.Type {
+	case spotifyuri.Playlist:
+		playlist, err := client.GetPlaylist(ctx, spotifyLib.ID(r.ID))
+		if err != nil {
+			return "", fmt.Errorf("failed to get playlist: %w", err)
+		}
+		total := int(playlist.Tracks.Total)
+		if total == 0 {
+			return "", fmt.Errorf("playlist is empty")
+		}
+		page, err := client.GetPlaylistItems(ctx, spotifyLib.ID(r.ID), spotifyLib.Offset(total-1), spotifyLib.Limit(1))
+		if err != nil {
+			return "", fmt.Errorf("failed to get the last track: %w", err)
+		}
+		if len(page.Items) == 0 || itemURI(page.Items[0]) == "" {
+			return "", fmt.Errorf("the last track isn't playable")
+		}
+		return itemURI(page.Items[0]), nil
+	case spotifyuri.Album:
+		album, err := client.GetAlbum(ctx, spotifyLib.ID(r.ID))
+		if err != nil {
+			return "", fmt.Errorf("failed to get album: %w", err)
+		}
+		tracks := album.Tracks.Tracks
+		if len(tracks) == 0 || len(tracks) < int(album.Tracks.Total) {
+			return "", fmt.Errorf("the album's last track isn't listed")
+		}
+		return tracks[len(tracks)-1].URI, nil
+	}
+	return "", fmt.Errorf("a %s has no known last track", r.Type)
+}
+
+// add installs `w` for `account`, subscribing to playback events if this
+// is the first watch.
+func (ew *EndWatcher) add(account string, w *endWatch) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	if old := ew.watches[account]; old != nil {
+		old.stop()
+	}
+	if ew.watches == nil {
+		ew.watches = map[string]*endWatch{}
+	}
+	ew.watches[account] = w
+
+	if ew.unsubscribe == nil {
+		events, unsubscribe := SubscribeEvents(TopicPlayback)
+		ew.unsubscribe = unsubscribe
+		go func() {
+			for ev := range events {
+				if pe, ok := ev.Data.(PlaybackEvent); ok {
+					ew.handle(ev.Account, pe)
+				}
+			}
+		}()
+	}
+}
+
+// clear drops `account`'s watch.
+func (ew *EndWatcher) clear(account string) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	ew.removeLocked(account)
+}
+
+// removeLocked drops `account`'s watch and unsubscribes once none are
+// left. Callers hold ew.mu.
+func (ew *EndWatcher) removeLocked(account string) *endWatch {
+	w := ew.watches[account]
+	if w == nil {
+		return nil
+	}
+	w.stop()
+	delete(ew.watches, account)
+	if len(ew.watches) == 0 && ew.unsubscribe != nil {
+		ew.unsubscribe()
+		ew.unsubscribe = nil
+	}
+	return w
+}
+
+// handle applies one playback event to `account`'s watch: the context
+// ending runs the on_end action, the last track starting schedules a
+// fade-out, and other playback taking over ends the watch.
+func (ew *EndWatcher) handle(account string, ev PlaybackEvent) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	w := ew.watches[account]
+	if w == nil || ev.Type == EventState {
+		return
+	}
+
+	switch ev.Type {
+	case EventContextEnded:
+		if ev.ContextURI != string(w.contextURI) {
+			return
+		}
+		ew.removeLocked(account)
+		go runOnEnd(w)
+	case EventTrackChanged, EventResumed:
+		if ev.ContextURI != string(w.contextURI) {
+			if w.seen {
+				ew.removeLocked(account)
+			}
+			return
+		}
+		w.seen = true
+		if ev.Type != EventTrackChanged {
+			return
+		}
+		w.stop()
+		if strings.EqualFold(w.req.OnEnd, OnEndFadeOut) && w.lastTrackURI != "" && ev.TrackURI == string(w.lastTrackURI) {
+			fadeCtx, cancel := context.WithCancel(w.ctx)
+			w.cancelFade = cancel
+			go fadeOut(fadeCtx, w.deviceID, ev)
+		}
+	}
+}
+
+// runOnEnd performs a watch's on_end action once its context has ended.
+func runOnEnd(w *endWatch) {
+	var err error
+	ctx := withPlaySource(w.ctx, "on_end "+w.req.OnEnd)
+	switch onEnd := w.req.OnEnd; {
+	case strings.EqualFold(onEnd, OnEndRepeat):
+		_, err = Play(ctx, w.req)
+	default:
+		if name, ok := onEndPreset(onEnd); ok {
+			_, err = PlayPreset(ctx, name)
+		}
+	}
+	if err != nil {
+		log.Printf("on_end %s (%s): %v", w.req.OnEnd, AccountFrom(w.ctx), err)
+		return
+	}
+	log.Printf("on_end %s (%s): ran after %s ended", w.req.OnEnd, AccountFrom(w.ctx), w.contextURI)
+}
+
+// fadeOut lowers the volume from where the last track `ev` started to
+// zero as it finishes, pauses, and puts the volume back for next time. A
+// fade cancelled part way (a skip, or a new play) puts it back at once.
+func fadeOut(ctx context.Context, deviceID spotifyLib.ID, ev PlaybackEvent) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return
+	}
+	remaining := time.Duration(ev.DurationMs-ev.ProgressMs) * time.Millisecond
+	wait := remaining - fadeOutDuration
+	fade := min(fadeOutDuration, remaining)
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(max(wait, 0)):
+	}
+
+	opts := &spotifyLib.PlayOptions{DeviceID: &deviceID}
+	start := ev.Volume
+	steps := max(int(fade/fadeOutStep), 1)
+	ticker := time.NewTicker(fadeOutStep)
+	defer ticker.Stop()
+	for i := 1; i <= steps; i++ {
+		select {
+		case <-ctx.Done():
+			client.VolumeOpt(context.WithoutCancel(ctx), start, opts)
+			return
+		case <-ticker.C:
+		}
+		if err := client.VolumeOpt(ctx, start*(steps-i)/steps, opts); err != nil {
+			log.Printf("on_end %s: %v", OnEndFadeOut, err)
+			return
+		}
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if err := client.Pause(ctx); err != nil {
+		log.Printf("on_end %s: failed to pause: %v", OnEndFadeOut, err)
+	}
+	if err := client.VolumeOpt(ctx, start, opts); err != nil {
+		log.Printf("on_end %s: failed to restore volume: %v", OnEndFadeOut, err)
+	}
+}
@@ -0,0 +1,247 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: OpenAPI 3 document and Swagger UI page. The spec is built
+// from the route table in routes.go, with response schemas derived from
+// the Go response types by reflection, so it always matches what the
+// server actually serves.
+//
+
+package spotify
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPIVersion is the version reported in the spec's info block. Bump
+// it when the API changes shape.
+const openAPIVersion = "1.0.0"
+
+// BuildOpenAPISpec returns the OpenAPI 3.0 document for the route table.
+func BuildOpenAPISpec() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+
+	for _, rt := range apiRoutes() {
+		ops := map[string]any{}
+		for _, method := range rt.Methods {
+			ops[strings.ToLower(method)] = openAPIOperation(rt, method, schemas)
+		}
+		paths[rt.Pattern] = ops
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "spotify-shortcut API",
+			"version":     openAPIVersion,
+			"description": "Remote control for Spotify Connect playback. Authenticate with the API access token as a Bearer header, an X-API-Token header, or a `token` query parameter.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth":  map[string]any{"type": "http", "scheme": "bearer"},
+				"queryToken":  map[string]any{"type": "apiKey", "in": "query", "name": "token"},
+				"headerToken": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Token"},
+			},
+		},
+	}
+}
+
+// accountParam is documented on every account-scoped route.
+var accountParam = apiParam{Name: "account", Type: "string", Description: "Named Spotify account to use (see SPOTIFY_ACCOUNTS); the default account when omitted"}
+
+// openAPIOperation describes one method on one route. GET parameters go
+// in the query string; POST parameters go in a JSON body. Path params are
+// path params either way.
+func openAPIOperation(rt apiRoute, method string, schemas map[string]any) map[string]any {
+	var params []any
+	props := map[string]any{}
+	var required []string
+
+	all := rt.Params
+	if rt.accountScoped() {
+		all = append(all[:len(all):len(all)], accountParam)
+	}
+	for _, p := range all {
+		schema := map[string]any{"type": p.Type}
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+
+		switch {
+		case strings.Contains(rt.Pattern, "{"+p.Name+"}"):
+			params = append(params, map[string]any{
+				"name": p.Name, "in": "path", "required": true, "description": p.Description, "schema": schema,
+			})
+		case method == http.MethodPost:
+			schema["description"] = p.Description
+			props[p.Name] = schema
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		default:
+			params = append(params, map[string]any{
+				"name": p.Name, "in": "query", "required": p.Required, "description": p.Description, "schema": schema,
+			})
+		}
+	}
+
+	op := map[string]any{
+		"summary": rt.Summary,
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Success",
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemaRef(reflect.TypeOf(rt.Response), schemas)},
+				},
+			},
+		},
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if len(props) > 0 {
+		body := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			body["required"] = required
+		}
+		op["requestBody"] = map[string]any{
+			"required": len(required) > 0,
+			"content":  map[string]any{"application/json": map[string]any{"schema": body}},
+		}
+	}
+	if rt.Public {
+		op["security"] = []any{}
+	} else {
+		op["security"] = []any{map[string]any{"bearerAuth": []any{}}, map[string]any{"queryToken": []any{}}, map[string]any{"headerToken": []any{}}}
+	}
+	return op
+}
+
+// timeType is special-cased to an RFC 3339 string.
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef returns the JSON schema for t. Named structs are added to
+// `schemas` once and referenced by name.
+func schemaRef(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil // reserve the name so recursive types terminate
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Struct:
+		return structSchema(t, schemas)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema builds an object schema from exported fields and their json
+// tags. Fields without omitempty are marked required, matching what the
+// encoder always emits.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := map[string]any{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// An untagged embedded struct's fields are promoted, as the
+		// encoder does.
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			embedded := structSchema(f.Type, schemas)
+			for k, v := range embedded["properties"].(map[string]any) {
+				props[k] = v
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaRef(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// HandleOpenAPIRequest handles GET /api/v1/openapi.json. It is public so
+// the docs page and client generators can load it without a token.
+func HandleOpenAPIRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(BuildOpenAPISpec())
+}
+
+// docsPage is the Swagger UI shell. The UI assets come from a CDN; only
+// the spec is served locally.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>spotify-shortcut API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// HandleDocsRequest handles GET /docs with a Swagger UI page for the spec.
+func HandleDocsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
@@ -0,0 +1,93 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: CLI output levels. Progress lines ("Searching for
+// playlist...", "Using device: Kitchen") go through Sayf and warnings
+// through warnf, so -quiet can drop both and leave only what was asked
+// for and errors, and -verbose adds Verbosef's detail on stderr: API call
+// timings and why a device was chosen. The server runs at the normal
+// level.
+//
+
+package spotify
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// OutputLevel is how much the CLI prints besides what was asked for.
+type OutputLevel int
+
+const (
+	// OutputQuiet prints only requested output and errors.
+	OutputQuiet OutputLevel = iota - 1
+	// OutputNormal adds progress lines and warnings.
+	OutputNormal
+	// OutputVerbose adds API call timings and device choice reasoning.
+	OutputVerbose
+)
+
+// outputLevel is set once at startup, before anything prints.
+var outputLevel = OutputNormal
+
+// progressOutput is where Sayf prints; -json moves it to stderr so stdout
+// is only the JSON.
+var progressOutput io.Writer = os.Stdout
+
+// SetOutputLevel sets how much is printed.
+func SetOutputLevel(level OutputLevel) {
+	outputLevel = level
+}
+
+// SetProgressOutput sets where progress lines go.
+func SetProgressOutput(w io.Writer) {
+	progressOutput = w
+}
+
+// Quiet reports whether only requested output and errors are printed.
+func Quiet() bool {
+	return outputLevel <= OutputQuiet
+}
+
+// Verbose reports whether timings and reasoning are printed.
+func Verbose() bool {
+	return outputLevel >= OutputVerbose
+}
+
+// Sayf prints a progress line, unless quiet.
+func Sayf(format string, args ...any) {
+	if Quiet() {
+		return
+	}
+	fmt.Fprintf(progressOutput, format+"\n", args...)
+}
+
+// ProgressWriter returns where progress lines go, or io.Discard when
+// quiet, for functions that print their progress to a writer.
+func ProgressWriter() io.Writer {
+	if Quiet() {
+		return io.Discard
+	}
+	return progressOutput
+}
+
+// Verbosef prints a line of detail on stderr when verbose.
+func Verbosef(format string, args ...any) {
+	if !Verbose() {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// Warnf logs a warning, unless quiet.
+func Warnf(format string, args ...any) {
+	if Quiet() {
+		return
+	}
+	log.Printf("Warning: "+format, args...)
+}
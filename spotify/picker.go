@@ -0,0 +1,272 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Interactive picker for the CLI. When a play has no
+// playlist, or its playlist or device can't be found, and the CLI runs in
+// a terminal, the user picks one instead of the CLI giving up: typing
+// narrows the list by fuzzy match, the arrow keys move, and Enter picks.
+// The terminal is switched to character-at-a-time input with stty; where
+// that isn't available the list is numbered and a number is read
+// instead. -non-interactive, or stdin or stdout not being a terminal,
+// turns the picker off so scripts still fail fast.
+//
+
+package spotify
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// ErrPickCancelled is returned when the user cancels a pick.
+var ErrPickCancelled = errors.New("cancelled")
+
+// pickerRows is how many choices the picker shows at once.
+const pickerRows = 10
+
+// nonInteractive is set by -non-interactive.
+var nonInteractive bool
+
+// PickItem is one choice in the picker.
+type PickItem struct {
+	// Label is what's shown and matched.
+	Label string
+	// Value is what the caller gets back, like a playlist or device ID.
+	Value string
+}
+
+// SetNonInteractive turns the picker off, as for -non-interactive.
+func SetNonInteractive() {
+	nonInteractive = true
+}
+
+// Interactive reports whether the CLI may ask the user to pick: both stdin
+// and stdout are terminals and -non-interactive isn't set.
+func Interactive() bool {
+	return !nonInteractive && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// Pick asks the user to choose one of `items` under `title`, with `query`
+// already typed. A query that matches nothing starts empty instead.
+func Pick(title, query string, items []PickItem) (PickItem, error) {
+	if len(items) == 0 {
+		return PickItem{}, fmt.Errorf("nothing to pick from")
+	}
+	if len(fuzzyFilter(query, items)) == 0 {
+		query = ""
+	}
+	restore, err := cbreak(os.Stdin)
+	if err != nil {
+		return pickNumbered(os.Stdin, os.Stdout, title, query, items)
+	}
+	defer restore()
+	return pickFrom(os.Stdin, os.Stdout, title, query, items)
+}
+
+// cbreak switches the terminal on f to reading a key at a time, without
+// echo or Ctrl-C raising a signal, and returns a function that restores
+// it.
+func cbreak(f *os.File) (restore func(), err error) {
+	saved, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(f, strings.TrimSpace(saved)) }, nil
+}
+
+// stty runs stty on the terminal f.
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// fuzzyScore reports whether `query` matches `s`, ignoring case, and how
+// well: lower is better. A substring scores its position; otherwise the
+// query's letters must appear in order, scoring how spread out they are.
+func fuzzyScore(query, s string) (int, bool) {
+	query, s = strings.ToLower(query), strings.ToLower(s)
+	if i := strings.Index(s, query); i >= 0 {
+		return i, true
+	}
+	first, pos := -1, 0
+	for _, r := range query {
+		i := strings.IndexRune(s[pos:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if first < 0 {
+			first = pos + i
+		}
+		pos += i + utf8.RuneLen(r)
+	}
+	return len(s) + pos - first, true
+}
+
+// fuzzyFilter returns the items `query` matches, best first.
+func fuzzyFilter(query string, items []PickItem) []PickItem {
+	type scored struct {
+		item  PickItem
+		score int
+	}
+	var matches []scored
+	for _, it := range items {
+		if score, ok := fuzzyScore(query, it.Label); ok {
+			matches = append(matches, scored{it, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+	out := make([]PickItem, len(matches))
+	for i, m := range matches {
+		out[i] = m.item
+	}
+	return out
+}
+
+// pickFrom runs the picker on a terminal already reading a key at a time:
+// printable keys edit the query, the arrow keys (or Ctrl-P and Ctrl-N)
+// move, Enter picks, and Ctrl-C or Ctrl-D cancels.
+func pickFrom(r io.Reader, w io.Writer, title, query string, items []PickItem) (PickItem, error) {
+	in := bufio.NewReader(r)
+	selected, drawn := 0, 0
+	for {
+		matches := fuzzyFilter(query, items)
+		selected = min(selected, max(len(matches)-1, 0))
+		drawn = drawPicker(w, drawn, title, query, matches, selected)
+
+		b, err := in.ReadByte()
+		if err != nil {
+			clearPicker(w, drawn)
+			return PickItem{}, ErrPickCancelled
+		}
+		switch b {
+		case '\r', '\n':
+			if len(matches) > 0 {
+				clearPicker(w, drawn)
+				fmt.Fprintf(w, "%s: %s\n", title, matches[selected].Label)
+				return matches[selected], nil
+			}
+		case 0x03, 0x04:
+			clearPicker(w, drawn)
+			return PickItem{}, ErrPickCancelled
+		case 0x7f, 0x08:
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				selected = 0
+			}
+		case 0x10:
+			selected = max(selected-1, 0)
+		case 0x0e:
+			selected++
+		case 0x1b:
+			// Arrow keys are ESC [ A and ESC [ B, or ESC O A and ESC O B.
+			if next, _ := in.ReadByte(); next == '[' || next == 'O' {
+				switch key, _ := in.ReadByte(); key {
+				case 'A':
+					selected = max(selected-1, 0)
+				case 'B':
+					selected++
+				}
+			}
+		default:
+			if b < 0x20 {
+				break
+			}
+			// A non-ASCII character arrives as several bytes; read the
+			// rest of it before adding it to the query.
+			buf := []byte{b}
+			for !utf8.FullRune(buf) {
+				next, err := in.ReadByte()
+				if err != nil {
+					break
+				}
+				buf = append(buf, next)
+			}
+			if r, _ := utf8.DecodeRune(buf); r != utf8.RuneError {
+				query += string(r)
+				selected = 0
+			}
+		}
+	}
+}
+
+// drawPicker redraws the picker over the `drawn` lines it drew last time
+// and returns how many lines it drew now. The choices scroll to keep the
+// selected one in view.
+func drawPicker(w io.Writer, drawn int, title, query string, matches []PickItem, selected int) int {
+	clearPicker(w, drawn)
+	lines := 0
+	say := func(format string, args ...any) {
+		fmt.Fprintf(w, format+"\n", args...)
+		lines++
+	}
+
+	say("%s %s", title, color.HiBlackString("(type to filter, %s to move, Enter to pick, Ctrl-C to cancel)", symbol("↑/↓", "up/down")))
+	say("> %s", query)
+	if len(matches) == 0 {
+		say("  %s", color.HiBlackString("no matches"))
+		return lines
+	}
+	start := max(selected-pickerRows+1, 0)
+	end := min(start+pickerRows, len(matches))
+	for i := start; i < end; i++ {
+		if i == selected {
+			say("%s %s", color.CyanString(symbol("▶", ">")), color.New(color.Bold).Sprint(matches[i].Label))
+		} else {
+			say("  %s", matches[i].Label)
+		}
+	}
+	if more := len(matches) - end; more > 0 {
+		say("  %s", color.HiBlackString("%d more", more))
+	}
+	return lines
+}
+
+// clearPicker erases the `drawn` lines above the cursor.
+func clearPicker(w io.Writer, drawn int) {
+	if drawn > 0 {
+		fmt.Fprintf(w, "\033[%dA\r\033[J", drawn)
+	}
+}
+
+// pickNumbered lists the items `query` matches, or all of them, numbered,
+// and reads the number of one, asking again until it gets a valid one.
+func pickNumbered(r io.Reader, w io.Writer, title, query string, items []PickItem) (PickItem, error) {
+	matches := fuzzyFilter(query, items)
+	if len(matches) == 0 {
+		matches = items
+	}
+	fmt.Fprintln(w, title+":")
+	for i, it := range matches {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, it.Label)
+	}
+	in := bufio.NewScanner(r)
+	for {
+		fmt.Fprintf(w, "Pick 1-%d: ", len(matches))
+		if !in.Scan() {
+			fmt.Fprintln(w)
+			return PickItem{}, ErrPickCancelled
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(in.Text()))
+		if err == nil && n >= 1 && n <= len(matches) {
+			return matches[n-1], nil
+		}
+	}
+}
@@ -0,0 +1,530 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Playback event stream. Spotify's Web API has no push
+// channel, so a background goroutine polls each account's player state,
+// diffs it against the last poll, and publishes the differences (track
+// changed, paused, device changed, volume changed, context ended) on the
+// event bus, where WebSocket clients on /ws pick them up. Polling only
+// runs while something subscribes to playback events.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+	"golang.org/x/net/websocket"
+)
+
+// Playback event types. EventState carries the full current state and is
+// sent to each client when it connects. EventContextEnded follows the
+// pause when a playlist, album, or track list plays out on its own.
+const (
+	EventState         = "state"
+	EventTrackChanged  = "track_changed"
+	EventPaused        = "paused"
+	EventResumed       = "resumed"
+	EventDeviceChanged = "device_changed"
+	EventVolumeChanged = "volume_changed"
+	EventContextEnded  = "context_ended"
+)
+
+// defaultPlaybackPollInterval is how often the player state is polled
+// while clients are connected, unless PLAYBACK_POLL_INTERVAL says otherwise.
+const defaultPlaybackPollInterval = 2 * time.Second
+
+// contextEndSlack is how close to the end of its track playback has to
+// stop to count as the context running out rather than someone pausing.
+const contextEndSlack = 3 * time.Second
+
+// PlaybackEvent is one message on the event stream. Every event carries
+// the full state after the change, so a client can render from any single
+// message.
+type PlaybackEvent struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	IsPlaying  bool      `json:"is_playing"`
+	TrackURI   string    `json:"track_uri,omitempty"`
+	ContextURI string    `json:"context_uri,omitempty"`
+	TrackName  string    `json:"track_name,omitempty"`
+	Artists    string    `json:"artists,omitempty"`
+	Album      string    `json:"album,omitempty"`
+	DurationMs int       `json:"duration_ms,omitempty"`
+	ProgressMs int       `json:"progress_ms"`
+	DeviceID   string    `json:"device_id,omitempty"`
+	DeviceName string    `json:"device_name,omitempty"`
+	Volume     int       `json:"volume"`
+	// Features are the track's audio features, when Spotify has them.
+	Features *AudioFeatures `json:"features,omitempty"`
+}
+
+// playbackSnapshot is the part of the player state the stream diffs.
+type playbackSnapshot struct {
+	playing    bool
+	trackURI   string
+	contextURI string
+	trackName  string
+	artists    string
+	album      string
+	durationMs int
+	progressMs int
+	deviceID   string
+	deviceName string
+	volume     int
+	features   *AudioFeatures
+	// at is when the state was observed; zero when unknown.
+	at time.Time
+}
+
+// snapshotFromState reduces a player state to a snapshot. A nil state
+// (nothing playing anywhere) is the zero snapshot.
+func snapshotFromState(state *spotifyLib.PlayerState) playbackSnapshot {
+	var s playbackSnapshot
+	if state == nil {
+		return s
+	}
+	s.playing = state.Playing
+	s.contextURI = string(state.PlaybackContext.URI)
+	s.progressMs = int(state.Progress)
+	s.deviceID = string(state.Device.ID)
+	s.deviceName = state.Device.Name
+	s.volume = int(state.Device.Volume)
+	if item := state.Item; item != nil {
+		s.trackURI = string(item.URI)
+		s.trackName = item.Name
+		s.album = item.Album.Name
+		s.durationMs = int(item.Duration)
+		names := make([]string, len(item.Artists))
+		for i, a := range item.Artists {
+			names[i] = a.Name
+		}
+		s.artists = strings.Join(names, ", ")
+	}
+	return s
+}
+
+// event builds an event of type `t` describing `s`.
+func (s playbackSnapshot) event(t string, at time.Time) PlaybackEvent {
+	return PlaybackEvent{
+		Type:       t,
+		Time:       at,
+		IsPlaying:  s.playing,
+		TrackURI:   s.trackURI,
+		ContextURI: s.contextURI,
+		TrackName:  s.trackName,
+		Artists:    s.artists,
+		Album:      s.album,
+		DurationMs: s.durationMs,
+		ProgressMs: s.progressMs,
+		DeviceID:   s.deviceID,
+		DeviceName: s.deviceName,
+		Volume:     s.volume,
+		Features:   s.features,
+	}
+}
+
+// diffPlayback returns the events that take `prev` to `cur`, in the order
+// a dashboard would want to apply them. Progress alone never produces an
+// event.
+func diffPlayback(prev, cur playbackSnapshot, at time.Time) []PlaybackEvent {
+	var events []PlaybackEvent
+	if cur.deviceID != prev.deviceID {
+		events = append(events, cur.event(EventDeviceChanged, at))
+	}
+	if cur.trackURI != prev.trackURI {
+		events = append(events, cur.event(EventTrackChanged, at))
+	}
+	if cur.playing != prev.playing {
+		t := EventPaused
+		if cur.playing {
+			t = EventResumed
+		}
+		events = append(events, cur.event(t, at))
+	}
+	// A device change already reports the new volume.
+	if cur.volume != prev.volume && cur.deviceID == prev.deviceID {
+		events = append(events, cur.event(EventVolumeChanged, at))
+	}
+	if prev.ranOut(cur, at) {
+		ev := cur.event(EventContextEnded, at)
+		ev.ContextURI = prev.contextURI
+		events = append(events, ev)
+	}
+	return events
+}
+
+// ranOut reports whether playback stopped at `at` because the context or
+// track list `s` was playing reached its end: it stopped by itself, with the last
+// track due to have finished. Spotify gives no other sign, so a pause in
+// the last few seconds of a track looks the same.
+func (s playbackSnapshot) ranOut(cur playbackSnapshot, at time.Time) bool {
+	if !s.playing || cur.playing || s.durationMs == 0 || s.at.IsZero() {
+		return false
+	}
+	if cur.deviceID != "" && cur.deviceID != s.deviceID {
+		return false
+	}
+	remaining := time.Duration(s.durationMs-s.progressMs) * time.Millisecond
+	return at.Sub(s.at)+contextEndSlack >= remaining
+}
+
+// PlaybackWatcher polls every account's player state while anything on
+// the event bus wants playback events, and publishes the changes.
+type PlaybackWatcher struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// demands maps each subscriber to the poll interval it needs; the
+	// watcher polls at the shortest.
+	demands map[int]time.Duration
+	nextID  int
+	last    map[string]*playbackSnapshot
+	stop    context.CancelFunc
+	wake    chan struct{}
+	// lastPoll is when the last poll began; pokeAt, when set, pulls the
+	// next poll forward (a new subscriber, a player command).
+	lastPoll time.Time
+	pokeAt   time.Time
+	// waiting is the wait run last chose, for PollStatus.
+	waiting time.Duration
+}
+
+// playbackWatcher is the process-wide watcher behind the playback topic.
+var playbackWatcher = &PlaybackWatcher{interval: defaultPlaybackPollInterval}
+
+// SetPlaybackPollInterval sets how often live subscribers' (/ws, SSE, and
+// sinks) state is polled while something plays; see PollPolicy for the
+// rest of the schedule.
+func SetPlaybackPollInterval(d time.Duration) {
+	if d > 0 {
+		playbackWatcher.mu.Lock()
+		playbackWatcher.interval = d
+		playbackWatcher.mu.Unlock()
+	}
+}
+
+// subscribe subscribes to `topics` on the bus, primed with each account's
+// last known state, and keeps polling at least every `every` (zero means
+// the configured interval) until unsubscribed. Polling stops with the
+// last subscriber.
+func (pw *PlaybackWatcher) subscribe(every time.Duration, size int, topics []string) (<-chan Event, func()) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	// Holding pw.mu keeps a poll from publishing between reading the last
+	// state and subscribing, so nothing is missed or seen twice.
+	var initial []Event
+	now := time.Now()
+	for account, snap := range pw.last {
+		initial = append(initial, Event{Topic: TopicPlayback, Type: EventState, Time: now, Account: account, Data: snap.event(EventState, now)})
+	}
+	events, unsubscribe := eventBus.subscribe(topics, initial, size)
+
+	if pw.demands == nil {
+		pw.demands = map[int]time.Duration{}
+	}
+	id := pw.nextID
+	pw.nextID++
+	pw.demands[id] = every
+	if pw.stop == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		pw.stop = cancel
+		wake := make(chan struct{}, 1)
+		pw.wake = wake
+		go pw.run(ctx, wake)
+	} else {
+		pw.pokeLocked(0)
+	}
+
+	return events, func() {
+		unsubscribe()
+
+		pw.mu.Lock()
+		defer pw.mu.Unlock()
+		if _, ok := pw.demands[id]; !ok {
+			return
+		}
+		delete(pw.demands, id)
+		if len(pw.demands) == 0 && pw.stop != nil {
+			pw.stop()
+			pw.stop = nil
+			// Nobody saw what happened while no one was watching, so the
+			// next subscriber starts from a fresh state.
+			pw.last = nil
+		}
+	}
+}
+
+// pokeAfter pulls the next poll forward to at most `d` from now, if the
+// watcher is running. The call budget still applies.
+func (pw *PlaybackWatcher) pokeAfter(d time.Duration) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.pokeLocked(d)
+}
+
+// pokeLocked is pokeAfter for callers holding pw.mu.
+func (pw *PlaybackWatcher) pokeLocked(d time.Duration) {
+	if pw.stop == nil {
+		return
+	}
+	at := time.Now().Add(d)
+	if pw.pokeAt.IsZero() || at.Before(pw.pokeAt) {
+		pw.pokeAt = at
+	}
+	select {
+	case pw.wake <- struct{}{}:
+	default:
+	}
+}
+
+// playingInterval is the shortest interval any subscriber needs while
+// something plays. Callers hold pw.mu.
+func (pw *PlaybackWatcher) playingInterval() time.Duration {
+	shortest := time.Duration(0)
+	for _, every := range pw.demands {
+		if every <= 0 {
+			every = pw.interval
+		}
+		if shortest == 0 || every < shortest {
+			shortest = every
+		}
+	}
+	if shortest == 0 {
+		shortest = pw.interval
+	}
+	return shortest
+}
+
+// playing reports whether any account was playing at the last poll.
+// Callers hold pw.mu.
+func (pw *PlaybackWatcher) playing() bool {
+	for _, snap := range pw.last {
+		if snap.playing {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWait is how long from `now` until the next poll: the policy's
+// interval after the last poll, or sooner if poked, but never before the
+// call budget has room for one poll of every account.
+func (pw *PlaybackWatcher) nextWait(now time.Time) time.Duration {
+	policy := currentPollPolicy()
+	accounts := len(AccountNames())
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	next := pw.lastPoll.Add(policy.interval(pw.playingInterval(), pw.playing()))
+	if !pw.pokeAt.IsZero() && pw.pokeAt.Before(next) {
+		next = pw.pokeAt
+	}
+	wait := max(next.Sub(now), 0)
+	if policy.Budget > 0 {
+		wait += spotifyCallWindow.waitFor(now.Add(wait), policy.Budget, accounts)
+	}
+	pw.waiting = wait
+	return wait
+}
+
+// Status describes the watcher's polling.
+func (pw *PlaybackWatcher) Status() PollStatus {
+	policy := currentPollPolicy()
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	status := PollStatus{
+		Strategy:        policy.Strategy,
+		Active:          pw.stop != nil,
+		Playing:         pw.playing(),
+		CallsLastMinute: spotifyCallWindow.count(time.Now()),
+		CallBudget:      policy.Budget,
+	}
+	if status.Active {
+		status.IntervalMs = pw.waiting.Milliseconds()
+	}
+	return status
+}
+
+// run polls every account until ctx is cancelled.
+func (pw *PlaybackWatcher) run(ctx context.Context, wake <-chan struct{}) {
+	lastErr := map[string]string{}
+	for {
+		pw.mu.Lock()
+		pw.lastPoll, pw.pokeAt = time.Now(), time.Time{}
+		pw.mu.Unlock()
+
+		for _, name := range AccountNames() {
+			actx := WithAccount(ctx, name)
+			client, err := clientFor(actx)
+			if err != nil {
+				continue
+			}
+			state, err := client.PlayerState(ctx)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				// Log each distinct failure once rather than every poll.
+				if err.Error() != lastErr[name] {
+					log.Printf("playback events (%s): failed to get player state: %v", name, err)
+					lastErr[name] = err.Error()
+				}
+			default:
+				delete(lastErr, name)
+				snap := snapshotFromState(state)
+				snap.features = featuresFor(actx, client, snap.trackURI)
+				pw.observe(name, snap, time.Now())
+			}
+		}
+
+		if !pw.waitForPoll(ctx, wake) {
+			return
+		}
+	}
+}
+
+// waitForPoll sleeps until the next poll is due, working the wait out
+// again whenever the watcher is poked. It returns false once ctx is done.
+func (pw *PlaybackWatcher) waitForPoll(ctx context.Context, wake <-chan struct{}) bool {
+	for {
+		timer := time.NewTimer(pw.nextWait(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-wake:
+			timer.Stop()
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// observe diffs `cur` against the account's last state and publishes the
+// result. The first observation is published as a state event.
+func (pw *PlaybackWatcher) observe(account string, cur playbackSnapshot, at time.Time) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	// A poll that finished after the last subscriber left is stale.
+	if pw.stop == nil {
+		return
+	}
+
+	var events []PlaybackEvent
+	if prev, ok := pw.last[account]; ok {
+		events = diffPlayback(*prev, cur, at)
+	} else {
+		events = []PlaybackEvent{cur.event(EventState, at)}
+	}
+	if pw.last == nil {
+		pw.last = map[string]*playbackSnapshot{}
+	}
+	cur.at = at
+	pw.last[account] = &cur
+
+	for _, ev := range events {
+		eventBus.Publish(Event{Topic: TopicPlayback, Type: ev.Type, Time: at, Account: account, Data: ev})
+	}
+}
+
+// playbackEventsServer upgrades /ws requests. Browsers always send an
+// Origin; a cross-origin one must be allowed by the CORS settings, so a
+// random web page can't read your playback. Clients that send no Origin
+// (scripts, dashboards) are accepted.
+var playbackEventsServer = websocket.Server{
+	Handshake: func(cfg *websocket.Config, r *http.Request) error {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return nil
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(u.Host, r.Host) && (corsConfig == nil || corsConfig.allowOrigin(origin) == "") {
+			return websocket.ErrBadWebSocketOrigin
+		}
+		cfg.Origin = u
+		return nil
+	},
+	Handler: streamPlaybackEvents,
+}
+
+// streamPlaybackEvents writes one account's playback events to one
+// WebSocket client until it disconnects.
+func streamPlaybackEvents(ws *websocket.Conn) {
+	defer ws.Close()
+
+	account := strings.ToLower(ws.Request().URL.Query().Get("account"))
+	if account == "" {
+		account = DefaultAccount
+	}
+	events, unsubscribe := SubscribeEvents(TopicPlayback)
+	defer unsubscribe()
+
+	// Clients don't send anything; a read returning is how we learn they
+	// went away.
+	closed := make(chan struct{})
+	go func() {
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if ev.Account != account {
+				continue
+			}
+			if err := websocket.JSON.Send(ws, ev.Data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// HandlePlaybackEventsRequest handles GET /ws, upgrading to a WebSocket
+// that streams PlaybackEvent messages for ?account= (the default account
+// when omitted). Browsers can't set headers on a WebSocket, so the token
+// normally comes as ?token=.
+func HandlePlaybackEventsRequest(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+	if account := strings.ToLower(r.URL.Query().Get("account")); !accountExists(account) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: fmt.Sprintf("Unknown account %q", account), Code: CodeBadRequest})
+		return
+	}
+
+	// Hijacking the connection clears the server's read and write
+	// deadlines, so the stream outlives HTTP_WRITE_TIMEOUT.
+	playbackEventsServer.ServeHTTP(w, r)
+}
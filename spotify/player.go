@@ -10,55 +10,260 @@ package spotify
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
 	spotifyLib "github.com/zmb3/spotify/v2"
 )
 
 // PlayPlaylist starts playback of a playlist on the specified device.
-// This function is used by both CLI and API server modes.
-func PlayPlaylist(deviceName, playlistInput string, shuffle bool) (string, error) {
-	if spotifyClient == nil {
-		return "", fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+// This function is used by both CLI and API server modes. Album and artist
+// URIs and links play the album or artist (see PlayContext).
+func PlayPlaylist(ctx context.Context, deviceName, playlistInput string, shuffle bool) (string, error) {
+	return PlayContext(ctx, deviceName, playlistInput, shuffle)
+}
+
+// PlayContext starts playback of anything Spotify can play on the
+// specified device. URIs and links of any type play that item (see
+// ResolvePlayTarget); anything else (including a name) is looked up as a
+// playlist. Use PlayRequest.Album, Artist, Track, or Audiobook to play one
+// by name or query.
+func PlayContext(ctx context.Context, deviceName, contextInput string, shuffle bool) (string, error) {
+	return Play(ctx, PlayRequest{Device: deviceName, Playlist: contextInput, Shuffle: shuffle})
+}
+
+// Play starts playback described by req. The start track is chosen by the
+// request's start-position strategy, and the volume, if set, is applied
+// before the music starts (or once it's playing, for a device that won't
+// take it earlier), then faded up to req.TargetVolume if the request has
+// a fade-in. A volume failure is logged but doesn't fail the request. With req.Confirm, Play doesn't return success until the
+// player reports the playlist actually playing on the target device. The
+// account comes from ctx (see WithAccount). A playlist input that links
+// to something else plays that instead. The outcome is published on the
+// play topic.
+func Play(ctx context.Context, req PlayRequest) (msg string, err error) {
+	var device *spotifyLib.PlayerDevice
+	defer func() { publishPlay(ctx, req, device, msg, err) }()
+
+	if req.Volume != nil && (*req.Volume < 0 || *req.Volume > 100) {
+		return "", fmt.Errorf("volume must be between 0 and 100, got %d", *req.Volume)
+	}
+	req, err = req.retarget(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	ctx := context.Background()
+	ctx = routeByDevice(ctx, req.Device)
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if req.FadeIn != "" && req.Volume == nil {
+		start := fadeInStartVolume
+		req.Volume = &start
+	}
 
-	// Get available devices
-	devices, err := spotifyClient.PlayerDevices(ctx)
+	started := time.Now()
+	msg, target, err := startPlayback(ctx, client, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get devices: %w", err)
+		return "", err
+	}
+	device = target.device
+
+	notePlayedDevice(ctx, PlayedDevice{ID: string(device.ID), Name: device.Name, Requested: req.Device, Fallback: target.fallback, Via: target.via})
+	if target.fallback {
+		recordFallback(ctx, FallbackEvent{
+			Time:      started,
+			Account:   AccountFrom(ctx),
+			Requested: req.Device,
+			Via:       target.via,
+			DeviceID:  string(device.ID),
+			Device:    device.Name,
+			Playlist:  req.Playlist,
+			Album:     req.Album,
+			Artist:    req.Artist,
+			Track:     req.Track,
+			Audiobook: req.Audiobook,
+			Show:      req.Show,
+		})
+	}
+
+	if req.Confirm {
+		took, err := waitUntilPlaying(ctx, started, confirmTimeout, target)
+		if err != nil {
+			return "", fmt.Errorf("Spotify accepted the request but %w", err)
+		}
+		msg += fmt.Sprintf("; confirmed playing after %.1fs", took.Seconds())
+	}
+
+	watchEnd(ctx, client, req, target)
+
+	if req.Volume != nil && !target.volumeSet {
+		opts := &spotifyLib.PlayOptions{DeviceID: &device.ID}
+		if err := client.VolumeOpt(ctx, *req.Volume, opts); err != nil {
+			warnf(ctx, "failed to set volume on %s: %v", device.Name, err)
+		} else {
+			target.volumeSet = true
+		}
+	}
+	if target.volumeSet {
+		msg += fmt.Sprintf("; Volume set to %d%% on %s", *req.Volume, device.Name)
+	}
+	msg += startFadeIn(ctx, req, device)
+
+	return msg, nil
+}
+
+// PlayEvent is the payload of play-topic events: what a play asked for,
+// where it went, and how it ended.
+type PlayEvent struct {
+	Request EffectiveRequest `json:"request"`
+	// Source is what started the play, like "schedule Wake up"; empty
+	// for the API and the CLI.
+	Source   string    `json:"source,omitempty"`
+	Device   string    `json:"device,omitempty"`
+	DeviceID string    `json:"device_id,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Code     ErrorCode `json:"code,omitempty"`
+}
+
+// playSourceKey is the context key for what started a play.
+type playSourceKey struct{}
+
+// withPlaySource records what started the plays made with ctx, for their
+// play events.
+func withPlaySource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, playSourceKey{}, source)
+}
+
+// publishPlay publishes the outcome of a play on the play topic.
+func publishPlay(ctx context.Context, req PlayRequest, device *spotifyLib.PlayerDevice, msg string, err error) {
+	ev := PlayEvent{Request: effectiveRequest(req), Message: msg}
+	ev.Source, _ = ctx.Value(playSourceKey{}).(string)
+	if device != nil {
+		ev.Device, ev.DeviceID = device.Name, string(device.ID)
+	}
+	typ := EventPlayStarted
+	if err != nil {
+		typ, ev.Error, ev.Code = EventPlayFailed, err.Error(), ErrorCodeOf(err)
+	}
+	publishEvent(TopicPlay, typ, AccountFrom(ctx), ev)
+}
+
+// startPlayback does the work of Play and returns where playback started
+// and what should be playing there.
+func startPlayback(ctx context.Context, client Client, req PlayRequest) (string, *playbackTarget, error) {
+	if err := req.Validate(); err != nil {
+		return "", nil, err
+	}
+	strategy, err := StartStrategyFor(req.Start, req.Shuffle)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var device *spotifyLib.PlayerDevice
+	var via string
+	if req.Wait != "" {
+		device, err = awaitDevice(ctx, client, req.Device, req.Wait)
+	} else {
+		device, via, err = pickDevice(ctx, client, req.Device)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Set the volume before anything plays, so an alarm doesn't start at
+	// whatever the speaker was left at. Play tries again once playing if
+	// the device won't take it yet.
+	volumeSet := req.Volume != nil && client.VolumeOpt(ctx, *req.Volume, &spotifyLib.PlayOptions{DeviceID: &device.ID}) == nil
+
+	msg, target, err := playOn(ctx, client, req, strategy, device)
+	if target != nil {
+		target.fallback = via != "" && req.Device != ""
+		target.via = via
+		target.volumeSet = volumeSet
+	}
+	return msg, target, err
+}
+
+// pickDevice finds the device `deviceName` names, claiming it over
+// zeroconf if it isn't linked, or walks the fallback chain (see
+// SetDeviceFallback) when it's empty or can't be found. via is the chain
+// entry that picked the device, or "" when it's the one named.
+func pickDevice(ctx context.Context, client Client, deviceName string) (*spotifyLib.PlayerDevice, string, error) {
+	targetDevice, devices, err := findOrClaimDevice(ctx, client, deviceName)
+	if err != nil {
+		return nil, "", err
+	}
+	if targetDevice != nil {
+		Verbosef("device: %q matched %s (%s, id %s)", deviceName, targetDevice.Name, targetDevice.Type, targetDevice.ID)
+		return targetDevice, "", nil
 	}
 
-	// Find the target device in the existing cloud list.
-	var targetDevice *spotifyLib.PlayerDevice
-	for i, device := range devices {
-		if deviceName != "" && (device.Name == deviceName || string(device.ID) == deviceName) {
-			targetDevice = &devices[i]
-			break
+	if len(devices) == 0 {
+		return nil, "", withCode(CodeDevice, fmt.Errorf("no Spotify Connect devices found"))
+	}
+
+	targetDevice, via := FallbackDevice(devices)
+	if targetDevice == nil {
+		if deviceName != "" {
+			return nil, "", withCode(CodeDevice, fmt.Errorf("device %q not found and no device in the fallback chain (%s) is online", deviceName, strings.Join(deviceFallbackChain, ", ")))
 		}
+		return nil, "", withCode(CodeDevice, fmt.Errorf("no device in the fallback chain (%s) is online", strings.Join(deviceFallbackChain, ", ")))
+	}
+	if deviceName != "" {
+		warnf(ctx, "requested device %q not found after claiming it, fell back to %s (fallback chain: %s)", deviceName, targetDevice.Name, via)
 	}
+	Verbosef("device: %s picked by fallback chain entry %q of %s", targetDevice.Name, via, strings.Join(deviceFallbackChain, ", "))
+	return targetDevice, via, nil
+}
+
+// findOrClaimDevice finds the device `deviceName` names in the device
+// list, claiming it over zeroconf if it isn't there. The device is nil
+// when it's empty or the claimed device still isn't listed; the list is
+// returned for picking another.
+func findOrClaimDevice(ctx context.Context, client Client, deviceName string) (*spotifyLib.PlayerDevice, []spotifyLib.PlayerDevice, error) {
+	devices, err := client.PlayerDevices(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	observeDevices(devices)
+
+	// Find the target device in the existing cloud list. A group plays on
+	// its first member that's online.
+	targetDevice := lookupDevice(devices, deviceName)
 
 	// If a specific device was requested but isn't in the cloud list, try
 	// to claim it via zeroconf. This is the multi-account-household path:
 	// another user previously linked this speaker to their account and we
 	// need to take it back.
 	if targetDevice == nil && deviceName != "" {
+		if g, ok := GetSettings().FindGroup(deviceName); ok {
+			deviceName = g.primaryRef()
+		}
 		log.Printf("device %q not in Spotify cloud list, attempting zeroconf claim", deviceName)
 		claim, claimErr := ClaimDevice(ctx, deviceName)
 		if claimErr != nil {
-			return "", fmt.Errorf("device %q not available and zeroconf claim failed: %w", deviceName, claimErr)
+			return nil, nil, withCode(CodeDevice, fmt.Errorf("device %q not available and zeroconf claim failed: %w", deviceName, claimErr))
 		}
 		log.Printf("claimed %q -> deviceID=%s", deviceName, claim.DeviceID)
+		Verbosef("device: %q wasn't in the device list; claimed it over zeroconf", deviceName)
 
 		// Re-fetch devices and find the now-registered one.
-		devices, err = spotifyClient.PlayerDevices(ctx)
+		devices, err = client.PlayerDevices(ctx)
 		if err != nil {
-			return "", fmt.Errorf("failed to refresh devices after claim: %w", err)
+			return nil, nil, fmt.Errorf("failed to refresh devices after claim: %w", err)
 		}
+		observeDevices(devices)
 		for i, device := range devices {
 			if string(device.ID) == claim.DeviceID {
 				targetDevice = &devices[i]
@@ -66,38 +271,73 @@ func PlayPlaylist(deviceName, playlistInput string, shuffle bool) (string, error
 			}
 		}
 	}
+	return targetDevice, devices, nil
+}
 
-	if targetDevice == nil && len(devices) == 0 {
-		return "", fmt.Errorf("no Spotify Connect devices found")
-	}
-
-	// If no device specified or still not found, fall back to first active or first device.
-	if targetDevice == nil {
-		for i, device := range devices {
-			if device.Active {
-				targetDevice = &devices[i]
-				break
-			}
-		}
-		if targetDevice == nil {
-			targetDevice = &devices[0]
-		}
+// playOn starts req's playlist, album, artist, track, or audiobook on
+// `targetDevice`.
+func playOn(ctx context.Context, client Client, req PlayRequest, strategy StartStrategy, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	switch {
+	case req.Album != "":
+		return playAlbum(ctx, client, req, strategy, targetDevice)
+	case req.Artist != "":
+		return playArtist(ctx, client, req, targetDevice)
+	case req.Track != "":
+		return playTrack(ctx, client, req, targetDevice)
+	case req.Audiobook != "":
+		return playAudiobook(ctx, client, req, targetDevice)
+	case req.Show != "":
+		return playShow(ctx, client, req, targetDevice)
+	case IsLikedSongs(req.Playlist):
+		return playLiked(ctx, client, req, targetDevice)
 	}
 
 	// Resolve playlist
-	playlistID, err := ResolvePlaylistIDQuiet(ctx, spotifyClient, playlistInput)
+	playlistID, err := ResolvePlaylistIDQuiet(ctx, client, req.Playlist)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve playlist: %w", err)
+		return "", nil, fmt.Errorf("failed to resolve playlist: %w", err)
 	}
 
+	playlistURI := spotifyLib.URI(spotifyuri.Resource{Type: spotifyuri.Playlist, ID: playlistID}.URI())
+
 	// Get playlist info
-	playlist, err := spotifyClient.GetPlaylist(ctx, spotifyLib.ID(playlistID))
+	playlist, err := client.GetPlaylist(ctx, spotifyLib.ID(playlistID))
 	if err != nil {
-		return "", fmt.Errorf("failed to get playlist: %w", err)
+		if req.strictMetadata() || req.NewestFirst || req.LeastPlayed || req.Duration != "" || !isMetadataUnavailable(err) {
+			return "", nil, fmt.Errorf("failed to get playlist: %w", err)
+		}
+		return playWithoutMetadata(ctx, client, req, targetDevice, playlistURI, err)
 	}
 
 	trackCount := int(playlist.Tracks.Total)
-	playlistURI := spotifyLib.URI("spotify:playlist:" + playlistID)
+
+	if req.NewestFirst || req.LeastPlayed || req.Duration != "" {
+		var uris []spotifyLib.URI
+		label := "newest first"
+		switch {
+		case req.Duration != "":
+			uris, label, err = durationURIs(ctx, client, req, strategy, playlistID, trackCount)
+		case req.LeastPlayed:
+			label = "least played first"
+			db, _ := historyFor(AccountFrom(ctx))
+			uris, err = LeastPlayedURIs(ctx, client, playlistID, db)
+		default:
+			uris, err = NewestFirstURIs(ctx, client, playlistID)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		err = client.PlayOpt(ctx, &spotifyLib.PlayOptions{DeviceID: &targetDevice.ID, URIs: uris})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to start playback: %w", err)
+		}
+		recordStart(ctx, "", uris)
+		target := &playbackTarget{device: targetDevice}
+		if len(uris) > 0 {
+			target.trackURI, target.lastTrackURI = uris[0], uris[len(uris)-1]
+		}
+		return fmt.Sprintf("Now playing \"%s\" on %s (%s, %d tracks)", playlist.Name, targetDevice.Name, label, len(uris)), target, nil
+	}
 
 	// Build play options
 	opts := &spotifyLib.PlayOptions{
@@ -105,56 +345,47 @@ func PlayPlaylist(deviceName, playlistInput string, shuffle bool) (string, error
 		PlaybackContext: &playlistURI,
 	}
 
-	if shuffle {
-		// Pick random starting track
-		randomOffset := rand.Intn(trackCount)
-		opts.PlaybackOffset = &spotifyLib.PlaybackOffset{Position: &randomOffset}
-
-		err = spotifyClient.PlayOpt(ctx, opts)
-		if err != nil {
-			return "", fmt.Errorf("failed to start playback: %w", err)
-		}
-
-		// Wait for playback to initialize before setting shuffle
-		time.Sleep(500 * time.Millisecond)
+	position, err := strategy.Pick(ctx, client, playlistID, trackCount)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to pick start track: %w", err)
+	}
+	opts.PlaybackOffset = &spotifyLib.PlaybackOffset{Position: &position}
 
-		// Enable shuffle mode
-		err = spotifyClient.Shuffle(ctx, true)
-		if err != nil {
-			log.Printf("Warning: Failed to enable shuffle: %v", err)
-		}
+	err = client.PlayOpt(ctx, opts)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	RecordPlaylistStart(playlistID, position)
+	recordStart(ctx, string(playlistURI), nil)
+	target := &playbackTarget{device: targetDevice, contextURI: playlistURI}
 
+	if req.Shuffle {
+		enableShuffle(ctx, client)
 		return fmt.Sprintf("Now playing \"%s\" on %s (shuffle enabled, starting at track %d of %d)",
-			playlist.Name, targetDevice.Name, randomOffset+1, trackCount), nil
+			playlist.Name, targetDevice.Name, position+1, trackCount), target, nil
 	}
 
-	// Start from track 1
-	startPosition := 0
-	opts.PlaybackOffset = &spotifyLib.PlaybackOffset{Position: &startPosition}
-
-	err = spotifyClient.PlayOpt(ctx, opts)
-	if err != nil {
-		return "", fmt.Errorf("failed to start playback: %w", err)
+	if position == 0 {
+		return fmt.Sprintf("Now playing \"%s\" on %s (starting at track 1)", playlist.Name, targetDevice.Name), target, nil
 	}
-
-	return fmt.Sprintf("Now playing \"%s\" on %s (starting at track 1)", playlist.Name, targetDevice.Name), nil
+	return fmt.Sprintf("Now playing \"%s\" on %s (starting at track %d of %d)", playlist.Name, targetDevice.Name, position+1, trackCount), target, nil
 }
 
 // ListDevices returns the list of available Spotify Connect devices for the
 // authenticated user. Used by the API server to expose device discovery to
 // clients (e.g., the iOS Shortcut) so they can pick a target before calling
 // /api/v1/play.
-func ListDevices() ([]spotifyLib.PlayerDevice, error) {
-	if spotifyClient == nil {
-		return nil, fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+func ListDevices(ctx context.Context) ([]spotifyLib.PlayerDevice, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx := context.Background()
-
-	devices, err := spotifyClient.PlayerDevices(ctx)
+	devices, err := client.PlayerDevices(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get devices: %w", err)
 	}
+	observeDevices(devices)
 
 	return devices, nil
 }
@@ -164,48 +395,59 @@ func ListDevices() ([]spotifyLib.PlayerDevice, error) {
 // is supplied, we resolve it against the cloud devices list and pass the
 // ID to Spotify's volume API. `percent` must be 0-100.
 //
+// A group name sets the volume on each of its members (see
+// SetGroupVolume).
+//
 // Spotify Premium is required for volume control — non-Premium accounts
 // will get a "Restriction violated" error from the upstream API.
-func SetVolume(percent int, deviceName string) (string, error) {
-	if spotifyClient == nil {
-		return "", fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+func SetVolume(ctx context.Context, percent int, deviceName string) (string, error) {
+	if g, ok := GetSettings().FindGroup(deviceName); ok {
+		if percent < 0 || percent > 100 {
+			return "", fmt.Errorf("level must be between 0 and 100, got %d", percent)
+		}
+		return SetGroupVolume(ctx, percent, g)
+	}
+	return setDeviceVolume(ctx, percent, deviceName)
+}
+
+// setDeviceVolume is SetVolume for a single device, or the active one.
+func setDeviceVolume(ctx context.Context, percent int, deviceName string) (string, error) {
+	ctx = routeByDevice(ctx, deviceName)
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
 	}
 	if percent < 0 || percent > 100 {
 		return "", fmt.Errorf("level must be between 0 and 100, got %d", percent)
 	}
 
-	ctx := context.Background()
-
 	// No device specified — set on whatever's currently active.
 	if deviceName == "" {
-		if err := spotifyClient.Volume(ctx, percent); err != nil {
+		if err := client.Volume(ctx, percent); err != nil {
 			return "", fmt.Errorf("failed to set volume: %w", err)
 		}
 		return fmt.Sprintf("Volume set to %d%% on active device", percent), nil
 	}
 
 	// Device specified — resolve to an ID via the cloud devices list.
-	devices, err := spotifyClient.PlayerDevices(ctx)
+	devices, err := client.PlayerDevices(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get devices: %w", err)
 	}
 
 	var targetID spotifyLib.ID
 	var matchedName string
-	for _, d := range devices {
-		if d.Name == deviceName || string(d.ID) == deviceName {
-			targetID = d.ID
-			matchedName = d.Name
-			break
-		}
+	if d := findDevice(devices, deviceName); d != nil {
+		targetID = d.ID
+		matchedName = d.Name
 	}
 
 	if targetID == "" {
-		return "", fmt.Errorf("device %q not in Spotify cloud devices list — call /api/v1/wake first", deviceName)
+		return "", withCode(CodeDevice, fmt.Errorf("device %q not in Spotify cloud devices list — call /api/v1/wake first", deviceName))
 	}
 
 	opts := &spotifyLib.PlayOptions{DeviceID: &targetID}
-	if err := spotifyClient.VolumeOpt(ctx, percent, opts); err != nil {
+	if err := client.VolumeOpt(ctx, percent, opts); err != nil {
 		return "", fmt.Errorf("failed to set volume on %s: %w", matchedName, err)
 	}
 	return fmt.Sprintf("Volume set to %d%% on %s", percent, matchedName), nil
@@ -216,31 +458,359 @@ func SetVolume(percent int, deviceName string) (string, error) {
 // Spotify's API doesn't accept a device override here, so callers can't
 // skip "the bedroom speaker" when something else is the active device.
 // Premium-only.
-func SkipToNext() (string, error) {
-	if spotifyClient == nil {
-		return "", fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+func SkipToNext(ctx context.Context) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	ctx := context.Background()
-	if err := spotifyClient.Next(ctx); err != nil {
+	if err := client.Next(ctx); err != nil {
 		return "", fmt.Errorf("failed to skip: %w", err)
 	}
 	return "Skipped to next track", nil
 }
 
-// PausePlayback pauses the current Spotify playback.
-// This function is used by both CLI and API server modes.
-func PausePlayback() (string, error) {
-	if spotifyClient == nil {
-		return "", fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+// strictMetadata reports whether a playlist metadata failure should fail
+// the request, falling back to the server-wide default.
+func (req PlayRequest) strictMetadata() bool {
+	if req.StrictMetadata != nil {
+		return *req.StrictMetadata
+	}
+	return strictMetadataDefault
+}
+
+// isMetadataUnavailable reports whether err is Spotify refusing to show us
+// the playlist (404/403) — typical of region-restricted editorial
+// playlists that still play fine as a context. Auth and server errors
+// don't qualify.
+func isMetadataUnavailable(err error) bool {
+	var spErr spotifyLib.Error
+	if !errors.As(err, &spErr) {
+		return false
+	}
+	return spErr.Status == http.StatusNotFound || spErr.Status == http.StatusForbidden
+}
+
+// enableShuffle turns shuffle on for playback that was just started.
+// Spotify drops the request if it lands before the device has picked up
+// the play, so it waits briefly first. A failure only warns: the music is
+// already playing.
+func enableShuffle(ctx context.Context, client Client) {
+	time.Sleep(500 * time.Millisecond)
+	if err := client.Shuffle(ctx, true); err != nil {
+		warnf(ctx, "failed to enable shuffle: %v", err)
+	}
+}
+
+// playWithoutMetadata starts the playlist as a bare context when its
+// metadata can't be fetched. Without a track count no start strategy can
+// run, so Spotify picks where to begin.
+func playWithoutMetadata(ctx context.Context, client Client, req PlayRequest, device *spotifyLib.PlayerDevice, playlistURI spotifyLib.URI, metaErr error) (string, *playbackTarget, error) {
+	warnf(ctx, "playlist details unavailable (%v), playing %s with no start position", metaErr, playlistURI)
+
+	err := client.PlayOpt(ctx, &spotifyLib.PlayOptions{DeviceID: &device.ID, PlaybackContext: &playlistURI})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start playback (playlist metadata was also unavailable: %v): %w", metaErr, err)
+	}
+	recordStart(ctx, string(playlistURI), nil)
+
+	if req.Shuffle {
+		enableShuffle(ctx, client)
+	}
+
+	return fmt.Sprintf("Now playing %s on %s (playlist details unavailable, start position chosen by Spotify)", playlistURI, device.Name), &playbackTarget{device: device, contextURI: playlistURI}, nil
+}
+
+// Validate rejects option combinations that contradict the ordered
+// playback modes, which fix both the order and the first track, nothing
+// or more than one thing to play, and playlist-only options on albums,
+// artists, tracks, and audiobooks.
+func (req PlayRequest) Validate() error {
+	given := 0
+	for _, v := range []string{req.Playlist, req.Album, req.Artist, req.Track, req.Audiobook, req.Show} {
+		if v != "" {
+			given++
+		}
+	}
+	if given == 0 {
+		return fmt.Errorf("one of playlist, album, artist, track, audiobook, or show is required")
+	}
+	if given > 1 {
+		return fmt.Errorf("only one of playlist, album, artist, track, audiobook, or show can be given")
+	}
+	if err := req.validateOnEnd(); err != nil {
+		return err
+	}
+	if err := req.validateLiked(); err != nil {
+		return err
+	}
+	if err := req.validateDuration(); err != nil {
+		return err
+	}
+	if err := req.validateFadeIn(); err != nil {
+		return err
+	}
+	if err := req.validateWait(); err != nil {
+		return err
+	}
+	if req.Audiobook != "" {
+		switch {
+		case req.Shuffle:
+			return fmt.Errorf("shuffle doesn't apply to audiobooks")
+		case req.Start != "", req.NewestFirst, req.LeastPlayed:
+			return fmt.Errorf("start strategies and ordered modes don't apply to audiobooks: they resume where they left off")
+		}
+	}
+	if req.Show != "" {
+		switch {
+		case req.Shuffle && isEpisodeInput(req.Show):
+			return fmt.Errorf("shuffle doesn't apply to a single episode")
+		case req.Start != "", req.NewestFirst, req.LeastPlayed:
+			return fmt.Errorf("start strategies and ordered modes don't apply to shows")
+		}
+	}
+	if req.Track != "" {
+		switch {
+		case req.Shuffle:
+			return fmt.Errorf("shuffle doesn't apply to a single track")
+		case req.Start != "", req.NewestFirst, req.LeastPlayed:
+			return fmt.Errorf("start strategies and ordered modes don't apply to a single track")
+		}
+	}
+	if req.Album != "" || req.Artist != "" {
+		switch {
+		case req.NewestFirst:
+			return fmt.Errorf("newest_first only applies to playlists")
+		case req.LeastPlayed:
+			return fmt.Errorf("least_played only applies to playlists")
+		case strings.EqualFold(req.Start, StartNewest):
+			return fmt.Errorf("the %s start strategy only applies to playlists", StartNewest)
+		case req.Artist != "" && req.Start != "":
+			return fmt.Errorf("start strategies don't apply to artists: Spotify picks where an artist starts")
+		}
 	}
 
-	ctx := context.Background()
+	mode := ""
+	switch {
+	case req.NewestFirst && req.LeastPlayed:
+		return fmt.Errorf("newest_first and least_played cannot be combined")
+	case req.NewestFirst:
+		mode = "newest_first"
+	case req.LeastPlayed:
+		mode = "least_played"
+	default:
+		return nil
+	}
+
+	if req.Shuffle {
+		return fmt.Errorf("%s cannot be combined with shuffle", mode)
+	}
+	if req.Start != "" {
+		return fmt.Errorf("%s cannot be combined with a start strategy", mode)
+	}
+	return nil
+}
+
+// Seek jumps to `positionMs` milliseconds into the current track on the
+// active device. Handy for skipping long podcast intros remotely. Like
+// SkipToNext, Spotify only lets us target the active session. Premium-only.
+func Seek(ctx context.Context, positionMs int) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if positionMs < 0 {
+		return "", fmt.Errorf("position must be zero or greater, got %d", positionMs)
+	}
+
+	if err := client.Seek(ctx, positionMs); err != nil {
+		return "", fmt.Errorf("failed to seek: %w", err)
+	}
+	return fmt.Sprintf("Seeked to %s", formatPosition(positionMs)), nil
+}
+
+// spotifyAPIBaseURL is the Spotify Web API root. A variable so tests can
+// point raw calls at an httptest server.
+var spotifyAPIBaseURL = "https://api.spotify.com/v1/"
+
+// QueueTrack adds a track or podcast episode to the end of the playback
+// queue on the active device without interrupting the current context.
+// `uri` accepts spotify:track:/spotify:episode: URIs, open.spotify.com
+// links, or a bare track ID.
+func QueueTrack(ctx context.Context, uri string) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	item, err := parseQueueURI(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if item.Type == spotifyuri.Shortlink {
+		resolved, err := spotifyuri.Resolve(ctx, shortlinkHTTPClient, item.URL())
+		if err != nil {
+			return "", err
+		}
+		if item, err = checkQueueable(resolved); err != nil {
+			return "", err
+		}
+	}
 
-	err := spotifyClient.Pause(ctx)
+	// The upstream library only builds spotify:track: URIs, so episodes
+	// go straight to the same Web API endpoint.
+	if item.Type == spotifyuri.Episode {
+		err = queueEpisode(ctx, client, item)
+	} else {
+		err = client.QueueSong(ctx, spotifyLib.ID(item.ID))
+	}
 	if err != nil {
+		return "", fmt.Errorf("failed to add to queue: %w", err)
+	}
+
+	return fmt.Sprintf("Added %s %s to the queue", item.Type, item.ID), nil
+}
+
+// parseQueueURI identifies a queueable item: a track or episode URI or
+// link, a bare ID (assumed to be a track), or a shortlink to be resolved.
+func parseQueueURI(input string) (spotifyuri.Resource, error) {
+	r, err := spotifyuri.Parse(input)
+	if err != nil {
+		return spotifyuri.Resource{}, fmt.Errorf("unrecognized track or episode %q", input)
+	}
+	return checkQueueable(r)
+}
+
+// checkQueueable defaults a bare ID to a track and rejects anything that
+// isn't a track, episode, or shortlink.
+func checkQueueable(r spotifyuri.Resource) (spotifyuri.Resource, error) {
+	switch r.Type {
+	case "":
+		r.Type = spotifyuri.Track
+	case spotifyuri.Track, spotifyuri.Episode, spotifyuri.Shortlink:
+	default:
+		return spotifyuri.Resource{}, fmt.Errorf("only tracks and episodes can be queued, got %s", r.Type)
+	}
+	return r, nil
+}
+
+// queueEpisode posts the episode's URI to the add-to-queue endpoint
+// using the current access token.
+func queueEpisode(ctx context.Context, client Client, episode spotifyuri.Resource) error {
+	tok, err := client.Token()
+	if err != nil {
+		return fmt.Errorf("get access token: %w", err)
+	}
+
+	endpoint := spotifyAPIBaseURL + "me/player/queue?" + url.Values{"uri": {episode.URI()}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("spotify returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// formatPosition renders a millisecond offset as m:ss for human messages.
+func formatPosition(ms int) string {
+	total := ms / 1000
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// PausePlayback pauses the current Spotify playback.
+// This function is used by both CLI and API server modes.
+func PausePlayback(ctx context.Context) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := client.Pause(ctx); err != nil {
 		return "", fmt.Errorf("failed to pause playback: %w", err)
 	}
 
 	return "Playback paused", nil
 }
+
+// PauseDevice pauses playback on the device `deviceName` names and leaves
+// every other device alone. An account plays on one device at a time, so
+// the pause is only sent, aimed at the device's ID, when that device is
+// the one playing. A group pauses its members (see PauseGroup).
+func PauseDevice(ctx context.Context, deviceName string) (string, error) {
+	if IsDeviceGroup(deviceName) {
+		return PauseGroup(ctx, deviceName)
+	}
+
+	ctx = routeByDevice(ctx, deviceName)
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	devices, err := ListDevices(ctx)
+	if err != nil {
+		return "", err
+	}
+	device := findDevice(devices, deviceName)
+	if device == nil {
+		return "", withCode(CodeDevice, fmt.Errorf("device %q not found", deviceName))
+	}
+
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get playback state: %w", err)
+	}
+	if state == nil || !state.Playing || state.Device.ID != device.ID {
+		return fmt.Sprintf("Nothing playing on %s", device.Name), nil
+	}
+	if err := client.PauseOpt(ctx, &spotifyLib.PlayOptions{DeviceID: &device.ID}); err != nil {
+		return "", fmt.Errorf("failed to pause %s: %w", device.Name, err)
+	}
+	return fmt.Sprintf("Paused %s", device.Name), nil
+}
+
+// StopPlayback fully stops playback rather than just pausing it. Spotify's
+// API has no real stop, so this pauses, rewinds the current track to the
+// start (a later resume won't pick up mid-song), and — when transferTo
+// names a device — hands the paused session to that device so the
+// original speaker is released.
+func StopPlayback(ctx context.Context, transferTo string) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := client.Pause(ctx); err != nil {
+		return "", fmt.Errorf("failed to stop playback: %w", err)
+	}
+	if err := client.Seek(ctx, 0); err != nil {
+		warnf(ctx, "stop failed to rewind track: %v", err)
+	}
+
+	if transferTo == "" {
+		return "Playback stopped", nil
+	}
+
+	devices, err := client.PlayerDevices(ctx)
+	if err != nil {
+		return "", fmt.Errorf("playback stopped but failed to get devices: %w", err)
+	}
+	if d := findDevice(devices, transferTo); d != nil {
+		if err := client.TransferPlayback(ctx, d.ID, false); err != nil {
+			return "", fmt.Errorf("playback stopped but failed to transfer to %s: %w", d.Name, err)
+		}
+		return fmt.Sprintf("Playback stopped and session moved to %s", d.Name), nil
+	}
+	return "", withCode(CodeDevice, fmt.Errorf("playback stopped but device %q not in Spotify cloud devices list", transferTo))
+}
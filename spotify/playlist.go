@@ -10,49 +10,59 @@ package spotify
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
 
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
 	spotifyLib "github.com/zmb3/spotify/v2"
 )
 
-// ExtractPlaylistID extracts the playlist ID from a Spotify URL or returns
-// the input as-is if it's already just an ID.
+// shortlinkHTTPClient follows spotify.link shortlinks.
+var shortlinkHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// ExtractPlaylistID extracts the playlist ID from a Spotify URI or URL or
+// returns the input as-is if it's already just an ID.
 func ExtractPlaylistID(input string) string {
-	// If it's a full URL like https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=xxx
-	if strings.Contains(input, "spotify.com/playlist/") {
-		parts := strings.Split(input, "/playlist/")
-		if len(parts) > 1 {
-			// Remove any query parameters
-			id := strings.Split(parts[1], "?")[0]
-			return id
-		}
+	if r, err := spotifyuri.Parse(input); err == nil && r.Type == spotifyuri.Playlist {
+		return r.ID
 	}
-	// Already just an ID
-	return input
+	return strings.TrimSpace(input)
 }
 
-// ResolvePlaylistID resolves a playlist input (URL, name, or ID) to a playlist ID.
-// It first checks if it's a URL, then searches the user's playlists by name,
-// and finally assumes it's an ID if no match is found.
-func ResolvePlaylistID(ctx context.Context, client *spotifyLib.Client, input string) (string, error) {
-	// First, check if it's a URL and extract the ID
-	if strings.Contains(input, "spotify.com/playlist/") {
-		return ExtractPlaylistID(input), nil
+// playlistIDFromInput returns the playlist ID named by a URI, link,
+// shortlink, or bare ID. ok is false when the input isn't any of those and
+// should be looked up as a playlist name.
+func playlistIDFromInput(ctx context.Context, input string) (id string, ok bool, err error) {
+	r, err := spotifyuri.Resolve(ctx, shortlinkHTTPClient, input)
+	if errors.Is(err, spotifyuri.ErrUnrecognized) {
+		return "", false, nil
 	}
+	if err != nil {
+		return "", false, err
+	}
+	if r.Type != spotifyuri.Playlist && r.Type != "" {
+		return "", false, fmt.Errorf("%q is a Spotify %s, not a playlist", input, r.Type)
+	}
+	return r.ID, true, nil
+}
 
-	// Check if it looks like a Spotify ID (22 alphanumeric characters)
-	// If so, try it directly first
-	if len(input) == 22 && !strings.Contains(input, " ") {
-		return input, nil
+// ResolvePlaylistID resolves a playlist input (URL, name, or ID) to a playlist ID.
+// It first checks if it's a URI, link, or ID, then searches the user's
+// playlists by name, then Spotify's own playlists (Discover Weekly and the
+// like), and finally assumes it's an ID if no match is found.
+func ResolvePlaylistID(ctx context.Context, client *spotifyLib.Client, input string) (string, error) {
+	if id, ok, err := playlistIDFromInput(ctx, input); err != nil || ok {
+		return id, err
 	}
 
 	// Search user's playlists by name
-	fmt.Printf("Searching for playlist: \"%s\"...\n", input)
+	Sayf("Searching for playlist: \"%s\"...", input)
 
 	limit := 50
 	offset := 0
@@ -66,7 +76,7 @@ func ResolvePlaylistID(ctx context.Context, client *spotifyLib.Client, input str
 		for _, playlist := range playlists.Playlists {
 			// Check for exact name match (case-insensitive)
 			if strings.EqualFold(playlist.Name, input) {
-				fmt.Printf("Found playlist: \"%s\" (ID: %s)\n", playlist.Name, playlist.ID)
+				Sayf("Found playlist: \"%s\" (ID: %s)", playlist.Name, playlist.ID)
 				return string(playlist.ID), nil
 			}
 			// Also check if ID matches
@@ -82,22 +92,26 @@ func ResolvePlaylistID(ctx context.Context, client *spotifyLib.Client, input str
 		offset += limit
 	}
 
+	// Spotify's own playlists aren't in the library unless followed
+	found, err := findSpotifyPlaylist(ctx, client, input)
+	if err != nil {
+		Warnf("%v", err)
+	}
+	if found != nil {
+		Sayf("Found Spotify playlist: \"%s\" (ID: %s)", found.Name, found.ID)
+		return string(found.ID), nil
+	}
+
 	// If no match found by name, assume it's an ID
-	fmt.Printf("No playlist found with name \"%s\", trying as ID...\n", input)
+	Sayf("No playlist found with name \"%s\", trying as ID...", input)
 	return input, nil
 }
 
 // ResolvePlaylistIDQuiet resolves a playlist input without printing to stdout.
 // Used by the API server to avoid cluttering logs.
 func ResolvePlaylistIDQuiet(ctx context.Context, client Client, input string) (string, error) {
-	// First, check if it's a URL and extract the ID
-	if strings.Contains(input, "spotify.com/playlist/") {
-		return ExtractPlaylistID(input), nil
-	}
-
-	// Check if it looks like a Spotify ID (22 alphanumeric characters)
-	if len(input) == 22 && !strings.Contains(input, " ") {
-		return input, nil
+	if id, ok, err := playlistIDFromInput(ctx, input); err != nil || ok {
+		return id, err
 	}
 
 	// Search user's playlists by name
@@ -125,6 +139,15 @@ func ResolvePlaylistIDQuiet(ctx context.Context, client Client, input string) (s
 		offset += limit
 	}
 
+	// Spotify's own playlists aren't in the library unless followed
+	found, err := findSpotifyPlaylist(ctx, client, input)
+	if err != nil {
+		warnf(ctx, "%v", err)
+	}
+	if found != nil {
+		return string(found.ID), nil
+	}
+
 	// Assume it's an ID
 	return input, nil
 }
@@ -134,8 +157,9 @@ func ResolvePlaylistIDQuiet(ctx context.Context, client Client, input string) (s
 // the full playlist catalog to clients (the iOS Shortcut, etc.) without
 // each client having to handle pagination itself.
 func ListPlaylists(ctx context.Context) ([]spotifyLib.SimplePlaylist, error) {
-	if spotifyClient == nil {
-		return nil, fmt.Errorf("Spotify not authenticated. Visit /auth to authenticate")
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	const pageSize = 50
@@ -143,7 +167,7 @@ func ListPlaylists(ctx context.Context) ([]spotifyLib.SimplePlaylist, error) {
 	offset := 0
 
 	for {
-		page, err := spotifyClient.CurrentUsersPlaylists(ctx, spotifyLib.Limit(pageSize), spotifyLib.Offset(offset))
+		page, err := client.CurrentUsersPlaylists(ctx, spotifyLib.Limit(pageSize), spotifyLib.Offset(offset))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get playlists: %w", err)
 		}
@@ -157,17 +181,103 @@ func ListPlaylists(ctx context.Context) ([]spotifyLib.SimplePlaylist, error) {
 	return all, nil
 }
 
+// FollowPlaylist adds the playlist at `input` (a URI, link, shortlink, or
+// ID — it isn't in the library yet, so names can't work) to the user's
+// library, after which it resolves by name. `public` shows it on the
+// user's profile.
+func FollowPlaylist(ctx context.Context, input string, public bool) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id, ok, err := playlistIDFromInput(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%q isn't a playlist link, URI, or ID", input)
+	}
+
+	if err := client.FollowPlaylist(ctx, spotifyLib.ID(id), public); err != nil {
+		return "", fmt.Errorf("failed to follow playlist: %w", withScopeHint(err))
+	}
+	return fmt.Sprintf("Followed %s", playlistLabel(ctx, client, id)), nil
+}
+
+// UnfollowPlaylist removes the playlist at `input` (name, URI, link, or
+// ID) from the user's library.
+func UnfollowPlaylist(ctx context.Context, input string) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := ResolvePlaylistIDQuiet(ctx, client, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve playlist: %w", err)
+	}
+	label := playlistLabel(ctx, client, id)
+
+	if err := client.UnfollowPlaylist(ctx, spotifyLib.ID(id)); err != nil {
+		return "", fmt.Errorf("failed to unfollow playlist: %w", withScopeHint(err))
+	}
+	return fmt.Sprintf("Unfollowed %s", label), nil
+}
+
+// CreatePlaylist creates an empty playlist called `name` in the user's
+// library. Spotify doesn't allow a collaborative playlist to be public.
+func CreatePlaylist(ctx context.Context, name, description string, public, collaborative bool) (*spotifyLib.FullPlaylist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, withCode(CodeBadRequest, fmt.Errorf("a playlist name is required"))
+	}
+	if public && collaborative {
+		return nil, withCode(CodeBadRequest, fmt.Errorf("a collaborative playlist can't be public"))
+	}
+
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	playlist, err := client.CreatePlaylistForUser(ctx, user.ID, name, description, public, collaborative)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create playlist: %w", withScopeHint(err))
+	}
+	return playlist, nil
+}
+
+// playlistLabel names a playlist for messages: its quoted name if Spotify
+// returns it, otherwise the ID.
+func playlistLabel(ctx context.Context, client Client, id string) string {
+	if pl, err := client.GetPlaylist(ctx, spotifyLib.ID(id)); err == nil {
+		return fmt.Sprintf("%q", pl.Name)
+	}
+	return id
+}
+
+// withScopeHint explains a 401/403 from a library change: tokens issued
+// before the playlist-modify scopes were added can't make them.
+func withScopeHint(err error) error {
+	var spErr spotifyLib.Error
+	if errors.As(err, &spErr) && (spErr.Status == http.StatusForbidden || spErr.Status == http.StatusUnauthorized) {
+		return fmt.Errorf("%w (if this token predates follow support, re-authenticate at /auth to grant playlist-modify access)", err)
+	}
+	return err
+}
+
 // PrintPlaylistsTable displays the user's Spotify playlists in a formatted table.
 func PrintPlaylistsTable(playlists []spotifyLib.SimplePlaylist) {
 	green := color.New(color.FgGreen, color.Bold)
-	cyan := color.New(color.FgCyan)
 
-	fmt.Println()
-	cyan.Println("🎵 Your Spotify Playlists")
-	fmt.Println()
+	printHeading("🎵", "Your Spotify Playlists")
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	t := newTable()
 	t.AppendHeader(table.Row{"#", "Name", "Tracks", "Owner", "Playlist ID"})
 
 	for i, playlist := range playlists {
@@ -180,7 +290,6 @@ func PrintPlaylistsTable(playlists []spotifyLib.SimplePlaylist) {
 		})
 	}
 
-	t.SetStyle(table.StyleRounded)
 	t.Render()
 
 	fmt.Println()
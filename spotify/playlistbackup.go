@@ -0,0 +1,265 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Backing up and restoring every playlist. `-backup-playlists
+// <dir>` writes one JSON file per playlist in the library (the export
+// format plus whether it was followed rather than owned), and
+// `-restore-playlists <dir>` recreates the owned ones and re-follows the
+// rest, on the same account or, with -account, another one.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// PlaylistBackup is one playlist's backup file. Followed playlists belong
+// to someone else, so restoring follows them again instead of copying.
+type PlaylistBackup struct {
+	PlaylistExport
+	Followed bool `json:"followed,omitempty"`
+}
+
+// BackupPlaylists writes every playlist in the library to `dir`, creating
+// it if needed, as <name>_<id>.json, and logs each one to `out`. A
+// playlist that fails is logged and skipped, so one bad playlist doesn't
+// cost the rest of the backup; the summary counts the failures.
+func BackupPlaylists(ctx context.Context, dir string, out io.Writer) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	playlists, err := ListPlaylists(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	saved, failed := 0, 0
+	for _, p := range playlists {
+		export, err := ExportPlaylist(ctx, string(p.ID))
+		if err != nil {
+			fmt.Fprintf(out, "! %q: %v\n", p.Name, err)
+			failed++
+			continue
+		}
+		backup := PlaylistBackup{PlaylistExport: *export, Followed: p.Owner.ID != user.ID}
+
+		data, err := json.MarshalIndent(backup, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(dir, backupFileName(p.Name, string(p.ID)))
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Fprintf(out, "Backed up %q (%d track(s)) to %s\n", p.Name, len(export.Tracks), path)
+		saved++
+	}
+
+	msg := fmt.Sprintf("Backed up %d playlist(s) to %s", saved, dir)
+	if failed > 0 {
+		msg += fmt.Sprintf("; %d failed", failed)
+	}
+	return msg, nil
+}
+
+// backupFileName is a playlist's backup file name: its name reduced to
+// lowercase letters, digits, and dashes, then its ID, which keeps
+// playlists with the same name apart.
+func backupFileName(name, id string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return id + ".json"
+	}
+	return slug + "_" + id + ".json"
+}
+
+// RestorePlaylists restores every backup file in `dir` to the account in
+// ctx, logging each to `out`. Owned playlists are created again with
+// their details and tracks; followed ones are followed again. A playlist
+// whose name (or, if followed, ID) is already in the library is skipped,
+// so a restore can be rerun after a partial failure. Local files can't be
+// added back and are counted.
+func RestorePlaylists(ctx context.Context, dir string, out io.Writer) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", withCode(CodeNotFound, fmt.Errorf("no playlist backups in %s", dir))
+	}
+	sort.Strings(files)
+
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	library, err := ListPlaylists(ctx)
+	if err != nil {
+		return "", err
+	}
+	haveName, haveID := map[string]bool{}, map[string]bool{}
+	for _, p := range library {
+		haveName[strings.ToLower(p.Name)], haveID[string(p.ID)] = true, true
+	}
+
+	created, followed, skipped, failed := 0, 0, 0, 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		var backup PlaylistBackup
+		if err := json.Unmarshal(data, &backup); err != nil || backup.Name == "" {
+			fmt.Fprintf(out, "! %s: not a playlist backup\n", path)
+			failed++
+			continue
+		}
+
+		if backup.Followed {
+			if haveID[backup.ID] {
+				fmt.Fprintf(out, "Skipped %q: already followed\n", backup.Name)
+				skipped++
+				continue
+			}
+			if err := client.FollowPlaylist(ctx, spotifyLib.ID(backup.ID), backup.Public); err != nil {
+				fmt.Fprintf(out, "! %q: failed to follow: %v\n", backup.Name, withScopeHint(err))
+				failed++
+				continue
+			}
+			fmt.Fprintf(out, "Followed %q\n", backup.Name)
+			followed++
+			continue
+		}
+
+		if haveName[strings.ToLower(backup.Name)] {
+			fmt.Fprintf(out, "Skipped %q: already in the library\n", backup.Name)
+			skipped++
+			continue
+		}
+		n, lost, err := restorePlaylist(ctx, client, backup)
+		if err != nil {
+			fmt.Fprintf(out, "! %q: %v\n", backup.Name, err)
+			failed++
+			continue
+		}
+		line := fmt.Sprintf("Created %q with %d track(s)", backup.Name, n)
+		if lost > 0 {
+			line += fmt.Sprintf("; %d local file(s) couldn't be added", lost)
+		}
+		fmt.Fprintln(out, line)
+		haveName[strings.ToLower(backup.Name)] = true
+		created++
+	}
+
+	msg := fmt.Sprintf("Restored %d playlist(s) and followed %d; skipped %d already in the library", created, followed, skipped)
+	if failed > 0 {
+		msg += fmt.Sprintf("; %d failed", failed)
+	}
+	return msg, nil
+}
+
+// restorePlaylist creates `backup` as a new playlist and adds its tracks
+// and episodes, returning how many went in and how many couldn't.
+func restorePlaylist(ctx context.Context, client Client, backup PlaylistBackup) (added, lost int, err error) {
+	var items []spotifyuri.Resource
+	episodes := false
+	for _, t := range backup.Tracks {
+		r, err := spotifyuri.Parse(t.URI)
+		if err != nil || (r.Type != spotifyuri.Track && r.Type != spotifyuri.Episode) {
+			lost++
+			continue
+		}
+		items = append(items, r)
+		episodes = episodes || r.Type == spotifyuri.Episode
+	}
+
+	pl, err := CreatePlaylist(ctx, backup.Name, backup.Description, backup.Public && !backup.Collaborative, backup.Collaborative)
+	if err != nil {
+		return 0, 0, err
+	}
+	if episodes {
+		err = addPlaylistItems(ctx, client, string(pl.ID), items)
+	} else {
+		ids := make([]spotifyLib.ID, len(items))
+		for i, r := range items {
+			ids[i] = spotifyLib.ID(r.ID)
+		}
+		err = addPlaylistTracks(ctx, client, string(pl.ID), ids)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(items), lost, nil
+}
+
+// addPlaylistItems appends tracks and episodes to a playlist in batches.
+// The upstream library only builds spotify:track: URIs, so the URIs go
+// straight to the same Web API endpoint, like queueEpisode.
+func addPlaylistItems(ctx context.Context, client Client, playlistID string, items []spotifyuri.Resource) error {
+	tok, err := client.Token()
+	if err != nil {
+		return fmt.Errorf("get access token: %w", err)
+	}
+	for start := 0; start < len(items); start += playlistEditBatch {
+		var uris []string
+		for _, r := range items[start:min(start+playlistEditBatch, len(items))] {
+			uris = append(uris, r.URI())
+		}
+		body, err := json.Marshal(map[string][]string{"uris": uris})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, spotifyAPIBaseURL+"playlists/"+playlistID+"/tracks", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to add to playlist: %w", err)
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to add to playlist: spotify returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		}
+	}
+	return nil
+}
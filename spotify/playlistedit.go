@@ -0,0 +1,234 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Editing playlists. AddToPlaylist adds tracks, given as
+// URIs, links, or IDs, to a playlist the user owns or collaborates on.
+// "current" stands for the track playing now, which makes "keep this
+// song" a one-tap shortcut. RemoveFromPlaylist takes tracks out again, by
+// track or by position, and can show what it would remove first.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// CurrentTrack stands for the track playing now wherever a playlist edit
+// takes a track.
+const CurrentTrack = "current"
+
+// playlistEditBatch is the most tracks Spotify takes in one playlist edit.
+const playlistEditBatch = 100
+
+// AddToPlaylist appends `tracks` to the playlist `playlist` names (a name,
+// URI, link, or ID), in the order given. Tracks are URIs, links, shortlinks,
+// or IDs, or CurrentTrack; blank ones are skipped. Episodes can't be
+// added.
+func AddToPlaylist(ctx context.Context, playlist string, tracks ...string) (string, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ids := make([]spotifyLib.ID, 0, len(tracks))
+	var current *spotifyLib.FullTrack
+	for _, input := range tracks {
+		input = strings.TrimSpace(input)
+		switch {
+		case input == "":
+			continue
+		case strings.EqualFold(input, CurrentTrack):
+			if current, err = currentTrack(ctx, client); err != nil {
+				return "", err
+			}
+			ids = append(ids, current.ID)
+			continue
+		}
+
+		id, ok, err := trackIDFromInput(ctx, input)
+		if err != nil {
+			return "", withCode(CodeBadRequest, err)
+		}
+		if !ok {
+			return "", withCode(CodeBadRequest, fmt.Errorf("unrecognized track %q: use a URI, link, or ID", input))
+		}
+		ids = append(ids, spotifyLib.ID(id))
+	}
+	if len(ids) == 0 {
+		return "", withCode(CodeBadRequest, fmt.Errorf("no tracks to add"))
+	}
+
+	playlistID, err := ResolvePlaylistIDQuiet(ctx, client, playlist)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve playlist: %w", err)
+	}
+	if err := addPlaylistTracks(ctx, client, playlistID, ids); err != nil {
+		return "", err
+	}
+
+	label := playlistLabel(ctx, client, playlistID)
+	if len(ids) == 1 && current != nil {
+		return fmt.Sprintf("Added %q by %s to %s", current.Name, trackArtists(current.SimpleTrack), label), nil
+	}
+	return fmt.Sprintf("Added %d track(s) to %s", len(ids), label), nil
+}
+
+// addPlaylistTracks appends `ids` to a playlist in batches Spotify accepts.
+func addPlaylistTracks(ctx context.Context, client Client, playlistID string, ids []spotifyLib.ID) error {
+	for start := 0; start < len(ids); start += playlistEditBatch {
+		batch := ids[start:min(start+playlistEditBatch, len(ids))]
+		if _, err := client.AddTracksToPlaylist(ctx, spotifyLib.ID(playlistID), batch...); err != nil {
+			return fmt.Errorf("failed to add to playlist: %w", withScopeHint(err))
+		}
+	}
+	return nil
+}
+
+// currentTrack returns the track playing now. It fails when nothing is
+// playing or an episode is.
+func currentTrack(ctx context.Context, client Client) (*spotifyLib.FullTrack, error) {
+	playing, err := client.PlayerCurrentlyPlaying(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the current track: %w", err)
+	}
+	if playing == nil || playing.Item == nil || playing.Item.ID == "" {
+		return nil, withCode(CodeNotFound, fmt.Errorf("nothing is playing"))
+	}
+	return playing.Item, nil
+}
+
+// RemoveFromPlaylist removes entries from the playlist `playlist` names (a
+// name, URI, link, or ID). Each of `tracks` is a track URI, link, or ID,
+// which removes every occurrence of it, or a 1-based position, which
+// removes just that entry; CurrentTrack removes the track playing now. With
+// dryRun nothing changes and the entries that would go are returned, with
+// positions as the playlist was before the removal. A
+// track that isn't in the playlist is an error, so a typo doesn't pass for
+// success.
+func RemoveFromPlaylist(ctx context.Context, playlist string, dryRun bool, tracks ...string) (string, []PlaylistTrack, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	playlistID, err := ResolvePlaylistIDQuiet(ctx, client, playlist)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve playlist: %w", err)
+	}
+	pl, err := client.GetPlaylist(ctx, spotifyLib.ID(playlistID))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get playlist: %w", err)
+	}
+	items, err := fetchPlaylistItems(ctx, client, playlistID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	picked := map[int]bool{}
+	for _, input := range tracks {
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if pos, err := strconv.Atoi(input); err == nil {
+			if pos < 1 || pos > len(items) {
+				return "", nil, withCode(CodeBadRequest, fmt.Errorf("position %d is out of range: the playlist has %d item(s)", pos, len(items)))
+			}
+			picked[pos-1] = true
+			continue
+		}
+
+		var uri spotifyLib.URI
+		if strings.EqualFold(input, CurrentTrack) {
+			current, err := currentTrack(ctx, client)
+			if err != nil {
+				return "", nil, err
+			}
+			uri = current.URI
+		} else {
+			id, ok, err := trackIDFromInput(ctx, input)
+			if err != nil {
+				return "", nil, withCode(CodeBadRequest, err)
+			}
+			if !ok {
+				return "", nil, withCode(CodeBadRequest, fmt.Errorf("unrecognized track %q: use a URI, link, ID, or position", input))
+			}
+			uri = spotifyLib.URI("spotify:track:" + id)
+		}
+		found := false
+		for i, item := range items {
+			if itemURI(item) == uri {
+				picked[i], found = true, true
+			}
+		}
+		if !found {
+			return "", nil, withCode(CodeNotFound, fmt.Errorf("%s isn't in %q", uri, pl.Name))
+		}
+	}
+	if len(picked) == 0 {
+		return "", nil, withCode(CodeBadRequest, fmt.Errorf("no tracks to remove"))
+	}
+
+	removed := make([]PlaylistTrack, 0, len(picked))
+	for i, item := range items {
+		if picked[i] {
+			removed = append(removed, playlistTrack(i, item))
+		}
+	}
+	for _, r := range removed {
+		if r.URI == "" {
+			return "", nil, withCode(CodeBadRequest, fmt.Errorf("position %d is a local file, which Spotify can't remove through the API", r.Position))
+		}
+	}
+
+	if dryRun {
+		return fmt.Sprintf("Would remove %d item(s) from %q", len(removed), pl.Name), removed, nil
+	}
+	if err := removePlaylistEntries(ctx, client, playlistID, pl.SnapshotID, removed); err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("Removed %d item(s) from %q", len(removed), pl.Name), removed, nil
+}
+
+// removePlaylistEntries removes `entries` by URI and position, checked
+// against the playlist's snapshot, in batches Spotify accepts.
+func removePlaylistEntries(ctx context.Context, client Client, playlistID, snapshot string, entries []PlaylistTrack) error {
+	var batch []spotifyLib.TrackToRemove
+	byURI := map[string]int{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		next, err := client.RemoveTracksFromPlaylistOpt(ctx, spotifyLib.ID(playlistID), batch, snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to remove from playlist: %w", withScopeHint(err))
+		}
+		snapshot, batch, byURI = next, nil, map[string]int{}
+		return nil
+	}
+
+	// Later entries first, so positions in a later batch still hold after
+	// an earlier batch removed entries before them.
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if j, ok := byURI[e.URI]; ok {
+			batch[j].Positions = append(batch[j].Positions, e.Position-1)
+			continue
+		}
+		if len(batch) == playlistEditBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		byURI[e.URI] = len(batch)
+		batch = append(batch, spotifyLib.TrackToRemove{URI: e.URI, Positions: []int{e.Position - 1}})
+	}
+	return flush()
+}
@@ -0,0 +1,124 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Exporting playlists. `-export-playlist` pages through every
+// item of a playlist and writes title, artists, album, URI, and added date
+// as JSON or CSV, for offline backups of playlists curated over years.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// Export formats.
+const (
+	ExportJSON = "json"
+	ExportCSV  = "csv"
+)
+
+// ExportedTrack is one playlist item in an export. Episodes carry their
+// show as Album; local files have no URI.
+type ExportedTrack struct {
+	Title   string `json:"title"`
+	Artists string `json:"artists"`
+	Album   string `json:"album"`
+	URI     string `json:"uri"`
+	AddedAt string `json:"added_at"`
+}
+
+// PlaylistExport is a playlist's details and items as of ExportedAt.
+type PlaylistExport struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description,omitempty"`
+	Owner         string          `json:"owner"`
+	OwnerID       string          `json:"owner_id"`
+	Public        bool            `json:"public"`
+	Collaborative bool            `json:"collaborative"`
+	ExportedAt    time.Time       `json:"exported_at"`
+	Tracks        []ExportedTrack `json:"tracks"`
+}
+
+// ExportPlaylist fetches every item of the playlist `playlist` names (a
+// name, URI, link, or ID), in playlist order. Items Spotify no longer has
+// are kept, with whatever it still returns for them, so the backup matches
+// the playlist's length.
+func ExportPlaylist(ctx context.Context, playlist string) (*PlaylistExport, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	playlistID, err := ResolvePlaylistIDQuiet(ctx, client, playlist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve playlist: %w", err)
+	}
+	pl, err := client.GetPlaylist(ctx, spotifyLib.ID(playlistID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist: %w", err)
+	}
+	items, err := fetchPlaylistItems(ctx, client, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &PlaylistExport{
+		ID:            playlistID,
+		Name:          pl.Name,
+		Description:   pl.Description,
+		Owner:         pl.Owner.DisplayName,
+		OwnerID:       pl.Owner.ID,
+		Public:        pl.IsPublic,
+		Collaborative: pl.Collaborative,
+		ExportedAt:    time.Now().UTC(),
+		Tracks:        make([]ExportedTrack, 0, len(items)),
+	}
+	for _, item := range items {
+		export.Tracks = append(export.Tracks, exportedTrack(item))
+	}
+	return export, nil
+}
+
+// exportedTrack describes playlist item `item` for an export.
+func exportedTrack(item spotifyLib.PlaylistItem) ExportedTrack {
+	t := ExportedTrack{URI: string(itemURI(item)), AddedAt: item.AddedAt}
+	switch {
+	case item.Track.Track != nil:
+		track := item.Track.Track
+		t.Title, t.Artists, t.Album = track.Name, trackArtists(track.SimpleTrack), track.Album.Name
+	case item.Track.Episode != nil:
+		t.Title, t.Album = item.Track.Episode.Name, item.Track.Episode.Show.Name
+	}
+	return t
+}
+
+// WritePlaylistExport writes `export` to `w` as ExportJSON (the whole
+// export, indented) or ExportCSV (a header row, then one row per track).
+func WritePlaylistExport(w io.Writer, export *PlaylistExport, format string) error {
+	switch strings.ToLower(format) {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(export)
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"title", "artists", "album", "uri", "added_at"})
+		for _, t := range export.Tracks {
+			cw.Write([]string{t.Title, t.Artists, t.Album, t.URI, t.AddedAt})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("export format must be %s or %s, got %q", ExportJSON, ExportCSV, format)
+}
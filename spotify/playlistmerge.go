@@ -0,0 +1,136 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Merging playlists. `-merge-playlists "A,B,C" -into "Mega
+// Mix"` appends the tracks of several playlists to one, creating it if
+// the library has no playlist by that name, optionally skipping tracks it
+// already has.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// MergePlaylists appends the tracks of each of `sources` (names, URIs,
+// links, or IDs), in order, to the playlist `into` names. A name that
+// matches no playlist in the library creates a private playlist by that
+// name. With dedupe, a track already in the target or seen earlier in the
+// merge is added once only. Episodes and local files can't be added and
+// are skipped.
+func MergePlaylists(ctx context.Context, sources []string, into string, dedupe bool) (string, error) {
+	into = strings.TrimSpace(into)
+	if into == "" {
+		return "", withCode(CodeBadRequest, fmt.Errorf("a target playlist is required"))
+	}
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var ids []spotifyLib.ID
+	merged, skipped := 0, 0
+	for _, source := range sources {
+		if source = strings.TrimSpace(source); source == "" {
+			continue
+		}
+		sourceID, err := ResolvePlaylistIDQuiet(ctx, client, source)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve playlist %q: %w", source, err)
+		}
+		items, err := fetchPlaylistItems(ctx, client, sourceID)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range items {
+			if item.IsLocal || item.Track.Track == nil || item.Track.Track.ID == "" {
+				skipped++
+				continue
+			}
+			ids = append(ids, item.Track.Track.ID)
+		}
+		merged++
+	}
+	if merged == 0 {
+		return "", withCode(CodeBadRequest, fmt.Errorf("no playlists to merge"))
+	}
+
+	targetID, created, err := mergeTarget(ctx, client, into)
+	if err != nil {
+		return "", err
+	}
+
+	duplicates := 0
+	if dedupe {
+		seen := map[spotifyLib.ID]bool{}
+		if !created {
+			existing, err := fetchPlaylistItems(ctx, client, targetID)
+			if err != nil {
+				return "", err
+			}
+			for _, item := range existing {
+				if item.Track.Track != nil {
+					seen[item.Track.Track.ID] = true
+				}
+			}
+		}
+		unique := ids[:0]
+		for _, id := range ids {
+			if seen[id] {
+				duplicates++
+				continue
+			}
+			seen[id] = true
+			unique = append(unique, id)
+		}
+		ids = unique
+	}
+
+	if err := addPlaylistTracks(ctx, client, targetID, ids); err != nil {
+		return "", err
+	}
+
+	msg := fmt.Sprintf("Added %d track(s) from %d playlist(s) to %s", len(ids), merged, playlistLabel(ctx, client, targetID))
+	if created {
+		msg = fmt.Sprintf("Created %q with %d track(s) from %d playlist(s)", into, len(ids), merged)
+	}
+	if duplicates > 0 {
+		msg += fmt.Sprintf("; skipped %d duplicate(s)", duplicates)
+	}
+	if skipped > 0 {
+		msg += fmt.Sprintf("; skipped %d episode(s) or local file(s)", skipped)
+	}
+	return msg, nil
+}
+
+// mergeTarget finds the playlist `into` names: a URI, link, or ID, or the
+// name of a playlist in the library. A name that matches nothing creates
+// the playlist, and created reports that it did.
+func mergeTarget(ctx context.Context, client Client, into string) (id string, created bool, err error) {
+	if id, ok, err := playlistIDFromInput(ctx, into); err != nil || ok {
+		return id, false, err
+	}
+
+	playlists, err := ListPlaylists(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	for _, p := range playlists {
+		if strings.EqualFold(p.Name, into) {
+			return string(p.ID), false, nil
+		}
+	}
+
+	pl, err := CreatePlaylist(ctx, into, "", false, false)
+	if err != nil {
+		return "", false, err
+	}
+	return string(pl.ID), true, nil
+}
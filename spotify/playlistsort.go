@@ -0,0 +1,182 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Sorting playlists. Spotify's clients sort a playlist only
+// for display; `-sort-playlist` reorders the playlist itself by artist,
+// album, added date, title, or duration, moving runs of items that are
+// already in order together to keep the number of calls down.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// PlaylistSorts are the fields SortPlaylist orders by.
+var PlaylistSorts = []string{"artist", "album", "added", "title", "duration"}
+
+// SortPlaylist reorders the playlist `playlist` names (a name, URI, link,
+// or ID) by `by`, one of PlaylistSorts. Artist sorts by artist, then album
+// and track number; album by album and track number; added oldest first;
+// duration shortest first. Items that tie keep their order. Each move is
+// made against the snapshot the last one returned, so an edit made
+// elsewhere meanwhile fails the sort rather than scrambling the playlist.
+func SortPlaylist(ctx context.Context, playlist, by string) (string, error) {
+	by = strings.ToLower(strings.TrimSpace(by))
+	if !containsFold(PlaylistSorts, by) {
+		return "", withCode(CodeBadRequest, fmt.Errorf("sort must be one of %s, got %q", strings.Join(PlaylistSorts, ", "), by))
+	}
+
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	playlistID, err := ResolvePlaylistIDQuiet(ctx, client, playlist)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve playlist: %w", err)
+	}
+	pl, err := client.GetPlaylist(ctx, spotifyLib.ID(playlistID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get playlist: %w", err)
+	}
+	items, err := fetchPlaylistItems(ctx, client, playlistID)
+	if err != nil {
+		return "", err
+	}
+
+	want := make([]int, len(items))
+	for i := range want {
+		want[i] = i
+	}
+	less := playlistLess(by)
+	sort.SliceStable(want, func(a, b int) bool { return less(items[want[a]], items[want[b]]) })
+
+	snapshot := pl.SnapshotID
+	moves := playlistMoves(want)
+	for _, m := range moves {
+		m.SnapshotID = snapshot
+		if snapshot, err = client.ReorderPlaylistTracks(ctx, spotifyLib.ID(playlistID), m); err != nil {
+			return "", fmt.Errorf("failed to reorder playlist: %w", withScopeHint(err))
+		}
+	}
+
+	if len(moves) == 0 {
+		return fmt.Sprintf("%q is already sorted by %s", pl.Name, by), nil
+	}
+	return fmt.Sprintf("Sorted %q by %s (%d item(s), %d move(s))", pl.Name, by, len(items), len(moves)), nil
+}
+
+// playlistMoves lists the reorders that turn the playlist into `want`,
+// which holds the current positions in their new order. Position by
+// position it brings the item that belongs there forward, along with the
+// items already following it in the right order.
+func playlistMoves(want []int) []spotifyLib.PlaylistReorderOptions {
+	cur := make([]int, len(want))
+	for i := range cur {
+		cur[i] = i
+	}
+
+	var moves []spotifyLib.PlaylistReorderOptions
+	for i := 0; i < len(want); i++ {
+		j := i
+		for cur[j] != want[i] {
+			j++
+		}
+		if j == i {
+			continue
+		}
+		run := 1
+		for j+run < len(cur) && i+run < len(want) && cur[j+run] == want[i+run] {
+			run++
+		}
+		moves = append(moves, spotifyLib.PlaylistReorderOptions{
+			RangeStart:   spotifyLib.Numeric(j),
+			RangeLength:  spotifyLib.Numeric(run),
+			InsertBefore: spotifyLib.Numeric(i),
+		})
+
+		moved := append([]int{}, cur[j:j+run]...)
+		copy(cur[i+run:j+run], cur[i:j])
+		copy(cur[i:], moved)
+		i += run - 1
+	}
+	return moves
+}
+
+// playlistLess returns the order for sort field `by`.
+func playlistLess(by string) func(a, b spotifyLib.PlaylistItem) bool {
+	switch by {
+	case "artist":
+		return func(a, b spotifyLib.PlaylistItem) bool {
+			if x, y := sortArtist(a), sortArtist(b); x != y {
+				return x < y
+			}
+			return albumLess(a, b)
+		}
+	case "album":
+		return albumLess
+	case "added":
+		return func(a, b spotifyLib.PlaylistItem) bool { return a.AddedAt < b.AddedAt }
+	case "duration":
+		return func(a, b spotifyLib.PlaylistItem) bool { return itemDuration(a) < itemDuration(b) }
+	}
+	return func(a, b spotifyLib.PlaylistItem) bool { return sortTitle(a) < sortTitle(b) }
+}
+
+// albumLess orders items by album, then disc and track number. Episodes
+// sort by show.
+func albumLess(a, b spotifyLib.PlaylistItem) bool {
+	if x, y := sortAlbum(a), sortAlbum(b); x != y {
+		return x < y
+	}
+	ta, tb := a.Track.Track, b.Track.Track
+	if ta == nil || tb == nil {
+		return false
+	}
+	if ta.DiscNumber != tb.DiscNumber {
+		return ta.DiscNumber < tb.DiscNumber
+	}
+	return ta.TrackNumber < tb.TrackNumber
+}
+
+// sortTitle is an item's name, folded for sorting.
+func sortTitle(item spotifyLib.PlaylistItem) string {
+	switch {
+	case item.Track.Track != nil:
+		return strings.ToLower(item.Track.Track.Name)
+	case item.Track.Episode != nil:
+		return strings.ToLower(item.Track.Episode.Name)
+	}
+	return ""
+}
+
+// sortArtist is an item's artists, or an episode's show publisher, folded
+// for sorting.
+func sortArtist(item spotifyLib.PlaylistItem) string {
+	switch {
+	case item.Track.Track != nil:
+		return strings.ToLower(trackArtists(item.Track.Track.SimpleTrack))
+	case item.Track.Episode != nil:
+		return strings.ToLower(item.Track.Episode.Show.Publisher)
+	}
+	return ""
+}
+
+// sortAlbum is an item's album, or an episode's show, folded for sorting.
+func sortAlbum(item spotifyLib.PlaylistItem) string {
+	switch {
+	case item.Track.Track != nil:
+		return strings.ToLower(item.Track.Track.Album.Name)
+	case item.Track.Episode != nil:
+		return strings.ToLower(item.Track.Episode.Show.Name)
+	}
+	return ""
+}
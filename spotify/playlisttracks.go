@@ -0,0 +1,116 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Listing a playlist's tracks. `-tracks <playlist>` and GET
+// /api/v1/playlists/{id}/tracks page through the items, a window at a time
+// with limit and offset, numbered by their position in the playlist.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// playlistTracksPage is the most items Spotify returns per request.
+const playlistTracksPage = 100
+
+// PlaylistTrack is one playlist entry. Position is 1-based. Episodes carry
+// their show as Album; local files have no URI.
+type PlaylistTrack struct {
+	Position   int    `json:"position"`
+	URI        string `json:"uri"`
+	Name       string `json:"name"`
+	Artists    string `json:"artists,omitempty"`
+	Album      string `json:"album,omitempty"`
+	DurationMS int    `json:"duration_ms,omitempty"`
+}
+
+// playlistTrack describes playlist item `item` at 0-based index `i`.
+func playlistTrack(i int, item spotifyLib.PlaylistItem) PlaylistTrack {
+	t := PlaylistTrack{Position: i + 1, URI: string(itemURI(item)), DurationMS: int(itemDuration(item).Milliseconds())}
+	switch {
+	case item.Track.Track != nil:
+		track := item.Track.Track
+		t.Name, t.Artists, t.Album = track.Name, trackArtists(track.SimpleTrack), track.Album.Name
+	case item.Track.Episode != nil:
+		t.Name, t.Album = item.Track.Episode.Name, item.Track.Episode.Show.Name
+	}
+	return t
+}
+
+// ListPlaylistTracks returns up to `limit` tracks of the playlist
+// `playlist` names (a name, URI, link, or ID) starting at 0-based
+// `offset`, with the playlist's name and total item count. A limit of 0
+// lists everything from the offset on.
+func ListPlaylistTracks(ctx context.Context, playlist string, limit, offset int) (string, int, []PlaylistTrack, error) {
+	if limit < 0 || offset < 0 {
+		return "", 0, nil, withCode(CodeBadRequest, fmt.Errorf("limit and offset can't be negative"))
+	}
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	playlistID, err := ResolvePlaylistIDQuiet(ctx, client, playlist)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to resolve playlist: %w", err)
+	}
+	pl, err := client.GetPlaylist(ctx, spotifyLib.ID(playlistID))
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to get playlist: %w", err)
+	}
+
+	var tracks []PlaylistTrack
+	for next := offset; limit == 0 || len(tracks) < limit; {
+		size := playlistTracksPage
+		if limit > 0 {
+			size = min(size, limit-len(tracks))
+		}
+		page, err := client.GetPlaylistItems(ctx, spotifyLib.ID(playlistID), spotifyLib.Limit(size), spotifyLib.Offset(next))
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("failed to get playlist items: %w", err)
+		}
+		items := page.Items[:min(len(page.Items), size)]
+		for i, item := range items {
+			tracks = append(tracks, playlistTrack(next+i, item))
+		}
+		next += len(items)
+		if len(items) < size {
+			break
+		}
+	}
+	return pl.Name, int(pl.Tracks.Total), tracks, nil
+}
+
+// PrintPlaylistTracksTable prints a playlist's tracks in a table.
+func PrintPlaylistTracksTable(name string, total int, tracks []PlaylistTrack) {
+	green := color.New(color.FgGreen, color.Bold)
+
+	printHeading("🎵", name)
+
+	t := newTable()
+	t.AppendHeader(table.Row{"#", "Title", "Artist", "Duration"})
+	for _, track := range tracks {
+		title := color.New(color.Bold).Sprint(track.Name)
+		if track.URI == "" {
+			title += color.HiBlackString(" (local)")
+		}
+		t.AppendRow(table.Row{track.Position, title, track.Artists, formatPosition(track.DurationMS)})
+	}
+	t.Render()
+
+	fmt.Println()
+	if len(tracks) == 0 {
+		green.Printf("No tracks (the playlist has %d)\n", total)
+		return
+	}
+	green.Printf("Tracks %d%s%d of %d\n", tracks[0].Position, symbol("–", "-"), tracks[len(tracks)-1].Position, total)
+}
@@ -0,0 +1,122 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Ordered playback modes. Instead of playing the playlist as a
+// context, we fetch its items (see tracksource.go), put them in our own
+// order, and hand Spotify an explicit URI list: "new music first" sorts by added-at date for
+// playlists used as an inbox, and "least played first" sorts by the local
+// play history to surface forgotten songs in large playlists.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// maxOrderedURIs caps the URI list sent to Spotify by the ordered modes.
+// The play endpoint rejects very large bodies, and a listening session
+// rarely needs more than this.
+const maxOrderedURIs = 500
+
+// fetchPlaylistItems pages through every item in a playlist.
+func fetchPlaylistItems(ctx context.Context, client Client, playlistID string) ([]spotifyLib.PlaylistItem, error) {
+	const pageSize = 100
+	var items []spotifyLib.PlaylistItem
+
+	for offset := 0; ; offset += pageSize {
+		page, err := client.GetPlaylistItems(ctx, spotifyLib.ID(playlistID), spotifyLib.Limit(pageSize), spotifyLib.Offset(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get playlist items: %w", err)
+		}
+		items = append(items, page.Items...)
+		if len(page.Items) < pageSize {
+			return items, nil
+		}
+	}
+}
+
+// NewestFirstURIs returns the playlist's playable items ordered by added-at
+// date, newest first, capped at maxOrderedURIs. Local files and items
+// unavailable in the user's market are skipped; items with the same (or a
+// missing) added-at keep their playlist order.
+func NewestFirstURIs(ctx context.Context, client Client, playlistID string) ([]spotifyLib.URI, error) {
+	tracks, err := gatherTracks(ctx, client, PlaylistSource{ID: playlistID})
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(tracks)
+	return playableURIs(tracks)
+}
+
+// sortNewestFirst orders tracks by added-at date, newest first.
+func sortNewestFirst(tracks []SourceTrack) {
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return tracks[i].AddedAt > tracks[j].AddedAt
+	})
+}
+
+// LeastPlayedURIs returns the playlist's playable items ordered by their
+// decayed play score in `db`, least played first, capped at
+// maxOrderedURIs. Ties — including every never-played track — are
+// broken randomly so the same forgotten songs don't always lead.
+func LeastPlayedURIs(ctx context.Context, client Client, playlistID string, db *HistoryDB) ([]spotifyLib.URI, error) {
+	tracks, err := gatherTracks(ctx, client, PlaylistSource{ID: playlistID})
+	if err != nil {
+		return nil, err
+	}
+	sortLeastPlayed(tracks, db)
+	return playableURIs(tracks)
+}
+
+// sortLeastPlayed orders tracks by their decayed play score in `db`, least
+// played first, breaking ties randomly.
+func sortLeastPlayed(tracks []SourceTrack, db *HistoryDB) {
+	rand.Shuffle(len(tracks), func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] })
+
+	scores := make(map[spotifyLib.URI]float64, len(tracks))
+	for _, t := range tracks {
+		scores[t.URI] = db.Score(string(t.URI))
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return scores[tracks[i].URI] < scores[tracks[j].URI]
+	})
+}
+
+// itemURI returns the track or episode URI of a playlist item, or "" for
+// local files and items unavailable in the user's market.
+func itemURI(item spotifyLib.PlaylistItem) spotifyLib.URI {
+	switch {
+	case item.IsLocal:
+		return ""
+	case item.Track.Track != nil:
+		return item.Track.Track.URI
+	case item.Track.Episode != nil:
+		return item.Track.Episode.URI
+	}
+	return ""
+}
+
+// playableURIs collects the URIs of `tracks` in order, up to
+// maxOrderedURIs.
+func playableURIs(tracks []SourceTrack) ([]spotifyLib.URI, error) {
+	uris := make([]spotifyLib.URI, 0, min(len(tracks), maxOrderedURIs))
+	for _, t := range tracks {
+		if len(uris) == maxOrderedURIs {
+			break
+		}
+		uris = append(uris, t.URI)
+	}
+
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("playlist has no playable tracks")
+	}
+	return uris, nil
+}
@@ -0,0 +1,108 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Play targets. Anywhere a playlist is asked for, a URI or
+// open.spotify.com link of any playable type is taken at its word: an
+// album link plays the album, a show link the show, and so on. This file
+// works out what an input names and the PlayOptions shape that plays it.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// PlayTarget is the Spotify item a play input names. ID and URI are empty
+// for a name, which is looked up among your playlists.
+type PlayTarget struct {
+	Input string          `json:"input"`
+	Type  spotifyuri.Type `json:"type"`
+	ID    string          `json:"id,omitempty"`
+	URI   string          `json:"uri,omitempty"`
+}
+
+// ResolvePlayTarget works out what `input` names. URIs, links, and
+// shortlinks of any playable type (playlist, album, artist, track, show,
+// episode, audiobook, chapter) come back as that type. Bare IDs and
+// names are playlists, as they always have been; users can't be played.
+func ResolvePlayTarget(ctx context.Context, input string) (PlayTarget, error) {
+	target := PlayTarget{Input: input, Type: spotifyuri.Playlist}
+
+	r, err := spotifyuri.Resolve(ctx, shortlinkHTTPClient, input)
+	if errors.Is(err, spotifyuri.ErrUnrecognized) {
+		return target, nil
+	}
+	if err != nil {
+		return target, err
+	}
+	if r.Type == spotifyuri.User {
+		return target, fmt.Errorf("%q is a Spotify user, which can't be played", input)
+	}
+	if r.Type != "" {
+		target.Type = r.Type
+	}
+	target.ID = r.ID
+	target.URI = spotifyuri.Resource{Type: target.Type, ID: r.ID}.URI()
+	return target, nil
+}
+
+// playOptions returns the PlayOptions shape that plays the target on
+// `deviceID`: a playback context for playlists, albums, artists, shows,
+// and audiobooks, and a one-item URI list for tracks and episodes. Names
+// and chapters, which need looking up first, get nil.
+func (t PlayTarget) playOptions(deviceID spotifyLib.ID) *spotifyLib.PlayOptions {
+	if t.URI == "" {
+		return nil
+	}
+	uri := spotifyLib.URI(t.URI)
+	switch t.Type {
+	case spotifyuri.Playlist, spotifyuri.Album, spotifyuri.Artist, spotifyuri.Show, spotifyuri.Audiobook:
+		return &spotifyLib.PlayOptions{DeviceID: &deviceID, PlaybackContext: &uri}
+	case spotifyuri.Track, spotifyuri.Episode:
+		return &spotifyLib.PlayOptions{DeviceID: &deviceID, URIs: []spotifyLib.URI{uri}}
+	}
+	return nil
+}
+
+// retarget moves a req.Playlist that names something other than a
+// playlist into the request field for that type, so the rest of Play
+// (validation, resolution, playback) treats it as what it is.
+func (req PlayRequest) retarget(ctx context.Context) (PlayRequest, error) {
+	if req.Playlist == "" {
+		return req, nil
+	}
+	target, err := ResolvePlayTarget(ctx, req.Playlist)
+	if err != nil {
+		return req, err
+	}
+
+	var field *string
+	switch target.Type {
+	case spotifyuri.Album:
+		field = &req.Album
+	case spotifyuri.Artist:
+		field = &req.Artist
+	case spotifyuri.Track:
+		field = &req.Track
+	case spotifyuri.Audiobook, spotifyuri.Chapter:
+		field = &req.Audiobook
+	case spotifyuri.Show, spotifyuri.Episode:
+		field = &req.Show
+	default:
+		return req, nil
+	}
+	if *field != "" {
+		return req, fmt.Errorf("playlist names a %s, and a %s was given too", target.Type, target.Type)
+	}
+	// The URI saves resolving a shortlink a second time.
+	*field, req.Playlist = target.URI, ""
+	return req, nil
+}
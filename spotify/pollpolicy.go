@@ -0,0 +1,218 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Playback polling policy. The watcher polls fast while
+// something is playing and slowly while nothing is, polls right after a
+// player command this tool sent, keeps every interval between a floor and
+// a ceiling, and backs off when the process has used up its Spotify call
+// budget for the last minute — so dashboards stay responsive without the
+// poller eating the rate limit that commands need.
+//
+
+package spotify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Polling strategies.
+const (
+	// PollAdaptive polls at the playing interval while anything plays and
+	// at the idle interval otherwise.
+	PollAdaptive = "adaptive"
+	// PollFixed always polls at the playing interval.
+	PollFixed = "fixed"
+)
+
+// Poll policy defaults.
+const (
+	DefaultPollIdleInterval = 15 * time.Second
+	DefaultPollMinInterval  = time.Second
+	DefaultPollMaxInterval  = time.Minute
+	// DefaultPollCommandDelay gives Spotify a moment to reflect a command
+	// before the poll that picks it up.
+	DefaultPollCommandDelay = 500 * time.Millisecond
+)
+
+// callBudgetWindow is the span the call budget counts over.
+const callBudgetWindow = time.Minute
+
+// PollPolicy decides how often the playback watcher polls.
+type PollPolicy struct {
+	Strategy string
+	// Idle is the interval while nothing plays on any account (adaptive
+	// only). The playing interval is PLAYBACK_POLL_INTERVAL, or what a
+	// subscriber asked for.
+	Idle time.Duration
+	// Min and Max clamp every interval. The call budget may still stretch
+	// an interval past Max.
+	Min time.Duration
+	Max time.Duration
+	// CommandDelay is how soon after a player command the next poll runs.
+	CommandDelay time.Duration
+	// Budget caps Spotify calls per minute across all accounts; when it is
+	// used up the watcher waits for room. Zero means no budget. Commands
+	// are never held back, only polling.
+	Budget int
+}
+
+// DefaultPollPolicy returns the adaptive policy with default intervals and
+// no budget.
+func DefaultPollPolicy() PollPolicy {
+	return PollPolicy{
+		Strategy:     PollAdaptive,
+		Idle:         DefaultPollIdleInterval,
+		Min:          DefaultPollMinInterval,
+		Max:          DefaultPollMaxInterval,
+		CommandDelay: DefaultPollCommandDelay,
+	}
+}
+
+// Validate rejects an unknown strategy, negative values, and a floor above
+// the ceiling.
+func (p PollPolicy) Validate() error {
+	switch p.Strategy {
+	case PollAdaptive, PollFixed:
+	default:
+		return fmt.Errorf("unknown polling strategy %q: use %s or %s", p.Strategy, PollAdaptive, PollFixed)
+	}
+	if p.Idle < 0 || p.Min < 0 || p.Max < 0 || p.CommandDelay < 0 || p.Budget < 0 {
+		return fmt.Errorf("polling intervals and the call budget must not be negative")
+	}
+	if p.Max > 0 && p.Min > p.Max {
+		return fmt.Errorf("the polling floor (%s) is above the ceiling (%s)", p.Min, p.Max)
+	}
+	return nil
+}
+
+// interval is how long to wait before the next poll, given the interval
+// subscribers need while playing and whether anything is playing.
+func (p PollPolicy) interval(playingInterval time.Duration, playing bool) time.Duration {
+	d := playingInterval
+	if p.Strategy == PollAdaptive && !playing {
+		d = max(d, p.Idle)
+	}
+	if p.Min > 0 {
+		d = max(d, p.Min)
+	}
+	if p.Max > 0 {
+		d = min(d, p.Max)
+	}
+	return d
+}
+
+// pollPolicy is the process-wide policy.
+var (
+	pollPolicyMu sync.RWMutex
+	pollPolicy   = DefaultPollPolicy()
+)
+
+// SetPollPolicy sets the playback watcher's polling policy.
+func SetPollPolicy(p PollPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	pollPolicyMu.Lock()
+	pollPolicy = p
+	pollPolicyMu.Unlock()
+	playbackWatcher.pokeAfter(0)
+	return nil
+}
+
+// currentPollPolicy returns the process-wide policy.
+func currentPollPolicy() PollPolicy {
+	pollPolicyMu.RLock()
+	defer pollPolicyMu.RUnlock()
+	return pollPolicy
+}
+
+// callWindow counts Spotify calls over the last callBudgetWindow.
+type callWindow struct {
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+// spotifyCallWindow counts every Spotify call the process makes, on any
+// account, including retries.
+var spotifyCallWindow = &callWindow{}
+
+// add records a call at `at`.
+func (w *callWindow) add(at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.prune(at)
+	w.calls = append(w.calls, at)
+}
+
+// count returns how many calls were made in the window ending at `at`.
+func (w *callWindow) count(at time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.prune(at)
+	return len(w.calls)
+}
+
+// waitFor returns how long after `at` until `n` more calls fit in
+// `budget`, or zero if they fit then. `at` may be in the future, so
+// nothing is pruned.
+func (w *callWindow) waitFor(at time.Time, budget, n int) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cutoff := at.Add(-callBudgetWindow)
+	inWindow := w.calls
+	for len(inWindow) > 0 && !inWindow[0].After(cutoff) {
+		inWindow = inWindow[1:]
+	}
+	over := len(inWindow) + n - budget
+	if over <= 0 {
+		return 0
+	}
+	if over > len(inWindow) {
+		// More calls than the whole budget; wait out a full window.
+		return callBudgetWindow
+	}
+	return inWindow[over-1].Add(callBudgetWindow).Sub(at)
+}
+
+// prune drops calls older than the window. Callers hold w.mu.
+func (w *callWindow) prune(at time.Time) {
+	cutoff := at.Add(-callBudgetWindow)
+	i := 0
+	for i < len(w.calls) && !w.calls[i].After(cutoff) {
+		i++
+	}
+	w.calls = w.calls[i:]
+}
+
+// playerCommands are the calls that change playback, after which the
+// watcher polls early so subscribers see the result promptly.
+var playerCommands = map[string]bool{
+	"PlayOpt":          true,
+	"Pause":            true,
+	"PauseOpt":         true,
+	"Shuffle":          true,
+	"Repeat":           true,
+	"Volume":           true,
+	"VolumeOpt":        true,
+	"Next":             true,
+	"QueueSong":        true,
+	"Seek":             true,
+	"TransferPlayback": true,
+}
+
+// PollStatus describes the watcher's polling for /api/v1/stats/spotify.
+type PollStatus struct {
+	Strategy string `json:"strategy"`
+	// Active is whether anything is subscribed to playback events.
+	Active bool `json:"active"`
+	// Playing is whether any account was playing at the last poll.
+	Playing         bool  `json:"playing"`
+	IntervalMs      int64 `json:"interval_ms"`
+	CallsLastMinute int   `json:"calls_last_minute"`
+	CallBudget      int   `json:"call_budget,omitempty"`
+}
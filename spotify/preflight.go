@@ -0,0 +1,213 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Pre-flight check of the Spotify app configuration, run by
+// -doctor and at server startup. A wrong client secret and a redirect URI
+// the app doesn't have registered both surface as a sign-in that fails,
+// with nothing to say which. The client credentials are tried against
+// Spotify's token endpoint, and the redirect URI against its authorize
+// endpoint, so each problem is reported on its own.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Outcomes of a pre-flight check.
+const (
+	CheckOK      = "ok"
+	CheckFailed  = "failed"
+	CheckUnknown = "unknown"
+)
+
+// Spotify's accounts endpoints. Variables so tests can point them at a
+// fake.
+var (
+	spotifyTokenURL     = "https://accounts.spotify.com/api/token"
+	spotifyAuthorizeURL = "https://accounts.spotify.com/authorize"
+)
+
+// preflightHTTPClient doesn't follow redirects: the authorize endpoint
+// redirecting to the login page is how it accepts a redirect URI.
+var preflightHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// PreflightCheck is the outcome of one check. Status is CheckOK,
+// CheckFailed, or CheckUnknown when Spotify couldn't say.
+type PreflightCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	// Hint says how to fix a check that didn't pass, when Detail doesn't.
+	Hint string `json:"hint,omitempty"`
+}
+
+// ValidateAppConfig checks the app's client credentials and redirect URI
+// with Spotify.
+func ValidateAppConfig(ctx context.Context, clientID, clientSecret, redirectURI string) []PreflightCheck {
+	creds := checkClientCredentials(ctx, clientID, clientSecret)
+	return []PreflightCheck{creds, checkRedirectURI(ctx, clientID, redirectURI, creds.Status == CheckFailed)}
+}
+
+// PreflightFailed reports whether any check failed.
+func PreflightFailed(checks []PreflightCheck) bool {
+	for _, c := range checks {
+		if c.Status == CheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClientCredentials asks Spotify's token endpoint for an app token
+// with the client ID and secret. It needs no user and no redirect URI, so
+// a refusal is down to the credentials alone.
+func checkClientCredentials(ctx context.Context, clientID, clientSecret string) PreflightCheck {
+	check := PreflightCheck{Name: "client credentials"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spotifyTokenURL, strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		check.Status, check.Detail = CheckUnknown, err.Error()
+		return check
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := preflightHTTPClient.Do(req)
+	if err != nil {
+		check.Status, check.Detail = CheckUnknown, fmt.Sprintf("couldn't reach Spotify: %v", err)
+		return check
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		check.Status, check.Detail = CheckOK, "Spotify accepted SPOTIFY_CLIENT_ID and SPOTIFY_CLIENT_SECRET"
+	case body.Error == "invalid_client" || resp.StatusCode == http.StatusUnauthorized:
+		reason := body.Description
+		if reason == "" {
+			reason = body.Error
+		}
+		check.Status = CheckFailed
+		check.Detail = fmt.Sprintf("Spotify rejected SPOTIFY_CLIENT_ID and SPOTIFY_CLIENT_SECRET (%s). Copy both again from the app's page on the Spotify developer dashboard.", reason)
+	default:
+		check.Status, check.Detail = CheckUnknown, fmt.Sprintf("Spotify's token endpoint answered %s", resp.Status)
+	}
+	return check
+}
+
+// checkRedirectURI checks that the redirect URI is one Spotify allows and
+// this app serves, then starts a sign-in with it the way a browser would.
+// Spotify answers a registered redirect URI by sending the browser to its
+// login page, and an unregistered one with an "Invalid redirect URI"
+// error page. With the client credentials already rejected, that can't
+// be told apart, so the check is skipped.
+func checkRedirectURI(ctx context.Context, clientID, redirectURI string, badClient bool) PreflightCheck {
+	check := PreflightCheck{Name: "redirect URI"}
+
+	if problem := redirectURIProblem(redirectURI); problem != "" {
+		check.Status, check.Detail = CheckFailed, problem
+		return check
+	}
+	if badClient {
+		check.Status, check.Detail = CheckUnknown, "can't be checked until Spotify accepts the client credentials"
+		return check
+	}
+
+	q := url.Values{"client_id": {clientID}, "response_type": {"code"}, "redirect_uri": {redirectURI}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spotifyAuthorizeURL+"?"+q.Encode(), nil)
+	if err != nil {
+		check.Status, check.Detail = CheckUnknown, err.Error()
+		return check
+	}
+	resp, err := preflightHTTPClient.Do(req)
+	if err != nil {
+		check.Status, check.Detail = CheckUnknown, fmt.Sprintf("couldn't reach Spotify: %v", err)
+		return check
+	}
+	defer resp.Body.Close()
+	page, _ := io.ReadAll(io.LimitReader(resp.Body, 256<<10))
+
+	switch {
+	case strings.Contains(strings.ToLower(string(page)), "invalid redirect uri"):
+		check.Status = CheckFailed
+		check.Detail = fmt.Sprintf("Spotify doesn't have %s registered for this app. Add it, exactly as written, under Redirect URIs in the app's settings on the Spotify developer dashboard, or set SPOTIFY_REDIRECT_URI to one that is.", redirectURI)
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		check.Status, check.Detail = CheckOK, fmt.Sprintf("Spotify accepted %s", redirectURI)
+	default:
+		check.Status, check.Detail = CheckUnknown, fmt.Sprintf("Spotify's authorize endpoint answered %s", resp.Status)
+	}
+	return check
+}
+
+// redirectURIProblem checks a redirect URI without asking Spotify: it
+// must be an absolute URL ending in /callback, where this app listens, and
+// Spotify only allows plain http for a loopback IP address, never for
+// "localhost".
+func redirectURIProblem(redirectURI string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Sprintf("%q isn't an absolute http(s) URL", redirectURI)
+	}
+	if u.Path != "/callback" {
+		return fmt.Sprintf("%s must end in /callback, where this app receives the sign-in", redirectURI)
+	}
+	if u.Scheme == "http" {
+		if strings.EqualFold(u.Hostname(), "localhost") {
+			loopback := *u
+			loopback.Host = "127.0.0.1"
+			if port := u.Port(); port != "" {
+				loopback.Host += ":" + port
+			}
+			return fmt.Sprintf("Spotify doesn't allow localhost in redirect URIs; use %s instead, and register that", loopback.String())
+		}
+		if ip := net.ParseIP(u.Hostname()); ip == nil || !ip.IsLoopback() {
+			return fmt.Sprintf("Spotify only allows plain http for a loopback address such as 127.0.0.1; %s needs https", redirectURI)
+		}
+	}
+	return ""
+}
+
+// PrintPreflightChecks prints each check's outcome, with the hint under
+// one that didn't pass.
+func PrintPreflightChecks(checks []PreflightCheck) {
+	for _, c := range checks {
+		var mark string
+		switch c.Status {
+		case CheckOK:
+			mark = color.GreenString("ok")
+		case CheckFailed:
+			mark = color.RedString("FAILED")
+		default:
+			mark = color.YellowString("unknown")
+		}
+		fmt.Printf("%-20s %s  %s\n", c.Name, mark, c.Detail)
+		if c.Hint != "" && c.Status != CheckOK {
+			fmt.Printf("%-20s %s\n", "", color.CyanString(symbol("→", "->")+" "+c.Hint))
+		}
+	}
+}
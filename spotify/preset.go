@@ -0,0 +1,59 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Preset playback. A preset is a named play request stored in
+// the settings file, so a five-parameter shortcut collapses to one word.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+)
+
+// PlayPreset plays the preset called `name` from the settings file,
+// including its volume if one is set. The preset's account is used unless
+// ctx already names one.
+func PlayPreset(ctx context.Context, name string) (string, error) {
+	preset, ok := GetSettings().FindPreset(name)
+	if !ok {
+		return "", fmt.Errorf("unknown preset %q", name)
+	}
+	if preset.Playlist == "" {
+		return "", fmt.Errorf("preset %q has no playlist configured", name)
+	}
+
+	return Play(preset.context(ctx), preset.PlayRequest())
+}
+
+// context returns ctx set to the preset's account, unless ctx already
+// names one.
+func (p Preset) context(ctx context.Context) context.Context {
+	if accountNamed(ctx) || p.Account == "" {
+		return ctx
+	}
+	return WithAccount(ctx, p.Account)
+}
+
+// PlayRequest returns the play request the preset stands for.
+func (p Preset) PlayRequest() PlayRequest {
+	return PlayRequest{
+		Device:         p.Device,
+		Playlist:       p.Playlist,
+		Shuffle:        p.Shuffle,
+		Start:          p.Start,
+		NewestFirst:    p.NewestFirst,
+		LeastPlayed:    p.LeastPlayed,
+		Duration:       p.Duration,
+		Volume:         p.Volume,
+		StrictMetadata: p.StrictMetadata,
+		Confirm:        p.Confirm,
+		OnEnd:          p.OnEnd,
+		FadeIn:         p.FadeIn,
+		TargetVolume:   p.TargetVolume,
+		Wait:           p.Wait,
+	}
+}
@@ -0,0 +1,154 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Per-preset analytics for server mode. Every preset run
+// through the API is counted along with why it failed, and successful runs
+// are followed up by polling the player until audio is actually moving, so
+// flaky automations show up as a low success rate or a slow start time at
+// /api/v1/stats/presets. Stats live in memory and reset on restart.
+//
+
+package spotify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxFailureReasons caps the distinct failure messages kept per preset;
+// anything beyond is counted under "other".
+const maxFailureReasons = 20
+
+// presetStartTimeout is how long a preset gets to start playing before the
+// run is counted as a start timeout.
+var presetStartTimeout = 20 * time.Second
+
+// PresetStat is the record for one preset.
+type PresetStat struct {
+	Invocations int `json:"invocations"`
+	Successes   int `json:"successes"`
+	Failures    int `json:"failures"`
+	// SuccessRate is Successes/Invocations. A start timeout still counts
+	// as a success here — Spotify accepted the command — and is reported
+	// separately in StartTimeouts.
+	SuccessRate    float64        `json:"success_rate"`
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	// StartTimeouts counts successful runs that were never seen playing
+	// within the start timeout.
+	StartTimeouts int `json:"start_timeouts"`
+	// Measured is the number of runs AvgStartMs and MaxStartMs cover: time
+	// from the request arriving to the track actually playing.
+	Measured    int       `json:"measured"`
+	AvgStartMs  int64     `json:"avg_start_ms"`
+	MaxStartMs  int64     `json:"max_start_ms"`
+	LastInvoked time.Time `json:"last_invoked"`
+
+	totalStart time.Duration
+}
+
+// PresetStats collects PresetStat records by preset name.
+type PresetStats struct {
+	mu      sync.Mutex
+	since   time.Time
+	presets map[string]*PresetStat
+}
+
+// NewPresetStats returns an empty collector.
+func NewPresetStats() *PresetStats {
+	return &PresetStats{since: time.Now(), presets: map[string]*PresetStat{}}
+}
+
+// presetStats is the server's collector.
+var presetStats = NewPresetStats()
+
+// stat returns the record for name, creating it. Callers hold s.mu.
+func (s *PresetStats) stat(name string) *PresetStat {
+	st, ok := s.presets[name]
+	if !ok {
+		st = &PresetStat{}
+		s.presets[name] = st
+	}
+	return st
+}
+
+// RecordRun counts one invocation of preset `name` and, if err is set,
+// why it failed.
+func (s *PresetStats) RecordRun(name string, at time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stat(name)
+	st.Invocations++
+	st.LastInvoked = at
+	if err == nil {
+		st.Successes++
+	} else {
+		st.Failures++
+		st.LastError = err.Error()
+		if st.FailureReasons == nil {
+			st.FailureReasons = map[string]int{}
+		}
+		reason := err.Error()
+		if _, seen := st.FailureReasons[reason]; !seen && len(st.FailureReasons) >= maxFailureReasons {
+			reason = "other"
+		}
+		st.FailureReasons[reason]++
+	}
+	st.SuccessRate = float64(st.Successes) / float64(st.Invocations)
+}
+
+// RecordStart records how long a successful run took to start playing.
+func (s *PresetStats) RecordStart(name string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stat(name)
+	st.Measured++
+	st.totalStart += d
+	st.AvgStartMs = (st.totalStart / time.Duration(st.Measured)).Milliseconds()
+	if ms := d.Milliseconds(); ms > st.MaxStartMs {
+		st.MaxStartMs = ms
+	}
+}
+
+// RecordStartTimeout records a successful run that never started playing.
+func (s *PresetStats) RecordStartTimeout(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stat(name).StartTimeouts++
+}
+
+// Snapshot returns a copy of every record and when collection began.
+func (s *PresetStats) Snapshot() (map[string]PresetStat, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]PresetStat, len(s.presets))
+	for name, st := range s.presets {
+		cp := *st
+		if st.FailureReasons != nil {
+			cp.FailureReasons = make(map[string]int, len(st.FailureReasons))
+			for k, v := range st.FailureReasons {
+				cp.FailureReasons[k] = v
+			}
+		}
+		out[name] = cp
+	}
+	return out, s.since
+}
+
+// measurePresetStart waits for a successful preset run to start playing
+// on the account ctx selects, and records the latency or a start timeout.
+// Run it in a goroutine.
+func measurePresetStart(ctx context.Context, name string, started time.Time) {
+	d, err := waitUntilPlaying(ctx, started, presetStartTimeout, nil)
+	if err != nil {
+		presetStats.RecordStartTimeout(name)
+		return
+	}
+	presetStats.RecordStart(name, d)
+}
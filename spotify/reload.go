@@ -0,0 +1,104 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Reloading the configuration of a running server. SIGHUP or
+// `POST /api/v1/reload` re-reads the settings file (rooms, groups,
+// presets, rules, volume schedules, and schedules) and the API access
+// token, and swaps them in without closing the listener or touching the
+// Spotify logins. Everything is checked before anything changes, so a
+// typo leaves the server running on the old configuration.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+var (
+	// reloadMu keeps reloads from interleaving.
+	reloadMu sync.Mutex
+
+	// settingsJobsParent is the context StartSettingsJobs was given, and
+	// stopSettingsJobs stops the rules and volume schedules it started.
+	settingsJobsParent context.Context
+	stopSettingsJobs   context.CancelFunc
+
+	// apiTokenSource re-reads the API access token on reload; nil keeps
+	// the current one.
+	apiTokenSource func() (string, error)
+)
+
+// SetAPITokenSource sets where a reload reads the API access token from.
+func SetAPITokenSource(source func() (string, error)) {
+	apiTokenSource = source
+}
+
+// StartSettingsJobs starts the playback rules and volume schedules from
+// the settings file under a context a reload can cancel, to restart them
+// with the new settings.
+func StartSettingsJobs(ctx context.Context) error {
+	jobsCtx, cancel := context.WithCancel(ctx)
+	if err := StartRules(jobsCtx); err != nil {
+		cancel()
+		return err
+	}
+	if err := StartVolumeSchedules(jobsCtx); err != nil {
+		cancel()
+		return err
+	}
+	settingsJobsParent, stopSettingsJobs = ctx, cancel
+	return nil
+}
+
+// Reload re-reads the settings file and the API access token and swaps
+// them in, returning a summary of what's now loaded. A file or token
+// that doesn't check out is a CodeBadRequest error and nothing changes.
+func Reload() (string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	s, err := ReadSettingsFile(settingsFile)
+	if err != nil {
+		return "", withCode(CodeBadRequest, err)
+	}
+	if _, err := compileRules(s.Rules, s); err != nil {
+		return "", withCode(CodeBadRequest, fmt.Errorf("%s: %w", settingsFile, err))
+	}
+	if _, err := compileVolumeSchedules(s.VolumeSchedules); err != nil {
+		return "", withCode(CodeBadRequest, fmt.Errorf("%s: %w", settingsFile, err))
+	}
+	schedules, err := compileSettingsSchedules(s.Schedules)
+	if err != nil {
+		return "", withCode(CodeBadRequest, fmt.Errorf("%s: %w", settingsFile, err))
+	}
+	token := GetAPIAccessToken()
+	if apiTokenSource != nil {
+		if token, err = apiTokenSource(); err != nil {
+			return "", withCode(CodeBadRequest, err)
+		}
+	}
+
+	setSettings(s)
+	if stopSettingsJobs != nil {
+		stopSettingsJobs()
+		// The new settings compiled above, so this can't fail.
+		if err := StartSettingsJobs(settingsJobsParent); err != nil {
+			log.Printf("Warning: rules and volume schedules stopped: %v", err)
+		}
+	}
+	if scheduler != nil {
+		scheduler.replaceSettingsSchedules(schedules)
+	}
+	SetAPIAccessToken(token)
+
+	msg := fmt.Sprintf("Reloaded %s: %d rooms, %d groups, %d presets, %d rules, %d volume schedules, %d schedules",
+		settingsFile, len(s.Rooms), len(s.Groups), len(s.Presets), len(s.Rules), len(s.VolumeSchedules), len(s.Schedules))
+	log.Print(msg)
+	return msg, nil
+}
@@ -0,0 +1,92 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Shared request decoding for the API handlers. Every
+// endpoint accepts its parameters as a query string (the original GET
+// contract) or as a JSON POST body, so callers can keep options — and
+// anything sensitive — out of URLs and access logs.
+//
+
+package spotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxRequestBodyBytes bounds JSON bodies. Real requests are a few hundred
+// bytes at most.
+const maxRequestBodyBytes = 64 << 10
+
+// requestToken returns the API access token a request carries: the
+// `token` query parameter, an Authorization header with or without
+// "Bearer ", or an X-API-Token header, which is the easiest to set in
+// Apple Shortcuts' "Get Contents of URL".
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.Header.Get("X-API-Token")
+}
+
+// readParams returns the request's parameters as url.Values. Query
+// parameters are read first; a JSON object body on a POST then overrides
+// them key by key, with strings, booleans, and numbers converted to their
+// query-string form (true, 40, ...) so handlers parse both the same way.
+// Nested objects and arrays are rejected.
+func readParams(r *http.Request) (url.Values, error) {
+	params := r.URL.Query()
+	if r.Method != http.MethodPost || r.Body == nil {
+		return params, nil
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/json" {
+			return nil, fmt.Errorf("unsupported content type %q: send application/json", ct)
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	if len(data) > maxRequestBodyBytes {
+		return nil, fmt.Errorf("request body too large")
+	}
+	if len(data) == 0 {
+		return params, nil
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	for key, v := range body {
+		switch val := v.(type) {
+		case nil:
+			// null means "not set"; leave any query value alone.
+		case string:
+			params.Set(key, val)
+		case bool:
+			params.Set(key, strconv.FormatBool(val))
+		case float64:
+			params.Set(key, strconv.FormatFloat(val, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("field %q must be a string, number, or boolean", key)
+		}
+	}
+	return params, nil
+}
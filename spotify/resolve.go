@@ -0,0 +1,433 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Dry-run resolution of a play request. Works out which
+// playlist (or album, or artist) and device a request would hit, and with which effective
+// options, without starting playback — for writing automations and
+// untangling name collisions.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// ResolvedPlaylist is the playlist a request resolves to. MatchedBy is
+// "uri" (URI, link, or shortlink), "id" (bare ID), "name", "spotify" (a
+// Spotify-owned playlist found by searching the catalog), or "assumed-id"
+// (no name matched, so the input will be tried as an ID).
+type ResolvedPlaylist struct {
+	Input     string `json:"input"`
+	ID        string `json:"id"`
+	URI       string `json:"uri"`
+	MatchedBy string `json:"matched_by"`
+	Name      string `json:"name,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Tracks    int    `json:"tracks,omitempty"`
+}
+
+// ResolvedAlbum is the album a request resolves to. MatchedBy is "uri"
+// (URI, link, or shortlink), "id" (bare ID), or "name" (a saved album).
+type ResolvedAlbum struct {
+	Input     string `json:"input"`
+	ID        string `json:"id"`
+	URI       string `json:"uri"`
+	MatchedBy string `json:"matched_by"`
+	Name      string `json:"name,omitempty"`
+	Artists   string `json:"artists,omitempty"`
+	Tracks    int    `json:"tracks,omitempty"`
+}
+
+// ResolvedArtist is the artist a request resolves to. MatchedBy is "uri"
+// (URI, link, or shortlink), "id" (bare ID), "name" (a search result with
+// exactly that name), or "closest" (the top search result, whose name
+// differs).
+type ResolvedArtist struct {
+	Input     string `json:"input"`
+	ID        string `json:"id"`
+	URI       string `json:"uri"`
+	MatchedBy string `json:"matched_by"`
+	Name      string `json:"name,omitempty"`
+}
+
+// ResolvedTrack is the track a request resolves to. MatchedBy is "uri"
+// (URI, link, or shortlink), "id" (bare ID), or "search" (the top result
+// for a query).
+type ResolvedTrack struct {
+	Input     string `json:"input"`
+	ID        string `json:"id"`
+	URI       string `json:"uri"`
+	MatchedBy string `json:"matched_by"`
+	Name      string `json:"name,omitempty"`
+	Artists   string `json:"artists,omitempty"`
+}
+
+// ResolvedAudiobook is the audiobook a request resolves to and where it
+// would resume. MatchedBy is "uri", "id", "chapter" (a chapter link), or
+// "saved" (a saved audiobook's name). The chapter fields are empty when
+// the book would start from the beginning.
+type ResolvedAudiobook struct {
+	Input         string `json:"input"`
+	ID            string `json:"id"`
+	URI           string `json:"uri"`
+	MatchedBy     string `json:"matched_by"`
+	Name          string `json:"name,omitempty"`
+	Authors       string `json:"authors,omitempty"`
+	Chapter       string `json:"chapter,omitempty"`
+	ChapterURI    string `json:"chapter_uri,omitempty"`
+	ChapterNumber int    `json:"chapter_number,omitempty"`
+	PositionMs    int    `json:"position_ms"`
+}
+
+// ResolvedShow is the show or episode a request resolves to. Type is
+// "show" or "episode"; Show names the show either way. MatchedBy is "uri"
+// or "id". PositionMs is where an episode would resume.
+type ResolvedShow struct {
+	Input      string `json:"input"`
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	URI        string `json:"uri"`
+	MatchedBy  string `json:"matched_by"`
+	Name       string `json:"name,omitempty"`
+	Show       string `json:"show,omitempty"`
+	Publisher  string `json:"publisher,omitempty"`
+	PositionMs int    `json:"position_ms"`
+}
+
+// ResolvedDevice is the device a request resolves to. MatchedBy is
+// "name", "id", "registry" (stable or retired ID), "group" (a device
+// group's primary), "active", "first", or "claim" (not linked; playback
+// would try a zeroconf claim).
+type ResolvedDevice struct {
+	Input     string `json:"input,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	StableID  string `json:"stable_id,omitempty"`
+	MatchedBy string `json:"matched_by"`
+}
+
+// EffectiveRequest is a play request with every default filled in.
+type EffectiveRequest struct {
+	Device         string `json:"device,omitempty"`
+	Playlist       string `json:"playlist,omitempty"`
+	Album          string `json:"album,omitempty"`
+	Artist         string `json:"artist,omitempty"`
+	Track          string `json:"track,omitempty"`
+	Audiobook      string `json:"audiobook,omitempty"`
+	Show           string `json:"show,omitempty"`
+	Shuffle        bool   `json:"shuffle"`
+	Start          string `json:"start,omitempty"`
+	NewestFirst    bool   `json:"newest_first"`
+	LeastPlayed    bool   `json:"least_played"`
+	Duration       string `json:"duration,omitempty"`
+	Volume         *int   `json:"volume,omitempty"`
+	StrictMetadata bool   `json:"strict_metadata"`
+	Confirm        bool   `json:"confirm"`
+	OnEnd          string `json:"on_end,omitempty"`
+	FadeIn         string `json:"fadein,omitempty"`
+	TargetVolume   *int   `json:"target_volume,omitempty"`
+	Wait           string `json:"wait,omitempty"`
+}
+
+// effectiveRequest fills in the defaults Play would apply to `req`.
+func effectiveRequest(req PlayRequest) EffectiveRequest {
+	eff := EffectiveRequest{
+		Device:         req.Device,
+		Playlist:       req.Playlist,
+		Album:          req.Album,
+		Artist:         req.Artist,
+		Track:          req.Track,
+		Audiobook:      req.Audiobook,
+		Show:           req.Show,
+		Shuffle:        req.Shuffle,
+		NewestFirst:    req.NewestFirst,
+		LeastPlayed:    req.LeastPlayed,
+		Duration:       req.Duration,
+		Volume:         req.Volume,
+		StrictMetadata: req.strictMetadata(),
+		Confirm:        req.Confirm,
+		OnEnd:          strings.ToLower(req.OnEnd),
+		FadeIn:         req.FadeIn,
+		TargetVolume:   req.TargetVolume,
+		Wait:           req.Wait,
+	}
+	// Ordered modes play a track list from the top, Spotify picks where
+	// an artist starts, a single track has nowhere else to start, and
+	// audiobooks and shows resume or start at the top; no strategy applies
+	// to any of them.
+	if !req.NewestFirst && !req.LeastPlayed && req.Artist == "" && req.Track == "" && req.Audiobook == "" && req.Show == "" {
+		switch {
+		case req.Start != "":
+			eff.Start = strings.ToLower(req.Start)
+		case req.Shuffle:
+			eff.Start = StartRandom
+		default:
+			eff.Start = StartFirst
+		}
+	}
+	return eff
+}
+
+// ResolvePlay resolves `req` the way Play would, without playing.
+// Problems that would make Play fail or surprise you are reported as
+// warnings rather than errors so the whole picture comes back at once.
+func ResolvePlay(ctx context.Context, req PlayRequest) (*ResolveResponse, error) {
+	ctx = routeByDevice(ctx, req.Device)
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A link that can't be retargeted is left for the playlist lookup,
+	// which reports it.
+	if retargeted, err := req.retarget(ctx); err == nil {
+		req = retargeted
+	}
+	resp := &ResolveResponse{Success: true, Account: AccountFrom(ctx), Effective: effectiveRequest(req)}
+
+	switch {
+	case req.Show != "":
+		show, err := ResolveShow(ctx, client, req.Show)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "playback would fail: "+err.Error())
+		} else {
+			resp.Show = show
+		}
+	case req.Audiobook != "":
+		// Duplicate names and finished books are warned about as they're
+		// resolved; collect those into the response.
+		wctx, w := WithWarnings(ctx)
+		book, err := ResolveAudiobook(wctx, client, req.Audiobook)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "playback would fail: "+err.Error())
+		} else {
+			resp.Audiobook = book
+		}
+		resp.Warnings = append(resp.Warnings, w.List()...)
+	case req.Track != "":
+		track, err := ResolveTrack(ctx, client, req.Track)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "playback would fail: "+err.Error())
+		} else {
+			resp.Track = track
+		}
+	case req.Artist != "":
+		artist, err := ResolveArtist(ctx, client, req.Artist)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "playback would fail: "+err.Error())
+		} else {
+			resp.Artist = artist
+			if artist.MatchedBy == "closest" {
+				resp.Warnings = append(resp.Warnings, fmt.Sprintf("no artist is named exactly %q; the closest match, %s, would play", req.Artist, artist.Name))
+			}
+		}
+	case req.Album != "":
+		album, warnings, err := resolveAlbum(ctx, client, req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Album = album
+		resp.Warnings = append(resp.Warnings, warnings...)
+	case IsLikedSongs(req.Playlist):
+		liked, err := resolveLiked(ctx, client, req.Playlist)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "playback would fail: "+err.Error())
+		} else {
+			resp.Playlist = liked
+		}
+	default:
+		pl, warnings, err := resolvePlaylist(ctx, client, req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Playlist = pl
+		resp.Warnings = append(resp.Warnings, warnings...)
+	}
+
+	dev, warnings, err := resolveDevice(ctx, client, req.Device)
+	if err != nil {
+		return nil, err
+	}
+	resp.Device = dev
+	resp.Warnings = append(resp.Warnings, warnings...)
+
+	return resp, nil
+}
+
+// resolvePlaylist finds the playlist for req.Playlist, flagging name
+// collisions and playlists whose details Spotify won't return.
+func resolvePlaylist(ctx context.Context, client Client, req PlayRequest) (*ResolvedPlaylist, []string, error) {
+	var warnings []string
+	pl := &ResolvedPlaylist{Input: req.Playlist}
+
+	id, ok, err := playlistIDFromInput(ctx, req.Playlist)
+	if err != nil {
+		return nil, append(warnings, err.Error()), nil
+	}
+	if ok {
+		pl.ID, pl.MatchedBy = id, "uri"
+		if spotifyuri.IsID(strings.TrimSpace(req.Playlist)) {
+			pl.MatchedBy = "id"
+		}
+	} else {
+		all, err := ListPlaylists(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		var matches []spotifyLib.SimplePlaylist
+		for _, p := range all {
+			if strings.EqualFold(p.Name, req.Playlist) || string(p.ID) == req.Playlist {
+				matches = append(matches, p)
+			}
+		}
+		var found *spotifyLib.SimplePlaylist
+		if len(matches) == 0 {
+			found, err = findSpotifyPlaylist(ctx, client, req.Playlist)
+			if err != nil {
+				warnings = append(warnings, err.Error())
+			}
+		}
+		switch {
+		case found != nil:
+			pl.ID, pl.MatchedBy = string(found.ID), "spotify"
+		case len(matches) == 0:
+			pl.ID, pl.MatchedBy = req.Playlist, "assumed-id"
+			warnings = append(warnings, fmt.Sprintf("no playlist in your library or by Spotify is named %q; it will be tried as a playlist ID", req.Playlist))
+		default:
+			pl.ID, pl.MatchedBy = string(matches[0].ID), "name"
+			if len(matches) > 1 {
+				ids := make([]string, len(matches))
+				for i, m := range matches {
+					ids[i] = string(m.ID)
+				}
+				warnings = append(warnings, fmt.Sprintf("%d playlists match %q (%s); the first is used — pass an ID to pick another", len(matches), req.Playlist, strings.Join(ids, ", ")))
+			}
+		}
+	}
+	pl.URI = spotifyuri.Resource{Type: spotifyuri.Playlist, ID: pl.ID}.URI()
+
+	full, err := client.GetPlaylist(ctx, spotifyLib.ID(pl.ID))
+	switch {
+	case err == nil:
+		pl.Name, pl.Owner, pl.Tracks = full.Name, full.Owner.DisplayName, int(full.Tracks.Total)
+	case isMetadataUnavailable(err) && !req.strictMetadata() && !req.NewestFirst && !req.LeastPlayed:
+		warnings = append(warnings, fmt.Sprintf("Spotify won't return this playlist's details (%v); it will play with no start position", err))
+	default:
+		warnings = append(warnings, fmt.Sprintf("playback would fail: failed to get playlist: %v", err))
+	}
+
+	return pl, warnings, nil
+}
+
+// resolveAlbum finds the album for req.Album, flagging saved albums that
+// share its name.
+func resolveAlbum(ctx context.Context, client Client, req PlayRequest) (*ResolvedAlbum, []string, error) {
+	var warnings []string
+	album := &ResolvedAlbum{Input: req.Album}
+
+	id, ok, err := albumIDFromInput(ctx, req.Album)
+	if err != nil {
+		return nil, append(warnings, err.Error()), nil
+	}
+	if ok {
+		album.ID, album.MatchedBy = id, "uri"
+		if spotifyuri.IsID(strings.TrimSpace(req.Album)) {
+			album.MatchedBy = "id"
+		}
+	} else {
+		matches, err := savedAlbumsNamed(ctx, client, req.Album)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(matches) == 0 {
+			return album, append(warnings, fmt.Sprintf("playback would fail: no album in your library is named %q", req.Album)), nil
+		}
+		album.ID, album.MatchedBy = string(matches[0].ID), "name"
+		if len(matches) > 1 {
+			warnings = append(warnings, fmt.Sprintf("%d saved albums are named %q (%s); the first is used — pass a link or ID to pick another", len(matches), req.Album, albumList(matches)))
+		}
+	}
+	album.URI = spotifyuri.Resource{Type: spotifyuri.Album, ID: album.ID}.URI()
+
+	full, err := client.GetAlbum(ctx, spotifyLib.ID(album.ID))
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("playback would fail: failed to get album: %v", err))
+		return album, warnings, nil
+	}
+	album.Name, album.Artists, album.Tracks = full.Name, albumArtists(full.SimpleAlbum), int(full.TotalTracks)
+	return album, warnings, nil
+}
+
+// resolveDevice finds the device `ref` names, or the fallback Play would
+// use when it's empty, flagging duplicate names.
+func resolveDevice(ctx context.Context, client Client, ref string) (*ResolvedDevice, []string, error) {
+	var warnings []string
+
+	devices, err := client.PlayerDevices(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	describe := func(d *spotifyLib.PlayerDevice, matchedBy string) *ResolvedDevice {
+		return &ResolvedDevice{
+			Input:     ref,
+			ID:        string(d.ID),
+			Name:      d.Name,
+			Type:      d.Type,
+			StableID:  StableDeviceID(d.Name, d.Type),
+			MatchedBy: matchedBy,
+		}
+	}
+
+	if ref == "" {
+		if len(devices) == 0 {
+			return nil, append(warnings, "playback would fail: no Spotify Connect devices found"), nil
+		}
+		for i, d := range devices {
+			if d.Active {
+				return describe(&devices[i], "active"), warnings, nil
+			}
+		}
+		return describe(&devices[0], "first"), warnings, nil
+	}
+
+	if g, ok := GetSettings().FindGroup(ref); ok {
+		if d := g.pick(devices); d != nil {
+			return describe(d, "group"), warnings, nil
+		}
+		warnings = append(warnings, fmt.Sprintf("no device in group %q is linked to your account; playback would try to claim %q over zeroconf", g.Name, g.primaryRef()))
+		return &ResolvedDevice{Input: ref, Name: g.primaryRef(), MatchedBy: "claim"}, warnings, nil
+	}
+
+	sameName := 0
+	for _, d := range devices {
+		if d.Name == ref {
+			sameName++
+		}
+	}
+	if sameName > 1 {
+		warnings = append(warnings, fmt.Sprintf("%d devices are named %q; the first is used — pass its ID or stable ID to pick another", sameName, ref))
+	}
+
+	d := findDevice(devices, ref)
+	switch {
+	case d == nil:
+		warnings = append(warnings, fmt.Sprintf("device %q isn't linked to your account; playback would try to claim it over zeroconf", ref))
+		return &ResolvedDevice{Input: ref, Name: ref, MatchedBy: "claim"}, warnings, nil
+	case d.Name == ref:
+		return describe(d, "name"), warnings, nil
+	case string(d.ID) == ref:
+		return describe(d, "id"), warnings, nil
+	default:
+		return describe(d, "registry"), warnings, nil
+	}
+}
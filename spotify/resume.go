@@ -0,0 +1,74 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Resume the most recent listening. -resume-last and
+// /api/v1/resume-last pick up where you left off on whichever speaker
+// you ask: the session Spotify is still holding, at its position, or
+// failing that the last recently-played track, in its playlist or album.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// lastListening finds what to resume and says where it came from.
+// Spotify keeps a paused session, with its position, for a while; once
+// it's gone, recently played still knows the last track and its context,
+// though not how far in it got, so that starts from the top.
+func lastListening(ctx context.Context, client Client) (*HandoffSnapshot, string, error) {
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get playback state: %w", err)
+	}
+	if state != nil && state.Item != nil {
+		return &HandoffSnapshot{
+			ContextURI: string(state.PlaybackContext.URI),
+			TrackURI:   string(state.Item.URI),
+			PositionMs: int(state.Progress),
+			Shuffle:    state.ShuffleState,
+		}, "the current session", nil
+	}
+
+	recent, err := client.PlayerRecentlyPlayedOpt(ctx, &spotifyLib.RecentlyPlayedOptions{Limit: 1})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get recently played: %w", err)
+	}
+	if len(recent) == 0 {
+		return nil, "", errors.New("nothing has been played recently to resume")
+	}
+	return &HandoffSnapshot{
+		ContextURI: string(recent[0].PlaybackContext.URI),
+		TrackURI:   string(recent[0].Track.URI),
+	}, "recently played", nil
+}
+
+// ResumeLast resumes the most recent listening on `deviceName`, claiming
+// it if needed, or on the active (else first) device when it's empty.
+func ResumeLast(ctx context.Context, deviceName string) (string, error) {
+	ctx = routeByDevice(ctx, deviceName)
+	client, err := clientFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	snap, source, err := lastListening(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	target, _, err := pickDevice(ctx, client, deviceName)
+	if err != nil {
+		return "", err
+	}
+	if err := resumeOn(ctx, client, *snap, target); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Resumed on %s at %s (from %s)", target.Name, formatPosition(snap.PositionMs), source), nil
+}
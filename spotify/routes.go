@@ -0,0 +1,551 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The API route table. Every /api/v1 endpoint (plus /healthz
+// and /ws) is declared here once — pattern, handler, methods, parameters,
+// and response type — and the server mux, the startup endpoint listing,
+// and the OpenAPI spec are all built from it, so a new endpoint can't be
+// registered without also being documented.
+//
+
+package spotify
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// apiParam describes one request parameter. Type is an OpenAPI primitive:
+// string, integer, or boolean.
+type apiParam struct {
+	Name        string
+	Type        string
+	Required    bool
+	Description string
+	Enum        []string
+}
+
+// apiRoute is one entry in the route table.
+type apiRoute struct {
+	// Pattern is the ServeMux pattern; {name} segments are path params.
+	Pattern string
+	Handler http.HandlerFunc
+	// Methods are the documented methods. Control endpoints take GET with
+	// query params or POST with a JSON body (see readParams).
+	Methods []string
+	Summary string
+	Params  []apiParam
+	// Response is a zero value of the success response type.
+	Response any
+	// Public routes skip the API access token.
+	Public bool
+	// Stream routes hold the response open, so they have no text form
+	// (see textformat.go).
+	Stream bool
+	// Cache names the cache class of a read-only route (see cache.go);
+	// empty means responses aren't cacheable.
+	Cache string
+}
+
+// accountScoped reports whether the route takes the `account` parameter:
+// every authenticated /api/v1 route does.
+func (rt apiRoute) accountScoped() bool {
+	return !rt.Public && strings.HasPrefix(rt.Pattern, "/api/v1/")
+}
+
+// getOrPost is the method set of control endpoints.
+var getOrPost = []string{http.MethodGet, http.MethodPost}
+
+// apiRoutes returns the route table. It is a function rather than a
+// package var because the OpenAPI handler it lists reads the table itself.
+func apiRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			Pattern:  "/healthz",
+			Handler:  HandleHealthRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Readiness probe: 200 once a working Spotify token is confirmed, 503 otherwise",
+			Response: TokenStatus{},
+			Public:   true,
+		},
+		{
+			Pattern:  "/ws",
+			Handler:  HandlePlaybackEventsRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "WebSocket stream of playback events (track, pause/resume, device, volume changes)",
+			Params:   []apiParam{{Name: "account", Type: "string", Description: "Account whose playback to stream; defaults to the default account"}},
+			Response: PlaybackEvent{},
+		},
+		{
+			Pattern: "/api/v1/events",
+			Handler: HandleEventStreamRequest,
+			Methods: []string{http.MethodGet},
+			Summary: "Server-Sent Events stream of the event bus (playback, auth, fallback, digest)",
+			Params: []apiParam{
+				{Name: "topics", Type: "string", Description: "Comma-separated topics to stream: playback, auth, fallback, digest; defaults to all"},
+				{Name: "account", Type: "string", Description: "Only stream this account's events"},
+			},
+			Response: Event{},
+			Stream:   true,
+		},
+		{
+			Pattern:  "/api/v1/auth/logout",
+			Handler:  HandleLogoutRequest,
+			Methods:  []string{http.MethodPost},
+			Summary:  "Delete the account's stored token and drop its client; sign in again at /auth",
+			Response: APIResponse{},
+		},
+		{
+			Pattern: "/api/v1/play",
+			Handler: HandlePlayRequest,
+			Methods: getOrPost,
+			Summary: "Start playlist, album, artist, track, or audiobook playback",
+			Params: []apiParam{
+				{Name: "playlist", Type: "string", Description: "Playlist name or ID, `liked` for Liked Songs, or a URI or open.spotify.com/spotify.link URL of any type, which plays that item (this, album, artist, track, or audiobook is required)"},
+				{Name: "album", Type: "string", Description: "Album ID, URI, or URL, or the name of a saved album; plays the album instead of a playlist"},
+				{Name: "artist", Type: "string", Description: "Artist name, ID, URI, or URL; plays the artist instead of a playlist"},
+				{Name: "track", Type: "string", Description: "Track ID, URI, URL, or search query; plays just that track"},
+				{Name: "audiobook", Type: "string", Description: "Audiobook or chapter ID, URI, or URL, or the name of a saved audiobook; resumes where it left off"},
+				{Name: "device", Type: "string", Description: "Device name or ID, or a device group to play on its primary; claimed via zeroconf if needed"},
+				{Name: "shuffle", Type: "boolean", Description: "Enable shuffle"},
+				{Name: "start", Type: "string", Description: "Start-position strategy", Enum: StartStrategyNames()},
+				{Name: "newest_first", Type: "boolean", Description: "Play newest additions first"},
+				{Name: "least_played", Type: "boolean", Description: "Play least-played tracks first"},
+				{Name: "duration", Type: "string", Description: "Play about this long of the playlist, like 45m or 1h30m"},
+				{Name: "volume", Type: "integer", Description: "Volume (0-100) applied once playback starts"},
+				{Name: "confirm", Type: "boolean", Description: "Wait until the playlist is actually playing on the device; 504 if it doesn't start within 10s"},
+				{Name: "strict_metadata", Type: "boolean", Description: "false plays the playlist even if Spotify won't return its details (404/403); defaults to STRICT_METADATA"},
+				{Name: "on_end", Type: "string", Description: "What to do when playback runs out: stop, repeat, fade-out, or preset:<name>"},
+				{Name: "fadein", Type: "string", Description: "Alarm-style fade-in: raise the volume from `volume` (default 5) to target_volume over this long, like 10m. A manual pause stops it"},
+				{Name: "target_volume", Type: "integer", Description: "Volume (0-100) a fadein ends at; required with fadein"},
+				{Name: "wait", Type: "string", Description: "Wait up to this long, like 30s, for the device to appear; fails instead of falling back to another device"},
+				{Name: "delay", Type: "string", Description: "Play once this long has passed, like 20m, instead of now; returns a job_id that DELETE /api/v1/jobs/{id} cancels"},
+			},
+			Response: APIResponse{},
+		},
+		{
+			Pattern: "/api/v1/resolve",
+			Handler: HandleResolveRequest,
+			Methods: getOrPost,
+			Summary: "Dry run: show the playlist, device, and options a play request or preset would use, without playing",
+			Params: []apiParam{
+				{Name: "preset", Type: "string", Description: "Resolve this preset instead of the play parameters"},
+				{Name: "playlist", Type: "string", Description: "Playlist name or ID, `liked`, or a URI or URL of any type (required without preset)"},
+				{Name: "album", Type: "string", Description: "As for /play"},
+				{Name: "artist", Type: "string", Description: "As for /play"},
+				{Name: "track", Type: "string", Description: "As for /play"},
+				{Name: "audiobook", Type: "string", Description: "As for /play"},
+				{Name: "device", Type: "string", Description: "Device name, ID, or stable ID"},
+				{Name: "shuffle", Type: "boolean", Description: "As for /play"},
+				{Name: "start", Type: "string", Description: "As for /play", Enum: StartStrategyNames()},
+				{Name: "newest_first", Type: "boolean", Description: "As for /play"},
+				{Name: "least_played", Type: "boolean", Description: "As for /play"},
+				{Name: "duration", Type: "string", Description: "As for /play"},
+				{Name: "volume", Type: "integer", Description: "As for /play"},
+				{Name: "strict_metadata", Type: "boolean", Description: "As for /play"},
+				{Name: "confirm", Type: "boolean", Description: "As for /play"},
+				{Name: "on_end", Type: "string", Description: "As for /play"},
+				{Name: "fadein", Type: "string", Description: "As for /play"},
+				{Name: "target_volume", Type: "integer", Description: "As for /play"},
+				{Name: "wait", Type: "string", Description: "As for /play"},
+			},
+			Response: ResolveResponse{},
+		},
+		{
+			Pattern:  "/api/v1/preset/{name}",
+			Handler:  HandlePresetRequest,
+			Methods:  getOrPost,
+			Summary:  "Play a named preset from the settings file",
+			Params:   []apiParam{{Name: "name", Type: "string", Required: true, Description: "Preset name"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/stats/presets",
+			Handler:  HandlePresetStatsRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Per-preset success rate, failure reasons, and start latency since the server started",
+			Response: PresetStatsResponse{},
+		},
+		{
+			Pattern:  "/api/v1/stats/spotify",
+			Handler:  HandleSpotifyStatsRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Per-operation Spotify API call counts, outcomes, retries, and latency since the server started",
+			Response: SpotifyStatsResponse{},
+		},
+		{
+			Pattern:  "/api/v1/fallbacks",
+			Handler:  HandleFallbacksRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Recent plays that landed on another device than requested, newest first",
+			Response: FallbacksResponse{},
+		},
+		{
+			Pattern:  "/api/v1/digest",
+			Handler:  HandleDigestRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Tracks others added to shared playlists since the last digest",
+			Params:   []apiParam{{Name: "since", Type: "string", Description: "RFC 3339 time, or a duration like 48h; defaults to the last scheduled digest"}},
+			Response: DigestResponse{},
+		},
+		{
+			Pattern: "/api/v1/schedules",
+			Handler: HandleSchedulesRequest,
+			Methods: []string{http.MethodGet, http.MethodPost},
+			Summary: "List schedules with their next run (GET), or add one that plays or pauses on a cron expression (POST)",
+			Params: []apiParam{
+				{Name: "cron", Type: "string", Description: "POST: five-field cron expression in server local time, like \"30 6 * * mon-fri\" (this or when is required)"},
+				{Name: "when", Type: "string", Description: "POST: the friendly form, like \"weekdays at 6:30\" or \"sat,sun 9am\", or a solar time like \"daily at sunset-15m\" (needs SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE)"},
+				{Name: "action", Type: "string", Description: "POST (required): play or pause"},
+				{Name: "playlist", Type: "string", Description: "POST: playlist name, URI, or ID; required for play unless playlists covers every day"},
+				{Name: "playlists", Type: "string", Description: "POST: playlists by day, like \"weekdays=Wake Up;sat,sun=Lazy Sunday\"; the key naming the fewest days wins"},
+				{Name: "device", Type: "string", Description: "POST: device to play on or pause; defaults to the active device"},
+				{Name: "shuffle", Type: "boolean", Description: "POST: shuffle when playing"},
+				{Name: "volume", Type: "integer", Description: "POST: volume (0-100) to play at, or to start a fadein from"},
+				{Name: "fadein", Type: "string", Description: "POST: raise the volume to target_volume over this long, like 10m, as for /play"},
+				{Name: "target_volume", Type: "integer", Description: "POST: volume (0-100) a fadein ends at"},
+				{Name: "name", Type: "string", Description: "POST: label for logs"},
+				{Name: "account", Type: "string", Description: "POST: named account to use"},
+				{Name: "timezone", Type: "string", Description: "POST: IANA timezone the times are in, like America/New_York; defaults to SCHEDULE_TIMEZONE or the server's zone"},
+				{Name: "disabled", Type: "boolean", Description: "POST: keep the schedule without running it"},
+			},
+			Response: SchedulesResponse{},
+		},
+		{
+			Pattern: "/api/v1/schedules/{id}",
+			Handler: HandleScheduleRequest,
+			Methods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+			Summary: "Get a schedule (GET), change the fields given of one added over the API (POST), or remove it (DELETE)",
+			Params: []apiParam{
+				{Name: "id", Type: "string", Required: true, Description: "Schedule ID"},
+				{Name: "cron", Type: "string", Description: "POST: as for /schedules; replaces when"},
+				{Name: "when", Type: "string", Description: "POST: as for /schedules; replaces cron"},
+				{Name: "action", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "playlist", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "playlists", Type: "string", Description: "POST: as for /schedules; empty clears them"},
+				{Name: "device", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "shuffle", Type: "boolean", Description: "POST: as for /schedules"},
+				{Name: "volume", Type: "integer", Description: "POST: as for /schedules; empty clears it"},
+				{Name: "fadein", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "target_volume", Type: "integer", Description: "POST: as for /schedules; empty clears it"},
+				{Name: "name", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "account", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "timezone", Type: "string", Description: "POST: as for /schedules"},
+				{Name: "disabled", Type: "boolean", Description: "POST: true turns the schedule off, false back on"},
+			},
+			Response: SchedulesResponse{},
+		},
+		{
+			Pattern:  "/api/v1/jobs",
+			Handler:  HandleJobsRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Delayed plays waiting to run, soonest first",
+			Response: JobsResponse{},
+		},
+		{
+			Pattern:  "/api/v1/jobs/{id}",
+			Handler:  HandleJobRequest,
+			Methods:  []string{http.MethodDelete},
+			Summary:  "Cancel a delayed play before it runs",
+			Params:   []apiParam{{Name: "id", Type: "string", Required: true, Description: "Job ID from /play's job_id"}},
+			Response: JobsResponse{},
+		},
+		{
+			Pattern:  "/api/v1/reload",
+			Handler:  HandleReloadRequest,
+			Methods:  []string{http.MethodPost},
+			Summary:  "Re-read the settings file and API access token without restarting; a config that doesn't check out changes nothing",
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/history",
+			Handler:  HandleHistoryRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Play history, most recently played first, with audio features",
+			Params:   []apiParam{{Name: "limit", Type: "integer", Description: "Most tracks to return (default 50)"}},
+			Response: HistoryResponse{},
+		},
+		{
+			Pattern:  "/api/v1/context",
+			Handler:  HandleContextRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Now playing, devices, presets, volume schedules, and quiet-hours state in one call",
+			Response: ContextResponse{},
+		},
+		{
+			Pattern:  "/api/v1/shortcut/summary",
+			Handler:  HandleShortcutSummaryRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "What's playing as a flat dictionary for Siri Shortcuts: track, artist, playlist, device, is_playing, and a summary sentence",
+			Response: ShortcutSummary{},
+		},
+		{
+			Pattern: "/api/v1/pause",
+			Handler: HandlePauseRequest,
+			Methods: getOrPost,
+			Summary: "Pause current playback",
+			Params: []apiParam{
+				{Name: "device", Type: "string", Description: "Device name, ID, or group; pauses only that device (or the group's members) if it's playing, leaving other devices alone"},
+			},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/next",
+			Handler:  HandleNextRequest,
+			Methods:  getOrPost,
+			Summary:  "Skip to the next track",
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/seek",
+			Handler:  HandleSeekRequest,
+			Methods:  getOrPost,
+			Summary:  "Seek within the current track",
+			Params:   []apiParam{{Name: "position", Type: "integer", Required: true, Description: "Position in milliseconds"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/stop",
+			Handler:  HandleStopRequest,
+			Methods:  getOrPost,
+			Summary:  "Stop playback: pause, rewind, and optionally move the session",
+			Params:   []apiParam{{Name: "transfer", Type: "string", Description: "Device to move the stopped session to"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/resume-last",
+			Handler:  HandleResumeLastRequest,
+			Methods:  getOrPost,
+			Summary:  "Resume the most recent listening context at its saved position",
+			Params:   []apiParam{{Name: "device", Type: "string", Description: "Device to resume on (default: active or first)"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/queue/add",
+			Handler:  HandleQueueAddRequest,
+			Methods:  getOrPost,
+			Summary:  "Add a track or episode to the queue",
+			Params:   []apiParam{{Name: "uri", Type: "string", Required: true, Description: "spotify:track:/spotify:episode: URI, open.spotify.com or spotify.link link, or track ID"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/volume",
+			Handler:  HandleVolumeRequest,
+			Methods:  getOrPost,
+			Summary:  "Set playback volume",
+			Params: []apiParam{
+				{Name: "level", Type: "integer", Required: true, Description: "Volume 0-100"},
+				{Name: "device", Type: "string", Description: "Device name or ID, or a device group to set on every member; defaults to the active device"},
+			},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/devices",
+			Handler:  HandleDevicesRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Spotify Connect devices linked to the account",
+			Params: []apiParam{
+				{Name: "type", Type: "string", Description: "Only devices of these types, comma-separated, like Speaker,TV"},
+				{Name: "active", Type: "boolean", Description: "Only the active device (true) or only inactive ones (false)"},
+				{Name: "name", Type: "string", Description: "Only devices whose name contains this, ignoring case"},
+				{Name: "sort", Type: "string", Description: "Order by name, type, active (active first), or volume (loudest first); Spotify's order by default"},
+			},
+			Response: DevicesResponse{},
+			Cache:    CacheDevices,
+		},
+		{
+			Pattern:  "/api/v1/groups",
+			Handler:  HandleGroupsRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Device groups from the settings file, with which members are online",
+			Response: GroupsResponse{},
+		},
+		{
+			Pattern:  "/api/v1/devices/registry",
+			Handler:  HandleDeviceRegistryRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Registered devices with stable IDs and Spotify ID history",
+			Response: DeviceRegistryResponse{},
+			Cache:    CacheDevices,
+		},
+		{
+			Pattern:  "/api/v1/devices/register",
+			Handler:  HandleRegisterDevicesRequest,
+			Methods:  getOrPost,
+			Summary:  "Register every device Spotify currently reports",
+			Response: DeviceRegistryResponse{},
+		},
+		{
+			Pattern:  "/api/v1/lan-devices",
+			Handler:  HandleLANDevicesRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Spotify Connect devices discovered on the LAN via mDNS",
+			Response: LANDevicesResponse{},
+			Cache:    CacheDevices,
+		},
+		{
+			Pattern:  "/api/v1/wake",
+			Handler:  HandleWakeRequest,
+			Methods:  getOrPost,
+			Summary:  "Claim a LAN device for this account via zeroconf",
+			Params:   []apiParam{{Name: "device", Type: "string", Required: true, Description: "Device name"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern: "/api/v1/playlists",
+			Handler: HandlePlaylistsRequest,
+			Methods: []string{http.MethodGet, http.MethodPost},
+			Summary: "Playlists owned or followed by the user (GET), or create one (POST)",
+			Params: []apiParam{
+				{Name: "q", Type: "string", Description: "GET: keep playlists whose name contains this, ignoring case"},
+				{Name: "limit", Type: "integer", Description: "GET: most playlists to return (default all)"},
+				{Name: "offset", Type: "integer", Description: "GET: 0-based index of the first playlist to return"},
+				{Name: "name", Type: "string", Description: "POST: name of the new playlist (required)"},
+				{Name: "description", Type: "string", Description: "POST: description of the new playlist"},
+				{Name: "public", Type: "boolean", Description: "POST: show the new playlist on the user's profile"},
+				{Name: "collaborative", Type: "boolean", Description: "POST: let others edit the new playlist; can't be combined with public"},
+			},
+			Response: PlaylistsResponse{},
+			Cache:    CachePlaylists,
+		},
+		{
+			Pattern: "/api/v1/search",
+			Handler: HandleSearchRequest,
+			Methods: []string{http.MethodGet},
+			Summary: "Search Spotify's catalog",
+			Params: []apiParam{
+				{Name: "q", Type: "string", Required: true, Description: "Search query"},
+				{Name: "type", Type: "string", Description: "Comma-separated types to search: playlist, album, artist, track, show, episode (default playlist,album,artist,track)"},
+				{Name: "limit", Type: "integer", Description: "Results per type, 1-50 (default 5)"},
+			},
+			Response: SearchResponse{},
+		},
+		{
+			Pattern:  "/api/v1/playlists/follow",
+			Handler:  HandleFollowPlaylistRequest,
+			Methods:  getOrPost,
+			Summary:  "Add a playlist to the user's library",
+			Params: []apiParam{
+				{Name: "playlist", Type: "string", Required: true, Description: "Playlist URI, open.spotify.com or spotify.link link, or ID"},
+				{Name: "public", Type: "boolean", Description: "Show the playlist on the user's profile"},
+			},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/playlists/unfollow",
+			Handler:  HandleUnfollowPlaylistRequest,
+			Methods:  getOrPost,
+			Summary:  "Remove a playlist from the user's library",
+			Params:   []apiParam{{Name: "playlist", Type: "string", Required: true, Description: "Playlist name, URI, link, or ID"}},
+			Response: APIResponse{},
+		},
+		{
+			Pattern: "/api/v1/playlists/{id}/tracks",
+			Handler: HandlePlaylistTracksRequest,
+			Methods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+			Summary: "List a playlist's tracks (GET), add tracks to a playlist the user owns or collaborates on (POST), or remove them (DELETE)",
+			Params: []apiParam{
+				{Name: "id", Type: "string", Required: true, Description: "Playlist name, URI, or ID"},
+				{Name: "uris", Type: "string", Description: "POST/DELETE (required): comma-separated track URIs, links, or IDs; \"current\" is the track playing now. DELETE also takes 1-based positions. A GET with uris adds"},
+				{Name: "limit", Type: "integer", Description: "GET: most tracks to return (default 100)"},
+				{Name: "offset", Type: "integer", Description: "GET: 0-based position of the first track to return"},
+				{Name: "dry_run", Type: "boolean", Description: "DELETE: list what would be removed without removing it"},
+			},
+			Response: PlaylistTracksResponse{},
+		},
+		{
+			Pattern:  "/api/v1/lyrics/current",
+			Handler:  HandleCurrentLyricsRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Lyrics for the track playing now",
+			Response: LyricsResponse{},
+		},
+		{
+			Pattern: "/api/v1/handoff",
+			Handler: HandleHandoffRequest,
+			Methods: getOrPost,
+			Summary: "Hand current playback to a peer instance",
+			Params: []apiParam{
+				{Name: "to", Type: "string", Required: true, Description: "Peer name or URL from the settings file"},
+				{Name: "device", Type: "string", Description: "Device on the peer to resume on"},
+			},
+			Response: APIResponse{},
+		},
+		{
+			Pattern: "/api/v1/handoff/receive",
+			Handler: HandleHandoffReceiveRequest,
+			Methods: []string{http.MethodPost},
+			Summary: "Peer-to-peer half of a handoff: resume a playback snapshot here",
+			Params: []apiParam{
+				{Name: "track_uri", Type: "string", Required: true, Description: "Track to resume"},
+				{Name: "context_uri", Type: "string", Description: "Playlist or album to resume within"},
+				{Name: "position_ms", Type: "integer", Description: "Position in the track"},
+				{Name: "shuffle", Type: "boolean", Description: "Enable shuffle after resuming"},
+				{Name: "device", Type: "string", Description: "Device to resume on"},
+			},
+			Response: APIResponse{},
+		},
+		{
+			Pattern:  "/api/v1/status-page",
+			Handler:  HandlePublicStatusRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "Public now-playing status (track, artists, album, artwork), cached and rate limited; needs STATUS_PAGE=true",
+			Response: PublicStatus{},
+			Public:   true,
+		},
+		{
+			Pattern:  "/api/v1/openapi.json",
+			Handler:  HandleOpenAPIRequest,
+			Methods:  []string{http.MethodGet},
+			Summary:  "This OpenAPI document",
+			Response: map[string]any{},
+			Cache:    CacheSpec,
+			Public:   true,
+		},
+	}
+}
+
+// registerAPIRoutes adds every route in the table to mux.
+func registerAPIRoutes(mux *http.ServeMux) {
+	for _, rt := range apiRoutes() {
+		handler := rt.Handler
+		if rt.Cache != "" {
+			handler = withCaching(rt.Cache, handler)
+		}
+		if rt.accountScoped() {
+			handler = withAccount(handler)
+		}
+		if strings.HasPrefix(rt.Pattern, "/api/v1/") && !rt.Stream {
+			handler = withTextFormat(handler)
+		}
+		mux.HandleFunc(rt.Pattern, handler)
+	}
+}
+
+// printAPIRoutes writes the startup endpoint listing, one line per route,
+// e.g. "GET /api/v1/seek?position=<integer>".
+func printAPIRoutes() {
+	fmt.Println("Endpoints:")
+	for _, rt := range apiRoutes() {
+		var query []string
+		for _, p := range rt.Params {
+			if strings.Contains(rt.Pattern, "{"+p.Name+"}") {
+				continue
+			}
+			query = append(query, p.Name+"=<"+p.Type+">")
+		}
+
+		line := "  " + strings.Join(rt.Methods, "|") + " " + rt.Pattern
+		if len(query) > 0 {
+			line += "?" + strings.Join(query, "&")
+		}
+		fmt.Println(line)
+	}
+	fmt.Println("Control endpoints accept POST with the same parameters as a JSON body.")
+	fmt.Println("API endpoints take account=<name> to use a named Spotify account.")
+	fmt.Println("Add format=text to the query string for a one-line plain-text answer instead of JSON.")
+	fmt.Println("Docs: /docs (OpenAPI at /api/v1/openapi.json)")
+}
@@ -0,0 +1,301 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Playback rules. A rule in the settings file pairs a
+// condition on playback events ("track_changed and artist = 'Norah
+// Jones'") with actions (set the volume, pause, run a preset). The rule
+// runner is one more consumer of the event bus, so rules never poll
+// Spotify themselves.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+)
+
+// Rule runs its actions whenever a playback event matches When. At least
+// one action must be set; they run in the order volume, pause, preset.
+type Rule struct {
+	// Name identifies the rule in logs; the condition is used when empty.
+	Name string `json:"name,omitempty"`
+	// When is the condition, like `track_changed and artist = "Norah
+	// Jones"` (see parseCondition).
+	When string `json:"when"`
+	// Volume sets the volume (0-100) on the device that's playing.
+	Volume *int `json:"volume,omitempty"`
+	// Pause pauses playback.
+	Pause bool `json:"pause,omitempty"`
+	// Preset plays a preset from the settings file.
+	Preset string `json:"preset,omitempty"`
+}
+
+// ruleEvents are the playback event types a condition can name. State
+// events only restate what's already known, so no rule fires on them.
+var ruleEvents = []string{EventTrackChanged, EventPaused, EventResumed, EventDeviceChanged, EventVolumeChanged, EventContextEnded}
+
+// ruleFields are the event fields a condition can test.
+var ruleFields = []string{"artist", "track", "album", "context", "device", "account"}
+
+// ruleClause is one `<field> <op> <value>` test.
+type ruleClause struct {
+	field, op, value string
+}
+
+// ruleCondition is a parsed When: an event type and clauses that must all
+// hold.
+type ruleCondition struct {
+	event   string
+	clauses []ruleClause
+}
+
+// parseCondition parses a rule condition: an event type followed by any
+// number of `and <field> <op> <value>` clauses. Fields are artist, track,
+// album, context, device, and account; ops are = and != (whole value) and
+// ~ and !~ (contains), all ignoring case. Values are bare words or quoted
+// with " or '. A context value can be a Spotify link, matched by its URI.
+func parseCondition(s string) (ruleCondition, error) {
+	var cond ruleCondition
+	tokens, err := conditionTokens(s)
+	if err != nil {
+		return cond, err
+	}
+	if len(tokens) == 0 {
+		return cond, fmt.Errorf("empty condition")
+	}
+
+	cond.event = strings.ToLower(tokens[0])
+	if !slices.Contains(ruleEvents, cond.event) {
+		return cond, fmt.Errorf("%q is not an event (use %s)", tokens[0], strings.Join(ruleEvents, ", "))
+	}
+	for rest := tokens[1:]; len(rest) > 0; rest = rest[4:] {
+		if len(rest) < 4 || !strings.EqualFold(rest[0], "and") {
+			return cond, fmt.Errorf("expected `and <field> <op> <value>` after %q", strings.Join(tokens[:len(tokens)-len(rest)], " "))
+		}
+		c := ruleClause{field: strings.ToLower(rest[1]), op: rest[2], value: rest[3]}
+		if !slices.Contains(ruleFields, c.field) {
+			return cond, fmt.Errorf("%q is not a field (use %s)", rest[1], strings.Join(ruleFields, ", "))
+		}
+		if !slices.Contains([]string{"=", "!=", "~", "!~"}, c.op) {
+			return cond, fmt.Errorf("%q is not an operator (use =, !=, ~, or !~)", c.op)
+		}
+		if c.field == "context" {
+			if r, err := spotifyuri.Parse(c.value); err == nil && r.URI() != "" {
+				c.value = r.URI()
+			}
+		}
+		cond.clauses = append(cond.clauses, c)
+	}
+	return cond, nil
+}
+
+// conditionTokens splits a condition into words, operators, and quoted
+// strings (returned without their quotes). Operators needn't be spaced,
+// as in artist=Adele.
+func conditionTokens(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", s)
+			}
+			tokens = append(tokens, s[i+1:i+1+end])
+			i += end + 2
+		case c == '=' || c == '~':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '!' && i+1 < len(s) && (s[i+1] == '=' || s[i+1] == '~'):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		default:
+			// A value runs to the next space, so links with ?a=b survive.
+			stop := "=~!\"'"
+			if n := len(tokens); n > 0 && slices.Contains([]string{"=", "!=", "~", "!~"}, tokens[n-1]) {
+				stop = ""
+			}
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune(stop, rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q in %q", c, s)
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// matches reports whether `ev`, seen on `account`, satisfies the
+// condition.
+func (c ruleCondition) matches(account string, ev PlaybackEvent) bool {
+	if ev.Type != c.event {
+		return false
+	}
+	for _, cl := range c.clauses {
+		if !cl.matches(account, ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches applies the clause to the event's field. Artist tests each of
+// the track's artists, so `artist = X` holds for a collaboration too.
+func (cl ruleClause) matches(account string, ev PlaybackEvent) bool {
+	var values []string
+	switch cl.field {
+	case "artist":
+		values = strings.Split(ev.Artists, ", ")
+	case "track":
+		values = []string{ev.TrackName}
+	case "album":
+		values = []string{ev.Album}
+	case "context":
+		values = []string{ev.ContextURI}
+	case "device":
+		values = []string{ev.DeviceName, ev.DeviceID}
+	case "account":
+		values = []string{account}
+	}
+
+	want := strings.ToLower(cl.value)
+	found := slices.ContainsFunc(values, func(v string) bool {
+		v = strings.ToLower(v)
+		if cl.op == "~" || cl.op == "!~" {
+			return strings.Contains(v, want)
+		}
+		return v == want
+	})
+	if strings.HasPrefix(cl.op, "!") {
+		return !found
+	}
+	return found
+}
+
+// compiledRule is a rule with its condition parsed.
+type compiledRule struct {
+	Rule
+	cond ruleCondition
+}
+
+// name identifies the rule in logs.
+func (r compiledRule) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.When
+}
+
+// compileRules parses every rule's condition and checks its actions,
+// looking presets up in `s`.
+func compileRules(rules []Rule, s *Settings) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, r := range rules {
+		label := r.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		cond, err := parseCondition(r.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", label, err)
+		}
+		if r.Volume == nil && !r.Pause && r.Preset == "" {
+			return nil, fmt.Errorf("rule %s: no action (set volume, pause, or preset)", label)
+		}
+		if r.Volume != nil && (*r.Volume < 0 || *r.Volume > 100) {
+			return nil, fmt.Errorf("rule %s: volume must be between 0 and 100, got %d", label, *r.Volume)
+		}
+		if r.Preset != "" {
+			if _, ok := s.FindPreset(r.Preset); !ok {
+				return nil, fmt.Errorf("rule %s: unknown preset %q", label, r.Preset)
+			}
+		}
+		compiled = append(compiled, compiledRule{Rule: r, cond: cond})
+	}
+	return compiled, nil
+}
+
+// StartRules checks the settings file's rules and, if there are any,
+// runs them against playback events until ctx is done. An invalid rule
+// is an error and starts nothing.
+func StartRules(ctx context.Context) error {
+	s := GetSettings()
+	rules, err := compileRules(s.Rules, s)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	events, unsubscribe := SubscribeEvents(TopicPlayback)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if pe, ok := ev.Data.(PlaybackEvent); ok {
+					runRules(ctx, rules, ev.Account, pe)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// runRules runs the actions of every rule `ev` matches, in settings-file
+// order.
+func runRules(ctx context.Context, rules []compiledRule, account string, ev PlaybackEvent) {
+	for _, r := range rules {
+		if !r.cond.matches(account, ev) {
+			continue
+		}
+		if err := r.run(ctx, account, ev); err != nil {
+			log.Printf("rule %s (%s): %v", r.name(), account, err)
+			continue
+		}
+		log.Printf("rule %s (%s): ran on %s", r.name(), account, ev.Type)
+	}
+}
+
+// run performs the rule's actions. Volume and pause act on the account
+// the event came from; a preset plays as it would from /preset.
+func (r compiledRule) run(ctx context.Context, account string, ev PlaybackEvent) error {
+	actx := WithAccount(ctx, account)
+	if r.Volume != nil {
+		if _, err := SetVolume(actx, *r.Volume, ev.DeviceID); err != nil {
+			return err
+		}
+	}
+	if r.Pause {
+		if _, err := PausePlayback(actx); err != nil {
+			return err
+		}
+	}
+	if r.Preset != "" {
+		if _, err := PlayPreset(withPlaySource(ctx, "rule "+r.name()), r.Preset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,708 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Scheduled playback. A schedule pairs a cron expression
+// with an action — play a playlist on a device, with shuffle and volume,
+// or pause — so server mode can start the morning music on its own
+// instead of cron and curl. Schedules come from the settings file or are
+// added over the API; API schedules are kept in their own file so they
+// survive restarts. The CLI lists them with -schedules and turns API
+// ones on and off in that file, which a running server picks up.
+//
+
+package spotify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// DefaultSchedulesFile is the name of the file API-created schedules are
+// kept in, next to the token file, unless SPOTIFY_SCHEDULES_FILE says
+// otherwise (see schedulestore.go).
+const DefaultSchedulesFile = ".spotify_schedules.json"
+
+// Schedule actions.
+const (
+	ScheduleActionPlay  = "play"
+	ScheduleActionPause = "pause"
+)
+
+// Schedule sources.
+const (
+	ScheduleFromSettings = "settings"
+	ScheduleFromAPI      = "api"
+)
+
+// Schedule runs an action whenever its cron expression matches, in local
+// time.
+type Schedule struct {
+	// ID identifies the schedule in the API. Settings-file schedules
+	// without one get "settings-<n>".
+	ID string `json:"id,omitempty"`
+	// Name labels the schedule in logs.
+	Name string `json:"name,omitempty"`
+	// Cron is a five-field cron expression, like "30 6 * * mon-fri".
+	Cron string `json:"cron,omitempty"`
+	// When says the same thing the friendly way, like "weekdays at 6:30",
+	// or ties it to the sun, like "daily at sunset" (see compileWhen). A
+	// schedule has Cron or When, not both.
+	When string `json:"when,omitempty"`
+	// Action is play or pause.
+	Action string `json:"action"`
+	// Playlist is what play plays: a name, URI, link, or ID.
+	Playlist string `json:"playlist,omitempty"`
+	// Playlists picks the playlist by day, keyed by days like "weekdays",
+	// "sat,sun", or "fri"; the key naming the fewest days wins, and
+	// Playlist covers days no key names.
+	Playlists map[string]string `json:"playlists,omitempty"`
+	// Device is where to play, or what to pause; empty means the active
+	// device.
+	Device  string `json:"device,omitempty"`
+	Shuffle bool   `json:"shuffle,omitempty"`
+	// Volume, when set, is applied once playback starts.
+	Volume *int `json:"volume,omitempty"`
+	// FadeIn and TargetVolume make play an alarm that fades up, as for
+	// /play.
+	FadeIn       string `json:"fadein,omitempty"`
+	TargetVolume *int   `json:"target_volume,omitempty"`
+	// Account names the Spotify account to use.
+	Account string `json:"account,omitempty"`
+	// Timezone is the IANA zone, like "America/New_York", the schedule's
+	// times are in. Empty uses SCHEDULE_TIMEZONE, or the server's local
+	// zone (TZ).
+	Timezone string `json:"timezone,omitempty"`
+	// Disabled keeps the schedule without running it.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// ScheduleInfo is a schedule as the API lists it.
+type ScheduleInfo struct {
+	Schedule
+	Source  string     `json:"source"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	// NextPlaylist is what the next run plays.
+	NextPlaylist string     `json:"next_playlist,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// scheduled is a checked schedule with its parsed expression and last
+// run.
+type scheduled struct {
+	Schedule
+	spec cronSpec
+	loc  *time.Location
+	// dayPlaylists is the playlist for each weekday, Sunday first.
+	dayPlaylists [7]string
+	source       string
+	lastRun      time.Time
+	lastErr      string
+}
+
+// Scheduler holds the schedules and runs them once a minute.
+type Scheduler struct {
+	mu    sync.Mutex
+	path  string
+	items []*scheduled
+	// modTime is the schedules file's as last read or written, so changes
+	// from another process (the CLI) can be picked up.
+	modTime time.Time
+	now     func() time.Time
+	// run performs a schedule's action; a field so tests can watch.
+	run func(ctx context.Context, s Schedule) (string, error)
+}
+
+// scheduler is the process-wide scheduler; nil means scheduling is off.
+var scheduler *Scheduler
+
+// scheduleLocation is the zone of schedules that don't name one.
+var scheduleLocation = time.Local
+
+// SetScheduleTimezone sets the zone of schedules that don't name one, by
+// IANA name. Empty keeps the server's local zone.
+func SetScheduleTimezone(name string) error {
+	if name == "" {
+		scheduleLocation = time.Local
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("unknown timezone %q", name)
+	}
+	scheduleLocation = loc
+	return nil
+}
+
+// SetScheduler sets the scheduler the API manages.
+func SetScheduler(s *Scheduler) {
+	scheduler = s
+}
+
+// OpenScheduler loads the settings file's schedules and the API-created
+// ones saved at `path` (a missing file has none). An invalid schedule in
+// either is an error.
+func OpenScheduler(path string) (*Scheduler, error) {
+	items, err := compileSettingsSchedules(GetSettings().Schedules)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{path: path, items: items, now: time.Now, run: runSchedule}
+
+	saved, version, original, err := loadSchedules(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, sch := range saved {
+		item, err := compileSchedule(sch, ScheduleFromAPI)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		s.items = append(s.items, item)
+	}
+	if fi, err := os.Stat(path); err == nil {
+		s.modTime = fi.ModTime()
+	}
+
+	if version < schedulesFileVersion {
+		backup := fmt.Sprintf("%s.v%d.bak", path, version)
+		if err := os.WriteFile(backup, original, 0600); err != nil {
+			return nil, fmt.Errorf("back up schedules before migrating: %w", err)
+		}
+		if err := s.save(); err != nil {
+			return nil, fmt.Errorf("save migrated schedules: %w", err)
+		}
+		log.Printf("Migrated %s from version %d to %d; the original is in %s", path, version, schedulesFileVersion, backup)
+	}
+	return s, nil
+}
+
+// compileSettingsSchedules compiles the settings file's schedules,
+// numbering the ones without an ID.
+func compileSettingsSchedules(list []Schedule) ([]*scheduled, error) {
+	var items []*scheduled
+	for i, sch := range list {
+		if sch.ID == "" {
+			sch.ID = fmt.Sprintf("settings-%d", i+1)
+		}
+		item, err := compileSchedule(sch, ScheduleFromSettings)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// replaceSettingsSchedules swaps in freshly compiled settings-file
+// schedules, keeping the last run of any whose ID carries over. The
+// API-created ones are left alone.
+func (s *Scheduler) replaceSettingsSchedules(fresh []*scheduled) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := map[string]*scheduled{}
+	var api []*scheduled
+	for _, item := range s.items {
+		if item.source == ScheduleFromSettings {
+			previous[item.ID] = item
+		} else {
+			api = append(api, item)
+		}
+	}
+	for _, item := range fresh {
+		if prev := previous[item.ID]; prev != nil {
+			item.lastRun, item.lastErr = prev.lastRun, prev.lastErr
+		}
+	}
+	s.items = append(fresh, api...)
+}
+
+// compileSchedule checks a schedule and parses its expression.
+func compileSchedule(s Schedule, source string) (*scheduled, error) {
+	label := s.Name
+	if label == "" {
+		label = s.ID
+	}
+	var spec cronSpec
+	var err error
+	switch {
+	case s.Cron != "" && s.When != "":
+		return nil, fmt.Errorf("schedule %s: give cron or when, not both", label)
+	case s.When != "":
+		spec, err = compileWhen(s.When, scheduleCoordinates)
+	default:
+		spec, err = parseCron(s.Cron)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schedule %s: %w", label, err)
+	}
+	item := &scheduled{spec: spec, loc: scheduleLocation, source: source}
+	if s.Timezone != "" {
+		if item.loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("schedule %s: unknown timezone %q", label, s.Timezone)
+		}
+	}
+	if err := item.setDayPlaylists(s); err != nil {
+		return nil, fmt.Errorf("schedule %s: %w", label, err)
+	}
+
+	s.Action = strings.ToLower(strings.TrimSpace(s.Action))
+	switch s.Action {
+	case ScheduleActionPlay:
+		// A day-of-month field can pick any weekday.
+		for d, on := range spec.dow[:7] {
+			if (on || !spec.domAny) && item.dayPlaylists[d] == "" {
+				return nil, fmt.Errorf("schedule %s: play needs a playlist for %s", label, time.Weekday(d))
+			}
+		}
+	case ScheduleActionPause:
+		if s.Playlist != "" || len(s.Playlists) > 0 || s.Shuffle || s.Volume != nil || s.FadeIn != "" || s.TargetVolume != nil {
+			return nil, fmt.Errorf("schedule %s: pause takes only a device", label)
+		}
+	default:
+		return nil, fmt.Errorf("schedule %s: action must be %s or %s, got %q", label, ScheduleActionPlay, ScheduleActionPause, s.Action)
+	}
+	if s.Volume != nil && (*s.Volume < 0 || *s.Volume > 100) {
+		return nil, fmt.Errorf("schedule %s: volume must be between 0 and 100", label)
+	}
+	if err := s.playRequest().validateFadeIn(); err != nil {
+		return nil, fmt.Errorf("schedule %s: %w", label, err)
+	}
+	item.Schedule = s
+	return item, nil
+}
+
+// setDayPlaylists fills in the playlist for each weekday from
+// s.Playlists, falling back to s.Playlist. Two keys naming the same
+// number of days can't both cover a day.
+func (item *scheduled) setDayPlaylists(s Schedule) error {
+	var width [7]int
+	var from [7]string
+	for key, playlist := range s.Playlists {
+		days, err := parseDays(key)
+		if err != nil {
+			return fmt.Errorf("playlists: %w", err)
+		}
+		if strings.TrimSpace(playlist) == "" {
+			return fmt.Errorf("playlists: no playlist for %q", key)
+		}
+		n := 0
+		for _, on := range days {
+			if on {
+				n++
+			}
+		}
+		for d, on := range days {
+			switch {
+			case !on:
+			case width[d] == 0 || n < width[d]:
+				item.dayPlaylists[d], width[d], from[d] = playlist, n, key
+			case n == width[d]:
+				return fmt.Errorf("playlists: %q and %q both cover %s", from[d], key, time.Weekday(d))
+			}
+		}
+	}
+	for d := range item.dayPlaylists {
+		if item.dayPlaylists[d] == "" {
+			item.dayPlaylists[d] = s.Playlist
+		}
+	}
+	return nil
+}
+
+// playlistFor returns the playlist a run at `at` plays.
+func (item *scheduled) playlistFor(at time.Time) string {
+	return item.dayPlaylists[at.In(item.loc).Weekday()]
+}
+
+// info describes `item` as the API lists it, as of `now`.
+func (item *scheduled) info(now time.Time) ScheduleInfo {
+	info := ScheduleInfo{Schedule: item.Schedule, Source: item.source, LastError: item.lastErr}
+	if next, ok := item.spec.next(now.In(item.loc)); ok && !item.Disabled {
+		info.NextRun = &next
+		if item.Action == ScheduleActionPlay {
+			info.NextPlaylist = item.playlistFor(next)
+		}
+	}
+	if !item.lastRun.IsZero() {
+		last := item.lastRun
+		info.LastRun = &last
+	}
+	return info
+}
+
+// List returns every schedule, settings-file ones first, with when each
+// runs next.
+func (s *Scheduler) List() []ScheduleInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	out := make([]ScheduleInfo, 0, len(s.items))
+	for _, item := range s.items {
+		out = append(out, item.info(now))
+	}
+	return out
+}
+
+// Get returns the schedule `id`; an unknown ID is CodeNotFound.
+func (s *Scheduler) Get(id string) (ScheduleInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.find(id)
+	if err != nil {
+		return ScheduleInfo{}, err
+	}
+	return s.items[i].info(s.now()), nil
+}
+
+// find returns the index of schedule `id`. The caller holds s.mu.
+func (s *Scheduler) find(id string) (int, error) {
+	for i, item := range s.items {
+		if item.ID == id {
+			return i, nil
+		}
+	}
+	return 0, withCode(CodeNotFound, fmt.Errorf("no schedule %s", id))
+}
+
+// findAPI is find for a change: settings-file schedules are changed in
+// the settings file, so finding one is a CodeBadRequest error. The caller
+// holds s.mu.
+func (s *Scheduler) findAPI(id string) (int, error) {
+	i, err := s.find(id)
+	if err == nil && s.items[i].source != ScheduleFromAPI {
+		return 0, withCode(CodeBadRequest, fmt.Errorf("schedule %s is defined in the settings file; change it there", id))
+	}
+	return i, err
+}
+
+// Add checks `sch`, gives it a new ID, saves it, and returns it as
+// listed. A bad schedule is a CodeBadRequest error.
+func (s *Scheduler) Add(sch Schedule) (ScheduleInfo, error) {
+	id, err := randomID("sch-")
+	if err != nil {
+		return ScheduleInfo{}, err
+	}
+	sch.ID = id
+	item, err := compileSchedule(sch, ScheduleFromAPI)
+	if err != nil {
+		return ScheduleInfo{}, withCode(CodeBadRequest, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, item)
+	if err := s.save(); err != nil {
+		s.items = s.items[:len(s.items)-1]
+		return ScheduleInfo{}, fmt.Errorf("save schedules: %w", err)
+	}
+	return item.info(s.now()), nil
+}
+
+// Update replaces the API-created schedule `id` with `sch`, keeping its
+// ID and last run, saves it, and returns it as listed. Errors are as for
+// Add and Remove.
+func (s *Scheduler) Update(id string, sch Schedule) (ScheduleInfo, error) {
+	sch.ID = id
+	item, err := compileSchedule(sch, ScheduleFromAPI)
+	if err != nil {
+		return ScheduleInfo{}, withCode(CodeBadRequest, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, err := s.findAPI(id)
+	if err != nil {
+		return ScheduleInfo{}, err
+	}
+	old := s.items[i]
+	item.lastRun = old.lastRun
+	s.items[i] = item
+	if err := s.save(); err != nil {
+		s.items[i] = old
+		return ScheduleInfo{}, fmt.Errorf("save schedules: %w", err)
+	}
+	return item.info(s.now()), nil
+}
+
+// SetEnabled turns the API-created schedule `id` on or off, saves it,
+// and returns it as listed. Errors are as for findAPI.
+func (s *Scheduler) SetEnabled(id string, enabled bool) (ScheduleInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.findAPI(id)
+	if err != nil {
+		return ScheduleInfo{}, err
+	}
+	// A running schedule may be reading the old one.
+	old := s.items[i]
+	item := *old
+	item.Disabled = !enabled
+	s.items[i] = &item
+	if err := s.save(); err != nil {
+		s.items[i] = old
+		return ScheduleInfo{}, fmt.Errorf("save schedules: %w", err)
+	}
+	return item.info(s.now()), nil
+}
+
+// Remove deletes the API-created schedule `id`. Errors are as for
+// findAPI.
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.findAPI(id)
+	if err != nil {
+		return err
+	}
+	old := s.items
+	s.items = append(s.items[:i:i], s.items[i+1:]...)
+	if err := s.save(); err != nil {
+		s.items = old
+		return fmt.Errorf("save schedules: %w", err)
+	}
+	return nil
+}
+
+// save writes the API-created schedules. The caller holds s.mu.
+func (s *Scheduler) save() error {
+	var saved []Schedule
+	for _, item := range s.items {
+		if item.source == ScheduleFromAPI {
+			saved = append(saved, item.Schedule)
+		}
+	}
+	if err := writeSchedules(s.path, saved); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(s.path); err == nil {
+		s.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+// reload picks up changes another process, like the CLI's
+// -disable-schedule, made to the schedules file since it was last read
+// or written, keeping each schedule's last run. A file that no longer
+// loads is logged and the current schedules are kept.
+func (s *Scheduler) reload() {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fi.ModTime().Equal(s.modTime) {
+		return
+	}
+	s.modTime = fi.ModTime()
+
+	saved, _, _, err := loadSchedules(s.path)
+	if err != nil {
+		log.Printf("Warning: keeping the current schedules: %v", err)
+		return
+	}
+	previous := map[string]*scheduled{}
+	var items []*scheduled
+	for _, item := range s.items {
+		if item.source == ScheduleFromAPI {
+			previous[item.ID] = item
+		} else {
+			items = append(items, item)
+		}
+	}
+	for _, sch := range saved {
+		item, err := compileSchedule(sch, ScheduleFromAPI)
+		if err != nil {
+			log.Printf("Warning: keeping the current schedules: %s: %v", s.path, err)
+			return
+		}
+		if prev := previous[sch.ID]; prev != nil {
+			item.lastRun, item.lastErr = prev.lastRun, prev.lastErr
+		}
+		items = append(items, item)
+	}
+	s.items = items
+	log.Printf("Reloaded schedules from %s", s.path)
+}
+
+// randomID returns a random ID with `prefix`, like "sch-1a2b3c4d".
+func randomID(prefix string) (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// due returns the schedules that run in minute `at`.
+func (s *Scheduler) due(at time.Time) []*scheduled {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*scheduled
+	for _, item := range s.items {
+		if !item.Disabled && item.spec.dueAt(at, item.loc, item.lastRun) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// runDue runs every schedule due in minute `at`, each in its own
+// goroutine so a slow play doesn't hold up the rest, and waits for them.
+func (s *Scheduler) runDue(ctx context.Context, at time.Time) {
+	var wg sync.WaitGroup
+	for _, item := range s.due(at) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sch := item.Schedule
+			if sch.Action == ScheduleActionPlay {
+				sch.Playlist = item.playlistFor(at)
+			}
+			msg, err := s.run(ctx, sch)
+
+			s.mu.Lock()
+			item.lastRun, item.lastErr = at, ""
+			if err != nil {
+				item.lastErr = err.Error()
+			}
+			s.mu.Unlock()
+
+			label := item.Name
+			if label == "" {
+				label = item.ID
+			}
+			if err != nil {
+				log.Printf("schedule %s: %v", label, err)
+				return
+			}
+			log.Printf("schedule %s: %s", label, msg)
+		}()
+	}
+	wg.Wait()
+}
+
+// StartScheduler runs the scheduler's due schedules at the top of every
+// minute until ctx is done. Minutes missed while the process was down or
+// asleep aren't made up.
+func StartScheduler(ctx context.Context) {
+	s := scheduler
+	if s == nil {
+		return
+	}
+	go func() {
+		for {
+			now := s.now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			s.reload()
+			if spotifyClient == nil {
+				continue
+			}
+			go s.runDue(ctx, next)
+		}
+	}()
+}
+
+// runSchedule performs a schedule's action on its account.
+func runSchedule(ctx context.Context, s Schedule) (string, error) {
+	if s.Account != "" {
+		ctx = WithAccount(ctx, s.Account)
+	}
+	label := s.Name
+	if label == "" {
+		label = s.ID
+	}
+	ctx = withPlaySource(ctx, "schedule "+label)
+	if s.Action == ScheduleActionPause {
+		if s.Device != "" {
+			return PauseDevice(ctx, s.Device)
+		}
+		return PausePlayback(ctx)
+	}
+	return Play(ctx, s.playRequest())
+}
+
+// playRequest is the play a play schedule starts.
+func (s Schedule) playRequest() PlayRequest {
+	return PlayRequest{Device: s.Device, Playlist: s.Playlist, Shuffle: s.Shuffle, Volume: s.Volume, FadeIn: s.FadeIn, TargetVolume: s.TargetVolume}
+}
+
+// PrintSchedulesTable lists `schedules` in a table: when each runs next,
+// what it does, and whether it's on.
+func PrintSchedulesTable(schedules []ScheduleInfo) {
+	green := color.New(color.FgGreen, color.Bold)
+
+	printHeading("⏰", "Schedules")
+
+	t := newTable()
+	t.AppendHeader(table.Row{"ID", "Name", "When", "Action", "Playlist", "Device", "Next Run", "Enabled"})
+	enabled := 0
+	for _, sch := range schedules {
+		when := sch.When
+		if when == "" {
+			when = sch.Cron
+		}
+		playlist := sch.NextPlaylist
+		if playlist == "" {
+			playlist = sch.Playlist
+		}
+		device := sch.Device
+		if device == "" {
+			device = color.HiBlackString("active")
+		}
+		next := color.HiBlackString("never")
+		if sch.NextRun != nil {
+			next = sch.NextRun.Format("Mon Jan 2 15:04 MST")
+		}
+		state := color.GreenString(symbol("●", "*") + " Yes")
+		if sch.Disabled {
+			state = color.HiBlackString("No")
+			next = color.HiBlackString(symbol("—", "-"))
+		} else {
+			enabled++
+		}
+		id := sch.ID
+		if sch.Source == ScheduleFromSettings {
+			id += color.HiBlackString(" (settings)")
+		}
+
+		t.AppendRow(table.Row{
+			id,
+			color.New(color.Bold).Sprint(sch.Name),
+			when,
+			sch.Action,
+			playlist,
+			device,
+			next,
+			state,
+		})
+	}
+	t.Render()
+
+	fmt.Println()
+	green.Printf("Total schedules: %d (%d enabled)\n", len(schedules), enabled)
+}
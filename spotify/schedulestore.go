@@ -0,0 +1,143 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: The schedules file. API-created schedules are kept next to
+// the token file as {"version": N, "schedules": [...]}, written atomically
+// so a crash mid-save leaves the old file whole. Files from older builds
+// are migrated up one version at a time when they're opened, and the
+// original is kept beside the new one in case the migration gets it
+// wrong.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// schedulesFileVersion is the version save writes. Bump it, and add a
+// migration from the old version, when the format changes.
+const schedulesFileVersion = 2
+
+// schedulesFile is the schedules file as it's written.
+type schedulesFile struct {
+	Version   int        `json:"version"`
+	Schedules []Schedule `json:"schedules"`
+}
+
+// scheduleMigrations upgrade a schedules file from the version it's keyed
+// by to the next one, as raw JSON so a migration can read fields the
+// current Schedule no longer has.
+var scheduleMigrations = map[int]func([]byte) ([]byte, error){
+	// Version 1 was a bare array of schedules.
+	1: func(data []byte) ([]byte, error) {
+		var schedules []json.RawMessage
+		if err := json.Unmarshal(data, &schedules); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{"version": 2, "schedules": schedules})
+	},
+}
+
+// DefaultSchedulesPath is where the schedules file goes when
+// SPOTIFY_SCHEDULES_FILE isn't set: DefaultSchedulesFile in the token
+// file's directory.
+func DefaultSchedulesPath() string {
+	return filepath.Join(filepath.Dir(GetTokenFile()), DefaultSchedulesFile)
+}
+
+// schedulesVersion reports the version of a schedules file. Version 1
+// files are bare arrays and say nothing.
+func schedulesVersion(data []byte) (int, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return 1, nil
+	}
+	var head struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return 0, err
+	}
+	if head.Version < 1 {
+		return 0, fmt.Errorf("no version")
+	}
+	return head.Version, nil
+}
+
+// loadSchedules reads the schedules file at `path`, migrating it to
+// schedulesFileVersion in memory, and returns its schedules, the version
+// it was read as, and its original contents. A missing file has no
+// schedules and is current. A file from a newer build is an error rather
+// than something to overwrite.
+func loadSchedules(path string) ([]Schedule, int, []byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, schedulesFileVersion, nil, nil
+	}
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("read schedules: %w", err)
+	}
+	from, err := schedulesVersion(data)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("parse schedules %s: %w", path, err)
+	}
+	if from > schedulesFileVersion {
+		return nil, 0, nil, fmt.Errorf("schedules %s is version %d, newer than this build reads (%d)", path, from, schedulesFileVersion)
+	}
+
+	current := data
+	for v := from; v < schedulesFileVersion; v++ {
+		migrate := scheduleMigrations[v]
+		if migrate == nil {
+			return nil, 0, nil, fmt.Errorf("schedules %s: no migration from version %d", path, v)
+		}
+		if current, err = migrate(current); err != nil {
+			return nil, 0, nil, fmt.Errorf("migrate schedules %s from version %d: %w", path, v, err)
+		}
+	}
+
+	var file schedulesFile
+	if err := json.Unmarshal(current, &file); err != nil {
+		return nil, 0, nil, fmt.Errorf("parse schedules %s: %w", path, err)
+	}
+	return file.Schedules, from, data, nil
+}
+
+// writeSchedules writes `schedules` to `path` at schedulesFileVersion: to
+// a temporary file in the same directory, synced, then renamed over the
+// old one.
+func writeSchedules(path string, schedules []Schedule) error {
+	if schedules == nil {
+		schedules = []Schedule{}
+	}
+	data, err := json.MarshalIndent(schedulesFile{Version: schedulesFileVersion, Schedules: schedules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".schedules-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
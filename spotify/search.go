@@ -0,0 +1,175 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Catalog search. Queries Spotify's search API for
+// playlists, albums, artists, tracks, shows, and episodes, for /search
+// and the -search CLI mode. Every hit carries its URI, which any play
+// request takes as-is.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// defaultSearchTypes are searched when a request names none.
+const defaultSearchTypes = "playlist,album,artist,track"
+
+// Search result limits per type: the default, and Spotify's maximum.
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 50
+)
+
+// searchTypes maps the type names a search takes to the library's flags.
+var searchTypes = map[string]spotifyLib.SearchType{
+	"playlist": spotifyLib.SearchTypePlaylist,
+	"album":    spotifyLib.SearchTypeAlbum,
+	"artist":   spotifyLib.SearchTypeArtist,
+	"track":    spotifyLib.SearchTypeTrack,
+	"show":     spotifyLib.SearchTypeShow,
+	"episode":  spotifyLib.SearchTypeEpisode,
+}
+
+// parseSearchTypes parses a comma-separated type list like
+// "playlist,album,track", keeping its order and dropping repeats. Empty
+// means defaultSearchTypes.
+func parseSearchTypes(s string) ([]string, spotifyLib.SearchType, error) {
+	if strings.TrimSpace(s) == "" {
+		s = defaultSearchTypes
+	}
+	var names []string
+	var mask spotifyLib.SearchType
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		t, ok := searchTypes[name]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown search type %q (use playlist, album, artist, track, show, or episode)", name)
+		}
+		if mask&t == 0 {
+			names = append(names, name)
+			mask |= t
+		}
+	}
+	return names, mask, nil
+}
+
+// SearchCatalog searches Spotify's catalog for `query`, returning up to
+// `limit` hits (0 for the default) of each type in `types`. Hits come
+// grouped by type in the order given, each group in Spotify's ranking, so
+// the first hit is the best match of the first type.
+func SearchCatalog(ctx context.Context, query, types string, limit int) ([]SearchHit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("a search query is required")
+	}
+	names, mask, err := parseSearchTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	client, err := clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.Search(ctx, query, mask, spotifyLib.Limit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	hits := []SearchHit{}
+	for _, name := range names {
+		hits = append(hits, searchHits(result, name)...)
+	}
+	return hits, nil
+}
+
+// searchHits flattens one type's results. Spotify sometimes sends null
+// entries (items since removed); those have no URI and are skipped.
+func searchHits(result *spotifyLib.SearchResult, name string) []SearchHit {
+	var hits []SearchHit
+	add := func(hit SearchHit) {
+		if hit.URI != "" {
+			hit.Type = name
+			hits = append(hits, hit)
+		}
+	}
+
+	switch name {
+	case "playlist":
+		if result.Playlists != nil {
+			for _, p := range result.Playlists.Playlists {
+				add(SearchHit{ID: string(p.ID), URI: string(p.URI), Name: p.Name, By: p.Owner.DisplayName, Detail: fmt.Sprintf("%d tracks", p.Tracks.Total)})
+			}
+		}
+	case "album":
+		if result.Albums != nil {
+			for _, a := range result.Albums.Albums {
+				add(SearchHit{ID: string(a.ID), URI: string(a.URI), Name: a.Name, By: albumArtists(a), Detail: a.ReleaseDate})
+			}
+		}
+	case "artist":
+		if result.Artists != nil {
+			for _, a := range result.Artists.Artists {
+				add(SearchHit{ID: string(a.ID), URI: string(a.URI), Name: a.Name, Detail: strings.Join(a.Genres, ", ")})
+			}
+		}
+	case "track":
+		if result.Tracks != nil {
+			for _, t := range result.Tracks.Tracks {
+				add(SearchHit{ID: string(t.ID), URI: string(t.URI), Name: t.Name, By: trackArtists(t.SimpleTrack), Detail: t.Album.Name})
+			}
+		}
+	case "show":
+		if result.Shows != nil {
+			for _, s := range result.Shows.Shows {
+				add(SearchHit{ID: string(s.ID), URI: string(s.URI), Name: s.Name, By: s.Publisher})
+			}
+		}
+	case "episode":
+		if result.Episodes != nil {
+			for _, e := range result.Episodes.Episodes {
+				add(SearchHit{ID: string(e.ID), URI: string(e.URI), Name: e.Name, By: e.Show.Name, Detail: e.ReleaseDate})
+			}
+		}
+	}
+	return hits
+}
+
+// PrintSearchTable displays search hits in a formatted table.
+func PrintSearchTable(query string, hits []SearchHit) {
+	green := color.New(color.FgGreen, color.Bold)
+
+	printHeading("🔎", fmt.Sprintf("Spotify search: %q", query))
+
+	t := newTable()
+	t.AppendHeader(table.Row{"#", "Type", "Name", "By", "Details", "URI"})
+
+	for i, hit := range hits {
+		t.AppendRow(table.Row{
+			i + 1,
+			hit.Type,
+			color.New(color.Bold).Sprint(hit.Name),
+			hit.By,
+			hit.Detail,
+			color.HiBlackString(hit.URI),
+		})
+	}
+
+	t.Render()
+
+	fmt.Println()
+	green.Printf("Total results: %d\n", len(hits))
+}
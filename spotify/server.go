@@ -9,16 +9,19 @@
 package spotify
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
-
-	spotifyLib "github.com/zmb3/spotify/v2"
 )
 
 // loggingResponseWriter wraps http.ResponseWriter to capture the status code.
@@ -33,6 +36,23 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack passes through to the underlying writer so WebSocket upgrades
+// work behind the logger.
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	lrw.statusCode = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+// Unwrap exposes the underlying writer so http.ResponseController can
+// flush streams (SSE) and lift write deadlines through the logger.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 // loggingMiddleware wraps an http.Handler and logs each request.
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -60,30 +80,24 @@ func StartAPIServer() {
 	mux.HandleFunc("/", HandleRootRequest)
 	mux.HandleFunc("/auth", HandleAuthRequest)
 	mux.HandleFunc("/callback", HandleAuthCallback)
-	mux.HandleFunc("/api/v1/play", HandlePlayRequest)
-	mux.HandleFunc("/api/v1/pause", HandlePauseRequest)
-	mux.HandleFunc("/api/v1/devices", HandleDevicesRequest)
-	mux.HandleFunc("/api/v1/lan-devices", HandleLANDevicesRequest)
-	mux.HandleFunc("/api/v1/wake", HandleWakeRequest)
-	mux.HandleFunc("/api/v1/playlists", HandlePlaylistsRequest)
-	mux.HandleFunc("/api/v1/volume", HandleVolumeRequest)
-	mux.HandleFunc("/api/v1/next", HandleNextRequest)
-
-	fmt.Printf("Starting API server on port %s...\n", port)
-	fmt.Println("Endpoints:")
-	fmt.Println("  GET /api/v1/play?device=<name>&playlist=<name|id|url>&shuffle=<true|false>")
-	fmt.Println("  GET /api/v1/pause")
-	fmt.Println("  GET /api/v1/next")
-	fmt.Println("  GET /api/v1/devices")
-	fmt.Println("  GET /api/v1/lan-devices")
-	fmt.Println("  GET /api/v1/wake?device=<name>")
-	fmt.Println("  GET /api/v1/playlists")
-	fmt.Println("  GET /api/v1/volume?level=0-100&device=<optional name>")
-
-	// Wrap mux with logging middleware
-	handler := loggingMiddleware(mux)
-
-	err := http.ListenAndServe(":"+port, handler)
+	mux.HandleFunc("/docs", HandleDocsRequest)
+	mux.HandleFunc("/status-page", HandleStatusPageRequest)
+	mux.HandleFunc("/integrations/slack", HandleSlackCommandRequest)
+	mux.HandleFunc("/integrations/alexa", HandleAlexaRequest)
+	mux.HandleFunc("/metrics", HandleMetricsRequest)
+	registerAPIRoutes(mux)
+
+	scheme := "HTTP"
+	if serverConfig.TLS() {
+		scheme = "HTTPS"
+	}
+	fmt.Printf("Starting API server (%s) on port %s...\n", scheme, port)
+	printAPIRoutes()
+
+	// Wrap mux with the legacy compatibility layer, then CORS, then logging
+	handler := loggingMiddleware(corsMiddleware(legacyCompatMiddleware(mux)))
+
+	err := listen(newHTTPServer(":"+port, handler, serverConfig), serverConfig)
 	if err != nil {
 		log.Fatalf("Failed to start API server: %v", err)
 	}
@@ -103,384 +117,1800 @@ func HandleRootRequest(w http.ResponseWriter, r *http.Request) {
 // Requires the API access token for security.
 func HandleAuthRequest(w http.ResponseWriter, r *http.Request) {
 	// Verify access token
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	}
+	token := requestToken(r)
 
-	if token != apiAccessToken {
+	if token != GetAPIAccessToken() {
 		http.Error(w, "Unauthorized: Invalid or missing access token", http.StatusUnauthorized)
 		return
 	}
 
-	url := auth.AuthURL(state)
+	account := strings.ToLower(r.URL.Query().Get("account"))
+	if !accountExists(account) {
+		http.Error(w, fmt.Sprintf("Unknown account %q", account), http.StatusBadRequest)
+		return
+	}
+
+	// Every /auth hit gets its own state so concurrent flows (two phones
+	// re-authenticating at once) don't invalidate each other.
+	flowState, err := pendingAuthFlows.BeginFor(account)
+	if err != nil {
+		http.Error(w, "Failed to start authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	url := auth.AuthURL(flowState)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
 // HandleAuthCallback handles the OAuth callback from Spotify after user authorization.
+// The state must belong to a pending, unexpired flow started via /auth, and
+// is claimed before the code is exchanged so each flow completes at most
+// once; replays are refused.
 func HandleAuthCallback(w http.ResponseWriter, r *http.Request) {
-	tok, err := auth.Token(r.Context(), state, r)
+	st := r.FormValue("state")
+	if err := pendingAuthFlows.Claim(st); err != nil {
+		if errors.Is(err, errAuthStateUsed) {
+			log.Printf("Rejected replayed OAuth callback from %s", r.RemoteAddr)
+			http.Error(w, "Authentication request already used. Start again at /auth", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Unknown or expired authentication request. Start again at /auth", http.StatusForbidden)
+		return
+	}
+
+	tok, err := auth.Token(r.Context(), st, r)
 	if err != nil {
+		pendingAuthFlows.Release(st)
 		http.Error(w, "Failed to get token: "+err.Error(), http.StatusForbidden)
 		return
 	}
 
-	if st := r.FormValue("state"); st != state {
-		http.Error(w, "State mismatch", http.StatusForbidden)
+	account := pendingAuthFlows.Account(st)
+	if !pendingAuthFlows.Complete(st) {
+		http.Error(w, "Authentication request expired. Start again at /auth", http.StatusForbidden)
+		return
+	}
+
+	// A named account keeps its own token file and client; readiness
+	// tracks the default account only.
+	if account != "" {
+		path := accountTokenFile(account)
+		saveTokenFile(path, tok)
+		SetAccountClient(account, newClientFromTokenFile(tok, path))
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "Authentication successful for account %s! You can close this window.", account)
 		return
 	}
 
 	// Save token for future use
 	SaveToken(tok)
 
-	// Update the global client with the new token
-	spotifyClient = spotifyLib.New(auth.Client(r.Context(), tok))
+	// Update the global client with the new token and flip readiness
+	// without waiting for the next health check tick.
+	SetClient(NewClientFromToken(tok))
+	setTokenStatus(TokenStatus{Ready: true, Expiry: tok.Expiry, LastCheck: time.Now()})
 
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprint(w, "Authentication successful! You can close this window.")
 }
 
-// HandlePlayRequest handles the /api/v1/play endpoint to start playlist playback.
+// HandleLogoutRequest handles POST /api/v1/auth/logout. It deletes the
+// selected account's stored token and drops its client; /auth signs in
+// again. GET is refused so a link preview or prefetch can't log out.
+func HandleLogoutRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "logout requires POST", Code: CodeBadRequest})
+		return
+	}
+
+	msg, err := Logout(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// errNoPlayTarget is returned by playRequestFromParams when none of the
+// playback target parameters is set.
+var errNoPlayTarget = errors.New("playlist, album, artist, track, or audiobook parameter is required")
+
+// playRequestFromParams builds and validates a PlayRequest from /play
+// parameters. Errors are the caller's fault and map to 400.
+func playRequestFromParams(params url.Values) (PlayRequest, error) {
+	req := PlayRequest{
+		Device:      params.Get("device"),
+		Playlist:    params.Get("playlist"),
+		Album:       params.Get("album"),
+		Artist:      params.Get("artist"),
+		Track:       params.Get("track"),
+		Audiobook:   params.Get("audiobook"),
+		Shuffle:     strings.ToLower(params.Get("shuffle")) == "true",
+		Start:       params.Get("start"),
+		NewestFirst: strings.ToLower(params.Get("newest_first")) == "true",
+		LeastPlayed: strings.ToLower(params.Get("least_played")) == "true",
+		Duration:    params.Get("duration"),
+		Confirm:     strings.ToLower(params.Get("confirm")) == "true",
+		OnEnd:       params.Get("on_end"),
+		FadeIn:      params.Get("fadein"),
+		Wait:        params.Get("wait"),
+	}
+
+	if req.Playlist == "" && req.Album == "" && req.Artist == "" && req.Track == "" && req.Audiobook == "" {
+		return req, errNoPlayTarget
+	}
+
+	if v := params.Get("volume"); v != "" {
+		volume, err := strconv.Atoi(v)
+		if err != nil || volume < 0 || volume > 100 {
+			return req, fmt.Errorf("volume must be an integer between 0 and 100")
+		}
+		req.Volume = &volume
+	}
+
+	if v := params.Get("target_volume"); v != "" {
+		volume, err := strconv.Atoi(v)
+		if err != nil || volume < 0 || volume > 100 {
+			return req, fmt.Errorf("target_volume must be an integer between 0 and 100")
+		}
+		req.TargetVolume = &volume
+	}
+
+	if v := params.Get("strict_metadata"); v != "" {
+		strict, err := strconv.ParseBool(v)
+		if err != nil {
+			return req, fmt.Errorf("strict_metadata must be true or false")
+		}
+		req.StrictMetadata = &strict
+	}
+
+	if err := req.Validate(); err != nil {
+		return req, err
+	}
+	if _, err := StartStrategyFor(req.Start, req.Shuffle); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// HandlePlayRequest handles the /api/v1/play endpoint to start playlist
+// playback. Parameters come from the query string or a JSON POST body.
 func HandlePlayRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Verify access token
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	}
+	token := requestToken(r)
 
-	if token != apiAccessToken {
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: false,
 			Error:   "Invalid or missing access token",
+			Code:    CodeAuth,
 		})
 		return
 	}
 
-	// Get query parameters
-	deviceName := r.URL.Query().Get("device")
-	playlistInput := r.URL.Query().Get("playlist")
-	shuffleStr := r.URL.Query().Get("shuffle")
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
 
-	if playlistInput == "" {
+	req, err := playRequestFromParams(params)
+	if err != nil {
+		// The legacy GET contract predates the other targets and keeps its
+		// original message.
+		if errors.Is(err, errNoPlayTarget) && isLegacyRequest(r) {
+			err = errors.New("playlist parameter is required")
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: false,
-			Error:   "playlist parameter is required",
+			Error:   err.Error(),
+			Code:    CodeBadRequest,
 		})
 		return
 	}
 
-	shuffle := strings.ToLower(shuffleStr) == "true"
+	// delay=20m plays later instead, returning a job ID to cancel it.
+	if params.Has("delay") {
+		delay, err := ParseDelay(params.Get("delay"))
+		var job DelayedPlay
+		if err != nil {
+			err = withCode(CodeBadRequest, err)
+		} else {
+			job, err = PlayLater(r.Context(), req, delay)
+		}
+		if err != nil {
+			w.WriteHeader(scheduleErrorStatus(err))
+			json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+			return
+		}
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Message: fmt.Sprintf("Playing in %s, at %s", formatDuration(delay), job.At.Format("15:04:05")),
+			JobID:   job.ID,
+		})
+		return
+	}
 
 	// Play the playlist
-	result, err := PlayPlaylist(deviceName, playlistInput, shuffle)
+	ctx, warnings := WithWarnings(r.Context())
+	ctx, played := WithPlayedDevice(ctx)
+	result, err := Play(ctx, req)
+	if errors.Is(err, ErrPlaybackNotStarted) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success:  false,
+			Error:    err.Error(),
+			Code:     ErrorCodeOf(err),
+			Warnings: warnings.List(),
+		})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   err.Error(),
+			Success:  false,
+			Error:    err.Error(),
+			Code:     ErrorCodeOf(err),
+			Warnings: warnings.List(),
 		})
 		return
 	}
 
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: true,
-		Message: result,
+		Success:  true,
+		Message:  result,
+		Warnings: warnings.List(),
+		Device:   played.orNil(),
 	})
 }
 
-// HandleNextRequest handles GET /api/v1/next, advancing the current
-// Spotify session to the next track. Targets whatever device is the
-// active session — Spotify's API doesn't allow specifying a device
-// for skip operations.
-func HandleNextRequest(w http.ResponseWriter, r *http.Request) {
+// HandlePresetRequest handles GET /api/v1/preset/{name}, playing a named
+// preset from the settings file.
+func HandlePresetRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	}
-	if token != apiAccessToken {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token"})
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	name := r.PathValue("name")
+	preset, ok := GetSettings().FindPreset(name)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: fmt.Sprintf("unknown preset %q", name), Code: CodeNotFound})
 		return
 	}
 
-	msg, err := SkipToNext()
+	started := time.Now()
+	ctx, warnings := WithWarnings(r.Context())
+	ctx, played := WithPlayedDevice(ctx)
+	msg, err := PlayPreset(ctx, name)
+	presetStats.RecordRun(name, started, err)
+	if errors.Is(err, ErrPlaybackNotStarted) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err), Warnings: warnings.List()})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error()})
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err), Warnings: warnings.List()})
 		return
 	}
+	// The request context ends with this response; the measurement
+	// outlives it.
+	go measurePresetStart(preset.context(context.WithoutCancel(r.Context())), name, started)
 
-	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg, Warnings: warnings.List(), Device: played.orNil()})
 }
 
-// HandleVolumeRequest handles GET /api/v1/volume?level=0-100&device=<name>.
-// Sets playback volume on the named device, or on the current active
-// device if no name is given. Premium-only on Spotify's side.
-func HandleVolumeRequest(w http.ResponseWriter, r *http.Request) {
+// playParamNames are the /play parameters, used to spot ones that a
+// preset lookup would ignore.
+var playParamNames = []string{"playlist", "album", "artist", "track", "audiobook", "device", "shuffle", "start", "newest_first", "least_played", "duration", "volume", "strict_metadata", "confirm", "on_end", "fadein", "target_volume", "wait"}
+
+// HandleResolveRequest handles /api/v1/resolve: the /play parameters (or
+// `preset=<name>`) are resolved to the playlist, device, and effective
+// options a play would use, with warnings, and nothing is played.
+func HandleResolveRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	}
-	if token != apiAccessToken {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token"})
+		json.NewEncoder(w).Encode(ResolveResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
 		return
 	}
 
-	levelStr := r.URL.Query().Get("level")
-	if levelStr == "" {
+	params, err := readParams(r)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "level parameter is required (0-100)"})
+		json.NewEncoder(w).Encode(ResolveResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
 		return
 	}
 
-	level, err := strconv.Atoi(levelStr)
+	var req PlayRequest
+	var warnings []string
+	presetName := params.Get("preset")
+	if presetName != "" {
+		preset, ok := GetSettings().FindPreset(presetName)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ResolveResponse{Success: false, Error: fmt.Sprintf("unknown preset %q", presetName), Code: CodeNotFound})
+			return
+		}
+		req = preset.PlayRequest()
+		if req.Playlist == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ResolveResponse{Success: false, Error: fmt.Sprintf("preset %q has no playlist configured", presetName), Code: CodeBadRequest})
+			return
+		}
+		if err := req.Validate(); err != nil {
+			warnings = append(warnings, "playback would fail: "+err.Error())
+		} else if _, err := StartStrategyFor(req.Start, req.Shuffle); err != nil {
+			warnings = append(warnings, "playback would fail: "+err.Error())
+		}
+		for _, name := range playParamNames {
+			if params.Has(name) {
+				warnings = append(warnings, fmt.Sprintf("%s is ignored when resolving a preset", name))
+			}
+		}
+	} else {
+		req, err = playRequestFromParams(params)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ResolveResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+			return
+		}
+	}
+
+	resp, err := ResolvePlay(r.Context(), req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "level must be an integer"})
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ResolveResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
 		return
 	}
+	resp.Preset = presetName
+	resp.Warnings = append(warnings, resp.Warnings...)
+	if resp.Warnings == nil {
+		resp.Warnings = []string{}
+	}
 
-	deviceName := r.URL.Query().Get("device")
+	json.NewEncoder(w).Encode(resp)
+}
 
-	msg, err := SetVolume(level, deviceName)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error()})
+// HandlePresetStatsRequest handles GET /api/v1/stats/presets: invocation
+// counts, failure reasons, and start latency for every preset run through
+// the API since the server started.
+func HandlePresetStatsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(PresetStatsResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
 		return
 	}
 
-	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+	stats, since := presetStats.Snapshot()
+	json.NewEncoder(w).Encode(PresetStatsResponse{Success: true, Since: since, Presets: stats})
 }
 
-// HandlePlaylistsRequest handles GET /api/v1/playlists. Returns every
-// playlist owned or followed by the authenticated Spotify user. The server
-// paginates through Spotify's API so clients receive a single flat list.
-func HandlePlaylistsRequest(w http.ResponseWriter, r *http.Request) {
+// HandleSpotifyStatsRequest handles GET /api/v1/stats/spotify: call
+// counts, outcomes, retries, and latency per Spotify Web API operation
+// since the server started, across all accounts.
+func HandleSpotifyStatsRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(SpotifyStatsResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
 	}
-	if token != apiAccessToken {
+
+	ops, since := spotifyCallStats.Snapshot()
+	json.NewEncoder(w).Encode(SpotifyStatsResponse{Success: true, Since: since, Operations: ops, Polling: playbackWatcher.Status()})
+}
+
+// HandleContextRequest handles GET /api/v1/context: now playing, devices,
+// presets, volume schedules, and quiet-hours state in one response.
+func HandleContextRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token"})
+		json.NewEncoder(w).Encode(ContextResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
 		return
 	}
 
-	playlists, err := ListPlaylists(r.Context())
+	resp, err := BuildContext(r.Context(), time.Now())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error()})
+		json.NewEncoder(w).Encode(ContextResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
 		return
 	}
+	json.NewEncoder(w).Encode(resp)
+}
 
-	out := make([]PlaylistInfo, 0, len(playlists))
-	for _, p := range playlists {
-		out = append(out, PlaylistInfo{
-			ID:     string(p.ID),
-			Name:   p.Name,
-			Owner:  p.Owner.DisplayName,
-			Tracks: uint(p.Tracks.Total),
-		})
+// HandleMetricsRequest handles GET /metrics in the Prometheus text format:
+// spotify_errors_total, failed Spotify calls by error code. It takes the
+// API token like any other endpoint; point Prometheus at it with a bearer
+// token.
+func HandleMetricsRequest(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		http.Error(w, "Invalid or missing access token", http.StatusUnauthorized)
+		return
 	}
 
-	json.NewEncoder(w).Encode(PlaylistsResponse{
-		Success:   true,
-		Message:   fmt.Sprintf("Found %d playlist(s)", len(out)),
-		Playlists: out,
-	})
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	codes, counts := spotifyErrors.Snapshot()
+	fmt.Fprintln(w, "# HELP spotify_errors_total Failed Spotify Web API calls by error code.")
+	fmt.Fprintln(w, "# TYPE spotify_errors_total counter")
+	for _, code := range codes {
+		fmt.Fprintf(w, "spotify_errors_total{type=%q} %d\n", code, counts[code])
+	}
 }
 
-// HandleLANDevicesRequest handles GET /api/v1/lan-devices. Returns every
-// Spotify Connect device currently advertising itself on the local network
-// via mDNS — i.e. every speaker that *could* be played to, including ones
-// linked to other accounts. Useful for picking a wake target when the
-// device isn't yet in the Spotify cloud devices list.
-func HandleLANDevicesRequest(w http.ResponseWriter, r *http.Request) {
+// HandleFallbacksRequest handles GET /api/v1/fallbacks: recent plays that
+// landed on another device than requested, newest first.
+func HandleFallbacksRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	}
-	if token != apiAccessToken {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token"})
+		json.NewEncoder(w).Encode(FallbacksResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
 		return
 	}
 
-	locals, err := defaultDiscoveryCache.Devices(r.Context())
-	if err != nil && len(locals) == 0 {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error()})
-		return
+	events := fallbackLog.List()
+	if events == nil {
+		events = []FallbackEvent{}
 	}
+	json.NewEncoder(w).Encode(FallbacksResponse{Success: true, Fallbacks: events})
+}
 
-	out := make([]LANDeviceInfo, 0, len(locals))
-	for _, d := range locals {
-		out = append(out, LANDeviceInfo{
-			Name:     d.FriendlyName,
-			Hostname: d.Hostname,
-			IP:       d.IP,
-			Port:     d.Port,
-			Instance: d.InstanceName,
-		})
+// HandleGroupsRequest handles GET /api/v1/groups, listing the device
+// groups with which members are online.
+func HandleGroupsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(GroupsResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
 	}
 
-	json.NewEncoder(w).Encode(LANDevicesResponse{
-		Success: true,
-		Message: fmt.Sprintf("Found %d device(s) on LAN", len(out)),
-		Devices: out,
-	})
+	groups, err := ListGroups(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(GroupsResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+	json.NewEncoder(w).Encode(GroupsResponse{Success: true, Groups: groups})
 }
 
-// HandleWakeRequest handles GET /api/v1/wake?device=<name>. It runs the
-// zeroconf addUser handshake against the named device on the LAN, claiming
-// it for the current Spotify account, and waits for the device to appear
-// in Spotify cloud. Useful when a household member played to the speaker
-// from their account and the device is no longer linked to ours.
-func HandleWakeRequest(w http.ResponseWriter, r *http.Request) {
+// HandleDigestRequest handles GET /api/v1/digest?since=<time|duration>.
+// Returns tracks others added to shared playlists since `since` (RFC 3339,
+// or a duration like 48h meaning that long ago), defaulting to the last
+// scheduled digest. Read-only: nothing is sent and the schedule's mark
+// doesn't move.
+func HandleDigestRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Verify access token (query param takes priority over header)
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	}
-
-	if token != apiAccessToken {
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   "Invalid or missing access token",
-		})
+		json.NewEncoder(w).Encode(DigestResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
 		return
 	}
 
-	deviceName := r.URL.Query().Get("device")
-	if deviceName == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   "device parameter is required",
-		})
+	if digestJob == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(DigestResponse{Success: false, Error: "digest is disabled", Code: CodeUnavailable})
 		return
 	}
 
-	// Use a generous request context — the claim flow polls for up to ~12s.
-	result, err := ClaimDevice(r.Context(), deviceName)
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(DigestResponse{Success: false, Error: "since must be an RFC 3339 time or a duration like 48h", Code: CodeBadRequest})
+			return
+		}
+	}
+
+	digest, err := digestJob.Preview(r.Context(), since)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+		json.NewEncoder(w).Encode(DigestResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
 		return
 	}
 
-	msg := fmt.Sprintf("Claimed %q (deviceID=%s)", deviceName, result.DeviceID)
-	if result.AlreadyActive {
-		msg = fmt.Sprintf("%q already linked to current account (deviceID=%s)", deviceName, result.DeviceID)
-	}
-
-	json.NewEncoder(w).Encode(APIResponse{
-		Success: true,
-		Message: msg,
-	})
+	json.NewEncoder(w).Encode(DigestResponse{Success: true, Digest: digest})
 }
 
-// HandleDevicesRequest handles the /api/v1/devices endpoint, returning the
-// list of Spotify Connect devices visible to the authenticated user as JSON.
-// Requires the API access token (query param `token` or Bearer header).
-func HandleDevicesRequest(w http.ResponseWriter, r *http.Request) {
+// HandleSchedulesRequest handles /api/v1/schedules. GET lists every
+// schedule, from the settings file and the API, with its next and last
+// run; POST adds one, which is saved so it survives restarts.
+func HandleSchedulesRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Verify access token (query param takes priority over header)
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
 	}
 
-	if token != apiAccessToken {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   "Invalid or missing access token",
-		})
+	if scheduler == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: "scheduler is disabled", Code: CodeUnavailable})
 		return
 	}
 
-	// Fetch devices from Spotify
-	devices, err := ListDevices()
+	if r.Method != http.MethodPost {
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: true, Schedules: scheduler.List()})
+		return
+	}
+
+	params, err := readParams(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
 		return
 	}
+	sch, err := scheduleFromParams(Schedule{}, params)
+	if err == nil {
+		var info ScheduleInfo
+		if info, err = scheduler.Add(sch); err == nil {
+			json.NewEncoder(w).Encode(SchedulesResponse{Success: true, Message: fmt.Sprintf("Added schedule %s", info.ID), Schedule: &info})
+			return
+		}
+	}
+	w.WriteHeader(scheduleErrorStatus(err))
+	json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+}
 
-	// Convert to JSON-friendly DeviceInfo slice so we control the contract
-	infos := make([]DeviceInfo, 0, len(devices))
-	for _, d := range devices {
-		infos = append(infos, DeviceInfo{
-			ID:     string(d.ID),
-			Name:   d.Name,
-			Type:   d.Type,
-			Active: d.Active,
-		})
+// HandleScheduleRequest handles /api/v1/schedules/{id}. GET returns the
+// schedule; POST changes the fields it's given, keeping the rest, so a
+// hub can move the wake-up time with just `when`; DELETE removes it. Only
+// schedules added over the API can be changed or removed; settings-file
+// ones are edited in the settings file.
+func HandleScheduleRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
 	}
 
-	json.NewEncoder(w).Encode(APIResponse{
-		Success: true,
-		Message: fmt.Sprintf("Found %d device(s)", len(infos)),
-		Devices: infos,
-	})
+	if scheduler == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: "scheduler is disabled", Code: CodeUnavailable})
+		return
+	}
+
+	id := r.PathValue("id")
+	info, err := scheduler.Get(id)
+	switch {
+	case err != nil:
+	case r.Method == http.MethodDelete:
+		if err = scheduler.Remove(id); err == nil {
+			json.NewEncoder(w).Encode(SchedulesResponse{Success: true, Message: fmt.Sprintf("Removed schedule %s", id)})
+			return
+		}
+	case r.Method == http.MethodPost:
+		var params url.Values
+		if params, err = readParams(r); err != nil {
+			err = withCode(CodeBadRequest, err)
+			break
+		}
+		var sch Schedule
+		if sch, err = scheduleFromParams(info.Schedule, params); err != nil {
+			break
+		}
+		if info, err = scheduler.Update(id, sch); err == nil {
+			json.NewEncoder(w).Encode(SchedulesResponse{Success: true, Message: fmt.Sprintf("Updated schedule %s", id), Schedule: &info})
+			return
+		}
+	default:
+		json.NewEncoder(w).Encode(SchedulesResponse{Success: true, Schedule: &info})
+		return
+	}
+	w.WriteHeader(scheduleErrorStatus(err))
+	json.NewEncoder(w).Encode(SchedulesResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
 }
 
-// HandlePauseRequest handles the /api/v1/pause endpoint to pause playback.
-func HandlePauseRequest(w http.ResponseWriter, r *http.Request) {
+// HandleJobsRequest handles /api/v1/jobs: GET lists the delayed plays
+// waiting to run.
+func HandleJobsRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Verify access token
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(JobsResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
 	}
 
-	if token != apiAccessToken {
+	json.NewEncoder(w).Encode(JobsResponse{Success: true, Jobs: delayedPlays.List()})
+}
+
+// HandleJobRequest handles DELETE /api/v1/jobs/{id}, which cancels a
+// delayed play before it runs.
+func HandleJobRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   "Invalid or missing access token",
-		})
+		json.NewEncoder(w).Encode(JobsResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
 		return
 	}
 
-	// Pause playback
-	result, err := PausePlayback()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(APIResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+	id := r.PathValue("id")
+	if err := delayedPlays.Cancel(id); err != nil {
+		w.WriteHeader(scheduleErrorStatus(err))
+		json.NewEncoder(w).Encode(JobsResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
 		return
 	}
+	json.NewEncoder(w).Encode(JobsResponse{Success: true, Message: fmt.Sprintf("Cancelled job %s", id)})
+}
 
-	json.NewEncoder(w).Encode(APIResponse{
-		Success: true,
-		Message: result,
-	})
+// HandleReloadRequest handles POST /api/v1/reload, which re-reads the
+// settings file and API access token without restarting the server.
+func HandleReloadRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	msg, err := Reload()
+	if err != nil {
+		w.WriteHeader(scheduleErrorStatus(err))
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// scheduleErrorStatus is the HTTP status for a schedule, job, or reload
+// error.
+func scheduleErrorStatus(err error) int {
+	switch ErrorCodeOf(err) {
+	case CodeBadRequest:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// scheduleFromParams returns `sch` with the schedule fields `params`
+// gives. Setting cron clears when and the other way round, and an empty
+// volume, target_volume, or playlists clears it. Bad values are
+// CodeBadRequest errors.
+func scheduleFromParams(sch Schedule, params url.Values) (Schedule, error) {
+	for name, dst := range map[string]*string{
+		"name": &sch.Name, "action": &sch.Action, "playlist": &sch.Playlist,
+		"device": &sch.Device, "fadein": &sch.FadeIn, "account": &sch.Account, "timezone": &sch.Timezone,
+	} {
+		if params.Has(name) {
+			*dst = params.Get(name)
+		}
+	}
+	if params.Has("cron") {
+		sch.Cron, sch.When = params.Get("cron"), ""
+	}
+	if params.Has("when") {
+		sch.When, sch.Cron = params.Get("when"), ""
+	}
+	if params.Has("shuffle") {
+		sch.Shuffle = strings.ToLower(params.Get("shuffle")) == "true"
+	}
+	if params.Has("disabled") {
+		sch.Disabled = strings.ToLower(params.Get("disabled")) == "true"
+	}
+	if params.Has("playlists") {
+		sch.Playlists = nil
+		if v := params.Get("playlists"); v != "" {
+			playlists, err := parseDayPlaylists(v)
+			if err != nil {
+				return sch, withCode(CodeBadRequest, err)
+			}
+			sch.Playlists = playlists
+		}
+	}
+	for name, dst := range map[string]**int{"volume": &sch.Volume, "target_volume": &sch.TargetVolume} {
+		if !params.Has(name) {
+			continue
+		}
+		*dst = nil
+		if v := params.Get(name); v != "" {
+			volume, err := strconv.Atoi(v)
+			if err != nil {
+				return sch, withCode(CodeBadRequest, fmt.Errorf("%s must be an integer between 0 and 100", name))
+			}
+			*dst = &volume
+		}
+	}
+	return sch, nil
+}
+
+// parseDayPlaylists parses the playlists parameter of a schedule:
+// semicolon-separated days=playlist pairs, like "weekdays=Wake Up;
+// sat,sun=Lazy Sunday".
+func parseDayPlaylists(s string) (map[string]string, error) {
+	playlists := map[string]string{}
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		days, playlist, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("playlists: %q isn't days=playlist", strings.TrimSpace(pair))
+		}
+		playlists[strings.TrimSpace(days)] = strings.TrimSpace(playlist)
+	}
+	return playlists, nil
+}
+
+// HandleHistoryRequest handles GET /api/v1/history?limit=<n>: the
+// account's play history, most recently played first, with each track's
+// audio features once they're known.
+func HandleHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(HistoryResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	db, _ := historyFor(AccountFrom(r.Context()))
+	if db == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(HistoryResponse{Success: false, Error: "play history is disabled", Code: CodeUnavailable})
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(HistoryResponse{Success: false, Error: "limit must be a positive number", Code: CodeBadRequest})
+			return
+		}
+		limit = n
+	}
+
+	json.NewEncoder(w).Encode(HistoryResponse{Success: true, Tracks: db.Recent(limit)})
+}
+
+// HandleNextRequest handles GET /api/v1/next, advancing the current
+// Spotify session to the next track. Targets whatever device is the
+// active session — Spotify's API doesn't allow specifying a device
+// for skip operations.
+func HandleNextRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	msg, err := SkipToNext(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// HandleSeekRequest handles GET /api/v1/seek?position=<ms>, jumping to
+// that offset in the current track on the active device.
+func HandleSeekRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	positionStr := params.Get("position")
+	if positionStr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "position parameter is required (milliseconds)", Code: CodeBadRequest})
+		return
+	}
+
+	position, err := strconv.Atoi(positionStr)
+	if err != nil || position < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "position must be a non-negative integer", Code: CodeBadRequest})
+		return
+	}
+
+	msg, err := Seek(r.Context(), position)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// HandleQueueAddRequest handles GET /api/v1/queue/add?uri=<uri>, pushing a
+// track or episode onto the queue without interrupting the current context.
+func HandleQueueAddRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	uri := params.Get("uri")
+	if uri == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "uri parameter is required", Code: CodeBadRequest})
+		return
+	}
+	if _, err := parseQueueURI(uri); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	msg, err := QueueTrack(r.Context(), uri)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// HandleVolumeRequest handles GET /api/v1/volume?level=0-100&device=<name>.
+// Sets playback volume on the named device, or on the current active
+// device if no name is given. Premium-only on Spotify's side.
+func HandleVolumeRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	levelStr := params.Get("level")
+	if levelStr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "level parameter is required (0-100)", Code: CodeBadRequest})
+		return
+	}
+
+	level, err := strconv.Atoi(levelStr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "level must be an integer", Code: CodeBadRequest})
+		return
+	}
+
+	deviceName := params.Get("device")
+
+	msg, err := SetVolume(r.Context(), level, deviceName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// HandlePlaylistsRequest handles GET /api/v1/playlists. Returns every
+// playlist owned or followed by the authenticated Spotify user. The server
+// paginates through Spotify's API so clients receive a single flat list;
+// q=<text> keeps playlists whose name contains it, and limit and offset
+// return a window of the result, with total counting every match.
+// POST creates a playlist instead (see handleCreatePlaylist).
+func HandlePlaylistsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+	if r.Method == http.MethodPost {
+		handleCreatePlaylist(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, offset := 0, 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PlaylistsResponse{Success: false, Error: "limit must be a positive number", Code: CodeBadRequest})
+			return
+		}
+		limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PlaylistsResponse{Success: false, Error: "offset must be zero or a positive number", Code: CodeBadRequest})
+			return
+		}
+		offset = n
+	}
+	q := strings.ToLower(strings.TrimSpace(query.Get("q")))
+
+	playlists, err := ListPlaylists(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	out := make([]PlaylistInfo, 0, len(playlists))
+	for _, p := range playlists {
+		if q != "" && !strings.Contains(strings.ToLower(p.Name), q) {
+			continue
+		}
+		out = append(out, NewPlaylistInfo(p))
+	}
+	total := len(out)
+	out = out[min(offset, total):]
+	if limit > 0 {
+		out = out[:min(limit, len(out))]
+	}
+
+	json.NewEncoder(w).Encode(PlaylistsResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Found %d playlist(s)", total),
+		Total:     total,
+		Playlists: out,
+	})
+}
+
+// handleCreatePlaylist handles POST /api/v1/playlists: it creates a
+// playlist from name, description, public, and collaborative, and returns
+// it as the only entry in `playlists`. The caller has checked the token.
+func handleCreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PlaylistsResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	playlist, err := CreatePlaylist(r.Context(), params.Get("name"), params.Get("description"),
+		strings.ToLower(params.Get("public")) == "true", strings.ToLower(params.Get("collaborative")) == "true")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ErrorCodeOf(err) == CodeBadRequest {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(PlaylistsResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PlaylistsResponse{
+		Success: true,
+		Message: fmt.Sprintf("Created playlist %q (%s)", playlist.Name, playlist.ID),
+		Playlists: []PlaylistInfo{{
+			ID:    string(playlist.ID),
+			Name:  playlist.Name,
+			Owner: playlist.Owner.DisplayName,
+		}},
+	})
+}
+
+// HandleSearchRequest handles GET /api/v1/search?q=<query>&type=<types>.
+// Searches Spotify's catalog and returns the hits of each type, in the
+// order the types were given. Any hit's URI can be passed to /play.
+func HandleSearchRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(SearchResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params := r.URL.Query()
+	query := strings.TrimSpace(params.Get("q"))
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(SearchResponse{Success: false, Error: "q parameter is required", Code: CodeBadRequest})
+		return
+	}
+	if _, _, err := parseSearchTypes(params.Get("type")); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(SearchResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+	limit := 0
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SearchResponse{Success: false, Error: fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit), Code: CodeBadRequest})
+			return
+		}
+		limit = n
+	}
+
+	hits, err := SearchCatalog(r.Context(), query, params.Get("type"), limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(SearchResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(SearchResponse{
+		Success: true,
+		Message: fmt.Sprintf("Found %d result(s)", len(hits)),
+		Query:   query,
+		Results: hits,
+	})
+}
+
+// HandleFollowPlaylistRequest handles GET /api/v1/playlists/follow?playlist=<link>.
+// Adds a shared playlist to the user's library so it resolves by name from
+// then on. public=true also shows it on the user's profile.
+func HandleFollowPlaylistRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	playlist := params.Get("playlist")
+	if playlist == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "playlist parameter is required", Code: CodeBadRequest})
+		return
+	}
+
+	msg, err := FollowPlaylist(r.Context(), playlist, strings.ToLower(params.Get("public")) == "true")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// HandlePlaylistTracksRequest handles /api/v1/playlists/{id}/tracks. {id}
+// is the playlist's name, URI, or ID. GET lists the tracks (see
+// handleListPlaylistTracks); POST with uris=<comma-separated tracks> adds
+// them, and a track of "current" adds the one playing now; DELETE removes
+// tracks (see handleRemoveFromPlaylist). A GET with uris still adds, for
+// Shortcuts that only send GET.
+func HandlePlaylistTracksRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+	switch {
+	case r.Method == http.MethodDelete:
+		handleRemoveFromPlaylist(w, r)
+		return
+	case r.Method == http.MethodGet && r.URL.Query().Get("uris") == "":
+		handleListPlaylistTracks(w, r)
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	tracks := splitTrackList(params.Get("uris"))
+	if len(tracks) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: "uris parameter is required", Code: CodeBadRequest})
+		return
+	}
+
+	msg, err := AddToPlaylist(r.Context(), r.PathValue("id"), tracks...)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ErrorCodeOf(err) == CodeBadRequest {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: true, Message: msg})
+}
+
+// handleListPlaylistTracks handles GET /api/v1/playlists/{id}/tracks: up
+// to `limit` tracks (default 100) from 0-based `offset`, with the
+// playlist's name and total. The caller has checked the token.
+func handleListPlaylistTracks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset := 100, 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: "limit must be a positive number", Code: CodeBadRequest})
+			return
+		}
+		limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: "offset must be zero or a positive number", Code: CodeBadRequest})
+			return
+		}
+		offset = n
+	}
+
+	name, total, tracks, err := ListPlaylistTracks(r.Context(), r.PathValue("id"), limit, offset)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ErrorCodeOf(err) == CodeBadRequest {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+	if tracks == nil {
+		tracks = []PlaylistTrack{}
+	}
+
+	json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: true, Playlist: name, Total: total, Tracks: tracks})
+}
+
+// handleRemoveFromPlaylist handles DELETE /api/v1/playlists/{id}/tracks
+// with uris=<comma-separated tracks or 1-based positions>. dry_run=true
+// lists what would be removed without changing the playlist. The caller has
+// checked the token.
+func handleRemoveFromPlaylist(w http.ResponseWriter, r *http.Request) {
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	tracks := splitTrackList(params.Get("uris"))
+	if len(tracks) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: "uris parameter is required", Code: CodeBadRequest})
+		return
+	}
+	dryRun := strings.ToLower(params.Get("dry_run")) == "true"
+
+	msg, removed, err := RemoveFromPlaylist(r.Context(), r.PathValue("id"), dryRun, tracks...)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ErrorCodeOf(err) == CodeBadRequest {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(PlaylistTracksResponse{Success: true, Message: msg, DryRun: dryRun, Tracks: removed})
+}
+
+// splitTrackList splits a comma-separated track list, dropping blanks.
+func splitTrackList(s string) []string {
+	var tracks []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// HandleUnfollowPlaylistRequest handles GET /api/v1/playlists/unfollow?playlist=<name|link>.
+// Removes a playlist from the user's library.
+func HandleUnfollowPlaylistRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	playlist := params.Get("playlist")
+	if playlist == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "playlist parameter is required", Code: CodeBadRequest})
+		return
+	}
+
+	msg, err := UnfollowPlaylist(r.Context(), playlist)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg})
+}
+
+// HandleLANDevicesRequest handles GET /api/v1/lan-devices. Returns every
+// Spotify Connect device currently advertising itself on the local network
+// via mDNS — i.e. every speaker that *could* be played to, including ones
+// linked to other accounts. Useful for picking a wake target when the
+// device isn't yet in the Spotify cloud devices list.
+func HandleLANDevicesRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	locals, err := defaultDiscoveryCache.Devices(r.Context())
+	if err != nil && len(locals) == 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	out := make([]LANDeviceInfo, 0, len(locals))
+	for _, d := range locals {
+		out = append(out, LANDeviceInfo{
+			Name:     d.FriendlyName,
+			Hostname: d.Hostname,
+			IP:       d.IP,
+			Port:     d.Port,
+			Instance: d.InstanceName,
+		})
+	}
+
+	json.NewEncoder(w).Encode(LANDevicesResponse{
+		Success: true,
+		Message: fmt.Sprintf("Found %d device(s) on LAN", len(out)),
+		Devices: out,
+	})
+}
+
+// HandleWakeRequest handles GET /api/v1/wake?device=<name>. It runs the
+// zeroconf addUser handshake against the named device on the LAN, claiming
+// it for the current Spotify account, and waits for the device to appear
+// in Spotify cloud. Useful when a household member played to the speaker
+// from their account and the device is no longer linked to ours.
+func HandleWakeRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Verify access token (query param takes priority over header)
+	token := requestToken(r)
+
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   "Invalid or missing access token",
+			Code:    CodeAuth,
+		})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	deviceName := params.Get("device")
+	if deviceName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   "device parameter is required",
+			Code:    CodeBadRequest,
+		})
+		return
+	}
+
+	// Use a generous request context — the claim flow polls for up to ~12s.
+	result, err := ClaimDevice(r.Context(), deviceName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    ErrorCodeOf(err),
+		})
+		return
+	}
+
+	msg := fmt.Sprintf("Claimed %q (deviceID=%s)", deviceName, result.DeviceID)
+	if result.AlreadyActive {
+		msg = fmt.Sprintf("%q already linked to current account (deviceID=%s)", deviceName, result.DeviceID)
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Message: msg,
+	})
+}
+
+// HandleDevicesRequest handles the /api/v1/devices endpoint, returning the
+// list of Spotify Connect devices visible to the authenticated user as JSON,
+// narrowed and ordered by type=, active=, name=, and sort=. Requires the API access token (query param `token` or Bearer header).
+func HandleDevicesRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Verify access token (query param takes priority over header)
+	token := requestToken(r)
+
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(DevicesResponse{
+			Success: false,
+			Error:   "Invalid or missing access token",
+			Code:    CodeAuth,
+			Devices: []DeviceInfo{},
+		})
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := ParseDeviceFilter(q.Get("type"), q.Get("active"), q.Get("name"), q.Get("sort"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(DevicesResponse{Success: false, Error: err.Error(), Code: CodeBadRequest, Devices: []DeviceInfo{}})
+		return
+	}
+
+	// Fetch devices from Spotify
+	devices, err := ListDevices(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(DevicesResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    ErrorCodeOf(err),
+			Devices: []DeviceInfo{},
+		})
+		return
+	}
+
+	// Convert to JSON-friendly DeviceInfo slice so we control the contract
+	infos := DeviceInfos(filter.Apply(devices))
+
+	json.NewEncoder(w).Encode(DevicesResponse{
+		Success: true,
+		Message: fmt.Sprintf("Found %d device(s)", len(infos)),
+		Devices: infos,
+	})
+}
+
+// HandleDeviceRegistryRequest handles GET /api/v1/devices/registry,
+// listing every device the registry knows with its stable ID and ID
+// history.
+func HandleDeviceRegistryRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(DeviceRegistryResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+	if deviceRegistry == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(DeviceRegistryResponse{Success: false, Error: "device registry is disabled", Code: CodeUnavailable})
+		return
+	}
+
+	devices := deviceRegistry.List()
+	json.NewEncoder(w).Encode(DeviceRegistryResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d registered device(s)", len(devices)),
+		Devices: devices,
+	})
+}
+
+// HandleRegisterDevicesRequest handles /api/v1/devices/register, adding
+// every device Spotify currently reports to the registry in one call.
+func HandleRegisterDevicesRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(DeviceRegistryResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	devices, err := RegisterDevices(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(DeviceRegistryResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(DeviceRegistryResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d registered device(s)", len(devices)),
+		Devices: devices,
+	})
+}
+
+// HandlePauseRequest handles the /api/v1/pause endpoint to pause playback:
+// whatever is playing, or with device= only that device or group.
+func HandlePauseRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Verify access token
+	token := requestToken(r)
+
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   "Invalid or missing access token",
+			Code:    CodeAuth,
+		})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	// Pause playback, on the named device (or group's members) only if
+	// one is given
+	ctx, warnings := WithWarnings(r.Context())
+	var result string
+	if device := params.Get("device"); device != "" {
+		result, err = PauseDevice(ctx, device)
+	} else {
+		result, err = PausePlayback(ctx)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    ErrorCodeOf(err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{
+		Success:  true,
+		Message:  result,
+		Warnings: warnings.List(),
+	})
+}
+
+// HandleStopRequest handles GET /api/v1/stop?transfer=<device>. Unlike
+// pause it rewinds the track and can move the paused session off the
+// current speaker.
+func HandleStopRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Verify access token
+	token := requestToken(r)
+
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   "Invalid or missing access token",
+			Code:    CodeAuth,
+		})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	ctx, warnings := WithWarnings(r.Context())
+	result, err := StopPlayback(ctx, params.Get("transfer"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success:  false,
+			Error:    err.Error(),
+			Code:     ErrorCodeOf(err),
+			Warnings: warnings.List(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{
+		Success:  true,
+		Message:  result,
+		Warnings: warnings.List(),
+	})
+}
+
+// HandleResumeLastRequest handles GET /api/v1/resume-last?device=<name>,
+// picking up the most recent listening on that device.
+func HandleResumeLastRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Verify access token
+	token := requestToken(r)
+
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Error:   "Invalid or missing access token",
+			Code:    CodeAuth,
+		})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	ctx, warnings := WithWarnings(r.Context())
+	result, err := ResumeLast(ctx, params.Get("device"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success:  false,
+			Error:    err.Error(),
+			Code:     ErrorCodeOf(err),
+			Warnings: warnings.List(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{
+		Success:  true,
+		Message:  result,
+		Warnings: warnings.List(),
+	})
+}
+
+// HandleCurrentLyricsRequest handles GET /api/v1/lyrics/current, returning
+// synced and/or plain lyrics for the track playing now. Returns 503 when
+// no lyrics provider is configured.
+func HandleCurrentLyricsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(LyricsResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	if lyricsProvider == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(LyricsResponse{Success: false, Error: "Lyrics are disabled. Set LYRICS_PROVIDER=lrclib to enable them", Code: CodeUnavailable})
+		return
+	}
+
+	resp, err := CurrentLyrics(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(LyricsResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleHandoffRequest handles GET /api/v1/handoff?to=<peer>&device=<name>,
+// moving current playback to a peer instance from the settings file.
+func HandleHandoffRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	to := params.Get("to")
+	if to == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "to parameter is required", Code: CodeBadRequest})
+		return
+	}
+	if _, ok := GetSettings().FindPeer(to); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: fmt.Sprintf("unknown peer %q", to), Code: CodeNotFound})
+		return
+	}
+
+	ctx, warnings := WithWarnings(r.Context())
+	msg, err := Handoff(ctx, to, params.Get("device"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err), Warnings: warnings.List()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg, Warnings: warnings.List()})
+}
+
+// HandleHandoffReceiveRequest handles POST /api/v1/handoff/receive, the
+// peer-to-peer half of a handoff: it resumes the posted snapshot on this
+// instance's device.
+func HandleHandoffReceiveRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := requestToken(r)
+	if token != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: CodeBadRequest})
+		return
+	}
+
+	snap := HandoffSnapshot{
+		ContextURI: params.Get("context_uri"),
+		TrackURI:   params.Get("track_uri"),
+		Shuffle:    strings.ToLower(params.Get("shuffle")) == "true",
+		Device:     params.Get("device"),
+	}
+	if snap.TrackURI == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "track_uri is required", Code: CodeBadRequest})
+		return
+	}
+	if v := params.Get("position_ms"); v != "" {
+		position, err := strconv.Atoi(v)
+		if err != nil || position < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "position_ms must be a non-negative integer", Code: CodeBadRequest})
+			return
+		}
+		snap.PositionMs = position
+	}
+
+	ctx, warnings := WithWarnings(r.Context())
+	msg, err := ResumeSnapshot(ctx, snap)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Code: ErrorCodeOf(err), Warnings: warnings.List()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Message: msg, Warnings: warnings.List()})
 }
@@ -0,0 +1,258 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: On-disk JSON settings file for configuration that doesn't
+// fit in flat env vars — rooms (friendly groupings of Spotify Connect
+// devices), device groups, presets (named playback recipes), handoff
+// peers, playback rules, and volume schedules.
+//
+
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Settings is the shape of the JSON settings file. Every section is
+// optional so a missing or partial file is always valid.
+type Settings struct {
+	Rooms   []Room            `json:"rooms,omitempty"`
+	Presets map[string]Preset `json:"presets,omitempty"`
+	// Groups are named sets of devices that play, pause, and set volume
+	// as one (see group.go).
+	Groups []DeviceGroup `json:"groups,omitempty"`
+	// Peers are other instances playback can be handed off to.
+	Peers []Peer `json:"peers,omitempty"`
+	// DeviceAccounts maps a device (name, Spotify ID, or stable ID) to
+	// the named account that plays on it when a request doesn't name one,
+	// e.g. the kids' room speaker to the kids' account.
+	DeviceAccounts map[string]string `json:"device_accounts,omitempty"`
+	// Rules run actions when playback events match their conditions.
+	Rules []Rule `json:"rules,omitempty"`
+	// VolumeSchedules cap device volumes by time of day.
+	VolumeSchedules []VolumeSchedule `json:"volume_schedules,omitempty"`
+	// Schedules play or pause on cron expressions (see scheduler.go).
+	Schedules []Schedule `json:"schedules,omitempty"`
+}
+
+// Room maps a human name (usually a Home Assistant area, e.g. "Kitchen")
+// to the Spotify Connect device names that live in it.
+type Room struct {
+	Name    string   `json:"name"`
+	Devices []string `json:"devices"`
+}
+
+// Preset is a named playback recipe. Fields mirror the /api/v1/play query
+// parameters so a preset is just a saved play request.
+type Preset struct {
+	Playlist string `json:"playlist,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Shuffle  bool   `json:"shuffle,omitempty"`
+	Volume   *int   `json:"volume,omitempty"`
+	// Start names the start-position strategy: first, random,
+	// least-recent, or newest.
+	Start string `json:"start,omitempty"`
+	// NewestFirst plays the playlist newest-added first.
+	NewestFirst bool `json:"newest_first,omitempty"`
+	// LeastPlayed plays the playlist least-played first.
+	LeastPlayed bool `json:"least_played,omitempty"`
+	// Duration plays about this long of the playlist, like "45m".
+	Duration string `json:"duration,omitempty"`
+	// StrictMetadata overrides STRICT_METADATA for this preset.
+	StrictMetadata *bool `json:"strict_metadata,omitempty"`
+	// Confirm waits until playback has actually started before reporting
+	// success.
+	Confirm bool `json:"confirm,omitempty"`
+	// OnEnd says what happens when the playlist runs out, as for /play.
+	OnEnd string `json:"on_end,omitempty"`
+	// FadeIn raises the volume from Volume to TargetVolume over this
+	// long, like "10m", as for /play.
+	FadeIn       string `json:"fadein,omitempty"`
+	TargetVolume *int   `json:"target_volume,omitempty"`
+	// Wait waits up to this long for the device to appear, as for /play.
+	Wait string `json:"wait,omitempty"`
+	// Account names the Spotify account to play on when the request
+	// doesn't name one.
+	Account string `json:"account,omitempty"`
+}
+
+// LoadSettings reads the settings file from disk into the package-level
+// settings. A missing file is not an error — it just means no rooms or
+// presets have been configured yet.
+func LoadSettings() error {
+	s, err := ReadSettingsFile(settingsFile)
+	if err != nil {
+		return err
+	}
+	setSettings(s)
+	return nil
+}
+
+// ReadSettingsFile parses the settings file at `path`. Returns an empty
+// Settings if the file doesn't exist.
+func ReadSettingsFile(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Settings{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read settings file: %w", err)
+	}
+
+	var s Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse settings file %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// WriteSettingsFile writes `s` to `path` as indented JSON so the file
+// stays pleasant to hand-edit.
+func WriteSettingsFile(path string, s *Settings) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode settings: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write settings file: %w", err)
+	}
+	return nil
+}
+
+// FindRoom looks up a configured room by name (case insensitive).
+func (s *Settings) FindRoom(name string) (Room, bool) {
+	for _, r := range s.Rooms {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Room{}, false
+}
+
+// FindPreset looks up a preset by name (case insensitive).
+func (s *Settings) FindPreset(name string) (Preset, bool) {
+	if p, ok := s.Presets[name]; ok {
+		return p, true
+	}
+	for k, p := range s.Presets {
+		if strings.EqualFold(k, name) {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// AccountForDevice returns the account DeviceAccounts assigns to `ref`
+// (case insensitive), or "".
+func (s *Settings) AccountForDevice(ref string) string {
+	if name, ok := s.DeviceAccounts[ref]; ok {
+		return name
+	}
+	for k, name := range s.DeviceAccounts {
+		if strings.EqualFold(k, ref) {
+			return name
+		}
+	}
+	return ""
+}
+
+// clone returns a deep copy of `s`, for changing settings without touching
+// the loaded ones other goroutines are reading.
+func (s *Settings) clone() (*Settings, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	c := &Settings{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RemapDeviceID replaces Spotify device ID `oldID` with `newID` in preset,
+// room, and group device references, returning how many were changed. Names and
+// stable IDs are left alone; they don't change when a speaker resets.
+func (s *Settings) RemapDeviceID(oldID, newID string) int {
+	n := 0
+	for name, p := range s.Presets {
+		if p.Device == oldID {
+			p.Device = newID
+			s.Presets[name] = p
+			n++
+		}
+	}
+	for i := range s.Rooms {
+		for j, d := range s.Rooms[i].Devices {
+			if d == oldID {
+				s.Rooms[i].Devices[j] = newID
+				n++
+			}
+		}
+	}
+	for i := range s.Groups {
+		for j, d := range s.Groups[i].Devices {
+			if d == oldID {
+				s.Groups[i].Devices[j] = newID
+				n++
+			}
+		}
+		if s.Groups[i].Primary == oldID {
+			s.Groups[i].Primary = newID
+			n++
+		}
+	}
+	return n
+}
+
+// Merge folds rooms and presets from `other` into `s`, keeping any entry
+// that already exists in `s`. Used by importers so re-running an import
+// never clobbers hand edits.
+func (s *Settings) Merge(other *Settings) (addedRooms, addedPresets int) {
+	for _, r := range other.Rooms {
+		if _, ok := s.FindRoom(r.Name); ok {
+			continue
+		}
+		s.Rooms = append(s.Rooms, r)
+		addedRooms++
+	}
+
+	for name, p := range other.Presets {
+		if _, ok := s.Presets[name]; ok {
+			continue
+		}
+		if s.Presets == nil {
+			s.Presets = make(map[string]Preset)
+		}
+		s.Presets[name] = p
+		addedPresets++
+	}
+
+	return addedRooms, addedPresets
+}
+
+// MergeIntoSettings merges `imported` into a copy of the loaded settings,
+// saves the copy to the settings file, and only then swaps it in, so other
+// goroutines never see a half-merged value and a failed save changes
+// nothing. It holds reloadMu so a reload can't interleave with it.
+func MergeIntoSettings(imported *Settings) (addedRooms, addedPresets int, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	s, err := GetSettings().clone()
+	if err != nil {
+		return 0, 0, fmt.Errorf("copy settings: %w", err)
+	}
+	addedRooms, addedPresets = s.Merge(imported)
+	if err := WriteSettingsFile(settingsFile, s); err != nil {
+		return 0, 0, err
+	}
+	setSettings(s)
+	return addedRooms, addedPresets, nil
+}
@@ -0,0 +1,100 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: /api/v1/shortcut/summary, what's playing as a small flat
+// dictionary for Siri Shortcuts. "Get Contents of URL" turns it straight
+// into a dictionary, so every key is always there and "Show Result" on
+// the `summary` key reads as a sentence. Shortcuts can send the token in
+// an X-API-Token header (see requestToken).
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+)
+
+// ShortcutSummary is the response of /api/v1/shortcut/summary. Fields
+// are empty strings, never missing, when nothing is playing.
+type ShortcutSummary struct {
+	Track     string `json:"track"`
+	Artist    string `json:"artist"`
+	Playlist  string `json:"playlist"`
+	Device    string `json:"device"`
+	IsPlaying bool   `json:"is_playing"`
+	// Summary is the rest as a sentence, e.g. "Playing So What by Miles
+	// Davis on Kitchen", or the error.
+	Summary string    `json:"summary"`
+	Error   string    `json:"error,omitempty"`
+	Code    ErrorCode `json:"code,omitempty"`
+}
+
+// BuildShortcutSummary returns what ctx's account is playing. Playlist is
+// the playing playlist's or album's name, when there is one.
+func BuildShortcutSummary(ctx context.Context) (ShortcutSummary, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return ShortcutSummary{}, err
+	}
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		return ShortcutSummary{}, err
+	}
+	if state == nil || state.Item == nil {
+		return ShortcutSummary{Summary: "Nothing is playing"}, nil
+	}
+
+	ev := snapshotFromState(state).event(EventState, time.Now())
+	sum := ShortcutSummary{Track: ev.TrackName, Artist: ev.Artists, Device: ev.DeviceName, IsPlaying: ev.IsPlaying}
+	if r, err := spotifyuri.Parse(ev.ContextURI); err == nil {
+		switch r.Type {
+		case spotifyuri.Playlist:
+			if pl, err := client.GetPlaylist(ctx, spotifyLib.ID(r.ID)); err == nil {
+				sum.Playlist = pl.Name
+			}
+		case spotifyuri.Album:
+			sum.Playlist = ev.Album
+		}
+	}
+
+	sum.Summary = "Paused " + sum.Track
+	if sum.IsPlaying {
+		sum.Summary = "Playing " + sum.Track
+	}
+	if sum.Artist != "" {
+		sum.Summary += " by " + sum.Artist
+	}
+	if sum.Device != "" {
+		sum.Summary += " on " + sum.Device
+	}
+	return sum, nil
+}
+
+// HandleShortcutSummaryRequest handles GET /api/v1/shortcut/summary.
+// Requires the API access token.
+func HandleShortcutSummaryRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if requestToken(r) != GetAPIAccessToken() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ShortcutSummary{Summary: "Error: invalid or missing access token", Error: "Invalid or missing access token", Code: CodeAuth})
+		return
+	}
+
+	sum, err := BuildShortcutSummary(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ShortcutSummary{Summary: "Error: " + err.Error(), Error: err.Error(), Code: ErrorCodeOf(err)})
+		return
+	}
+	json.NewEncoder(w).Encode(sum)
+}
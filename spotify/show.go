@@ -0,0 +1,105 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Podcast playback. A show plays as a context, from the top
+// of its episode list, and a single episode resumes where you left off.
+// Both are named by URI, link, or ID; there's no lookup by name.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// isEpisodeInput reports whether input is an episode URI or link.
+func isEpisodeInput(input string) bool {
+	r, err := spotifyuri.Parse(input)
+	return err == nil && r.Type == spotifyuri.Episode
+}
+
+// ResolveShow finds the show or episode `input` names. A bare ID is taken
+// as a show.
+func ResolveShow(ctx context.Context, client Client, input string) (*ResolvedShow, error) {
+	target, err := ResolvePlayTarget(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case target.Type == spotifyuri.Playlist && target.ID != "":
+		// ResolvePlayTarget takes bare IDs for playlists.
+		target.Type = spotifyuri.Show
+		target.URI = spotifyuri.Resource{Type: spotifyuri.Show, ID: target.ID}.URI()
+	case target.ID == "":
+		return nil, fmt.Errorf("shows are played by link, URI, or ID, not by name (%q)", input)
+	case target.Type != spotifyuri.Show && target.Type != spotifyuri.Episode:
+		return nil, fmt.Errorf("%q is a Spotify %s, not a show or episode", input, target.Type)
+	}
+
+	show := &ResolvedShow{Input: input, Type: string(target.Type), ID: target.ID, URI: target.URI, MatchedBy: "uri"}
+	if spotifyuri.IsID(strings.TrimSpace(input)) {
+		show.MatchedBy = "id"
+	}
+	if target.Type == spotifyuri.Episode {
+		episode, err := client.GetEpisode(ctx, target.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get episode: %w", err)
+		}
+		show.Name, show.Show, show.Publisher = episode.Name, episode.Show.Name, episode.Show.Publisher
+		if !episode.ResumePoint.FullyPlayed {
+			show.PositionMs = int(episode.ResumePoint.ResumePositionMs)
+		}
+		return show, nil
+	}
+
+	full, err := client.GetShow(ctx, spotifyLib.ID(target.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get show: %w", err)
+	}
+	show.Name, show.Show, show.Publisher = full.Name, full.Name, full.Publisher
+	return show, nil
+}
+
+// playShow plays req's show or episode on `targetDevice`.
+func playShow(ctx context.Context, client Client, req PlayRequest, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	show, err := ResolveShow(ctx, client, req.Show)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve show: %w", err)
+	}
+
+	opts := PlayTarget{Type: spotifyuri.Type(show.Type), ID: show.ID, URI: show.URI}.playOptions(targetDevice.ID)
+	if opts == nil {
+		return "", nil, errors.New("nothing to play")
+	}
+	opts.PositionMs = spotifyLib.Numeric(show.PositionMs)
+	if err := client.PlayOpt(ctx, opts); err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+
+	uri := spotifyLib.URI(show.URI)
+	if show.Type == string(spotifyuri.Episode) {
+		recordStart(ctx, "", opts.URIs)
+		target := &playbackTarget{device: targetDevice, trackURI: uri, lastTrackURI: uri}
+		msg := fmt.Sprintf("Now playing \"%s\" from %s on %s", show.Name, show.Show, targetDevice.Name)
+		if show.PositionMs > 0 {
+			msg += fmt.Sprintf(" (resuming at %s)", formatPosition(show.PositionMs))
+		}
+		return msg, target, nil
+	}
+	recordStart(ctx, show.URI, nil)
+	target := &playbackTarget{device: targetDevice, contextURI: uri}
+
+	if req.Shuffle {
+		enableShuffle(ctx, client)
+		return fmt.Sprintf("Now playing \"%s\" on %s (shuffle enabled)", show.Name, targetDevice.Name), target, nil
+	}
+	return fmt.Sprintf("Now playing \"%s\" on %s", show.Name, targetDevice.Name), target, nil
+}
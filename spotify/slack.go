@@ -0,0 +1,139 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Slack slash command. With SLACK_SIGNING_SECRET set,
+// /integrations/slack takes a slash command such as
+// `/music play Focus Mix on Office`, checks Slack's request signature in
+// place of the API token, runs the chat command (chatcommand.go), and
+// replies with an ephemeral message only the sender sees. Slack gives up
+// on a reply after three seconds, so a slow play answers "working on it"
+// and posts the result to the command's response URL when it's done.
+//
+
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackSigningSecret is the app's signing secret; empty means the
+// endpoint is off.
+var slackSigningSecret string
+
+// slackMaxSkew is how old a request's timestamp may be before it's
+// treated as a replay.
+const slackMaxSkew = 5 * time.Minute
+
+// slackReplyWithin is how long a command may run before the reply moves
+// to the response URL; a variable so tests can shorten it.
+var slackReplyWithin = 2500 * time.Millisecond
+
+// SlackResponse is a slash command reply.
+type SlackResponse struct {
+	// ResponseType is "ephemeral": only the sender sees the reply.
+	ResponseType    string `json:"response_type"`
+	Text            string `json:"text"`
+	ReplaceOriginal bool   `json:"replace_original,omitempty"`
+}
+
+// EnableSlack turns on /integrations/slack, checking requests with the
+// Slack app's signing secret.
+func EnableSlack(signingSecret string) {
+	slackSigningSecret = signingSecret
+}
+
+// verifySlackSignature checks the X-Slack-Signature of `body`, signed at
+// X-Slack-Request-Timestamp, against `secret`.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) bool {
+	ts, err := strconv.ParseInt(header.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + strconv.FormatInt(ts, 10) + ":"))
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(header.Get("X-Slack-Signature")))
+}
+
+// HandleSlackCommandRequest handles POST /integrations/slack: a Slack
+// slash command, answered with an ephemeral message. It's only served
+// with SLACK_SIGNING_SECRET set.
+func HandleSlackCommandRequest(w http.ResponseWriter, r *http.Request) {
+	secret := slackSigningSecret
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !verifySlackSignature(secret, r.Header, body, time.Now()) {
+		http.Error(w, "Unauthorized: invalid Slack signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cs := chatStyle{Prefix: form.Get("command") + " ", Bold: func(s string) string { return "*" + s + "*" }}
+	cmd, args, _ := strings.Cut(strings.TrimSpace(form.Get("text")), " ")
+	// The command outlives the request when it's slow.
+	ctx := withPlaySource(context.Background(), "slack "+form.Get("user_name"))
+	done := make(chan string, 1)
+	go func() { done <- runChatCommand(ctx, cs, cmd, args) }()
+
+	reply := SlackResponse{ResponseType: "ephemeral"}
+	select {
+	case reply.Text = <-done:
+	case <-time.After(slackReplyWithin):
+		reply.Text = "Working on it…"
+		go postSlackResponse(form.Get("response_url"), done)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// postSlackResponse waits for a slow command's reply and posts it to the
+// command's response URL, replacing the "working on it" message.
+func postSlackResponse(responseURL string, done <-chan string) {
+	text := <-done
+	if responseURL == "" {
+		return
+	}
+	body, _ := json.Marshal(SlackResponse{ResponseType: "ephemeral", Text: text, ReplaceOriginal: true})
+	req, err := http.NewRequest(http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Warning: invalid Slack response URL: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := sendNotification(req); err != nil {
+		log.Printf("Warning: Slack reply failed: %v", err)
+	}
+}
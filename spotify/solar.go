@@ -0,0 +1,168 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Sunrise and sunset schedule times. A `when` like
+// "daily at sunset" or "weekdays at sunrise+30m" runs when the sun rises
+// or sets at SCHEDULE_LATITUDE/SCHEDULE_LONGITUDE, so evening music starts
+// as it actually gets dark. The times are worked out locally with NOAA's
+// approximate solar position formulas, good to a minute or two away from
+// the poles; on days the sun doesn't rise or set there, the schedule
+// doesn't run.
+//
+
+package spotify
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Solar events a schedule can run at.
+const (
+	SolarSunrise = "sunrise"
+	SolarSunset  = "sunset"
+)
+
+// maxSolarOffset bounds the offset on a solar time, keeping a run on the
+// day of the event it follows.
+const maxSolarOffset = 6 * time.Hour
+
+// geoPoint is a place on Earth, in degrees.
+type geoPoint struct {
+	lat, lon float64
+}
+
+// scheduleCoordinates is where solar schedule times are worked out for;
+// nil until SetScheduleCoordinates is given a place.
+var scheduleCoordinates *geoPoint
+
+// SetScheduleCoordinates sets where sunrise and sunset schedules are
+// worked out for, from decimal degrees like "40.71" and "-74.01". Both
+// empty turns solar times off.
+func SetScheduleCoordinates(lat, lon string) error {
+	if lat == "" && lon == "" {
+		scheduleCoordinates = nil
+		return nil
+	}
+	if lat == "" || lon == "" {
+		return fmt.Errorf("SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE go together")
+	}
+	la, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil || la < -90 || la > 90 {
+		return fmt.Errorf("latitude must be a number between -90 and 90, got %q", lat)
+	}
+	lo, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil || lo < -180 || lo > 180 {
+		return fmt.Errorf("longitude must be a number between -180 and 180, got %q", lon)
+	}
+	scheduleCoordinates = &geoPoint{lat: la, lon: lo}
+	return nil
+}
+
+// solarTime is a schedule time tied to the sun: an event and an offset
+// from it, at a place.
+type solarTime struct {
+	event  string
+	offset time.Duration
+	at     geoPoint
+}
+
+// parseSolarTime parses a solar time like "sunset", "sunrise+30m", or
+// "sunset-1h15m", reporting false if `s` doesn't name a solar event.
+func parseSolarTime(s string) (event string, offset time.Duration, ok bool, err error) {
+	for _, name := range []string{SolarSunrise, SolarSunset} {
+		rest, found := strings.CutPrefix(s, name)
+		if !found {
+			continue
+		}
+		if rest == "" {
+			return name, 0, true, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			return "", 0, true, fmt.Errorf("time %q isn't like %s, %s+30m, or %s-15m", s, name, name, name)
+		}
+		offset, err := time.ParseDuration(rest)
+		if err != nil {
+			return "", 0, true, fmt.Errorf("time %q isn't like %s, %s+30m, or %s-15m", s, name, name, name)
+		}
+		if offset < -maxSolarOffset || offset > maxSolarOffset {
+			return "", 0, true, fmt.Errorf("time %q: the offset can be at most %s either way", s, formatDuration(maxSolarOffset))
+		}
+		return name, offset, true, nil
+	}
+	return "", 0, false, nil
+}
+
+// sunEvent returns when the sun rises (or sets) on the date y-m-d at
+// `at`, taking the date as the day around local solar noon there. It
+// reports false on days the sun stays up or down.
+func sunEvent(y int, m time.Month, d int, event string, at geoPoint) (time.Time, bool) {
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	g := 2 * math.Pi / 365 * float64(midnight.YearDay()-1)
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) - 0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) - 0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) - 0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+
+	// The sun's center 0.833 degrees below the horizon allows for
+	// refraction and the size of its disc.
+	lat := at.lat * math.Pi / 180
+	cosHA := math.Cos(90.833*math.Pi/180)/(math.Cos(lat)*math.Cos(decl)) - math.Tan(lat)*math.Tan(decl)
+	if cosHA < -1 || cosHA > 1 {
+		return time.Time{}, false
+	}
+	ha := math.Acos(cosHA) * 180 / math.Pi
+	if event == SolarSunset {
+		ha = -ha
+	}
+	minutes := 720 - 4*(at.lon+ha) - eqTime
+	return midnight.Add(time.Duration(minutes * float64(time.Minute))), true
+}
+
+// on returns the minute the schedule runs on the date y-m-d.
+func (s solarTime) on(y int, m time.Month, d int) (time.Time, bool) {
+	t, ok := sunEvent(y, m, d, s.event, s.at)
+	if !ok {
+		return time.Time{}, false
+	}
+	return t.Add(s.offset).Truncate(time.Minute), true
+}
+
+// solarDue reports whether a solar schedule on this expression runs in
+// the minute starting at `at`. The expression supplies the days, in
+// `loc`; the day before and after are checked too, since an offset or a
+// zone far from the sun's can carry a run past midnight.
+func (c cronSpec) solarDue(at time.Time, loc *time.Location) bool {
+	at = at.Truncate(time.Minute)
+	local := at.In(loc)
+	for i := -1; i <= 1; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 12, 0, 0, 0, loc)
+		if !c.month[int(day.Month())] || !c.dayMatches(day) {
+			continue
+		}
+		if t, ok := c.solar.on(day.Date()); ok && t.Equal(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// solarNext returns when a solar schedule on this expression next runs
+// after `after`, in after's zone. One that can't run in the next two
+// years (sunrise in a polar night) reports false.
+func (c cronSpec) solarNext(after time.Time) (time.Time, bool) {
+	loc := after.Location()
+	for i := -1; i <= 2*366; i++ {
+		day := time.Date(after.Year(), after.Month(), after.Day()+i, 12, 0, 0, 0, loc)
+		if !c.month[int(day.Month())] || !c.dayMatches(day) {
+			continue
+		}
+		if t, ok := c.solar.on(day.Date()); ok && t.After(after) {
+			return t.In(loc), true
+		}
+	}
+	return time.Time{}, false
+}
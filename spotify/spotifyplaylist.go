@@ -0,0 +1,51 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Spotify's own playlists by name. Name lookups only see the
+// playlists in your library, which leaves out Discover Weekly, Release
+// Radar, the Daily Mixes, and Spotify's editorial playlists unless you
+// follow them. When nothing in the library matches, the catalog is
+// searched for a playlist of that exact name owned by Spotify.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// spotifyOwnerID is the user ID Spotify's own playlists belong to.
+const spotifyOwnerID = "spotify"
+
+// spotifyPlaylistSearchLimit is how many search results are checked for a
+// Spotify-owned name match; user playlists copying the name crowd them.
+const spotifyPlaylistSearchLimit = 20
+
+// findSpotifyPlaylist searches the catalog for a Spotify-owned playlist
+// named `name` (case-insensitive). It returns nil when there's none.
+func findSpotifyPlaylist(ctx context.Context, client Client, name string) (*spotifyLib.SimplePlaylist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	result, err := client.Search(ctx, name, spotifyLib.SearchTypePlaylist, spotifyLib.Limit(spotifyPlaylistSearchLimit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for playlist %q: %w", name, err)
+	}
+	if result == nil || result.Playlists == nil {
+		return nil, nil
+	}
+	// Spotify returns null for some results, which decode as empty.
+	for i, p := range result.Playlists.Playlists {
+		if p.Owner.ID == spotifyOwnerID && strings.EqualFold(p.Name, name) {
+			return &result.Playlists.Playlists[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,220 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Parsing of everything people paste to identify something on
+// Spotify: spotify: URIs (including legacy user-scoped playlist URIs),
+// open.spotify.com and play.spotify.com links (localized and embed forms
+// too), spotify.link shortlinks, and bare base62 IDs. Parse is pure;
+// Resolve additionally follows shortlinks over the network.
+//
+
+package spotifyuri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Type is the kind of Spotify resource.
+type Type string
+
+// Resource types. A bare ID parses with an empty Type because nothing in
+// it says what it is; Shortlink is a spotify.link code that Resolve can
+// expand.
+const (
+	Playlist  Type = "playlist"
+	Album     Type = "album"
+	Artist    Type = "artist"
+	Track     Type = "track"
+	Show      Type = "show"
+	Episode   Type = "episode"
+	Audiobook Type = "audiobook"
+	Chapter   Type = "chapter"
+	User      Type = "user"
+	Shortlink Type = "shortlink"
+)
+
+// knownTypes are the types that can appear in URIs and link paths.
+var knownTypes = map[Type]bool{
+	Playlist: true, Album: true, Artist: true, Track: true, Show: true, Episode: true, Audiobook: true, Chapter: true, User: true,
+}
+
+// ErrUnrecognized is wrapped by Parse for input that isn't a Spotify URI,
+// link, or ID.
+var ErrUnrecognized = errors.New("not a Spotify URI, link, or ID")
+
+// Resource is a parsed Spotify reference.
+type Resource struct {
+	Type Type
+	// ID is the base62 ID, the user name for User, or the short code for
+	// Shortlink.
+	ID string
+	// Owner is the user a legacy spotify:user:<name>:playlist:<id> URI was
+	// scoped to. It doesn't affect playback.
+	Owner string
+}
+
+// URI returns the canonical spotify:<type>:<id> form, or "" for a bare ID
+// or shortlink.
+func (r Resource) URI() string {
+	if !knownTypes[r.Type] {
+		return ""
+	}
+	return "spotify:" + string(r.Type) + ":" + r.ID
+}
+
+// URL returns the open.spotify.com link, or the spotify.link URL for a
+// shortlink, or "" for a bare ID.
+func (r Resource) URL() string {
+	switch {
+	case r.Type == Shortlink:
+		return "https://spotify.link/" + r.ID
+	case knownTypes[r.Type]:
+		return "https://open.spotify.com/" + string(r.Type) + "/" + r.ID
+	default:
+		return ""
+	}
+}
+
+// idPattern matches a bare Spotify ID. IDs inside URIs and links only
+// have to match base62Pattern, since their position already says what
+// they are.
+var (
+	idPattern     = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+	base62Pattern = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+)
+
+// IsID reports whether s is a bare 22-character base62 Spotify ID.
+func IsID(s string) bool {
+	return idPattern.MatchString(s)
+}
+
+// Parse identifies `input`. It accepts:
+//
+//	spotify:track:<id>, spotify:user:<name>, spotify:user:<name>:playlist:<id>
+//	https://open.spotify.com/[intl-xx/][embed/]<type>/<id>[?si=...]
+//	https://spotify.link/<code>
+//	<22-character base62 id>
+//
+// Anything else is an error wrapping ErrUnrecognized.
+func Parse(input string) (Resource, error) {
+	s := strings.TrimSpace(input)
+
+	switch {
+	case strings.HasPrefix(s, "spotify:"):
+		return parseURI(s)
+	case strings.Contains(s, "spotify.com/"), strings.Contains(s, "spotify.link/"), strings.Contains(s, "spotify.app.link/"):
+		return parseURL(s)
+	case IsID(s):
+		return Resource{ID: s}, nil
+	}
+	return Resource{}, fmt.Errorf("%q: %w", input, ErrUnrecognized)
+}
+
+// parseURI handles the spotify: scheme.
+func parseURI(s string) (Resource, error) {
+	parts := strings.Split(s, ":")
+
+	switch {
+	case len(parts) == 3 && knownTypes[Type(parts[1])] && parts[2] != "":
+		return validated(Resource{Type: Type(parts[1]), ID: parts[2]}, s)
+	case len(parts) == 5 && parts[1] == "user" && parts[3] == "playlist":
+		return validated(Resource{Type: Playlist, ID: parts[4], Owner: parts[2]}, s)
+	}
+	return Resource{}, fmt.Errorf("%q: %w", s, ErrUnrecognized)
+}
+
+// parseURL handles open.spotify.com, play.spotify.com, and shortlinks.
+func parseURL(s string) (Resource, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return Resource{}, fmt.Errorf("%q: %w", s, ErrUnrecognized)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	host := strings.ToLower(u.Hostname())
+
+	switch host {
+	case "spotify.link", "spotify.app.link":
+		if len(parts) == 1 && parts[0] != "" {
+			return Resource{Type: Shortlink, ID: parts[0]}, nil
+		}
+	case "open.spotify.com", "play.spotify.com":
+		// Localized links look like /intl-de/track/<id>; embeds like
+		// /embed/track/<id>.
+		if len(parts) > 0 && strings.HasPrefix(parts[0], "intl-") {
+			parts = parts[1:]
+		}
+		if len(parts) > 0 && parts[0] == "embed" {
+			parts = parts[1:]
+		}
+		switch {
+		case len(parts) == 2 && knownTypes[Type(parts[0])] && parts[1] != "":
+			return validated(Resource{Type: Type(parts[0]), ID: parts[1]}, s)
+		case len(parts) == 4 && parts[0] == "user" && parts[2] == "playlist":
+			return validated(Resource{Type: Playlist, ID: parts[3], Owner: parts[1]}, s)
+		}
+	}
+	return Resource{}, fmt.Errorf("%q: %w", s, ErrUnrecognized)
+}
+
+// validated checks the ID of a non-user resource is base62.
+func validated(r Resource, input string) (Resource, error) {
+	if r.Type != User && !base62Pattern.MatchString(r.ID) {
+		return Resource{}, fmt.Errorf("%q has an invalid %s ID: %w", input, r.Type, ErrUnrecognized)
+	}
+	return r, nil
+}
+
+// openURLPattern finds an open.spotify.com link in a shortlink landing
+// page.
+var openURLPattern = regexp.MustCompile(`https://open\.spotify\.com/[^"'\s<>]+`)
+
+// Resolve parses `input` like Parse and, if it is a shortlink, follows it
+// with `client` (http.DefaultClient when nil) to the resource it points at.
+// spotify.link answers with redirects and, at the end of them, sometimes
+// an HTML page carrying the real link, so both are handled.
+func Resolve(ctx context.Context, client *http.Client, input string) (Resource, error) {
+	r, err := Parse(input)
+	if err != nil || r.Type != Shortlink {
+		return r, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL(), nil)
+	if err != nil {
+		return Resource{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Resource{}, fmt.Errorf("follow shortlink %s: %w", r.URL(), err)
+	}
+	defer resp.Body.Close()
+
+	if final := resp.Request.URL; strings.EqualFold(final.Hostname(), "open.spotify.com") {
+		if target, err := Parse(final.String()); err == nil {
+			return target, nil
+		}
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256<<10))
+	for _, link := range openURLPattern.FindAllString(string(body), -1) {
+		if target, err := Parse(link); err == nil {
+			return target, nil
+		}
+	}
+	return Resource{}, fmt.Errorf("shortlink %s didn't lead to a Spotify link", r.URL())
+}
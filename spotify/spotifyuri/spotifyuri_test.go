@@ -0,0 +1,130 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Tests for Spotify URI and link parsing.
+//
+
+package spotifyuri
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParse covers every accepted input form and a few rejects.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Resource
+		wantErr bool
+	}{
+		{input: "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M", want: Resource{Type: Playlist, ID: "37i9dQZF1DXcBWIGoYBM5M"}},
+		{input: "spotify:album:4aawyAB9vmqN3uQ7FjRGTy", want: Resource{Type: Album, ID: "4aawyAB9vmqN3uQ7FjRGTy"}},
+		{input: "spotify:artist:0TnOYISbd1XYRBk9myaseg", want: Resource{Type: Artist, ID: "0TnOYISbd1XYRBk9myaseg"}},
+		{input: " spotify:track:4uLU6hMCjMI75M1A2tKUQC ", want: Resource{Type: Track, ID: "4uLU6hMCjMI75M1A2tKUQC"}},
+		{input: "spotify:show:5CfCWKI5pZ28U0uOzXkDHe", want: Resource{Type: Show, ID: "5CfCWKI5pZ28U0uOzXkDHe"}},
+		{input: "spotify:episode:512ojhOuo1ktJprKbVcKyQ", want: Resource{Type: Episode, ID: "512ojhOuo1ktJprKbVcKyQ"}},
+		{input: "spotify:user:spotify", want: Resource{Type: User, ID: "spotify"}},
+		{input: "spotify:user:spotify:playlist:37i9dQZF1DXcBWIGoYBM5M", want: Resource{Type: Playlist, ID: "37i9dQZF1DXcBWIGoYBM5M", Owner: "spotify"}},
+		{input: "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc123", want: Resource{Type: Playlist, ID: "37i9dQZF1DXcBWIGoYBM5M"}},
+		{input: "https://open.spotify.com/intl-de/track/4uLU6hMCjMI75M1A2tKUQC", want: Resource{Type: Track, ID: "4uLU6hMCjMI75M1A2tKUQC"}},
+		{input: "https://open.spotify.com/embed/album/4aawyAB9vmqN3uQ7FjRGTy", want: Resource{Type: Album, ID: "4aawyAB9vmqN3uQ7FjRGTy"}},
+		{input: "open.spotify.com/show/5CfCWKI5pZ28U0uOzXkDHe", want: Resource{Type: Show, ID: "5CfCWKI5pZ28U0uOzXkDHe"}},
+		{input: "https://open.spotify.com/audiobook/7iHfbu1YPACw6oZPAFJtqe?si=x", want: Resource{Type: Audiobook, ID: "7iHfbu1YPACw6oZPAFJtqe"}},
+		{input: "spotify:chapter:0D5wENdkdwbqlrHoaJ9g29", want: Resource{Type: Chapter, ID: "0D5wENdkdwbqlrHoaJ9g29"}},
+		{input: "https://open.spotify.com/user/spotify/playlist/37i9dQZF1DXcBWIGoYBM5M", want: Resource{Type: Playlist, ID: "37i9dQZF1DXcBWIGoYBM5M", Owner: "spotify"}},
+		{input: "https://open.spotify.com/user/someone", want: Resource{Type: User, ID: "someone"}},
+		{input: "https://spotify.link/a1b2c3d4e5", want: Resource{Type: Shortlink, ID: "a1b2c3d4e5"}},
+		{input: "37i9dQZF1DXcBWIGoYBM5M", want: Resource{ID: "37i9dQZF1DXcBWIGoYBM5M"}},
+		{input: "My Chill Playlist", wantErr: true},
+		{input: "Chill", wantErr: true},
+		{input: "spotify:playlist:not-an-id", wantErr: true},
+		{input: "spotify:banana:37i9dQZF1DXcBWIGoYBM5M", wantErr: true},
+		{input: "https://open.spotify.com/", wantErr: true},
+		{input: "https://example.com/playlist/37i9dQZF1DXcBWIGoYBM5M", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnrecognized) {
+				t.Errorf("Parse(%q): expected ErrUnrecognized, got %+v, %v", tt.input, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+// TestResourceURIAndURL tests the canonical forms.
+func TestResourceURIAndURL(t *testing.T) {
+	r := Resource{Type: Playlist, ID: "37i9dQZF1DXcBWIGoYBM5M", Owner: "spotify"}
+	if r.URI() != "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M" {
+		t.Errorf("unexpected URI %q", r.URI())
+	}
+	if r.URL() != "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M" {
+		t.Errorf("unexpected URL %q", r.URL())
+	}
+	if (Resource{ID: "37i9dQZF1DXcBWIGoYBM5M"}).URI() != "" {
+		t.Error("expected empty URI for a bare ID")
+	}
+}
+
+// TestResolve follows a shortlink through a redirect and through an HTML
+// landing page.
+func TestResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/landing", http.StatusTemporaryRedirect)
+		case "/landing":
+			w.Write([]byte(`<html><a href="https://open.spotify.com/album/4aawyAB9vmqN3uQ7FjRGTy?si=x">Open</a></html>`))
+		default:
+			w.Write([]byte(`<html>nothing here</html>`))
+		}
+	}))
+	defer srv.Close()
+
+	// Route spotify.link to the test server.
+	client := &http.Client{Transport: rewriteHost{target: strings.TrimPrefix(srv.URL, "http://")}}
+
+	got, err := Resolve(context.Background(), client, "https://spotify.link/redirect")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != (Resource{Type: Album, ID: "4aawyAB9vmqN3uQ7FjRGTy"}) {
+		t.Errorf("unexpected resource %+v", got)
+	}
+
+	if _, err := Resolve(context.Background(), client, "https://spotify.link/dead"); err == nil {
+		t.Error("expected error for a shortlink with no target")
+	}
+
+	// Non-shortlinks don't touch the network.
+	got, err = Resolve(context.Background(), nil, "spotify:track:4uLU6hMCjMI75M1A2tKUQC")
+	if err != nil || got.Type != Track {
+		t.Errorf("unexpected result %+v, %v", got, err)
+	}
+}
+
+// rewriteHost sends every request to the test server over plain HTTP.
+type rewriteHost struct {
+	target string
+}
+
+// RoundTrip rewrites the request URL and forwards it.
+func (rh rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = rh.target
+	return http.DefaultTransport.RoundTrip(req)
+}
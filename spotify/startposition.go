@@ -0,0 +1,204 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Pluggable start-position strategies. A strategy picks the
+// track a playlist starts on — first track, pure random, weighted toward
+// positions we haven't started on lately, or the most recently added
+// track — and can be chosen per play request or per preset.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Start strategy names accepted by the `start` play parameter, the
+// -start CLI flag, and the preset `start` field.
+const (
+	StartFirst       = "first"
+	StartRandom      = "random"
+	StartLeastRecent = "least-recent"
+	StartNewest      = "newest"
+)
+
+// StartStrategy picks the zero-based track position a playlist should
+// start playing from.
+type StartStrategy interface {
+	Pick(ctx context.Context, client Client, playlistID string, trackCount int) (int, error)
+}
+
+// firstStart always starts at track 1.
+type firstStart struct{}
+
+// Pick returns position 0.
+func (firstStart) Pick(ctx context.Context, client Client, playlistID string, trackCount int) (int, error) {
+	return 0, nil
+}
+
+// randomStart picks a uniformly random track — the original shuffle
+// behavior.
+type randomStart struct{}
+
+// Pick returns a random position in the playlist.
+func (randomStart) Pick(ctx context.Context, client Client, playlistID string, trackCount int) (int, error) {
+	if trackCount <= 0 {
+		return 0, nil
+	}
+	return rand.Intn(trackCount), nil
+}
+
+// leastRecentStart picks a random track weighted by how long it's been
+// since we last started the playlist there, so repeated plays of the same
+// playlist don't keep opening on the same handful of songs.
+type leastRecentStart struct {
+	history *startHistory
+}
+
+// leastRecentMaxAge is the age at which a position counts as "never
+// started" — anything older gets the full weight.
+const leastRecentMaxAge = 7 * 24 * time.Hour
+
+// Pick returns a position chosen with weight proportional to the time
+// since it was last used as a start position (capped at a week).
+func (s leastRecentStart) Pick(ctx context.Context, client Client, playlistID string, trackCount int) (int, error) {
+	if trackCount <= 0 {
+		return 0, nil
+	}
+
+	started := s.history.startsFor(playlistID)
+	now := time.Now()
+
+	weights := make([]float64, trackCount)
+	var total float64
+	for i := range weights {
+		age := leastRecentMaxAge
+		if at, ok := started[i]; ok && now.Sub(at) < age {
+			age = now.Sub(at)
+		}
+		// +1s keeps a just-started position from getting a zero weight,
+		// which would break the draw when every position is brand new.
+		weights[i] = age.Seconds() + 1
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return i, nil
+		}
+		r -= w
+	}
+	return trackCount - 1, nil
+}
+
+// newestStart starts at the most recently added track, for playlists used
+// as an inbox of new music.
+type newestStart struct{}
+
+// Pick fetches the playlist items and returns the position of the item
+// with the latest added_at timestamp.
+func (newestStart) Pick(ctx context.Context, client Client, playlistID string, trackCount int) (int, error) {
+	items, err := fetchPlaylistItems(ctx, client, playlistID)
+	if err != nil {
+		return 0, err
+	}
+
+	newestPos := 0
+	newestAt := ""
+	for i, item := range items {
+		// added_at is RFC 3339 in UTC, so string order is time order.
+		if item.AddedAt > newestAt {
+			newestAt = item.AddedAt
+			newestPos = i
+		}
+	}
+	return newestPos, nil
+}
+
+// startHistory remembers when each playlist was last started at each
+// position. It lives in memory for the life of the process.
+type startHistory struct {
+	mu     sync.Mutex
+	starts map[string]map[int]time.Time
+}
+
+// defaultStartHistory backs the least-recent strategy and is fed by every
+// successful playlist start.
+var defaultStartHistory = &startHistory{}
+
+// record notes that playlistID was started at position just now.
+func (h *startHistory) record(playlistID string, position int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.starts == nil {
+		h.starts = make(map[string]map[int]time.Time)
+	}
+	if h.starts[playlistID] == nil {
+		h.starts[playlistID] = make(map[int]time.Time)
+	}
+	h.starts[playlistID][position] = time.Now()
+}
+
+// startsFor returns a copy of the start times recorded for playlistID.
+func (h *startHistory) startsFor(playlistID string) map[int]time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[int]time.Time, len(h.starts[playlistID]))
+	for k, v := range h.starts[playlistID] {
+		out[k] = v
+	}
+	return out
+}
+
+// RecordPlaylistStart notes a successful start so the least-recent
+// strategy can steer away from it next time. Exported for the CLI play
+// path, which drives the Spotify client directly.
+func RecordPlaylistStart(playlistID string, position int) {
+	defaultStartHistory.record(playlistID, position)
+}
+
+// startStrategies maps strategy names to implementations.
+var startStrategies = map[string]StartStrategy{
+	StartFirst:       firstStart{},
+	StartRandom:      randomStart{},
+	StartLeastRecent: leastRecentStart{history: defaultStartHistory},
+	StartNewest:      newestStart{},
+}
+
+// StartStrategyNames returns the accepted strategy names, sorted.
+func StartStrategyNames() []string {
+	names := make([]string, 0, len(startStrategies))
+	for n := range startStrategies {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartStrategyFor returns the strategy named `name`. An empty name keeps
+// the historical defaults: random when shuffling, track 1 otherwise.
+func StartStrategyFor(name string, shuffle bool) (StartStrategy, error) {
+	if name == "" {
+		if shuffle {
+			return startStrategies[StartRandom], nil
+		}
+		return startStrategies[StartFirst], nil
+	}
+
+	s, ok := startStrategies[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown start strategy %q (expected one of: %s)", name, strings.Join(StartStrategyNames(), ", "))
+	}
+	return s, nil
+}
@@ -0,0 +1,274 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Public status page. With STATUS_PAGE=true, /status-page
+// (HTML) and /api/v1/status-page (JSON) show what's playing — track,
+// artists, album, and artwork, nothing else — without a token, for
+// embedding on a personal website. Every visitor is served from one
+// cached player state, and each address is rate limited, so the page
+// can't be used to run up Spotify calls.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Status page defaults: how long a player state is served before Spotify
+// is asked again, and how many requests an address may make per minute.
+const (
+	DefaultStatusPageTTL  = 15 * time.Second
+	DefaultStatusPageRate = 30
+)
+
+// maxRateLimitedClients bounds the rate limiter's memory; it starts over
+// when full.
+const maxRateLimitedClients = 10000
+
+// StatusPageConfig configures the public status page.
+type StatusPageConfig struct {
+	// Account is whose playback is shown; empty is the default account.
+	Account string
+	// TTL is how long a fetched state is served. Zero uses the default.
+	TTL time.Duration
+	// Rate is how many requests one address may make per minute. Zero
+	// uses the default.
+	Rate int
+}
+
+// PublicStatus is what the status page shows. It deliberately leaves out
+// devices, volume, and anything that would help someone control playback.
+type PublicStatus struct {
+	Playing    bool      `json:"playing"`
+	Track      string    `json:"track,omitempty"`
+	Artists    string    `json:"artists,omitempty"`
+	Album      string    `json:"album,omitempty"`
+	ArtworkURL string    `json:"artwork_url,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// StatusPage serves the cached public status.
+type StatusPage struct {
+	config StatusPageConfig
+	// mu also serializes fetches, so a burst of visitors costs one call.
+	mu      sync.Mutex
+	status  *PublicStatus
+	limiter *rateLimiter
+}
+
+// statusPage is the process-wide status page; nil means it's off.
+var statusPage *StatusPage
+
+// EnableStatusPage turns on the public status page.
+func EnableStatusPage(cfg StatusPageConfig) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultStatusPageTTL
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = DefaultStatusPageRate
+	}
+	statusPage = &StatusPage{config: cfg, limiter: newRateLimiter(cfg.Rate, time.Minute)}
+}
+
+// Current returns the public status, fetching it again once the cached
+// one is older than the TTL. If Spotify can't be reached the last status
+// is served stale.
+func (sp *StatusPage) Current(ctx context.Context) (PublicStatus, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	now := time.Now()
+	if sp.status != nil && now.Sub(sp.status.UpdatedAt) < sp.config.TTL {
+		return *sp.status, nil
+	}
+
+	status, err := fetchPublicStatus(WithAccount(ctx, sp.config.Account))
+	if err != nil {
+		if sp.status != nil {
+			return *sp.status, nil
+		}
+		return PublicStatus{}, err
+	}
+	status.UpdatedAt = now
+	sp.status = &status
+	return status, nil
+}
+
+// fetchPublicStatus reads ctx's account's player state down to what the
+// page may show. Episodes and ads have no track, so they show as nothing
+// playing.
+func fetchPublicStatus(ctx context.Context) (PublicStatus, error) {
+	client, err := clientFor(ctx)
+	if err != nil {
+		return PublicStatus{}, err
+	}
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		return PublicStatus{}, fmt.Errorf("failed to get player state: %w", err)
+	}
+
+	var status PublicStatus
+	if state == nil || state.Item == nil {
+		return status, nil
+	}
+	item := state.Item
+	status.Playing = state.Playing
+	status.Track = item.Name
+	status.Artists = trackArtists(item.SimpleTrack)
+	status.Album = item.Album.Name
+	if len(item.Album.Images) > 0 {
+		// Spotify lists the largest image first.
+		status.ArtworkURL = item.Album.Images[0].URL
+	}
+	status.URL = item.ExternalURLs["spotify"]
+	return status, nil
+}
+
+// rateLimiter allows each client `limit` requests per fixed window.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+}
+
+// rateWindow is one client's count in the current window.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// newRateLimiter returns a limiter allowing `limit` requests per `window`.
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, clients: map[string]*rateWindow{}}
+}
+
+// allow counts a request from `client` at `now`. When it's over the
+// limit it returns false and how long until the window resets.
+func (rl *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	w := rl.clients[client]
+	if w == nil || now.Sub(w.start) >= rl.window {
+		if w == nil && len(rl.clients) >= maxRateLimitedClients {
+			rl.clients = map[string]*rateWindow{}
+		}
+		w = &rateWindow{start: now}
+		rl.clients[client] = w
+	}
+	if w.count >= rl.limit {
+		return false, w.start.Add(rl.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// clientAddress is the address a request came from, without its port.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// serveStatusPage checks that the page is on and the client is within its
+// rate, writing the error response if not. It returns the page to serve.
+func serveStatusPage(w http.ResponseWriter, r *http.Request) *StatusPage {
+	sp := statusPage
+	if sp == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	if ok, wait := sp.limiter.allow(clientAddress(r), time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second)/time.Second)+1))
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return nil
+	}
+	return sp
+}
+
+// HandlePublicStatusRequest handles GET /api/v1/status-page: the public
+// now-playing status as JSON. It needs no token and any site may fetch
+// it, but it's only served with STATUS_PAGE=true.
+func HandlePublicStatusRequest(w http.ResponseWriter, r *http.Request) {
+	sp := serveStatusPage(w, r)
+	if sp == nil {
+		return
+	}
+
+	status, err := sp.Current(r.Context())
+	if err != nil {
+		http.Error(w, "Status unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(sp.config.TTL/time.Second)))
+	json.NewEncoder(w).Encode(status)
+}
+
+// HandleStatusPageRequest handles GET /status-page with a small page that
+// shows the public status and refreshes itself.
+func HandleStatusPageRequest(w http.ResponseWriter, r *http.Request) {
+	if serveStatusPage(w, r) == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(statusPageHTML))
+}
+
+// statusPageHTML renders /api/v1/status-page. It polls every 30 seconds;
+// the server's cache absorbs the rest.
+const statusPageHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Now playing</title>
+  <style>
+    body { margin: 0; font-family: system-ui, sans-serif; background: transparent; }
+    .card { display: flex; gap: 12px; align-items: center; padding: 12px; }
+    .card img { width: 64px; height: 64px; border-radius: 4px; object-fit: cover; }
+    .track { font-weight: 600; }
+    .meta { color: #666; font-size: 0.9em; }
+    a { color: inherit; text-decoration: none; }
+  </style>
+</head>
+<body>
+  <a class="card" id="card" target="_blank" rel="noopener">
+    <img id="art" alt="" hidden>
+    <div><div class="track" id="track">Nothing playing</div><div class="meta" id="meta"></div></div>
+  </a>
+  <script>
+    async function refresh() {
+      try {
+        const s = await (await fetch("/api/v1/status-page")).json();
+        const playing = s.playing && s.track;
+        document.getElementById("track").textContent = playing ? s.track : "Nothing playing";
+        document.getElementById("meta").textContent = playing ? s.artists + " — " + s.album : "";
+        const art = document.getElementById("art");
+        art.hidden = !(playing && s.artwork_url);
+        if (!art.hidden) art.src = s.artwork_url;
+        const card = document.getElementById("card");
+        if (playing && s.url) card.href = s.url; else card.removeAttribute("href");
+      } catch (e) {}
+    }
+    refresh();
+    setInterval(refresh, 30000);
+  </script>
+</body>
+</html>
+`
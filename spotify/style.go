@@ -0,0 +1,70 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Plain output for pipes, files, and cron. The table
+// renderers draw rounded box tables with colors and emoji headings, which
+// turn into escape codes and mojibake in a log file. When stdout isn't a
+// terminal, NO_COLOR is set, TERM is dumb, or -no-color is given, they
+// print plain ASCII tables without colors or emoji instead.
+//
+
+package spotify
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// plainOutput is whether the tables are drawn in plain ASCII without
+// colors.
+var plainOutput = detectPlainOutput()
+
+// detectPlainOutput reports whether stdout can't show colors: it isn't a
+// terminal, NO_COLOR is set (https://no-color.org), or TERM is dumb.
+func detectPlainOutput() bool {
+	return os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(os.Stdout)
+}
+
+// DisableColor switches the output to plain ASCII without colors, as for
+// -no-color.
+func DisableColor() {
+	plainOutput = true
+	color.NoColor = true
+}
+
+// newTable returns a table that renders to stdout, rounded or plain.
+func newTable() table.Writer {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	if plainOutput {
+		t.SetStyle(table.StyleDefault)
+	} else {
+		t.SetStyle(table.StyleRounded)
+	}
+	return t
+}
+
+// printHeading prints a table's heading between blank lines, after
+// `emoji` unless the output is plain.
+func printHeading(emoji, heading string) {
+	fmt.Println()
+	if plainOutput {
+		fmt.Println(heading)
+	} else {
+		color.New(color.FgCyan).Println(emoji + " " + heading)
+	}
+	fmt.Println()
+}
+
+// symbol returns `fancy`, or `plain` when the output is plain ASCII.
+func symbol(fancy, plain string) string {
+	if plainOutput {
+		return plain
+	}
+	return fancy
+}
@@ -0,0 +1,180 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Telegram bot mode. With TELEGRAM_BOT_TOKEN set, the server
+// long-polls the Telegram Bot API and answers the chat commands
+// (chatcommand.go) like `/play jazz kitchen`, `/pause`, `/status`, and
+// `/devices`. Only the chats in TELEGRAM_CHAT_IDS may
+// use it; anyone else is told their chat ID and ignored.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramAPIURL is the Bot API's base URL; a variable so tests can point
+// it at a fake.
+var telegramAPIURL = "https://api.telegram.org"
+
+// telegramPollTimeout is how long one getUpdates call waits for a message.
+const telegramPollTimeout = 30 * time.Second
+
+// telegramChat is how Telegram spells commands.
+var telegramChat = chatStyle{Prefix: "/"}
+
+// TelegramBot answers playback commands sent to a Telegram bot.
+type TelegramBot struct {
+	token string
+	// chats are the chat IDs allowed to control playback.
+	chats  map[int64]bool
+	client *http.Client
+	offset int64
+}
+
+// telegramUpdate is the part of a Bot API update the bot reads.
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// NewTelegramBot returns a bot for `token` that answers the chats in
+// `chatIDs`, a comma-separated list. At least one chat is required, since a
+// bot anyone can find would otherwise control the house's speakers.
+func NewTelegramBot(token, chatIDs string) (*TelegramBot, error) {
+	if token == "" {
+		return nil, fmt.Errorf("the bot token is empty")
+	}
+	b := &TelegramBot{token: token, chats: map[int64]bool{}, client: &http.Client{Timeout: telegramPollTimeout + 10*time.Second}}
+	for _, s := range strings.Split(chatIDs, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID %q", s)
+		}
+		b.chats[id] = true
+	}
+	if len(b.chats) == 0 {
+		return nil, fmt.Errorf("no chat IDs are allowed; set TELEGRAM_CHAT_IDS (message the bot to learn yours)")
+	}
+	return b, nil
+}
+
+// Start polls for messages until ctx is done.
+func (b *TelegramBot) Start(ctx context.Context) {
+	go func() {
+		failing := false
+		for ctx.Err() == nil {
+			updates, err := b.getUpdates(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if !failing {
+					log.Printf("Warning: Telegram polling failed, retrying: %v", err)
+				}
+				failing = true
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+				continue
+			}
+			failing = false
+			for _, u := range updates {
+				b.offset = u.UpdateID + 1
+				if u.Message == nil || u.Message.Text == "" {
+					continue
+				}
+				reply := b.handle(ctx, u.Message.Chat.ID, u.Message.Text)
+				if err := b.sendMessage(ctx, u.Message.Chat.ID, reply); err != nil {
+					log.Printf("Warning: Telegram reply failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// handle runs one message from `chatID` and returns the reply. Commands
+// are in chatcommand.go.
+func (b *TelegramBot) handle(ctx context.Context, chatID int64, text string) string {
+	if !b.chats[chatID] {
+		log.Printf("Telegram: ignoring a message from chat %d, which isn't in TELEGRAM_CHAT_IDS", chatID)
+		return fmt.Sprintf("This chat isn't allowed to control playback. Its ID is %d; add it to TELEGRAM_CHAT_IDS.", chatID)
+	}
+
+	cmd, args, _ := strings.Cut(strings.TrimSpace(text), " ")
+	// In groups, commands come addressed as /play@SomeBot.
+	cmd, _, _ = strings.Cut(strings.TrimPrefix(cmd, "/"), "@")
+	return runChatCommand(withPlaySource(ctx, "telegram"), telegramChat, cmd, args)
+}
+
+// getUpdates waits for the messages after the last one handled.
+func (b *TelegramBot) getUpdates(ctx context.Context) ([]telegramUpdate, error) {
+	q := url.Values{
+		"offset":          {strconv.FormatInt(b.offset, 10)},
+		"timeout":         {strconv.Itoa(int(telegramPollTimeout / time.Second))},
+		"allowed_updates": {`["message"]`},
+	}
+	var updates []telegramUpdate
+	err := b.call(ctx, "getUpdates", q, &updates)
+	return updates, err
+}
+
+// sendMessage sends `text` to `chatID`.
+func (b *TelegramBot) sendMessage(ctx context.Context, chatID int64, text string) error {
+	q := url.Values{"chat_id": {strconv.FormatInt(chatID, 10)}, "text": {text}}
+	return b.call(ctx, "sendMessage", q, nil)
+}
+
+// call performs a Bot API method and decodes its result into `out`, if
+// given. The token is left out of errors, since it's part of the URL.
+func (b *TelegramBot) call(ctx context.Context, method string, params url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramAPIURL+"/bot"+b.token+"/"+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return fmt.Errorf("telegram %s: invalid request", method)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("telegram %s: HTTP %d: %w", method, resp.StatusCode, err)
+	}
+	if !body.OK {
+		return fmt.Errorf("telegram %s: %s", method, body.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(body.Result, out)
+	}
+	return nil
+}
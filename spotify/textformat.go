@@ -0,0 +1,142 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Plain-text responses. With format=text in the query string,
+// an /api/v1 endpoint answers with one short line of text/plain instead
+// of JSON, e.g. `Now playing "Jazz Vibes" on Kitchen`, for clients that
+// can't parse JSON (Stream Deck buttons, Tasker, old IoT firmware). The
+// handler's JSON is rendered down here, so endpoints don't need a text
+// form of their own; the status code is kept.
+//
+
+package spotify
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// textListKeys are the response fields listed by name in text form, in
+// the order they're looked for.
+var textListKeys = []string{"devices", "playlists", "results", "groups", "presets", "schedules", "jobs", "tracks", "fallbacks"}
+
+// wantsText reports whether `r` asks for a plain-text response.
+func wantsText(r *http.Request) bool {
+	return strings.EqualFold(r.URL.Query().Get("format"), "text")
+}
+
+// withTextFormat renders the handler's JSON response as one line of text
+// when the request asks for format=text. Responses that aren't JSON, like
+// the plain-text 401, pass through.
+func withTextFormat(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !wantsText(r) {
+			next(w, r)
+			return
+		}
+		buf := &bufferedResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+		next(buf, r)
+
+		for k, v := range buf.header {
+			w.Header()[k] = v
+		}
+		body := buf.body.Bytes()
+		mediaType, _, _ := mime.ParseMediaType(buf.header.Get("Content-Type"))
+		var resp map[string]any
+		if mediaType == "application/json" && json.Unmarshal(body, &resp) == nil {
+			body = []byte(textSummary(resp) + "\n")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(buf.statusCode)
+		w.Write(body)
+	}
+}
+
+// textSummary renders a JSON response as one line: its error, what's
+// playing, the names in its list, its summary, or its message without the
+// details.
+func textSummary(resp map[string]any) string {
+	str := func(m map[string]any, key string) string {
+		s, _ := m[key].(string)
+		return s
+	}
+	oneLine := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+
+	if e := str(resp, "error"); e != "" {
+		return oneLine("Error: " + e)
+	}
+	if np, ok := resp["now_playing"]; ok {
+		track, _ := np.(map[string]any)
+		if str(track, "track_name") == "" {
+			return "Nothing playing"
+		}
+		line := "Paused "
+		if playing, _ := track["is_playing"].(bool); playing {
+			line = "Playing "
+		}
+		line += str(track, "track_name")
+		if a := str(track, "artists"); a != "" {
+			line += " by " + a
+		}
+		if d := str(track, "device_name"); d != "" {
+			line += " on " + d
+		}
+		return oneLine(line)
+	}
+	for _, key := range textListKeys {
+		items, ok := resp[key].([]any)
+		if !ok {
+			continue
+		}
+		if len(items) == 0 {
+			return "None"
+		}
+		names := make([]string, 0, len(items))
+		for _, it := range items {
+			item, _ := it.(map[string]any)
+			name := str(item, "name")
+			if name == "" {
+				name = str(item, "track_name")
+			}
+			if name == "" {
+				name = str(item, "id")
+			}
+			if active, _ := item["active"].(bool); active {
+				name += " (active)"
+			}
+			names = append(names, name)
+		}
+		return oneLine(strings.Join(names, ", "))
+	}
+	if sum := str(resp, "summary"); sum != "" {
+		return oneLine(sum)
+	}
+	if msg := str(resp, "message"); msg != "" {
+		return oneLine(shortMessage(msg))
+	}
+	if ok, _ := resp["success"].(bool); ok {
+		return "OK"
+	}
+	if code := str(resp, "code"); code != "" {
+		return "Error: " + code
+	}
+	return "Error"
+}
+
+// shortMessage drops the details of a handler message: the parenthetical
+// and anything after a semicolon, as in `Now playing "Jazz Vibes" on
+// Kitchen (starting at track 3 of 40); Volume set to 30% on Kitchen`.
+func shortMessage(msg string) string {
+	msg, _, _ = strings.Cut(msg, "; ")
+	if i := strings.Index(msg, " ("); i > 0 {
+		msg = msg[:i]
+	}
+	return msg
+}
@@ -0,0 +1,251 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Token lifecycle helpers. Spotify clients are backed by a
+// token source that refreshes ahead of expiry and persists every refreshed
+// token to disk, and a background checker in server mode exercises it on
+// an interval and tracks readiness — so the first request after a long
+// idle night isn't the one that discovers a dead token.
+//
+
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshWindow is how long before expiry a token is refreshed.
+// The health checker widens this to cover its polling interval.
+var tokenRefreshWindow = 5 * time.Minute
+
+// persistingTokenSource hands out the current token, refreshing it once it
+// is within tokenRefreshWindow of expiry and saving the result to the
+// token file. Without this, the oauth2 transport refreshes in memory only
+// and a restart falls back to whatever stale token is on disk.
+type persistingTokenSource struct {
+	mu      sync.Mutex
+	tok     *oauth2.Token
+	refresh func(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error)
+	// path is the token file to persist to; empty means the default
+	// account's SPOTIFY_TOKEN_FILE.
+	path string
+}
+
+// Token returns a valid token, refreshing and persisting it if needed.
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok.Valid() && time.Until(s.tok.Expiry) > tokenRefreshWindow {
+		return s.tok, nil
+	}
+	if s.tok.RefreshToken == "" {
+		if s.tok.Valid() {
+			return s.tok, nil
+		}
+		return nil, errNoRefreshToken
+	}
+
+	fresh, err := s.refresh(context.Background(), s.tok)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token: %w", err)
+	}
+	s.tok = fresh
+	if s.path != "" {
+		saveTokenFile(s.path, fresh)
+	} else {
+		SaveToken(fresh)
+	}
+	return fresh, nil
+}
+
+// refreshViaAuth forces a refresh through the Spotify authenticator. The
+// authenticator only refreshes tokens it considers expired, so we hand it
+// a copy with the expiry backdated.
+func refreshViaAuth(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error) {
+	stale := *tok
+	stale.Expiry = time.Now().Add(-time.Minute)
+	return auth.RefreshToken(ctx, &stale)
+}
+
+// NewClientFromToken builds a Spotify client whose token refreshes early
+// and is persisted to the token file on every refresh.
+func NewClientFromToken(tok *oauth2.Token) *spotifyLib.Client {
+	return newClientFromTokenFile(tok, "")
+}
+
+// newClientFromTokenFile is NewClientFromToken for a named account's
+// token file.
+func newClientFromTokenFile(tok *oauth2.Token, path string) *spotifyLib.Client {
+	src := &persistingTokenSource{tok: tok, refresh: refreshViaAuth, path: path}
+	httpClient := &http.Client{Transport: &oauth2.Transport{Source: src}}
+	return spotifyLib.New(httpClient)
+}
+
+// TokenStatus is the readiness snapshot maintained by the health checker
+// and served from /healthz.
+type TokenStatus struct {
+	Ready     bool      `json:"ready"`
+	Expiry    time.Time `json:"expiry,omitempty"`
+	LastCheck time.Time `json:"last_check,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	tokenStatusMu sync.Mutex
+	tokenStatus   TokenStatus
+	// accountTokenStatus is the last status of each named account, so
+	// their auth events fire on transitions like the default account's.
+	accountTokenStatus = map[string]TokenStatus{}
+)
+
+// GetTokenStatus returns the latest readiness snapshot.
+func GetTokenStatus() TokenStatus {
+	tokenStatusMu.Lock()
+	defer tokenStatusMu.Unlock()
+	return tokenStatus
+}
+
+// setTokenStatus records a new readiness snapshot, logging transitions so
+// the server log shows when and why readiness flipped.
+func setTokenStatus(s TokenStatus) {
+	tokenStatusMu.Lock()
+	defer tokenStatusMu.Unlock()
+
+	announceTokenStatus(DefaultAccount, tokenStatus, s)
+	tokenStatus = s
+}
+
+// setAccountTokenStatus records named account `name`'s token status,
+// logging and publishing transitions like setTokenStatus. It doesn't
+// change readiness, which only tracks the default account.
+func setAccountTokenStatus(name string, s TokenStatus) {
+	tokenStatusMu.Lock()
+	defer tokenStatusMu.Unlock()
+
+	announceTokenStatus(name, accountTokenStatus[name], s)
+	accountTokenStatus[name] = s
+}
+
+// announceTokenStatus logs and publishes an auth event for `account` when
+// its status `cur` is a transition from `prev`. Callers hold
+// tokenStatusMu.
+func announceTokenStatus(account string, prev, cur TokenStatus) {
+	if cur.Ready == prev.Ready && (cur.Ready || cur.Error == prev.Error) {
+		return
+	}
+	prefix := "token health"
+	if account != DefaultAccount {
+		prefix += ": account " + account
+	}
+	if cur.Ready {
+		log.Printf("%s: ready (expires %s)", prefix, cur.Expiry.Format(time.RFC3339))
+		publishEvent(TopicAuth, EventAuthReady, account, cur)
+	} else {
+		log.Printf("%s: not ready: %s", prefix, cur.Error)
+		publishEvent(TopicAuth, EventAuthNotReady, account, cur)
+	}
+}
+
+// CheckTokenHealth fetches the current token (refreshing it early if it is
+// inside the refresh window) and confirms Spotify still accepts it, then
+// updates the readiness state.
+func CheckTokenHealth(ctx context.Context) TokenStatus {
+	var status TokenStatus
+	if spotifyClient == nil {
+		status = TokenStatus{LastCheck: time.Now(), Error: "Spotify not authenticated. Visit /auth to authenticate"}
+	} else {
+		status = checkClientToken(ctx, spotifyClient)
+	}
+	setTokenStatus(status)
+	return status
+}
+
+// checkClientToken fetches `client`'s token, refreshing it if needed, and
+// confirms Spotify still accepts it.
+func checkClientToken(ctx context.Context, client Client) TokenStatus {
+	status := TokenStatus{LastCheck: time.Now()}
+
+	tok, err := client.Token()
+	if err != nil {
+		status.Error = fmt.Sprintf("token unavailable: %v", err)
+		return status
+	}
+	status.Expiry = tok.Expiry
+
+	if _, err := client.CurrentUser(ctx); err != nil {
+		status.Error = fmt.Sprintf("token rejected by Spotify: %v", err)
+		return status
+	}
+
+	status.Ready = true
+	return status
+}
+
+// maxTokenRefreshWindow caps the refresh window well under Spotify's
+// one-hour token lifetime so a fresh token isn't immediately refreshed
+// again.
+const maxTokenRefreshWindow = 30 * time.Minute
+
+// StartTokenHealthChecker runs CheckTokenHealth immediately and then every
+// `interval` until ctx is cancelled. The refresh window is widened to the
+// interval plus a margin (capped at maxTokenRefreshWindow) so a token
+// can't expire between two checks.
+func StartTokenHealthChecker(ctx context.Context, interval time.Duration) {
+	tokenRefreshWindow = min(interval+5*time.Minute, maxTokenRefreshWindow)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			CheckTokenHealth(ctx)
+			checkAccountTokens(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkAccountTokens checks each named account's token like the default
+// account's, so it is refreshed and persisted on the same schedule and
+// publishes auth events under the account's name. Readiness only tracks
+// the default account.
+func checkAccountTokens(ctx context.Context) {
+	for _, name := range AccountNames()[1:] {
+		actx := WithAccount(ctx, name)
+		client, err := clientFor(actx)
+		if err != nil {
+			setAccountTokenStatus(name, TokenStatus{LastCheck: time.Now(), Error: err.Error()})
+			continue
+		}
+		setAccountTokenStatus(name, checkClientToken(actx, client))
+	}
+}
+
+// HandleHealthRequest handles GET /healthz. It is unauthenticated so
+// launchd/uptime monitors can probe it, and returns 503 until the token
+// health checker has confirmed a working Spotify token.
+func HandleHealthRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status := GetTokenStatus()
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,107 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Single-track playback. A track is named by URI, link, ID,
+// or a search query, and plays on its own as an explicit URI list rather
+// than a context, so playback stops when it ends.
+//
+
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudmanic/spotify-shortcut/spotify/spotifyuri"
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// trackIDFromInput returns the track ID named by a URI, link, shortlink,
+// or bare ID. ok is false when the input isn't any of those and should be
+// searched for.
+func trackIDFromInput(ctx context.Context, input string) (id string, ok bool, err error) {
+	r, err := spotifyuri.Resolve(ctx, shortlinkHTTPClient, input)
+	if errors.Is(err, spotifyuri.ErrUnrecognized) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if r.Type != spotifyuri.Track && r.Type != "" {
+		return "", false, fmt.Errorf("%q is a Spotify %s, not a track", input, r.Type)
+	}
+	return r.ID, true, nil
+}
+
+// trackArtists joins a track's artist names.
+func trackArtists(track spotifyLib.SimpleTrack) string {
+	names := make([]string, len(track.Artists))
+	for i, a := range track.Artists {
+		names[i] = a.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// ResolveTrack finds the track `input` names. Anything that isn't a URI,
+// link, or ID is a search query, like "hey jude beatles"; the top result
+// wins, since Spotify ranks by relevance and popularity.
+func ResolveTrack(ctx context.Context, client Client, input string) (*ResolvedTrack, error) {
+	track := &ResolvedTrack{Input: input}
+
+	id, ok, err := trackIDFromInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	var full *spotifyLib.FullTrack
+	if ok {
+		track.MatchedBy = "uri"
+		if spotifyuri.IsID(strings.TrimSpace(input)) {
+			track.MatchedBy = "id"
+		}
+		full, err = client.GetTrack(ctx, spotifyLib.ID(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get track: %w", err)
+		}
+	} else {
+		result, err := client.Search(ctx, input, spotifyLib.SearchTypeTrack, spotifyLib.Limit(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for track: %w", err)
+		}
+		if result.Tracks == nil || len(result.Tracks.Tracks) == 0 {
+			return nil, fmt.Errorf("no track found for %q", input)
+		}
+		full = &result.Tracks.Tracks[0]
+		track.MatchedBy = "search"
+	}
+
+	track.ID = string(full.ID)
+	track.URI = spotifyuri.Resource{Type: spotifyuri.Track, ID: track.ID}.URI()
+	track.Name, track.Artists = full.Name, trackArtists(full.SimpleTrack)
+	return track, nil
+}
+
+// playTrack plays req's track on `targetDevice`.
+func playTrack(ctx context.Context, client Client, req PlayRequest, targetDevice *spotifyLib.PlayerDevice) (string, *playbackTarget, error) {
+	track, err := ResolveTrack(ctx, client, req.Track)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve track: %w", err)
+	}
+
+	uris := []spotifyLib.URI{spotifyLib.URI(track.URI)}
+	err = client.PlayOpt(ctx, &spotifyLib.PlayOptions{DeviceID: &targetDevice.ID, URIs: uris})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	recordStart(ctx, "", uris)
+	target := &playbackTarget{device: targetDevice, trackURI: uris[0], lastTrackURI: uris[0]}
+
+	title := fmt.Sprintf("\"%s\"", track.Name)
+	if track.Artists != "" {
+		title += " by " + track.Artists
+	}
+	return fmt.Sprintf("Now playing %s on %s", title, targetDevice.Name), target, nil
+}
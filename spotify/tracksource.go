@@ -0,0 +1,234 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Track sources for the modes that build their own queue
+// (newest first, least played, duration targeting). A TrackSource turns a
+// playlist, Liked Songs, an album, an artist's top tracks, Spotify's
+// recommendations, or the local play history into one flat list of
+// playable tracks, doing the paging and dropping local files and
+// unavailable items itself. A mode orders and trims that list; a new mode
+// picks its sources and gets the fetching for free.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// sourcePageSize is the page size used when paging through a source.
+const sourcePageSize = 50
+
+// SourceTrack is one playable track or episode from a TrackSource.
+// Duration is 0 and AddedAt empty where the source doesn't know them.
+type SourceTrack struct {
+	URI      spotifyLib.URI
+	Duration time.Duration
+	// AddedAt is when the track was added to the playlist or saved, RFC
+	// 3339 in UTC, so string order is time order.
+	AddedAt string
+}
+
+// TrackSource yields playable tracks, in the source's own order.
+type TrackSource interface {
+	// Describe names the source for messages, like "playlist 37i9dQ...".
+	Describe() string
+	// Tracks fetches every playable track the source has.
+	Tracks(ctx context.Context, client Client) ([]SourceTrack, error)
+}
+
+// PlaylistSource is a playlist's items, in playlist order.
+type PlaylistSource struct {
+	ID string
+}
+
+// Describe names the playlist.
+func (s PlaylistSource) Describe() string {
+	return "playlist " + s.ID
+}
+
+// Tracks pages through the playlist, skipping local files and items
+// unavailable in the user's market.
+func (s PlaylistSource) Tracks(ctx context.Context, client Client) ([]SourceTrack, error) {
+	items, err := fetchPlaylistItems(ctx, client, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]SourceTrack, 0, len(items))
+	for _, item := range items {
+		if uri := itemURI(item); uri != "" {
+			tracks = append(tracks, SourceTrack{URI: uri, Duration: itemDuration(item), AddedAt: item.AddedAt})
+		}
+	}
+	return tracks, nil
+}
+
+// LikedSource is the account's Liked Songs, most recently saved first.
+type LikedSource struct{}
+
+// Describe names Liked Songs.
+func (LikedSource) Describe() string {
+	return "Liked Songs"
+}
+
+// Tracks pages through the saved tracks.
+func (LikedSource) Tracks(ctx context.Context, client Client) ([]SourceTrack, error) {
+	var tracks []SourceTrack
+	for offset := 0; ; offset += sourcePageSize {
+		page, err := client.CurrentUsersTracks(ctx, spotifyLib.Limit(sourcePageSize), spotifyLib.Offset(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get liked songs: %w", err)
+		}
+		for _, t := range page.Tracks {
+			if t.URI != "" {
+				tracks = append(tracks, SourceTrack{URI: t.URI, Duration: t.TimeDuration(), AddedAt: t.AddedAt})
+			}
+		}
+		if len(page.Tracks) < sourcePageSize {
+			return tracks, nil
+		}
+	}
+}
+
+// AlbumSource is an album's tracks, in album order.
+type AlbumSource struct {
+	ID string
+}
+
+// Describe names the album.
+func (s AlbumSource) Describe() string {
+	return "album " + s.ID
+}
+
+// Tracks pages through the album's tracks.
+func (s AlbumSource) Tracks(ctx context.Context, client Client) ([]SourceTrack, error) {
+	var tracks []SourceTrack
+	for offset := 0; ; offset += sourcePageSize {
+		page, err := client.GetAlbumTracks(ctx, spotifyLib.ID(s.ID), spotifyLib.Limit(sourcePageSize), spotifyLib.Offset(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get album tracks: %w", err)
+		}
+		for _, t := range page.Tracks {
+			if t.URI != "" {
+				tracks = append(tracks, SourceTrack{URI: t.URI, Duration: t.TimeDuration()})
+			}
+		}
+		if len(page.Tracks) < sourcePageSize {
+			return tracks, nil
+		}
+	}
+}
+
+// ArtistTopTracksSource is an artist's top tracks in the account's
+// country, most popular first. Spotify returns at most 10.
+type ArtistTopTracksSource struct {
+	ID string
+}
+
+// Describe names the artist.
+func (s ArtistTopTracksSource) Describe() string {
+	return "top tracks of artist " + s.ID
+}
+
+// Tracks fetches the artist's top tracks for the account's country.
+func (s ArtistTopTracksSource) Tracks(ctx context.Context, client Client) ([]SourceTrack, error) {
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	top, err := client.GetArtistsTopTracks(ctx, spotifyLib.ID(s.ID), user.Country)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artist top tracks: %w", err)
+	}
+	tracks := make([]SourceTrack, 0, len(top))
+	for _, t := range top {
+		tracks = append(tracks, SourceTrack{URI: t.URI, Duration: t.TimeDuration()})
+	}
+	return tracks, nil
+}
+
+// RecommendationsSource is Spotify's recommendations for up to five seed
+// artists, tracks, and genres. Limit is at most 100; 0 means Spotify's
+// default of 20.
+type RecommendationsSource struct {
+	Seeds spotifyLib.Seeds
+	Limit int
+}
+
+// Describe names the source.
+func (s RecommendationsSource) Describe() string {
+	return "recommendations"
+}
+
+// Tracks asks Spotify for recommendations.
+func (s RecommendationsSource) Tracks(ctx context.Context, client Client) ([]SourceTrack, error) {
+	var opts []spotifyLib.RequestOption
+	if s.Limit > 0 {
+		opts = append(opts, spotifyLib.Limit(s.Limit))
+	}
+	recs, err := client.GetRecommendations(ctx, s.Seeds, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recommendations: %w", err)
+	}
+	tracks := make([]SourceTrack, 0, len(recs.Tracks))
+	for _, t := range recs.Tracks {
+		tracks = append(tracks, SourceTrack{URI: t.URI, Duration: t.TimeDuration()})
+	}
+	return tracks, nil
+}
+
+// HistorySource is the tracks in the local play history, most recently
+// played first, up to Limit (0 means all). The history keeps no lengths,
+// so these tracks have no Duration.
+type HistorySource struct {
+	DB    *HistoryDB
+	Limit int
+}
+
+// Describe names the source.
+func (s HistorySource) Describe() string {
+	return "play history"
+}
+
+// Tracks lists the history. A nil DB, with history turned off, has none.
+func (s HistorySource) Tracks(ctx context.Context, client Client) ([]SourceTrack, error) {
+	if s.DB == nil {
+		return nil, nil
+	}
+	entries := s.DB.Recent(s.Limit)
+	tracks := make([]SourceTrack, 0, len(entries))
+	for _, e := range entries {
+		tracks = append(tracks, SourceTrack{URI: spotifyLib.URI(e.URI)})
+	}
+	return tracks, nil
+}
+
+// gatherTracks fetches each source in turn and concatenates their tracks.
+// A track an earlier source already gave is left out; repeats within one
+// source, like a song twice in a playlist, are kept.
+func gatherTracks(ctx context.Context, client Client, sources ...TrackSource) ([]SourceTrack, error) {
+	var tracks []SourceTrack
+	seen := map[spotifyLib.URI]bool{}
+	for _, source := range sources {
+		got, err := source.Tracks(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		n := len(tracks)
+		for _, t := range got {
+			if !seen[t.URI] {
+				tracks = append(tracks, t)
+			}
+		}
+		for _, t := range tracks[n:] {
+			seen[t.URI] = true
+		}
+	}
+	return tracks, nil
+}
@@ -10,6 +10,7 @@ package spotify
 
 import (
 	"context"
+	"time"
 
 	spotifyLib "github.com/zmb3/spotify/v2"
 	"golang.org/x/oauth2"
@@ -24,7 +25,12 @@ type Client interface {
 	GetPlaylist(ctx context.Context, playlistID spotifyLib.ID, opts ...spotifyLib.RequestOption) (*spotifyLib.FullPlaylist, error)
 	PlayOpt(ctx context.Context, opts *spotifyLib.PlayOptions) error
 	Pause(ctx context.Context) error
+	// PauseOpt is the same as Pause but lets the caller target a specific
+	// device via PlayOptions.DeviceID.
+	PauseOpt(ctx context.Context, opt *spotifyLib.PlayOptions) error
 	Shuffle(ctx context.Context, shuffle bool) error
+	// Repeat sets the repeat mode: "track", "context", or "off".
+	Repeat(ctx context.Context, state string) error
 	// Volume sets the playback volume on the user's current active device
 	// to `percent` (0-100). Premium-only.
 	Volume(ctx context.Context, percent int) error
@@ -34,18 +40,151 @@ type Client interface {
 	VolumeOpt(ctx context.Context, percent int, opt *spotifyLib.PlayOptions) error
 	// Next skips to the next track in the current playback queue.
 	Next(ctx context.Context) error
+	// GetPlaylistItems returns one page of a playlist's items, including
+	// each item's added_at timestamp.
+	GetPlaylistItems(ctx context.Context, playlistID spotifyLib.ID, opts ...spotifyLib.RequestOption) (*spotifyLib.PlaylistItemPage, error)
+	// QueueSong appends a track to the playback queue on the user's active
+	// device.
+	QueueSong(ctx context.Context, trackID spotifyLib.ID) error
+	// Seek jumps to `position` milliseconds into the currently playing
+	// track on the user's active device.
+	Seek(ctx context.Context, position int) error
+	// PlayerState returns the full playback state, including the active
+	// device and shuffle state.
+	PlayerState(ctx context.Context, opts ...spotifyLib.RequestOption) (*spotifyLib.PlayerState, error)
+	// PlayerCurrentlyPlaying returns the track currently playing and the
+	// context it is playing from.
+	PlayerCurrentlyPlaying(ctx context.Context, opts ...spotifyLib.RequestOption) (*spotifyLib.CurrentlyPlaying, error)
+	// PlayerRecentlyPlayedOpt returns the user's recently played tracks,
+	// most recent first, each with the context it played from.
+	PlayerRecentlyPlayedOpt(ctx context.Context, opt *spotifyLib.RecentlyPlayedOptions) ([]spotifyLib.RecentlyPlayedItem, error)
+	// TransferPlayback moves the user's session to another device. With
+	// play=false the session arrives paused.
+	TransferPlayback(ctx context.Context, deviceID spotifyLib.ID, play bool) error
+	// FollowPlaylist adds a playlist to the user's library; `public`
+	// controls whether it shows on their profile.
+	FollowPlaylist(ctx context.Context, playlist spotifyLib.ID, public bool) error
+	// UnfollowPlaylist removes a playlist from the user's library.
+	UnfollowPlaylist(ctx context.Context, playlist spotifyLib.ID) error
+	// CreatePlaylistForUser creates an empty playlist owned by `userID`.
+	CreatePlaylistForUser(ctx context.Context, userID, name, description string, public, collaborative bool) (*spotifyLib.FullPlaylist, error)
+	// AddTracksToPlaylist appends up to 100 tracks to a playlist and
+	// returns its new snapshot ID.
+	AddTracksToPlaylist(ctx context.Context, playlistID spotifyLib.ID, trackIDs ...spotifyLib.ID) (string, error)
+	// RemoveTracksFromPlaylistOpt removes up to 100 tracks, each at the
+	// given positions, from the playlist version `snapshotID` names, and
+	// returns the new snapshot ID.
+	RemoveTracksFromPlaylistOpt(ctx context.Context, playlistID spotifyLib.ID, tracks []spotifyLib.TrackToRemove, snapshotID string) (string, error)
+	// ReorderPlaylistTracks moves a run of playlist items to another
+	// position and returns the new snapshot ID.
+	ReorderPlaylistTracks(ctx context.Context, playlistID spotifyLib.ID, opt spotifyLib.PlaylistReorderOptions) (string, error)
+	// CurrentUsersAlbums returns one page of the albums saved in the
+	// user's library, for resolving album names.
+	CurrentUsersAlbums(ctx context.Context, opts ...spotifyLib.RequestOption) (*spotifyLib.SavedAlbumPage, error)
+	// GetAlbum returns an album's details, including its track count.
+	GetAlbum(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (*spotifyLib.FullAlbum, error)
+	// Search looks up the catalog, for resolving artist names and track
+	// queries.
+	Search(ctx context.Context, query string, t spotifyLib.SearchType, opts ...spotifyLib.RequestOption) (*spotifyLib.SearchResult, error)
+	// GetArtist returns an artist's details.
+	GetArtist(ctx context.Context, id spotifyLib.ID) (*spotifyLib.FullArtist, error)
+	// GetTrack returns a track's details.
+	GetTrack(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (*spotifyLib.FullTrack, error)
+	// GetShow returns a podcast show's details.
+	GetShow(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (*spotifyLib.FullShow, error)
+	// GetEpisode returns a podcast episode's details.
+	GetEpisode(ctx context.Context, id string, opts ...spotifyLib.RequestOption) (*spotifyLib.EpisodePage, error)
+	// GetAudioFeatures returns tracks' audio features (tempo, energy,
+	// and so on), with nil for tracks that have none.
+	GetAudioFeatures(ctx context.Context, ids ...spotifyLib.ID) ([]*spotifyLib.AudioFeatures, error)
+	// CurrentUsersTracks returns one page of the user's Liked Songs, most
+	// recently saved first.
+	CurrentUsersTracks(ctx context.Context, opts ...spotifyLib.RequestOption) (*spotifyLib.SavedTrackPage, error)
+	// GetAlbumTracks returns one page of an album's tracks.
+	GetAlbumTracks(ctx context.Context, id spotifyLib.ID, opts ...spotifyLib.RequestOption) (*spotifyLib.SimpleTrackPage, error)
+	// GetArtistsTopTracks returns an artist's top tracks in `country`.
+	GetArtistsTopTracks(ctx context.Context, artistID spotifyLib.ID, country string) ([]spotifyLib.FullTrack, error)
+	// GetRecommendations returns tracks recommended for the seeds.
+	GetRecommendations(ctx context.Context, seeds spotifyLib.Seeds, trackAttributes *spotifyLib.TrackAttributes, opts ...spotifyLib.RequestOption) (*spotifyLib.Recommendations, error)
 	// Token returns the current OAuth token, refreshing it if needed.
 	// We need the access token to push to Spotify Connect devices via the
 	// zeroconf addUser flow.
 	Token() (*oauth2.Token, error)
 }
 
-// APIResponse represents a standard JSON response for the API.
+// PlayRequest describes a playlist, album, artist, track, or audiobook playback request. It is the common
+// shape behind /api/v1/play, the CLI, and presets so new play options only
+// need to be added in one place.
+type PlayRequest struct {
+	// Device is a device name or ID. Empty means "active or first device".
+	Device string
+	// Playlist is a playlist name, ID, or URL.
+	Playlist string
+	// Album is an album URI, link, ID, or the name of a saved album. Set
+	// it instead of Playlist to play an album.
+	Album string
+	// Artist is an artist URI, link, ID, or name. Set it instead of
+	// Playlist to play the artist.
+	Artist string
+	// Track is a track URI, link, ID, or search query. Set it instead of
+	// Playlist to play just that track.
+	Track string
+	// Audiobook is an audiobook or chapter URI, link, or ID, or the name
+	// of a saved audiobook. Set it instead of Playlist to resume the book.
+	Audiobook string
+	// Show is a podcast show or episode URI, link, or ID. A playlist input
+	// naming one is moved here (see ResolvePlayTarget).
+	Show string
+	// Shuffle turns on Spotify's shuffle mode after playback starts.
+	Shuffle bool
+	// Start names the start-position strategy (see StartStrategyFor).
+	// Empty keeps the default: random when shuffling, track 1 otherwise.
+	Start string
+	// NewestFirst plays the playlist's items as an explicit URI list sorted
+	// by added-at date, newest first. Incompatible with Shuffle and Start.
+	NewestFirst bool
+	// LeastPlayed plays the playlist's items as an explicit URI list sorted
+	// by local play history, least played first. Incompatible with
+	// Shuffle, Start, and NewestFirst.
+	LeastPlayed bool
+	// Duration, like "45m", plays about that long: tracks are picked from
+	// the playlist until their lengths add up to it (see duration.go).
+	Duration string
+	// Volume, when set, is applied to the target device (0-100) once
+	// playback has started.
+	Volume *int
+	// FadeIn, like "10m", raises the volume from Volume (or a low default)
+	// to TargetVolume over that long, alarm style (see fadein.go).
+	FadeIn       string
+	TargetVolume *int
+	// StrictMetadata controls what happens when Spotify won't return the
+	// playlist's metadata (404/403): true fails the request, false plays
+	// the playlist context anyway with no start position. Nil uses the
+	// server default (STRICT_METADATA, true unless set to false).
+	StrictMetadata *bool
+	// Confirm makes Play wait until the player reports the playlist
+	// actually playing on the device, failing if it never does.
+	Confirm bool
+	// OnEnd says what happens when the playback runs out: stop, repeat,
+	// fade-out, or preset:<name> (see onend.go). Empty leaves it to
+	// Spotify.
+	OnEnd string
+	// Wait, like "30s", waits up to that long for Device to appear in the
+	// device list, and fails instead of falling back to another device if
+	// it doesn't (see waitdevice.go).
+	Wait string
+}
+
+// APIResponse represents a standard JSON response for the API. On failure
+// Code classifies Error in a fixed vocabulary (see errorcode.go).
 type APIResponse struct {
-	Success bool          `json:"success"`
-	Message string        `json:"message,omitempty"`
-	Error   string        `json:"error,omitempty"`
-	Devices []DeviceInfo  `json:"devices,omitempty"`
+	Success  bool          `json:"success"`
+	Message  string        `json:"message,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Code     ErrorCode     `json:"code,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Device   *PlayedDevice `json:"device,omitempty"`
+	JobID    string        `json:"job_id,omitempty"`
 }
 
 // DeviceInfo is the JSON-friendly subset of a Spotify Connect device returned
@@ -56,6 +195,48 @@ type DeviceInfo struct {
 	Name   string `json:"name"`
 	Type   string `json:"type"`
 	Active bool   `json:"active"`
+	// Volume is the device's volume in percent; 0 for devices that don't
+	// report one.
+	Volume int `json:"volume"`
+	// StableID survives the device's Spotify ID changing; presets can use
+	// it in place of the name or ID.
+	StableID string `json:"stable_id"`
+	// Groups names the device groups the device belongs to.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// newDeviceInfo converts a Spotify device to its DeviceInfo.
+func newDeviceInfo(d spotifyLib.PlayerDevice) DeviceInfo {
+	return DeviceInfo{
+		ID:       string(d.ID),
+		Name:     d.Name,
+		Type:     d.Type,
+		Active:   d.Active,
+		Volume:   int(d.Volume),
+		StableID: StableDeviceID(d.Name, d.Type),
+		Groups:   groupsOf(d),
+	}
+}
+
+// DeviceInfos converts Spotify devices to the DeviceInfo list of
+// /api/v1/devices, for the API and the CLI's -json.
+func DeviceInfos(devices []spotifyLib.PlayerDevice) []DeviceInfo {
+	infos := make([]DeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		infos = append(infos, newDeviceInfo(d))
+	}
+	return infos
+}
+
+// DevicesResponse is the shape returned by /api/v1/devices. Kept separate
+// from APIResponse so the device list can be typed. Devices is always a
+// list, empty on errors, never null.
+type DevicesResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Code    ErrorCode    `json:"code,omitempty"`
+	Devices []DeviceInfo `json:"devices"`
 }
 
 // LANDeviceInfo is one entry in the /api/v1/lan-devices response — a
@@ -75,6 +256,7 @@ type LANDevicesResponse struct {
 	Success bool            `json:"success"`
 	Message string          `json:"message,omitempty"`
 	Error   string          `json:"error,omitempty"`
+	Code    ErrorCode       `json:"code,omitempty"`
 	Devices []LANDeviceInfo `json:"devices"`
 }
 
@@ -88,10 +270,182 @@ type PlaylistInfo struct {
 	Tracks uint   `json:"tracks"`
 }
 
-// PlaylistsResponse is the shape returned by /api/v1/playlists.
+// NewPlaylistInfo converts a Spotify playlist to its PlaylistInfo.
+func NewPlaylistInfo(p spotifyLib.SimplePlaylist) PlaylistInfo {
+	return PlaylistInfo{ID: string(p.ID), Name: p.Name, Owner: p.Owner.DisplayName, Tracks: uint(p.Tracks.Total)}
+}
+
+// LyricsResponse is the shape returned by /api/v1/lyrics/current. Lyrics
+// is nil when nothing is playing; ProgressMs lets a display line up
+// synced lyrics with the song.
+type LyricsResponse struct {
+	Success    bool      `json:"success"`
+	Message    string    `json:"message,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Code       ErrorCode `json:"code,omitempty"`
+	IsPlaying  bool      `json:"is_playing"`
+	ProgressMs int       `json:"progress_ms"`
+	Lyrics     *Lyrics   `json:"lyrics,omitempty"`
+}
+
+// PlaylistsResponse is the shape returned by /api/v1/playlists. Total
+// counts every playlist matching q, before limit and offset.
 type PlaylistsResponse struct {
 	Success   bool           `json:"success"`
 	Message   string         `json:"message,omitempty"`
 	Error     string         `json:"error,omitempty"`
+	Code      ErrorCode      `json:"code,omitempty"`
+	Total     int            `json:"total,omitempty"`
 	Playlists []PlaylistInfo `json:"playlists"`
 }
+
+// PlaylistTracksResponse is the response of /api/v1/playlists/{id}/tracks.
+// Listing returns a page of the playlist's tracks out of Total; removing
+// lists the entries removed, or on a dry run the ones that would be.
+type PlaylistTracksResponse struct {
+	Success  bool            `json:"success"`
+	Message  string          `json:"message,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Code     ErrorCode       `json:"code,omitempty"`
+	DryRun   bool            `json:"dry_run,omitempty"`
+	Playlist string          `json:"playlist,omitempty"`
+	Total    int             `json:"total,omitempty"`
+	Tracks   []PlaylistTrack `json:"tracks,omitempty"`
+}
+
+// SearchHit is one catalog search result. By is the artists, owner, or
+// publisher; Detail is a short type-specific note (track count, release
+// date, album, genres).
+type SearchHit struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	URI    string `json:"uri"`
+	Name   string `json:"name"`
+	By     string `json:"by,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SearchResponse is the shape returned by /api/v1/search.
+type SearchResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Code    ErrorCode   `json:"code,omitempty"`
+	Query   string      `json:"query,omitempty"`
+	Results []SearchHit `json:"results"`
+}
+
+// PresetStatsResponse is the JSON response for /api/v1/stats/presets.
+type PresetStatsResponse struct {
+	Success bool                  `json:"success"`
+	Error   string                `json:"error,omitempty"`
+	Code    ErrorCode             `json:"code,omitempty"`
+	Since   time.Time             `json:"since"`
+	Presets map[string]PresetStat `json:"presets"`
+}
+
+// FallbacksResponse is the JSON response for /api/v1/fallbacks.
+type FallbacksResponse struct {
+	Success   bool            `json:"success"`
+	Error     string          `json:"error,omitempty"`
+	Code      ErrorCode       `json:"code,omitempty"`
+	Fallbacks []FallbackEvent `json:"fallbacks"`
+}
+
+// GroupsResponse is the JSON response for /api/v1/groups.
+type GroupsResponse struct {
+	Success bool        `json:"success"`
+	Error   string      `json:"error,omitempty"`
+	Code    ErrorCode   `json:"code,omitempty"`
+	Groups  []GroupInfo `json:"groups"`
+}
+
+// SpotifyStatsResponse is the JSON response for /api/v1/stats/spotify.
+type SpotifyStatsResponse struct {
+	Success    bool                       `json:"success"`
+	Error      string                     `json:"error,omitempty"`
+	Code       ErrorCode                  `json:"code,omitempty"`
+	Since      time.Time                  `json:"since"`
+	Operations map[string]SpotifyCallStat `json:"operations"`
+	Polling    PollStatus                 `json:"polling"`
+}
+
+// DigestResponse is the JSON response for /api/v1/digest.
+type DigestResponse struct {
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+	Code    ErrorCode `json:"code,omitempty"`
+	Digest  *Digest   `json:"digest,omitempty"`
+}
+
+// SchedulesResponse is the JSON response for /api/v1/schedules. Schedule
+// is the one a POST created.
+type SchedulesResponse struct {
+	Success   bool           `json:"success"`
+	Message   string         `json:"message,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Code      ErrorCode      `json:"code,omitempty"`
+	Schedule  *ScheduleInfo  `json:"schedule,omitempty"`
+	Schedules []ScheduleInfo `json:"schedules,omitempty"`
+}
+
+// JobsResponse is the JSON response for /api/v1/jobs.
+type JobsResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Code    ErrorCode     `json:"code,omitempty"`
+	Jobs    []DelayedPlay `json:"jobs,omitempty"`
+}
+
+// ContextResponse is the JSON response for /api/v1/context. NowPlaying is
+// nil when nothing is playing.
+type ContextResponse struct {
+	Success    bool            `json:"success"`
+	Error      string          `json:"error,omitempty"`
+	Code       ErrorCode       `json:"code,omitempty"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	Time       time.Time       `json:"time"`
+	NowPlaying *PlaybackEvent  `json:"now_playing"`
+	Devices    []DeviceInfo    `json:"devices"`
+	Presets    []PresetSummary `json:"presets"`
+	Schedules  []ScheduleState `json:"schedules"`
+	QuietHours QuietHours      `json:"quiet_hours"`
+}
+
+// HistoryResponse is the JSON response for /api/v1/history.
+type HistoryResponse struct {
+	Success bool           `json:"success"`
+	Error   string         `json:"error,omitempty"`
+	Code    ErrorCode      `json:"code,omitempty"`
+	Tracks  []HistoryEntry `json:"tracks"`
+}
+
+// DeviceRegistryResponse is the JSON response for the device registry
+// endpoints.
+type DeviceRegistryResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	Code    ErrorCode          `json:"code,omitempty"`
+	Devices []RegisteredDevice `json:"devices"`
+}
+
+// ResolveResponse is the JSON response for /api/v1/resolve: what a play
+// request would do, without doing it.
+type ResolveResponse struct {
+	Success   bool               `json:"success"`
+	Error     string             `json:"error,omitempty"`
+	Code      ErrorCode          `json:"code,omitempty"`
+	Preset    string             `json:"preset,omitempty"`
+	Account   string             `json:"account"`
+	Effective EffectiveRequest   `json:"effective"`
+	Playlist  *ResolvedPlaylist  `json:"playlist,omitempty"`
+	Album     *ResolvedAlbum     `json:"album,omitempty"`
+	Artist    *ResolvedArtist    `json:"artist,omitempty"`
+	Track     *ResolvedTrack     `json:"track,omitempty"`
+	Audiobook *ResolvedAudiobook `json:"audiobook,omitempty"`
+	Show      *ResolvedShow      `json:"show,omitempty"`
+	Device    *ResolvedDevice    `json:"device,omitempty"`
+	Warnings  []string           `json:"warnings"`
+}
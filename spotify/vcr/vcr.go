@@ -0,0 +1,386 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: VCR-style HTTP record/replay. A Recorder is an
+// http.RoundTripper that, in record mode, passes requests to the real API
+// and saves each exchange to a JSON cassette with secrets scrubbed, and in
+// replay mode answers from the cassette without touching the network, so
+// integration tests run deterministically without live credentials.
+//
+
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode says whether a Recorder replays or records.
+type Mode int
+
+const (
+	// Replay answers from the cassette and fails unknown requests.
+	Replay Mode = iota
+	// Record sends requests to the real API and rewrites the cassette.
+	Record
+)
+
+// ModeEnv is the environment variable that switches tests to recording.
+const ModeEnv = "VCR_MODE"
+
+// Redacted replaces scrubbed secrets in cassettes.
+const Redacted = "REDACTED"
+
+// ModeFromEnv returns Record when VCR_MODE is "record", else Replay.
+func ModeFromEnv() Mode {
+	if strings.EqualFold(os.Getenv(ModeEnv), "record") {
+		return Record
+	}
+	return Replay
+}
+
+// secretFields are JSON keys, query parameters, and form fields whose
+// values are always scrubbed.
+var secretFields = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"client_id":     true,
+	"code":          true,
+	"token":         true,
+}
+
+// keptHeaders are the response headers saved to cassettes. Request
+// headers are never saved: they carry the Authorization bearer token.
+var keptHeaders = []string{"Content-Type", "Retry-After", "Location"}
+
+// Cassette is the recorded exchanges of one test.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. A JSON body is kept in JSON so cassettes
+// stay readable; any other body goes in Body.
+type Request struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   string          `json:"body,omitempty"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+}
+
+// Response is a recorded response, with its body stored as for Request.
+type Response struct {
+	Status  int             `json:"status"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    string          `json:"body,omitempty"`
+	JSON    json.RawMessage `json:"json,omitempty"`
+}
+
+// Recorder records or replays HTTP exchanges against one cassette file.
+type Recorder struct {
+	path    string
+	mode    Mode
+	real    http.RoundTripper
+	replace []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New returns a Recorder for the cassette at `path`. In Replay mode the
+// cassette must exist. In Record mode requests go through `real` (the
+// default transport when nil) and Save overwrites the cassette.
+func New(path string, mode Mode, real http.RoundTripper) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, real: real}
+	if mode == Record {
+		if r.real == nil {
+			r.real = http.DefaultTransport
+		}
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: read cassette (record it with %s=record): %w", ModeEnv, err)
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("vcr: parse cassette %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// AddSecret scrubs every occurrence of `s` (say, a client ID) from the
+// cassette, beyond the token fields that are always scrubbed.
+func (r *Recorder) AddSecret(s string) {
+	r.Replace(s, Redacted)
+}
+
+// Replace rewrites every occurrence of `live` to `fixture` in the
+// cassette. Recording against a real account, this maps its device and
+// playlist names to the ones the tests replay with.
+func (r *Recorder) Replace(live, fixture string) {
+	if live == "" || live == fixture {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replace = append(r.replace, live, fixture)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: read request body: %w", err)
+	}
+	recorded := r.scrubRequest(req.Method, req.URL, body)
+
+	if r.mode == Replay {
+		return r.replay(req, recorded)
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := r.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{Request: recorded, Response: r.scrubResponse(resp, respBody)}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay answers `req` with the first unused interaction matching
+// `recorded`, so repeated identical requests replay in recorded order.
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || !sameRequest(in.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		body := []byte(in.Response.Body)
+		if in.Response.JSON != nil {
+			body = []byte(compactJSON(in.Response.JSON))
+		}
+		header := in.Response.Headers.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("vcr: no recorded interaction left for %s %s in %s", recorded.Method, recorded.URL, r.path)
+}
+
+// Unused returns the interactions that were never replayed, as
+// "METHOD URL", so tests can notice flows that stopped making a call. It
+// is always empty in Record mode.
+func (r *Recorder) Unused() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mode == Record {
+		return nil
+	}
+
+	var unused []string
+	for i, in := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, in.Request.Method+" "+in.Request.URL)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded cassette in Record mode, via a temp file and
+// rename. It does nothing in Replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != Record {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("vcr: encode cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("vcr: write cassette: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("vcr: write cassette: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("vcr: write cassette: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("vcr: write cassette: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("vcr: write cassette: %w", err)
+	}
+	return nil
+}
+
+// scrubRequest builds the cassette form of a request with secrets removed.
+// Live requests are scrubbed the same way before matching, so a replay
+// matches whatever token it is made with.
+func (r *Recorder) scrubRequest(method string, u *url.URL, body []byte) Request {
+	scrubbed := *u
+	scrubbed.RawQuery = scrubValues(u.Query()).Encode()
+	if scrubbed.RawQuery == "" {
+		scrubbed.ForceQuery = false
+	}
+
+	req := Request{Method: method, URL: r.scrubString(scrubbed.String())}
+	req.Body, req.JSON = r.scrubBody(body)
+	return req
+}
+
+// scrubResponse builds the cassette form of a response.
+func (r *Recorder) scrubResponse(resp *http.Response, body []byte) Response {
+	out := Response{Status: resp.StatusCode}
+	for _, h := range keptHeaders {
+		if v := resp.Header.Values(h); len(v) > 0 {
+			if out.Headers == nil {
+				out.Headers = http.Header{}
+			}
+			out.Headers[h] = v
+		}
+	}
+	out.Body, out.JSON = r.scrubBody(body)
+	return out
+}
+
+// scrubBody returns `body` scrubbed, as compact JSON when it parses as
+// JSON, as a form when it parses as one, or as plain text.
+func (r *Recorder) scrubBody(body []byte) (string, json.RawMessage) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return "", nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err == nil {
+		data, err := json.Marshal(scrubJSON(v))
+		if err == nil {
+			return "", json.RawMessage(r.scrubString(string(data)))
+		}
+	}
+	if form, err := url.ParseQuery(string(body)); err == nil && strings.Contains(string(body), "=") {
+		return r.scrubString(scrubValues(form).Encode()), nil
+	}
+	return r.scrubString(string(body)), nil
+}
+
+// scrubString applies the extra secrets and replacements to `s`.
+func (r *Recorder) scrubString(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.replace) == 0 {
+		return s
+	}
+	return strings.NewReplacer(r.replace...).Replace(s)
+}
+
+// scrubValues redacts the secret fields of a query or form.
+func scrubValues(values url.Values) url.Values {
+	for k := range values {
+		if secretFields[strings.ToLower(k)] {
+			values[k] = []string{Redacted}
+		}
+	}
+	return values
+}
+
+// scrubJSON redacts the secret fields anywhere in a decoded JSON value.
+func scrubJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if secretFields[strings.ToLower(k)] {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = scrubJSON(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = scrubJSON(val)
+		}
+	}
+	return v
+}
+
+// sameRequest reports whether two scrubbed requests match. JSON bodies are
+// compared after re-encoding, so formatting differences don't matter.
+func sameRequest(a, b Request) bool {
+	if a.Method != b.Method || a.URL != b.URL || a.Body != b.Body {
+		return false
+	}
+	return compactJSON(a.JSON) == compactJSON(b.JSON)
+}
+
+// compactJSON returns `raw` without insignificant whitespace.
+func compactJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// readBody reads and closes `body`, which may be nil.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
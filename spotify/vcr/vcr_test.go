@@ -0,0 +1,120 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Tests for HTTP record/replay cassettes.
+//
+
+package vcr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRecordThenReplay records exchanges with secrets scrubbed, then
+// replays them in order without the server.
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		if r.URL.Path == "/token" {
+			io.WriteString(w, `{"access_token":"live-access","refresh_token":"live-refresh","expires_in":3600}`)
+			return
+		}
+		io.WriteString(w, `{"n":`+string(rune('0'+calls))+`,"owner":"client-abc"}`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "flow.json")
+	rec, err := New(path, Record, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	rec.AddSecret("client-abc")
+	client := &http.Client{Transport: rec}
+
+	get := func(c *http.Client, url string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer live-access")
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("get %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	resp, err := client.PostForm(srv.URL+"/token", map[string][]string{"grant_type": {"authorization_code"}, "code": {"live-code"}})
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	first := get(client, srv.URL+"/items?limit=1")
+	second := get(client, srv.URL+"/items?limit=1")
+	if unused := rec.Unused(); unused != nil {
+		t.Errorf("expected nothing unused while recording, got %v", unused)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read cassette: %v", err)
+	}
+	for _, secret := range []string{"live-access", "live-refresh", "live-code", "client-abc", "secret-cookie"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette leaks %q:\n%s", secret, data)
+		}
+	}
+
+	srv.Close()
+	replayer, err := New(path, Replay, nil)
+	if err != nil {
+		t.Fatalf("new replay: %v", err)
+	}
+	client = &http.Client{Transport: replayer}
+	if got := get(client, srv.URL+"/items?limit=1"); got != strings.ReplaceAll(first, "client-abc", Redacted) {
+		t.Errorf("expected first recorded response, got %s", got)
+	}
+	if got := get(client, srv.URL+"/items?limit=1"); got != strings.ReplaceAll(second, "client-abc", Redacted) {
+		t.Errorf("expected second recorded response, got %s", got)
+	}
+	if unused := replayer.Unused(); len(unused) != 1 || !strings.HasPrefix(unused[0], "POST ") {
+		t.Errorf("expected only the token exchange unused, got %v", unused)
+	}
+	if _, err := client.Get(srv.URL + "/items?limit=1"); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("expected an exhausted cassette to fail, got %v", err)
+	}
+}
+
+// TestNew_MissingCassette explains how to record a missing cassette.
+func TestNew_MissingCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"), Replay, nil)
+	if err == nil || !strings.Contains(err.Error(), ModeEnv+"=record") {
+		t.Errorf("expected a hint to record, got %v", err)
+	}
+}
+
+// TestModeFromEnv switches to recording only for VCR_MODE=record.
+func TestModeFromEnv(t *testing.T) {
+	t.Setenv(ModeEnv, "")
+	if ModeFromEnv() != Replay {
+		t.Error("expected replay by default")
+	}
+	t.Setenv(ModeEnv, "Record")
+	if ModeFromEnv() != Record {
+		t.Error("expected record")
+	}
+}
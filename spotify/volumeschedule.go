@@ -0,0 +1,224 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Volume schedules. A schedule in the settings file caps a
+// device's volume along a straight line between two times of day (60 at
+// 20:00 down to 25 at 22:00), so the house quiets down over the evening
+// without anyone touching a control. The playback watcher reports each
+// device's volume; anything above the cap is turned down to it.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// volumeScheduleTick is how often the caps are checked again between
+// events, since they fall while nothing changes. A variable so tests can
+// shorten it.
+var volumeScheduleTick = time.Minute
+
+// minutesPerDay is the length of the clock schedules run on.
+const minutesPerDay = 24 * 60
+
+// VolumeSchedule caps a device's volume over part of the day. The cap
+// runs in a straight line from StartMax at From to EndMax at To, then
+// holds at EndMax until HoldUntil, if set. Times are "15:04" in local
+// time and may wrap past midnight.
+type VolumeSchedule struct {
+	// Device is a device name, Spotify ID, or stable ID, or a room name
+	// for every device in the room.
+	Device    string `json:"device"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	StartMax  int    `json:"start_max"`
+	EndMax    int    `json:"end_max"`
+	HoldUntil string `json:"hold_until,omitempty"`
+}
+
+// compiledSchedule is a schedule with its times in minutes past midnight.
+type compiledSchedule struct {
+	VolumeSchedule
+	from, to, until int
+}
+
+// parseClock parses a "15:04" time of day into minutes past midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use 24-hour HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// compileVolumeSchedules parses and checks every schedule.
+func compileVolumeSchedules(schedules []VolumeSchedule) ([]compiledSchedule, error) {
+	compiled := make([]compiledSchedule, 0, len(schedules))
+	for i, s := range schedules {
+		label := s.Device
+		if label == "" {
+			return nil, fmt.Errorf("volume schedule #%d: no device", i+1)
+		}
+		c := compiledSchedule{VolumeSchedule: s}
+		var err error
+		if c.from, err = parseClock(s.From); err != nil {
+			return nil, fmt.Errorf("volume schedule %s: from: %w", label, err)
+		}
+		if c.to, err = parseClock(s.To); err != nil {
+			return nil, fmt.Errorf("volume schedule %s: to: %w", label, err)
+		}
+		if c.from == c.to {
+			return nil, fmt.Errorf("volume schedule %s: from and to are the same time", label)
+		}
+		c.until = c.to
+		if s.HoldUntil != "" {
+			if c.until, err = parseClock(s.HoldUntil); err != nil {
+				return nil, fmt.Errorf("volume schedule %s: hold_until: %w", label, err)
+			}
+			if c.sinceFrom(c.until) <= c.sinceFrom(c.to) {
+				return nil, fmt.Errorf("volume schedule %s: hold_until must come after to", label)
+			}
+		}
+		for _, v := range []int{s.StartMax, s.EndMax} {
+			if v < 0 || v > 100 {
+				return nil, fmt.Errorf("volume schedule %s: volume must be between 0 and 100, got %d", label, v)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// sinceFrom returns how many minutes past the schedule's start `minute`
+// is, wrapping past midnight.
+func (c compiledSchedule) sinceFrom(minute int) int {
+	return ((minute-c.from)%minutesPerDay + minutesPerDay) % minutesPerDay
+}
+
+// capAt returns the schedule's volume cap at `at`, and false outside the
+// schedule.
+func (c compiledSchedule) capAt(at time.Time) (int, bool) {
+	at = at.Local()
+	since := float64(c.sinceFrom(at.Hour()*60+at.Minute())) + float64(at.Second())/60
+	span, hold := float64(c.sinceFrom(c.to)), float64(c.sinceFrom(c.until))
+	switch {
+	case since <= span:
+		return c.StartMax + int(math.Round(float64(c.EndMax-c.StartMax)*since/span)), true
+	case since < hold:
+		return c.EndMax, true
+	}
+	return 0, false
+}
+
+// matches reports whether the schedule covers the device `ev` played on.
+func (c compiledSchedule) matches(ev PlaybackEvent) bool {
+	refs := []string{c.Device}
+	if room, ok := GetSettings().FindRoom(c.Device); ok {
+		refs = room.Devices
+	}
+	for _, ref := range refs {
+		if strings.EqualFold(ref, ev.DeviceName) || ref == ev.DeviceID {
+			return true
+		}
+		if rec, ok := deviceRegistry.Lookup(ref); ok && strings.EqualFold(rec.Name, ev.DeviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// volumeCap returns the lowest cap any schedule puts on `ev`'s device at
+// `at`, and false when none applies.
+func volumeCap(schedules []compiledSchedule, ev PlaybackEvent, at time.Time) (int, bool) {
+	lowest, found := 0, false
+	for _, s := range schedules {
+		if !s.matches(ev) {
+			continue
+		}
+		if v, ok := s.capAt(at); ok && (!found || v < lowest) {
+			lowest, found = v, true
+		}
+	}
+	return lowest, found
+}
+
+// enforceVolumeCap turns `ev`'s device down to its cap at `at` if it's
+// playing above it, returning the volume it's left at.
+func enforceVolumeCap(ctx context.Context, schedules []compiledSchedule, account string, ev PlaybackEvent, at time.Time) (int, error) {
+	limit, ok := volumeCap(schedules, ev, at)
+	if !ok || !ev.IsPlaying || ev.DeviceID == "" || ev.Volume <= limit {
+		return ev.Volume, nil
+	}
+	actx := WithAccount(ctx, account)
+	client, err := clientFor(actx)
+	if err != nil {
+		return ev.Volume, err
+	}
+	deviceID := spotifyLib.ID(ev.DeviceID)
+	if err := client.VolumeOpt(actx, limit, &spotifyLib.PlayOptions{DeviceID: &deviceID}); err != nil {
+		return ev.Volume, fmt.Errorf("failed to lower volume on %s: %w", ev.DeviceName, err)
+	}
+	log.Printf("volume schedule (%s): %s lowered from %d%% to %d%%", account, ev.DeviceName, ev.Volume, limit)
+	return limit, nil
+}
+
+// StartVolumeSchedules checks the settings file's volume schedules and,
+// if there are any, enforces them until ctx is done: on each playback
+// event, and every volumeScheduleTick against the last one seen. An
+// invalid schedule is an error and starts nothing.
+func StartVolumeSchedules(ctx context.Context) error {
+	schedules, err := compileVolumeSchedules(GetSettings().VolumeSchedules)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		return nil
+	}
+
+	events, unsubscribe := SubscribeEvents(TopicPlayback)
+	go func() {
+		defer unsubscribe()
+		ticker := time.NewTicker(volumeScheduleTick)
+		defer ticker.Stop()
+
+		// last is each account's latest event, with the volume we left.
+		last := map[string]PlaybackEvent{}
+		enforce := func(account string, ev PlaybackEvent, at time.Time) {
+			volume, err := enforceVolumeCap(ctx, schedules, account, ev, at)
+			if err != nil {
+				log.Printf("volume schedule (%s): %v", account, err)
+			}
+			ev.Volume = volume
+			last[account] = ev
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if pe, ok := ev.Data.(PlaybackEvent); ok {
+					enforce(ev.Account, pe, time.Now())
+				}
+			case now := <-ticker.C:
+				for account, ev := range last {
+					enforce(account, ev, now)
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,111 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Waiting for a device to show up. A Spotify Connect speaker
+// that was asleep takes a few seconds to appear in the device list after
+// it wakes. wait=30s polls the list, backing off, until the named device
+// appears, and fails if it never does rather than falling back to
+// whichever speaker happens to be active.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	spotifyLib "github.com/zmb3/spotify/v2"
+)
+
+// maxDeviceWait bounds the wait option, so a typo like 30m doesn't hold a
+// request open for half an hour.
+const maxDeviceWait = 5 * time.Minute
+
+// deviceWaitBackoff is the first pause between device list polls; it
+// doubles after each miss, up to deviceWaitMaxBackoff.
+var (
+	deviceWaitBackoff    = 500 * time.Millisecond
+	deviceWaitMaxBackoff = 5 * time.Second
+)
+
+// validateWait checks the wait option: a positive duration up to
+// maxDeviceWait, and only with a device to wait for.
+func (req PlayRequest) validateWait() error {
+	if req.Wait == "" {
+		return nil
+	}
+	if req.Device == "" {
+		return fmt.Errorf("wait needs a device to wait for")
+	}
+	d, err := time.ParseDuration(req.Wait)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("wait must be a positive duration like 30s, got %q", req.Wait)
+	}
+	if d > maxDeviceWait {
+		return fmt.Errorf("wait can be at most %s", maxDeviceWait)
+	}
+	return nil
+}
+
+// waitForDevice polls the device list until `deviceName` is in it, and
+// returns it. It returns nil once `timeout` passes without it appearing.
+// A failed poll is retried like a miss: Spotify is often slow to answer
+// while a device registers.
+func waitForDevice(ctx context.Context, client Client, deviceName string, timeout time.Duration) (*spotifyLib.PlayerDevice, error) {
+	deadline := time.Now().Add(timeout)
+	backoff := deviceWaitBackoff
+
+	for {
+		devices, err := client.PlayerDevices(ctx)
+		if err == nil {
+			observeDevices(devices)
+			if device := lookupDevice(devices, deviceName); device != nil {
+				return device, nil
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, nil
+		}
+		pause := min(backoff, remaining)
+		log.Printf("device %q not in Spotify cloud list yet, checking again in %s", deviceName, pause)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pause):
+		}
+		backoff = min(backoff*2, deviceWaitMaxBackoff)
+	}
+}
+
+// awaitDevice is pickDevice for a request with wait set: it waits for the
+// device to appear, then tries to claim it over zeroconf, and fails rather
+// than fall back to another device.
+func awaitDevice(ctx context.Context, client Client, deviceName, wait string) (*spotifyLib.PlayerDevice, error) {
+	timeout, err := time.ParseDuration(wait)
+	if err != nil {
+		return nil, err
+	}
+	device, err := waitForDevice(ctx, client, deviceName, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if device != nil {
+		return device, nil
+	}
+
+	device, _, err = findOrClaimDevice(ctx, client, deviceName)
+	if err != nil {
+		return nil, withCode(CodeDevice, fmt.Errorf("device %q didn't appear within %s: %w", deviceName, timeout, err))
+	}
+	if device == nil {
+		return nil, withCode(CodeDevice, fmt.Errorf("device %q didn't appear within %s", deviceName, timeout))
+	}
+	return device, nil
+}
@@ -0,0 +1,60 @@
+//
+// Date: 2026-10-17
+// Author: Spicer Matthews <spicer@cloudmanic.com>
+// Copyright (c) 2026 Cloudmanic Labs, LLC. All rights reserved.
+//
+// Description: Per-request warnings. Problems that don't fail a request —
+// a fallback device, shuffle that wouldn't turn on, a volume that didn't
+// stick — are collected on the request's context and returned in the
+// response's `warnings` array, so automations can tell a partial success
+// from a full one.
+//
+
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Warnings collects the warnings of one request.
+type Warnings struct {
+	mu   sync.Mutex
+	list []string
+}
+
+// warningsKey is the context key for a request's Warnings.
+type warningsKey struct{}
+
+// WithWarnings returns ctx with a new collector for warnf, and the
+// collector.
+func WithWarnings(ctx context.Context) (context.Context, *Warnings) {
+	w := &Warnings{}
+	return context.WithValue(ctx, warningsKey{}, w), w
+}
+
+// List returns the collected warnings in order, or nil when there are none.
+func (w *Warnings) List() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.list) == 0 {
+		return nil
+	}
+	return append([]string(nil), w.list...)
+}
+
+// warnf logs a warning and adds it to ctx's collector, if it has one.
+func warnf(ctx context.Context, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	Warnf("%s", msg)
+
+	if w, ok := ctx.Value(warningsKey{}).(*Warnings); ok {
+		w.mu.Lock()
+		w.list = append(w.list, msg)
+		w.mu.Unlock()
+	}
+}